@@ -0,0 +1,200 @@
+package swkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// accessoryIdsFile is kept in HomeKit directory, it maps accessories to their HomeKit ids.
+const accessoryIdsFile = "accessory_ids.json"
+
+// homeKitBridgeId is HomeKit id of the bridge itself, accessories can not use it.
+const homeKitBridgeId = 1
+
+// accessoryIdentity describes accessory for unique id assignment: explicit Id (optional), Name and Serial
+// (driver and pin), see AssignUniqueIds.
+type accessoryIdentity struct {
+	Kind   string
+	Id     string
+	Name   string
+	Serial string
+}
+
+// hash is default unique id: hash of Id when set, of Name otherwise.
+func (ai accessoryIdentity) hash() uint64 {
+	hash := fnv.New64()
+	if len(ai.Id) > 0 {
+		hash.Write([]byte(ai.Kind + "#" + ai.Id))
+	} else {
+		hash.Write([]byte(ai.Kind + "_" + ai.Name))
+	}
+	return hash.Sum64()
+}
+
+func (ai accessoryIdentity) String() string {
+	if len(ai.Id) > 0 {
+		return fmt.Sprintf("%s %q (Id %s)", ai.Kind, ai.Name, ai.Id)
+	}
+	return fmt.Sprintf("%s %q", ai.Kind, ai.Name)
+}
+
+// identifiable is HkThing which unique id can be assigned.
+type identifiable interface {
+	HkThing
+	identity() accessoryIdentity
+	setUniqueId(id uint64)
+}
+
+// accessoryIdEntry is single record of accessory ids file.
+type accessoryIdEntry struct {
+	accessoryIdentity
+	UniqueId uint64
+}
+
+func (sw *SwKit) getHkDirectory() string {
+	if len(sw.HkDirectory) > 1 {
+		return sw.HkDirectory
+	}
+	return defaultHomeKitDirectory
+}
+
+func (sw *SwKit) getIdentifiables() (things []identifiable) {
+	for _, thing := range sw.getHkThings() {
+		if identifiableThing, ok := thing.(identifiable); ok {
+			things = append(things, identifiableThing)
+		}
+	}
+	return
+}
+
+func loadAccessoryIds(path string) ([]accessoryIdEntry, error) {
+	entries := []accessoryIdEntry{}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read accessory ids")
+	}
+	err = json.Unmarshal(data, &entries)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode accessory ids (%s)", path)
+	}
+	return entries, nil
+}
+
+func saveAccessoryIds(path string, entries []accessoryIdEntry) error {
+	data, err := json.MarshalIndent(entries, "", "\t")
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(writeFileAtomic(path, data), "failed to save accessory ids")
+}
+
+// writeFileAtomic writes data to temporary file renamed to path, missing directories are created.
+func writeFileAtomic(path string, data []byte) error {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// assignUniqueIds matches things to known entries: by explicit Id, then by Name (accessory without Id
+// yet), then by Serial (renamed accessory). Remaining things get their default hash, hash collisions are
+// reported and resolved with a different hash. Returns entries of all things.
+func assignUniqueIds(things []identifiable, known []accessoryIdEntry) []accessoryIdEntry {
+	assigned := make([]uint64, len(things))
+	used := map[uint64]accessoryIdentity{homeKitBridgeId: {Kind: "Bridge"}}
+	claimed := make([]bool, len(known))
+
+	match := func(matches func(thing accessoryIdentity, entry accessoryIdentity) bool) {
+		for ix, thing := range things {
+			if assigned[ix] != 0 {
+				continue
+			}
+			identity := thing.identity()
+			for entryIx, entry := range known {
+				if claimed[entryIx] || entry.Kind != identity.Kind || !matches(identity, entry.accessoryIdentity) {
+					continue
+				}
+				if _, taken := used[entry.UniqueId]; taken || entry.UniqueId == 0 {
+					continue
+				}
+				claimed[entryIx] = true
+				assigned[ix] = entry.UniqueId
+				used[entry.UniqueId] = identity
+				break
+			}
+		}
+	}
+	match(func(thing, entry accessoryIdentity) bool {
+		return len(thing.Id) > 0 && thing.Id == entry.Id
+	})
+	match(func(thing, entry accessoryIdentity) bool {
+		return thing.Name == entry.Name && (len(entry.Id) == 0 || entry.Id == thing.Id)
+	})
+	match(func(thing, entry accessoryIdentity) bool {
+		return len(thing.Serial) > 0 && thing.Serial == entry.Serial && (len(entry.Id) == 0 || len(thing.Id) == 0)
+	})
+
+	entries := []accessoryIdEntry{}
+	for ix, thing := range things {
+		identity := thing.identity()
+		if assigned[ix] == 0 {
+			id := identity.hash()
+			for attempt := 1; ; attempt++ {
+				other, taken := used[id]
+				if !taken {
+					break
+				}
+				log.Printf("unique id %016x of %s collides with %s, alternative id is used", id, identity, other)
+				hash := fnv.New64()
+				hash.Write([]byte(fmt.Sprintf("%s#%s#%d", identity.Kind, identity.Name, attempt)))
+				id = hash.Sum64()
+			}
+			assigned[ix] = id
+			used[id] = identity
+		}
+		thing.setUniqueId(assigned[ix])
+		entries = append(entries, accessoryIdEntry{accessoryIdentity: identity, UniqueId: assigned[ix]})
+	}
+	return entries
+}
+
+// AssignUniqueIds sets unique (HomeKit and mqtt) ids of all accessories and keeps them in HkDirectory,
+// so accessory keeps its id when renamed (matched by driver and pin) or when explicit Id is set later.
+// Accessory with Id set keeps its unique id regardless of name, driver and pin.
+// Has to be called before starting HomeKit and mqtt bridge. Ids are assigned also when they can not be saved.
+func (sw *SwKit) AssignUniqueIds() error {
+	path := filepath.Join(sw.getHkDirectory(), accessoryIdsFile)
+	known, err := loadAccessoryIds(path)
+	if err != nil {
+		return err
+	}
+
+	entries := assignUniqueIds(sw.getIdentifiables(), known)
+	sw.uniqueIdsAssigned = true
+
+	return saveAccessoryIds(path, entries)
+}
@@ -0,0 +1,82 @@
+package swkit
+
+import (
+	"testing"
+)
+
+func TestAssignUniqueIds(t *testing.T) {
+	dir := t.TempDir()
+	sw := &SwKit{
+		HkDirectory: dir,
+		Lights: []*Light{
+			{Name: "Hall", DriverName: "mock_driver", OutPin: 1},
+			{Name: "Kitchen", DriverName: "mock_driver", OutPin: 2},
+			{Name: "Porch", Id: "porch", DriverName: "mock_driver", OutPin: 3},
+		},
+		Switches: []*Switch{{Name: "Hall", DriverName: "mock_driver", InPin: 10}},
+	}
+	err := sw.AssignUniqueIds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hall, kitchen, porch := sw.Lights[0].GetUniqueId(), sw.Lights[1].GetUniqueId(), sw.Lights[2].GetUniqueId()
+	assertBools(t, hall == sw.Lights[0].identity().hash(), true)
+	assertBools(t, hall != sw.Switches[0].GetUniqueId(), true)
+
+	// renamed light is matched by driver and pin, Id added later keeps id of the name,
+	// light with Id keeps its id regardless of name and pin
+	next := &SwKit{
+		HkDirectory: dir,
+		Lights: []*Light{
+			{Name: "Corridor", DriverName: "mock_driver", OutPin: 1},
+			{Name: "Kitchen", Id: "kitchen", DriverName: "mock_driver", OutPin: 2},
+			{Name: "Terrace", Id: "porch", DriverName: "mock_driver", OutPin: 4},
+			{Name: "Garden", DriverName: "mock_driver", OutPin: 5},
+		},
+	}
+	err = next.AssignUniqueIds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertBools(t, next.Lights[0].GetUniqueId() == hall, true)
+	assertBools(t, next.Lights[1].GetUniqueId() == kitchen, true)
+	assertBools(t, next.Lights[2].GetUniqueId() == porch, true)
+	assertBools(t, next.Lights[3].GetUniqueId() == next.Lights[3].identity().hash(), true)
+
+	// mapping was saved again, ids survive another restart
+	again := &SwKit{
+		HkDirectory: dir,
+		Lights:      []*Light{{Name: "Kitchen light", Id: "kitchen", DriverName: "mock_driver", OutPin: 7}},
+	}
+	err = again.AssignUniqueIds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertBools(t, again.Lights[0].GetUniqueId() == kitchen, true)
+}
+
+func TestAssignUniqueIdsCollision(t *testing.T) {
+	first := &Light{Name: "Hall", DriverName: "mock_driver", OutPin: 1}
+	second := &Light{Name: "Porch", DriverName: "mock_driver", OutPin: 2}
+	known := []accessoryIdEntry{{
+		accessoryIdentity: accessoryIdentity{Kind: "Light", Name: "Porch", Serial: "light:other:09"},
+		UniqueId:          first.identity().hash(),
+	}}
+
+	// Porch keeps its known id, Hall default hash is taken and gets alternative one
+	entries := assignUniqueIds([]identifiable{first, second}, known)
+	assertInts(t, len(entries), 2)
+	assertBools(t, second.GetUniqueId() == first.identity().hash(), true)
+	assertBools(t, first.GetUniqueId() != second.GetUniqueId(), true)
+	assertBools(t, first.GetUniqueId() != homeKitBridgeId, true)
+
+	sw := &SwKit{
+		Lights: []*Light{
+			{Name: "Hall", Id: "hall", DriverName: "mock_driver", OutPin: 1},
+			{Name: "Porch", Id: "hall", DriverName: "mock_driver", OutPin: 2},
+		},
+	}
+	problems := &ConfigError{}
+	sw.validateUniqueIds(problems)
+	assertBools(t, hasProblem(problems.Problems, `Light "Porch" (Id hall): duplicate Id "hall"`), true)
+}
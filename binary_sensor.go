@@ -0,0 +1,283 @@
+package swkit
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brutella/hap/accessory"
+	"github.com/brutella/hap/characteristic"
+	"github.com/brutella/hap/service"
+	drivers "github.com/hubertat/swkit/drivers"
+	"github.com/pkg/errors"
+)
+
+const (
+	BinarySensorContact        = "contact"
+	BinarySensorLeak           = "leak"
+	BinarySensorSmoke          = "smoke"
+	BinarySensorCarbonMonoxide = "carbon_monoxide"
+	BinarySensorOccupancy      = "occupancy"
+)
+
+// binarySensorDeviceClasses maps sensor Kind to Home Assistant binary_sensor device class.
+var binarySensorDeviceClasses = map[string]string{
+	BinarySensorContact:        "opening",
+	BinarySensorLeak:           "moisture",
+	BinarySensorSmoke:          "smoke",
+	BinarySensorCarbonMonoxide: "carbon_monoxide",
+	BinarySensorOccupancy:      "occupancy",
+}
+
+// BinarySensor maps DigitalInput to HomeKit contact, leak, smoke, carbon monoxide or occupancy sensor.
+// State true means sensor is active: contact open, leak, smoke, carbon monoxide or occupancy detected,
+// input on is active unless Invert. Changes are accepted after being stable for DebounceDuration.
+// Optional LowBatteryPin input (same driver) reports low battery when on (off with InvertLowBattery).
+type BinarySensor struct {
+	Name             string
+	Id               string
+	Kind             string
+	State            bool
+	IsFaulty         bool
+	DriverName       string
+	InPin            uint16
+	Invert           bool
+	DebounceDuration string
+	DisableHomekit   bool
+
+	LowBatteryInput  bool
+	LowBatteryPin    uint16
+	InvertLowBattery bool
+
+	input        drivers.DigitalInput
+	batteryInput drivers.DigitalInput
+	driver       drivers.IoDriver
+	debounce     time.Duration
+	lock         sync.Mutex
+	pending      bool
+	pendingSince time.Time
+	lowBattery   bool
+
+	hkAccessory *accessory.A
+	hkState     *characteristic.Int
+	hkFault     *characteristic.StatusFault
+	hkBattery   *characteristic.StatusLowBattery
+
+	uniqueId uint64
+}
+
+func (bs *BinarySensor) GetDriverName() string {
+	return bs.DriverName
+}
+
+func (bs *BinarySensor) GetUniqueId() uint64 {
+	if bs.uniqueId != 0 {
+		return bs.uniqueId
+	}
+	return bs.identity().hash()
+}
+
+func (bs *BinarySensor) identity() accessoryIdentity {
+	return accessoryIdentity{Kind: "BinarySensor", Id: bs.Id, Name: bs.Name, Serial: bs.serialNumber()}
+}
+
+func (bs *BinarySensor) serialNumber() string {
+	return fmt.Sprintf("%s_sensor:%s:%02d", bs.getKind(), bs.DriverName, bs.InPin)
+}
+
+func (bs *BinarySensor) setUniqueId(id uint64) {
+	bs.uniqueId = id
+}
+
+// getKind returns lower case Kind, default contact.
+func (bs *BinarySensor) getKind() string {
+	if len(bs.Kind) == 0 {
+		return BinarySensorContact
+	}
+	return strings.ToLower(bs.Kind)
+}
+
+// getInPins returns sensor and low battery pins.
+func (bs *BinarySensor) getInPins() []uint16 {
+	if bs.LowBatteryInput {
+		return []uint16{bs.InPin, bs.LowBatteryPin}
+	}
+	return []uint16{bs.InPin}
+}
+
+// newHkService returns HomeKit service for sensor kind and its state characteristic.
+func (bs *BinarySensor) newHkService() (*service.S, *characteristic.Int, error) {
+	switch bs.getKind() {
+	case BinarySensorContact:
+		s := service.NewContactSensor()
+		return s.S, s.ContactSensorState.Int, nil
+	case BinarySensorLeak:
+		s := service.NewLeakSensor()
+		return s.S, s.LeakDetected.Int, nil
+	case BinarySensorSmoke:
+		s := service.NewSmokeSensor()
+		return s.S, s.SmokeDetected.Int, nil
+	case BinarySensorCarbonMonoxide:
+		s := service.NewCarbonMonoxideSensor()
+		return s.S, s.CarbonMonoxideDetected.Int, nil
+	case BinarySensorOccupancy:
+		s := service.NewOccupancySensor()
+		return s.S, s.OccupancyDetected.Int, nil
+	}
+	return nil, nil, errors.Errorf("unknown Kind %s", bs.Kind)
+}
+
+func (bs *BinarySensor) Init(driver drivers.IoDriver) error {
+	if !strings.EqualFold(driver.NameId(), bs.DriverName) {
+		return fmt.Errorf("Init failed, mismatched or incorrect driver")
+	}
+
+	if !driver.IsReady() {
+		return fmt.Errorf("Init failed, driver not ready")
+	}
+
+	var err error
+	bs.debounce = 0
+	if len(bs.DebounceDuration) > 0 {
+		bs.debounce, err = time.ParseDuration(bs.DebounceDuration)
+		if err != nil {
+			return errors.Wrap(err, "Init failed, failed to parse DebounceDuration")
+		}
+	}
+
+	bs.driver = driver
+	bs.input, err = driver.GetInput(bs.InPin)
+	if err != nil {
+		return errors.Wrap(err, "Init failed on getting input")
+	}
+	bs.batteryInput = nil
+	if bs.LowBatteryInput {
+		bs.batteryInput, err = driver.GetInput(bs.LowBatteryPin)
+		if err != nil {
+			return errors.Wrap(err, "Init failed on getting low battery input")
+		}
+	}
+
+	// unreadable sensor is reported as faulty (as by Sync), not failing whole swkit
+	initState, err := bs.input.GetState()
+	lowBattery := false
+	if err == nil && bs.batteryInput != nil {
+		lowBattery, err = bs.batteryInput.GetState()
+	}
+	if err != nil {
+		log.Printf("%s sensor %s | failed to read initial state: %v", bs.getKind(), bs.Name, err)
+	}
+	bs.lock.Lock()
+	bs.IsFaulty = err != nil
+	bs.State = initState != bs.Invert
+	bs.pending = bs.State
+	bs.lowBattery = lowBattery != bs.InvertLowBattery
+	bs.lock.Unlock()
+
+	if bs.DisableHomekit {
+		return nil
+	}
+
+	hkService, hkState, err := bs.newHkService()
+	if err != nil {
+		return errors.Wrap(err, "Init failed")
+	}
+	bs.hkAccessory = accessory.New(accessory.Info{
+		Name:         bs.Name,
+		SerialNumber: bs.serialNumber(),
+	}, accessory.TypeSensor)
+	bs.hkState = hkState
+	bs.hkFault = characteristic.NewStatusFault()
+	bs.hkFault.SetValue(characteristic.StatusFaultNoFault)
+	hkService.AddC(bs.hkFault.C)
+	if bs.LowBatteryInput {
+		bs.hkBattery = characteristic.NewStatusLowBattery()
+		hkService.AddC(bs.hkBattery.C)
+	}
+	bs.hkAccessory.AddS(hkService)
+	bs.updateHk()
+
+	return nil
+}
+
+func (bs *BinarySensor) Sync() error {
+	state, err := bs.input.GetState()
+	var lowBattery bool
+	if err == nil && bs.batteryInput != nil {
+		lowBattery, err = bs.batteryInput.GetState()
+	}
+
+	bs.lock.Lock()
+	defer bs.lock.Unlock()
+
+	bs.IsFaulty = err != nil
+	if err == nil {
+		state = state != bs.Invert
+		if state != bs.pending {
+			bs.pending = state
+			bs.pendingSince = time.Now()
+		}
+		if bs.pending != bs.State && time.Since(bs.pendingSince) >= bs.debounce {
+			bs.State = bs.pending
+		}
+		bs.lowBattery = lowBattery != bs.InvertLowBattery
+	}
+	bs.updateHk()
+
+	return errors.Wrapf(err, "Sync failed on reading %s sensor %s", bs.getKind(), bs.Name)
+}
+
+// updateHk has to be called with lock held.
+func (bs *BinarySensor) updateHk() {
+	if bs.hkAccessory == nil {
+		return
+	}
+
+	// contact sensor state 1 means contact not detected (open), others detected
+	value := 0
+	if bs.State {
+		value = 1
+	}
+	if bs.hkState.Value() != value {
+		bs.hkState.SetValue(value)
+	}
+
+	fault := characteristic.StatusFaultNoFault
+	if bs.IsFaulty {
+		fault = characteristic.StatusFaultGeneralFault
+	}
+	if bs.hkFault.Value() != fault {
+		bs.hkFault.SetValue(fault)
+	}
+
+	if bs.hkBattery != nil {
+		battery := characteristic.StatusLowBatteryBatteryLevelNormal
+		if bs.lowBattery {
+			battery = characteristic.StatusLowBatteryBatteryLevelLow
+		}
+		if bs.hkBattery.Value() != battery {
+			bs.hkBattery.SetValue(battery)
+		}
+	}
+}
+
+func (bs *BinarySensor) GetHk() *accessory.A {
+	return bs.hkAccessory
+}
+
+// GetState returns debounced sensor state.
+func (bs *BinarySensor) GetState() (bool, error) {
+	bs.lock.Lock()
+	defer bs.lock.Unlock()
+
+	return bs.State, nil
+}
+
+func (bs *BinarySensor) GetLowBattery() bool {
+	bs.lock.Lock()
+	defer bs.lock.Unlock()
+
+	return bs.lowBattery
+}
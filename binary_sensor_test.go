@@ -0,0 +1,123 @@
+package swkit
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/brutella/hap/characteristic"
+	drivers "github.com/hubertat/swkit/drivers"
+)
+
+func binarySensorTest(t *testing.T, sensors ...*BinarySensor) *drivers.MockIoDriver {
+	t.Helper()
+
+	sw := newMockKit(t, func(sw *SwKit) {
+		sw.BinarySensors = sensors
+	})
+	md, _ := sw.GetMockIoDriver("mock_driver")
+	return md
+}
+
+type failingInput struct{}
+
+func (failingInput) GetState() (bool, error) {
+	return false, fmt.Errorf("read failed")
+}
+
+func (failingInput) SubscribeToPushEvent(drivers.EventListener) error {
+	return nil
+}
+
+// failingInputDriver is mock driver which inputs can't be read.
+type failingInputDriver struct {
+	*drivers.MockIoDriver
+}
+
+func (failingInputDriver) GetInput(uint16) (drivers.DigitalInput, error) {
+	return failingInput{}, nil
+}
+
+func TestBinarySensorKinds(t *testing.T) {
+	window := &BinarySensor{Name: "Window", DriverName: "mock_driver", InPin: 1, Invert: true}
+	leak := &BinarySensor{Name: "Bathroom", Kind: "Leak", DriverName: "mock_driver", InPin: 2}
+	smoke := &BinarySensor{Name: "Hall", Kind: "smoke", DriverName: "mock_driver", InPin: 3}
+	md := binarySensorTest(t, window, leak, smoke)
+
+	// inverted: input on (reed closed by magnet) means closed window
+	md.SetInput(1, true)
+	window.Sync()
+	assertBools(t, window.State, false)
+	assertInts(t, window.hkState.Value(), characteristic.ContactSensorStateContactDetected)
+
+	md.SetInput(1, false)
+	window.Sync()
+	assertBools(t, window.State, true)
+	assertInts(t, window.hkState.Value(), characteristic.ContactSensorStateContactNotDetected)
+
+	md.SetInput(2, true)
+	leak.Sync()
+	smoke.Sync()
+	assertInts(t, leak.hkState.Value(), characteristic.LeakDetectedLeakDetected)
+	assertInts(t, smoke.hkState.Value(), characteristic.SmokeDetectedSmokeNotDetected)
+
+	bad := &BinarySensor{Name: "Bad", Kind: "door", DriverName: "mock_driver", InPin: 1}
+	assertBools(t, bad.Init(md) != nil, true)
+}
+
+func TestBinarySensorDebounce(t *testing.T) {
+	sensor := &BinarySensor{Name: "Bathroom", Kind: "leak", DriverName: "mock_driver", InPin: 2, DebounceDuration: "50ms"}
+	md := binarySensorTest(t, sensor)
+
+	md.SetInput(2, true)
+	sensor.Sync()
+	assertBools(t, sensor.State, false)
+	md.SetInput(2, false)
+	sensor.Sync()
+	md.SetInput(2, true)
+	sensor.Sync()
+	time.Sleep(30 * time.Millisecond)
+	sensor.Sync()
+	assertBools(t, sensor.State, false)
+
+	time.Sleep(30 * time.Millisecond)
+	sensor.Sync()
+	state, _ := sensor.GetState()
+	assertBools(t, state, true)
+}
+
+func TestBinarySensorBatteryAndFault(t *testing.T) {
+	sensor := &BinarySensor{Name: "Garden", Kind: "occupancy", DriverName: "mock_driver", InPin: 4,
+		LowBatteryInput: true, LowBatteryPin: 5}
+	md := binarySensorTest(t, sensor)
+	assertInts(t, sensor.hkBattery.Value(), characteristic.StatusLowBatteryBatteryLevelNormal)
+
+	md.SetInput(5, true)
+	sensor.Sync()
+	assertBools(t, sensor.GetLowBattery(), true)
+	assertInts(t, sensor.hkBattery.Value(), characteristic.StatusLowBatteryBatteryLevelLow)
+
+	sensor.input = failingInput{}
+	assertBools(t, sensor.Sync() != nil, true)
+	assertBools(t, sensor.IsFaulty, true)
+	assertInts(t, sensor.hkFault.Value(), characteristic.StatusFaultGeneralFault)
+}
+
+func TestBinarySensorInitFault(t *testing.T) {
+	md := &drivers.MockIoDriver{}
+	err := md.Setup(context.Background(), []uint16{4}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer md.Close()
+
+	sensor := &BinarySensor{Name: "Garden", Kind: "leak", DriverName: "mock_driver", InPin: 4}
+	err = sensor.Init(failingInputDriver{md})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertBools(t, sensor.IsFaulty, true)
+	assertBools(t, sensor.State, false)
+	assertInts(t, sensor.hkFault.Value(), characteristic.StatusFaultGeneralFault)
+}
@@ -3,8 +3,6 @@ package swkit
 import (
 	"errors"
 	"fmt"
-	"hash/fnv"
-	"log"
 	"strings"
 
 	"github.com/brutella/hap/accessory"
@@ -14,6 +12,7 @@ import (
 
 type Button struct {
 	Name       string
+	Id         string
 	State      bool
 	DriverName string
 	InPin      uint16
@@ -21,26 +20,49 @@ type Button struct {
 	DisableHomekit bool
 
 	toggleMap map[drivers.PushEvent][]ClickableDevice
+	rampSlice []RampableDevice
+	listeners []drivers.EventListener
 
 	input  drivers.DigitalInput
 	driver drivers.IoDriver
 
 	hk *accessory.A
 	ss *service.StatelessProgrammableSwitch
+
+	uniqueId uint64
 }
 
 type ClickableDevice interface {
 	Toggle()
 }
 
+// RampableDevice is device which level is ramped while button is held after long press (dimmable light).
+type RampableDevice interface {
+	IsRampable() bool
+	Ramp(held func() bool)
+}
+
 func (bu *Button) GetDriverName() string {
 	return bu.DriverName
 }
 
 func (bu *Button) GetUniqueId() uint64 {
-	hash := fnv.New64()
-	hash.Write([]byte("Button_" + bu.Name))
-	return hash.Sum64()
+	if bu.uniqueId != 0 {
+		return bu.uniqueId
+	}
+	return bu.identity().hash()
+}
+
+func (bu *Button) identity() accessoryIdentity {
+	return accessoryIdentity{Kind: "Button", Id: bu.Id, Name: bu.Name, Serial: bu.serialNumber()}
+}
+
+func (bu *Button) serialNumber() string {
+	return fmt.Sprintf("button:%s:%02d", bu.DriverName, bu.InPin)
+}
+
+func (bu *Button) setUniqueId(id uint64) {
+	bu.uniqueId = id
 }
 
 func (bu *Button) Init(driver drivers.IoDriver) error {
@@ -55,6 +77,7 @@ func (bu *Button) Init(driver drivers.IoDriver) error {
 	var err error
 
 	bu.toggleMap = make(map[drivers.PushEvent][]ClickableDevice)
+	bu.rampSlice = nil
 
 	bu.driver = driver
 	bu.input, err = driver.GetInput(bu.InPin)
@@ -69,7 +92,8 @@ func (bu *Button) Init(driver drivers.IoDriver) error {
 
 	if !bu.DisableHomekit {
 		bu.hk = accessory.New(accessory.Info{
-			Name: bu.Name,
+			Name:         bu.Name,
+			SerialNumber: bu.serialNumber(),
 		}, accessory.TypeProgrammableSwitch)
 
 		bu.ss = service.NewStatelessProgrammableSwitch()
@@ -98,9 +122,18 @@ func (bu *Button) GetValue() bool {
 	return state
 }
 
-func (bu *Button) FireEvent(event drivers.PushEvent) {
-	log.Println("[DEBUG] Button: Push event: ", event, " ", bu.Name)
+// isHeld is true while button input is pressed, inputs without readable state are never held.
+func (bu *Button) isHeld() bool {
+	state, err := bu.input.GetState()
+	return err == nil && state
+}
 
+// SubscribeToPushEvent adds listener receiving push events of this button (e.g. mqtt bridge).
+func (bu *Button) SubscribeToPushEvent(listener drivers.EventListener) {
+	bu.listeners = append(bu.listeners, listener)
+}
+
+func (bu *Button) FireEvent(event drivers.PushEvent) {
 	if !bu.DisableHomekit {
 		bu.ss.ProgrammableSwitchEvent.SetValue(int(event))
 	}
@@ -111,4 +144,14 @@ func (bu *Button) FireEvent(event drivers.PushEvent) {
 		}
 	}
 
+	if event == drivers.PushEventLongPress {
+		for _, device := range bu.rampSlice {
+			go device.Ramp(bu.isHeld)
+		}
+	}
+
+	for _, listener := range bu.listeners {
+		listener.FireEvent(event)
+	}
+
 }
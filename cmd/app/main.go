@@ -2,9 +2,7 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"flag"
-	"io"
 	"log"
 	"os"
 	"time"
@@ -37,6 +35,10 @@ var (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(validate(os.Args[2:]))
+	}
+
 	log.Printf("swkit %s started\n", Version)
 	flag.Parse()
 
@@ -62,20 +64,9 @@ func main() {
 		panic(err)
 	}
 
-	sk := &swkit.SwKit{}
-	configFile, err := os.Open(*config)
-	if err == nil {
-		cBuff, err := io.ReadAll(configFile)
-		if err != nil {
-			log.Fatalf("failed reading config file: %v\n", err)
-		}
-
-		err = json.Unmarshal(cBuff, sk)
-		if err != nil {
-			log.Fatalf("failed unmarshalling json config: %v", err)
-		}
-	} else {
-		log.Fatalf("can't find/open config file (%s), will terminate. Reason: \n%v\n", *config, err)
+	sk, err := swkit.LoadConfig(*config)
+	if err != nil {
+		log.Fatalf("can't load config file (%s), will terminate. Reason: \n%v\n", *config, err)
 	}
 	log.Println("will init swkit drivers...")
 	err = sk.InitDrivers(ctx)
@@ -93,6 +84,10 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+	err = sk.AssignUniqueIds()
+	if err != nil {
+		log.Printf("accessory ids: %v\n we will proceed...", err)
+	}
 	log.Printf("drivers OK!\nwill try to MatchControllers:\n")
 	err = sk.MatchControllers()
 	if err != nil {
@@ -109,8 +104,20 @@ func main() {
 		log.Printf("\tOK\n")
 	}
 
+	if sk.MqttBridge != nil {
+		log.Println("starting mqtt bridge:")
+		err = sk.MqttBridge.Start(ctx, sk)
+		if err != nil {
+			log.Printf("mqtt bridge failed to start: %v\n we will proceed...", err)
+		} else {
+			log.Printf("\tOK\n")
+		}
+	}
+
 	sk.PrintIoStatus(os.Stdout)
 
+	go sk.ReloadOnSignal(ctx, *config)
+
 	if len(sk.HkPin) == 8 {
 		log.Println("Starting with HomeKit server")
 
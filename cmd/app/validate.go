@@ -0,0 +1,25 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hubertat/swkit"
+)
+
+// validate checks config file without touching hardware (swkit validate -config config.json),
+// returns exit code.
+func validate(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	configPath := flags.String("config", "config.json", "path of the configuration file to validate")
+	flags.Parse(args)
+
+	_, err := swkit.ValidateConfigFile(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *configPath, err)
+		return 1
+	}
+	fmt.Printf("%s: OK\n", *configPath)
+	return 0
+}
@@ -2,80 +2,122 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/hubertat/swkit"
-	"github.com/hubertat/swkit/drivers"
 )
 
 var (
 	Version string
 	Build   string
+
+	config         = flag.String("config", "config.json", "path of the configuration file to simulate")
+	syncInterval   = flag.String("sync", "250ms", "sync interval (time.Duration)")
+	withHomeKit    = flag.Bool("homekit", false, "start HomeKit server (uses separate -hk-dir)")
+	hkDirectory    = flag.String("hk-dir", "./mock_homekit", "HomeKit directory used by simulator")
+	withMqtt       = flag.Bool("mqtt", false, "start configured mqtt bridge")
+	scenarioFile   = flag.String("scenario", "", "run scenario file on start (needs -scenario-driver)")
+	scenarioDriver = flag.String("scenario-driver", "", "driver name the scenario is run against")
 )
 
 func main() {
-	var err error
-
-	log.Println("swkit started")
-	log.Println("mock instance for testing puproses, should work on MacOs")
+	flag.Parse()
 
-	syncDuration := 250 * time.Millisecond
-	log.Println("syncDuration is ", syncDuration)
-	sensorsSyncDuration := 2 * time.Minute
-	log.Println("sensorSyncDuration is ", sensorsSyncDuration)
+	log.Printf("swkit mock %s started\n", Version)
+	log.Println("simulator for testing configuration, all drivers are replaced with mocks")
 
-	sk := &swkit.SwKit{}
+	syncDuration, err := time.ParseDuration(*syncInterval)
+	if err != nil {
+		log.Fatalf("invalid sync interval: %v", err)
+	}
 
-	sk.HkPin = "88008800"
+	sk, err := swkit.LoadConfig(*config)
+	if err != nil {
+		log.Fatalf("can't load config file (%s): %v", *config, err)
+	}
+	if !*withMqtt {
+		sk.MqttBridge = nil
+	}
 
-	sk.Lights = append(sk.Lights, &swkit.Light{Name: "fake light", DriverName: "mock_driver", OutPin: 1})
-	sk.Outlets = append(sk.Outlets, &swkit.Outlet{Name: "fake outlet", DriverName: "mock_driver", OutPin: 2})
-	sk.FakeDriver = &drivers.MockIoDriver{}
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
 
+	sk.UseMockDrivers()
 	log.Println("will init swkit drivers...")
-	err = sk.InitDrivers()
+	err = sk.InitDrivers(ctx)
 	defer sk.Close()
 	if err != nil {
-		panic(err)
+		log.Fatalf("failed to init drivers: %v", err)
 	}
 	log.Println("will init swkit IOs...")
 	err = sk.InitIos()
 	if err != nil {
-		panic(err)
+		log.Fatalf("failed to init ios: %v", err)
 	}
 	log.Println("will init swkit sensors...")
 	err = sk.InitSensors()
 	if err != nil {
-		panic(err)
+		log.Fatalf("failed to init sensors: %v", err)
 	}
-
-	log.Printf("drivers OK!\nwill try to MatchControllers:\n")
 	err = sk.MatchControllers()
 	if err != nil {
 		log.Printf("Matching Controllers returned error: %v\n we will proceed...", err)
-	} else {
-		log.Println("MatchControllers OK!")
 	}
-
-	log.Println("trying to match thermostats:")
 	err = sk.MatchSensors()
 	if err != nil {
-		log.Println(err)
-	} else {
-		log.Printf("\tOK\n")
+		log.Printf("Matching sensors returned error: %v\n we will proceed...", err)
+	}
+	if *withHomeKit || sk.MqttBridge != nil {
+		sk.HkDirectory = *hkDirectory
+		err = sk.AssignUniqueIds()
+		if err != nil {
+			log.Printf("accessory ids: %v\n we will proceed...", err)
+		}
 	}
 
-	sk.FakeDriver.MonitorStateChanges(os.Stdout)
-
-	sk.PrintIoStatus(os.Stdout)
-
-	log.Println("starting mock with HomeKit service")
+	if sk.MqttBridge != nil {
+		err = sk.MqttBridge.Start(ctx, sk)
+		if err != nil {
+			log.Printf("mqtt bridge failed to start: %v\n we will proceed...", err)
+		}
+	}
 
-	go sk.StartTicker(syncDuration, sensorsSyncDuration)
+	go sk.StartTicker(syncDuration)
+	go sk.StartSensorTicker(syncDuration)
+
+	if *withHomeKit {
+		if len(sk.HkPin) != 8 {
+			log.Fatalln("HomeKit requested, but HkPin is not configured")
+		}
+		go func() {
+			err := sk.StartHomeKit(ctx, "mock: "+Version)
+			log.Println("HomeKit server stopped:", err)
+			cancel()
+		}()
+	}
 
-	sk.HkDirectory = "./mock_homekit"
-	log.Fatal(sk.StartHomeKit(context.Background(), "mock: "+Version))
+	sim := &simulator{sk: sk, configPath: *config, out: os.Stdout}
+	go sim.watchOutputs(ctx, syncDuration)
+
+	if len(*scenarioFile) > 0 {
+		go func() {
+			err := sim.runScenario(ctx, *scenarioDriver, *scenarioFile)
+			if err != nil {
+				log.Println("scenario failed:", err)
+			} else {
+				log.Println("scenario finished")
+			}
+		}()
+	}
 
+	go func() {
+		sim.console(os.Stdin)
+		cancel()
+	}()
+	<-ctx.Done()
 }
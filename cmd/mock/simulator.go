@@ -0,0 +1,374 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/hubertat/swkit"
+	"github.com/hubertat/swkit/drivers"
+)
+
+const consoleHelp = `commands:
+  status                  show state of all outputs, inputs and sensors
+  press <name>            single press of button
+  double <name>           double press of button
+  long <name>             long press of button
+  flip <name>             toggle switch or motion sensor input
+  on <name> / off <name>  set switch or motion sensor input
+  sensor <value> <name>   set value of sensor (temp is alias)
+  fail <name>             make output (light, outlet, thermostat) fail
+  recover <name>          restore failed output
+  scenario <driver> <file>  run scenario file against mock driver
+  reload                  reload config file (kept accessories keep their state)
+  help                    show this help
+  quit                    stop simulator
+`
+
+// pinRef is a pin of a mock driver used by accessory.
+type pinRef struct {
+	kind   string
+	name   string
+	driver string
+	pin    uint16
+}
+
+type simulator struct {
+	sk         *swkit.SwKit
+	configPath string
+	out        io.Writer
+}
+
+func (sim *simulator) outputs() (refs []pinRef) {
+	for _, li := range sim.sk.Lights {
+		refs = append(refs, pinRef{"light", li.Name, li.DriverName, li.OutPin})
+	}
+	for _, cl := range sim.sk.ColorLights {
+		for _, pin := range cl.ChannelPins {
+			refs = append(refs, pinRef{"color light", cl.Name, cl.DriverName, pin})
+		}
+		if len(cl.ChannelPins) == 0 {
+			refs = append(refs, pinRef{"color light", cl.Name, cl.DriverName, cl.OutPin})
+		}
+	}
+	for _, ou := range sim.sk.Outlets {
+		refs = append(refs, pinRef{"outlet", ou.Name, ou.DriverName, ou.OutPin})
+	}
+	for _, shu := range sim.sk.Shutters {
+		if strings.EqualFold(shu.Wiring, "on_direction") {
+			refs = append(refs, pinRef{"shutter on", shu.Name, shu.DriverName, shu.OnPin})
+			refs = append(refs, pinRef{"shutter direction", shu.Name, shu.DriverName, shu.DirectionPin})
+		} else {
+			refs = append(refs, pinRef{"shutter up", shu.Name, shu.DriverName, shu.UpPin})
+			refs = append(refs, pinRef{"shutter down", shu.Name, shu.DriverName, shu.DownPin})
+		}
+	}
+	for _, gd := range sim.sk.GarageDoors {
+		refs = append(refs, pinRef{"garage door", gd.Name, gd.DriverName, gd.OutPin})
+	}
+	for _, th := range sim.sk.Thermostats {
+		refs = append(refs, pinRef{"thermostat heating", th.Name, th.DriverName, th.HeatPin})
+		if th.CoolingEnabled {
+			refs = append(refs, pinRef{"thermostat cooling", th.Name, th.DriverName, th.CoolPin})
+		}
+	}
+	return
+}
+
+func (sim *simulator) inputs() (refs []pinRef) {
+	for _, bu := range sim.sk.Buttons {
+		refs = append(refs, pinRef{"button", bu.Name, bu.DriverName, bu.InPin})
+	}
+	for _, swb := range sim.sk.Switches {
+		refs = append(refs, pinRef{"switch", swb.Name, swb.DriverName, swb.InPin})
+	}
+	for _, ms := range sim.sk.MotionSensors {
+		refs = append(refs, pinRef{"motion", ms.Name, ms.DriverName, ms.InPin})
+	}
+	for _, bs := range sim.sk.BinarySensors {
+		refs = append(refs, pinRef{"binary sensor", bs.Name, bs.DriverName, bs.InPin})
+		if bs.LowBatteryInput {
+			refs = append(refs, pinRef{"binary sensor low battery", bs.Name, bs.DriverName, bs.LowBatteryPin})
+		}
+	}
+	for _, gd := range sim.sk.GarageDoors {
+		if gd.OpenSensor {
+			refs = append(refs, pinRef{"garage door open sensor", gd.Name, gd.GetSensorDriverName(), gd.OpenPin})
+		}
+		if gd.ClosedSensor {
+			refs = append(refs, pinRef{"garage door closed sensor", gd.Name, gd.GetSensorDriverName(), gd.ClosedPin})
+		}
+	}
+	return
+}
+
+func findPinRef(refs []pinRef, name string) (ref pinRef, err error) {
+	for _, ref = range refs {
+		if strings.EqualFold(ref.name, name) {
+			return
+		}
+	}
+	err = errors.Errorf("%s not found", name)
+	return
+}
+
+// outputState describes output as on/off, dimmed output with its level (e.g. "on 40%").
+func (sim *simulator) outputState(ref pinRef) (string, error) {
+	md, err := sim.sk.GetMockIoDriver(ref.driver)
+	if err != nil {
+		return "", err
+	}
+	output, err := md.GetOutput(ref.pin)
+	if err != nil {
+		return "", err
+	}
+	state, err := output.GetState()
+	if err != nil {
+		return "", err
+	}
+	if analog, ok := output.(drivers.AnalogOutput); ok {
+		level, _ := analog.GetLevel()
+		if level > 0 && level < 100 {
+			return fmt.Sprintf("on %d%%", level), nil
+		}
+	}
+	return onOff(state), nil
+}
+
+func (sim *simulator) inputState(ref pinRef) (bool, error) {
+	md, err := sim.sk.GetMockIoDriver(ref.driver)
+	if err != nil {
+		return false, err
+	}
+	input, err := md.GetInput(ref.pin)
+	if err != nil {
+		return false, err
+	}
+	return input.GetState()
+}
+
+func onOff(state bool) string {
+	if state {
+		return "on"
+	}
+	return "off"
+}
+
+// watchOutputs prints every change of outputs state, so effects of simulated inputs are visible.
+func (sim *simulator) watchOutputs(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := map[pinRef]string{}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, ref := range sim.outputs() {
+				current, err := sim.outputState(ref)
+				if err != nil {
+					current = "fault: " + err.Error()
+				}
+				previous, seen := last[ref]
+				if seen && previous != current {
+					fmt.Fprintf(sim.out, "> %s %s: %s\n", ref.kind, ref.name, current)
+				}
+				last[ref] = current
+			}
+		}
+	}
+}
+
+func (sim *simulator) printStatus() {
+	fmt.Fprintln(sim.out, "outputs:")
+	for _, ref := range sim.outputs() {
+		state, err := sim.outputState(ref)
+		if err != nil {
+			fmt.Fprintf(sim.out, "  %s %s (%s:%d): fault %v\n", ref.kind, ref.name, ref.driver, ref.pin, err)
+			continue
+		}
+		fmt.Fprintf(sim.out, "  %s %s (%s:%d): %s\n", ref.kind, ref.name, ref.driver, ref.pin, state)
+	}
+	fmt.Fprintln(sim.out, "inputs:")
+	for _, ref := range sim.inputs() {
+		state, err := sim.inputState(ref)
+		if err != nil {
+			fmt.Fprintf(sim.out, "  %s %s (%s:%d): %v\n", ref.kind, ref.name, ref.driver, ref.pin, err)
+			continue
+		}
+		fmt.Fprintf(sim.out, "  %s %s (%s:%d): %s\n", ref.kind, ref.name, ref.driver, ref.pin, onOff(state))
+	}
+	fmt.Fprintln(sim.out, "sensors:")
+	for _, ts := range sim.sensors() {
+		value, err := ts.GetValue()
+		if err != nil {
+			fmt.Fprintf(sim.out, "  %s %s (%s): %v\n", ts.GetMeasurement(), ts.Name, ts.Id, err)
+			continue
+		}
+		fmt.Fprintf(sim.out, "  %s %s (%s): %.1f\n", ts.GetMeasurement(), ts.Name, ts.Id, value)
+	}
+	for _, th := range sim.sk.Thermostats {
+		fmt.Fprintf(sim.out, "  thermostat %s: current %.1f, target %.1f\n", th.Name, th.CurrentTemperature, th.TargetTemperature)
+	}
+}
+
+func (sim *simulator) pushInput(name string, event drivers.PushEvent) error {
+	ref, err := findPinRef(sim.inputs(), name)
+	if err != nil {
+		return err
+	}
+	md, err := sim.sk.GetMockIoDriver(ref.driver)
+	if err != nil {
+		return err
+	}
+	return md.Push(ref.pin, event)
+}
+
+func (sim *simulator) setInput(name string, toggle bool, state bool) error {
+	ref, err := findPinRef(sim.inputs(), name)
+	if err != nil {
+		return err
+	}
+	md, err := sim.sk.GetMockIoDriver(ref.driver)
+	if err != nil {
+		return err
+	}
+	if toggle {
+		return md.ToggleInput(ref.pin)
+	}
+	return md.SetInput(ref.pin, state)
+}
+
+func (sim *simulator) failOutput(name string, reason error) error {
+	found := false
+	for _, ref := range sim.outputs() {
+		if !strings.EqualFold(ref.name, name) {
+			continue
+		}
+		md, err := sim.sk.GetMockIoDriver(ref.driver)
+		if err != nil {
+			return err
+		}
+		err = md.FailOutput(ref.pin, reason)
+		if err != nil {
+			return err
+		}
+		found = true
+	}
+	if !found {
+		return errors.Errorf("%s not found", name)
+	}
+	return nil
+}
+
+func (sim *simulator) sensors() []*swkit.MeasurementSensor {
+	return append(append([]*swkit.MeasurementSensor{}, sim.sk.TemperatureSensors...), sim.sk.Sensors...)
+}
+
+func (sim *simulator) setSensorValue(value float64, name string) error {
+	for _, ts := range sim.sensors() {
+		if strings.EqualFold(ts.Name, name) || strings.EqualFold(ts.Id, name) {
+			msd, err := sim.sk.GetMockSensorDriver(ts.DriverName)
+			if err != nil {
+				return err
+			}
+			return msd.SetValue(ts.Id, value)
+		}
+	}
+	return errors.Errorf("sensor %s not found", name)
+}
+
+func (sim *simulator) runScenario(ctx context.Context, driverName string, path string) error {
+	md, err := sim.sk.GetMockIoDriver(driverName)
+	if err != nil {
+		return err
+	}
+	scenario, err := drivers.LoadMockScenario(path)
+	if err != nil {
+		return err
+	}
+	return md.RunScenario(ctx, scenario)
+}
+
+// execute runs single console command, returns false when simulator should stop.
+func (sim *simulator) execute(line string) (bool, error) {
+	command, args, _ := strings.Cut(strings.TrimSpace(line), " ")
+	args = strings.TrimSpace(args)
+
+	switch strings.ToLower(command) {
+	case "":
+	case "help":
+		fmt.Fprint(sim.out, consoleHelp)
+	case "quit", "exit":
+		return false, nil
+	case "status":
+		sim.printStatus()
+	case "press":
+		return true, sim.pushInput(args, drivers.PushEventSinglePress)
+	case "double":
+		return true, sim.pushInput(args, drivers.PushEventDoublePress)
+	case "long":
+		return true, sim.pushInput(args, drivers.PushEventLongPress)
+	case "flip":
+		return true, sim.setInput(args, true, false)
+	case "on":
+		return true, sim.setInput(args, false, true)
+	case "off":
+		return true, sim.setInput(args, false, false)
+	case "sensor", "temp":
+		valueText, name, _ := strings.Cut(args, " ")
+		value, err := strconv.ParseFloat(valueText, 64)
+		if err != nil {
+			return true, errors.Wrap(err, "invalid sensor value")
+		}
+		return true, sim.setSensorValue(value, strings.TrimSpace(name))
+	case "fail":
+		return true, sim.failOutput(args, errors.New("simulated failure"))
+	case "recover":
+		return true, sim.failOutput(args, nil)
+	case "reload":
+		summary, err := sim.sk.ReloadConfig(context.Background(), sim.configPath)
+		if summary != nil {
+			fmt.Fprintln(sim.out, summary)
+		}
+		return true, err
+	case "scenario":
+		driverName, path, _ := strings.Cut(args, " ")
+		go func() {
+			err := sim.runScenario(context.Background(), driverName, strings.TrimSpace(path))
+			if err != nil {
+				fmt.Fprintln(sim.out, "scenario failed:", err)
+				return
+			}
+			fmt.Fprintln(sim.out, "scenario finished")
+		}()
+	default:
+		return true, errors.Errorf("unknown command (%s), type help", command)
+	}
+	return true, nil
+}
+
+func (sim *simulator) console(in io.Reader) {
+	fmt.Fprint(sim.out, consoleHelp)
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(sim.out, "swkit> ")
+		if !scanner.Scan() {
+			return
+		}
+		proceed, err := sim.execute(scanner.Text())
+		if err != nil {
+			fmt.Fprintln(sim.out, "error:", err)
+		}
+		if !proceed {
+			return
+		}
+	}
+}
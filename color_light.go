@@ -0,0 +1,458 @@
+package swkit
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brutella/hap/accessory"
+	"github.com/brutella/hap/characteristic"
+	drivers "github.com/hubertat/swkit/drivers"
+	"github.com/pkg/errors"
+)
+
+const defaultColorTemperature = 300
+
+// ColorLight is RGB/RGBW (tunable white) light. It drives either three or four dimmable outputs
+// (ChannelPins: red, green, blue and optional white) or single colour controller output (OutPin, driver
+// ColorOutput, e.g. shelly RGBW or mqtt). Colour is set by Hue and Saturation or, in WhiteMode, by
+// ColorTemperature (mired). White channel takes the common part of red, green and blue.
+// Changes are faded over FadeDuration.
+type ColorLight struct {
+	Name           string
+	Id             string
+	State          bool
+	DriverName     string
+	OutPin         uint16   // colour controller output, used when ChannelPins are empty
+	ChannelPins    []uint16 // dimmable outputs: red, green, blue and optional white
+	WhiteChannel   bool     // colour controller (OutPin) has white channel
+	DisableHomekit bool
+	IsFaulty       bool
+
+	Hue              float64 // 0-360
+	Saturation       float64 // 0-100
+	Brightness       int     // 0-100, kept when light is switched off
+	ColorTemperature int     // mired, 140 (cold) - 500 (warm)
+	WhiteMode        bool    // ColorTemperature is used instead of Hue and Saturation
+	FadeDuration     string  // e.g. "500ms", default no fade
+
+	ControlBy []ControllingDevice
+
+	color    drivers.ColorOutput
+	channels []drivers.AnalogOutput
+	driver   drivers.IoDriver
+
+	hk          *accessory.ColoredLightbulb
+	temperature *characteristic.ColorTemperature
+	fault       *characteristic.StatusFault
+	lock        sync.Mutex
+
+	fadeTime time.Duration
+	current  [4]float64
+	levelId  int
+	fading   bool
+
+	uniqueId uint64
+}
+
+func (cl *ColorLight) GetDriverName() string {
+	return cl.DriverName
+}
+
+func (cl *ColorLight) GetUniqueId() uint64 {
+	if cl.uniqueId != 0 {
+		return cl.uniqueId
+	}
+	return cl.identity().hash()
+}
+
+func (cl *ColorLight) identity() accessoryIdentity {
+	return accessoryIdentity{Kind: "ColorLight", Id: cl.Id, Name: cl.Name, Serial: cl.serialNumber()}
+}
+
+func (cl *ColorLight) serialNumber() string {
+	return fmt.Sprintf("color_light:%s:%02d", cl.DriverName, cl.getPins()[0])
+}
+
+func (cl *ColorLight) setUniqueId(id uint64) {
+	cl.uniqueId = id
+}
+
+// getPins returns all output pins used by light.
+func (cl *ColorLight) getPins() []uint16 {
+	if len(cl.ChannelPins) > 0 {
+		return cl.ChannelPins
+	}
+	return []uint16{cl.OutPin}
+}
+
+func (cl *ColorLight) hasWhite() bool {
+	if len(cl.ChannelPins) > 0 {
+		return len(cl.ChannelPins) == 4
+	}
+	return cl.WhiteChannel
+}
+
+func (cl *ColorLight) Init(driver drivers.IoDriver) error {
+	if !strings.EqualFold(driver.NameId(), cl.DriverName) {
+		return fmt.Errorf("Init failed, mismatched or incorrect driver")
+	}
+
+	if !driver.IsReady() {
+		return fmt.Errorf("Init failed, driver not ready")
+	}
+
+	cl.driver = driver
+	cl.color = nil
+	cl.channels = nil
+
+	if len(cl.ChannelPins) > 0 {
+		if len(cl.ChannelPins) < 3 || len(cl.ChannelPins) > 4 {
+			return errors.Errorf("Init failed, ChannelPins needs 3 (rgb) or 4 (rgbw) pins, got %d", len(cl.ChannelPins))
+		}
+		for _, pin := range cl.ChannelPins {
+			output, err := driver.GetOutput(pin)
+			if err != nil {
+				return errors.Wrap(err, "Init failed")
+			}
+			channel, ok := output.(drivers.AnalogOutput)
+			if !ok {
+				return errors.Errorf("Init failed, output %d of driver %s is not dimmable", pin, cl.DriverName)
+			}
+			cl.channels = append(cl.channels, channel)
+		}
+	} else {
+		output, err := driver.GetOutput(cl.OutPin)
+		if err != nil {
+			return errors.Wrap(err, "Init failed")
+		}
+		var ok bool
+		cl.color, ok = output.(drivers.ColorOutput)
+		if !ok {
+			return errors.Errorf("Init failed, output %d of driver %s is not colour output", cl.OutPin, cl.DriverName)
+		}
+	}
+
+	cl.fadeTime = 0
+	if len(cl.FadeDuration) > 0 {
+		var err error
+		cl.fadeTime, err = time.ParseDuration(cl.FadeDuration)
+		if err != nil {
+			return errors.Wrap(err, "Init failed, failed to parse FadeDuration")
+		}
+	}
+	if cl.Brightness <= 0 || cl.Brightness > 100 {
+		cl.Brightness = 100
+	}
+	if cl.ColorTemperature < 140 || cl.ColorTemperature > 500 {
+		cl.ColorTemperature = defaultColorTemperature
+	}
+
+	if cl.DisableHomekit {
+		return nil
+	}
+
+	cl.hk = accessory.NewColoredLightbulb(accessory.Info{
+		Name:         cl.Name,
+		SerialNumber: cl.serialNumber(),
+	})
+	cl.hk.Lightbulb.Brightness.SetValue(cl.Brightness)
+	cl.hk.Lightbulb.Hue.SetValue(cl.Hue)
+	cl.hk.Lightbulb.Saturation.SetValue(cl.Saturation)
+
+	cl.temperature = characteristic.NewColorTemperature()
+	cl.temperature.SetValue(cl.ColorTemperature)
+	cl.hk.Lightbulb.AddC(cl.temperature.C)
+
+	cl.fault = characteristic.NewStatusFault()
+	cl.fault.SetValue(characteristic.StatusFaultNoFault)
+	cl.hk.Lightbulb.AddC(cl.fault.C)
+
+	cl.hk.Lightbulb.On.OnValueRemoteUpdate(cl.SetValue)
+	cl.hk.Lightbulb.Brightness.OnValueRemoteUpdate(cl.SetBrightness)
+	cl.hk.Lightbulb.Hue.OnValueRemoteUpdate(cl.SetHue)
+	cl.hk.Lightbulb.Saturation.OnValueRemoteUpdate(cl.SetSaturation)
+	cl.temperature.OnValueRemoteUpdate(cl.SetColorTemperature)
+
+	return nil
+}
+
+// GetState is true when any channel is on.
+func (cl *ColorLight) GetState() (bool, error) {
+	if cl.color != nil {
+		color, err := cl.color.GetRgbw()
+		return !color.IsOff(), err
+	}
+
+	state := false
+	for _, channel := range cl.channels {
+		level, err := channel.GetLevel()
+		if err != nil {
+			return false, err
+		}
+		state = state || level > 0
+	}
+	return state, nil
+}
+
+func (cl *ColorLight) Sync() (err error) {
+	cl.lock.Lock()
+	defer cl.lock.Unlock()
+
+	if !cl.fading {
+		var state bool
+		state, err = cl.GetState()
+		if err == nil {
+			cl.State = state
+		}
+	}
+	if cl.hk != nil {
+		if err != nil {
+			cl.fault.SetValue(characteristic.StatusFaultGeneralFault)
+			cl.IsFaulty = true
+		} else {
+			cl.fault.SetValue(characteristic.StatusFaultNoFault)
+			cl.IsFaulty = false
+		}
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "Sync failed on GetState()")
+	}
+
+	if cl.hk != nil && cl.State != cl.hk.Lightbulb.On.Value() {
+		cl.hk.Lightbulb.On.SetValue(cl.State)
+	}
+
+	return nil
+}
+
+func (cl *ColorLight) GetControllers() []ControllingDevice {
+	return cl.ControlBy
+}
+
+func (cl *ColorLight) GetHk() *accessory.A {
+	if cl.hk == nil {
+		return nil
+	}
+	return cl.hk.A
+}
+
+func (cl *ColorLight) SetValue(state bool) {
+	cl.update(func() {
+		cl.State = state
+	})
+}
+
+func (cl *ColorLight) Toggle() {
+	cl.lock.Lock()
+	state := cl.State
+	cl.lock.Unlock()
+
+	cl.SetValue(!state)
+}
+
+// SetBrightness switches light on with brightness (0-100), brightness 0 switches it off.
+func (cl *ColorLight) SetBrightness(brightness int) {
+	cl.update(func() {
+		cl.State = brightness > 0
+		if brightness > 0 {
+			cl.Brightness = min(brightness, 100)
+		}
+	})
+}
+
+func (cl *ColorLight) SetHue(hue float64) {
+	cl.update(func() {
+		cl.Hue = math.Mod(math.Max(hue, 0), 360)
+		cl.WhiteMode = false
+	})
+}
+
+func (cl *ColorLight) SetSaturation(saturation float64) {
+	cl.update(func() {
+		cl.Saturation = math.Min(math.Max(saturation, 0), 100)
+		cl.WhiteMode = false
+	})
+}
+
+// SetColorTemperature switches light to white mode with colour temperature in mired (140-500).
+func (cl *ColorLight) SetColorTemperature(mired int) {
+	cl.update(func() {
+		cl.ColorTemperature = min(max(mired, 140), 500)
+		cl.WhiteMode = true
+	})
+}
+
+// update changes settings under lock and fades outputs to the resulting colour.
+func (cl *ColorLight) update(change func()) {
+	cl.lock.Lock()
+	change()
+	target := cl.getChannels()
+	cl.lock.Unlock()
+
+	cl.fadeTo(target)
+}
+
+// getChannels returns red, green, blue and white channel (0-1) for current settings, has to be called with lock held.
+func (cl *ColorLight) getChannels() (channels [4]float64) {
+	if !cl.State {
+		return
+	}
+
+	var red, green, blue float64
+	if cl.WhiteMode {
+		red, green, blue = miredToRgb(cl.ColorTemperature)
+	} else {
+		red, green, blue = hsvToRgb(cl.Hue, cl.Saturation/100, 1)
+	}
+	white := 0.0
+	if cl.hasWhite() {
+		red, green, blue, white = mixWhite(red, green, blue)
+	}
+
+	brightness := float64(cl.Brightness) / 100
+	return [4]float64{red * brightness, green * brightness, blue * brightness, white * brightness}
+}
+
+func (cl *ColorLight) fadeTo(target [4]float64) {
+	cl.lock.Lock()
+	cl.levelId++
+	id := cl.levelId
+	from := cl.current
+	steps := int(cl.fadeTime / lightFadeStep)
+	cl.fading = steps > 1 && from != target
+	fading := cl.fading
+	if !fading {
+		cl.current = target
+	}
+	cl.lock.Unlock()
+
+	if !fading {
+		err := cl.write(target)
+		if err != nil {
+			log.Printf("color light %s | failed to set colour: %v", cl.Name, err)
+		}
+		return
+	}
+
+	go func() {
+		defer func() {
+			cl.lock.Lock()
+			if cl.levelId == id {
+				cl.fading = false
+			}
+			cl.lock.Unlock()
+		}()
+
+		for step := 1; step <= steps; step++ {
+			if step > 1 {
+				time.Sleep(lightFadeStep)
+			}
+			var current [4]float64
+			for ix := range current {
+				current[ix] = from[ix] + (target[ix]-from[ix])*float64(step)/float64(steps)
+			}
+
+			cl.lock.Lock()
+			replaced := cl.levelId != id
+			if !replaced {
+				cl.current = current
+			}
+			cl.lock.Unlock()
+			if replaced {
+				return
+			}
+
+			err := cl.write(current)
+			if err != nil {
+				log.Printf("color light %s | fade failed: %v", cl.Name, err)
+				return
+			}
+		}
+	}()
+}
+
+// write sets channels (0-1) to outputs, levels 0-100 of dimmable channels or 0-255 of colour output.
+func (cl *ColorLight) write(channels [4]float64) error {
+	if cl.color != nil {
+		scale := func(value float64) int {
+			return int(math.Round(value * 255))
+		}
+		return cl.color.SetRgbw(drivers.Rgbw{
+			Red:   scale(channels[0]),
+			Green: scale(channels[1]),
+			Blue:  scale(channels[2]),
+			White: scale(channels[3]),
+		})
+	}
+
+	for ix, channel := range cl.channels {
+		err := channel.SetLevel(int(math.Round(channels[ix] * 100)))
+		if err != nil {
+			return errors.Wrapf(err, "failed to set channel %d", ix)
+		}
+	}
+	return nil
+}
+
+// hsvToRgb converts hue (0-360), saturation and value (0-1) to red, green and blue (0-1).
+func hsvToRgb(hue, saturation, value float64) (red, green, blue float64) {
+	chroma := value * saturation
+	sector := math.Mod(hue, 360) / 60
+	x := chroma * (1 - math.Abs(math.Mod(sector, 2)-1))
+
+	switch {
+	case sector < 1:
+		red, green, blue = chroma, x, 0
+	case sector < 2:
+		red, green, blue = x, chroma, 0
+	case sector < 3:
+		red, green, blue = 0, chroma, x
+	case sector < 4:
+		red, green, blue = 0, x, chroma
+	case sector < 5:
+		red, green, blue = x, 0, chroma
+	default:
+		red, green, blue = chroma, 0, x
+	}
+
+	m := value - chroma
+	return red + m, green + m, blue + m
+}
+
+// miredToRgb approximates colour of black body at temperature given in mired, result is 0-1
+// (Tanner Helland's approximation, valid for 1000-40000 K).
+func miredToRgb(mired int) (red, green, blue float64) {
+	temperature := 1e6 / float64(max(mired, 1)) / 100
+	clamp := func(value float64) float64 {
+		return math.Min(math.Max(value, 0), 255) / 255
+	}
+
+	if temperature <= 66 {
+		red = 1
+		green = clamp(99.4708025861*math.Log(temperature) - 161.1195681661)
+	} else {
+		red = clamp(329.698727446 * math.Pow(temperature-60, -0.1332047592))
+		green = clamp(288.1221695283 * math.Pow(temperature-60, -0.0755148492))
+	}
+
+	switch {
+	case temperature >= 66:
+		blue = 1
+	case temperature <= 19:
+		blue = 0
+	default:
+		blue = clamp(138.5177312231*math.Log(temperature-10) - 305.0447927307)
+	}
+	return
+}
+
+// mixWhite moves common part of red, green and blue to white channel.
+func mixWhite(red, green, blue float64) (float64, float64, float64, float64) {
+	white := math.Min(red, math.Min(green, blue))
+	return red - white, green - white, blue - white, white
+}
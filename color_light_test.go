@@ -0,0 +1,135 @@
+package swkit
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	drivers "github.com/hubertat/swkit/drivers"
+)
+
+func colorLightTest(t *testing.T, light *ColorLight) *drivers.MockIoDriver {
+	t.Helper()
+
+	sw := newMockKit(t, func(sw *SwKit) {
+		sw.ColorLights = []*ColorLight{light}
+	})
+	md, _ := sw.GetMockIoDriver("mock_driver")
+	return md
+}
+
+func outputRgbw(md *drivers.MockIoDriver, pin uint16) drivers.Rgbw {
+	out, _ := md.GetOutput(pin)
+	color, _ := out.(drivers.ColorOutput).GetRgbw()
+	return color
+}
+
+func TestHsvToRgb(t *testing.T) {
+	round := func(value float64) float64 {
+		return math.Round(value*100) / 100
+	}
+	for _, tc := range []struct {
+		hue, saturation, value float64
+		red, green, blue       float64
+	}{
+		{0, 1, 1, 1, 0, 0},
+		{120, 1, 1, 0, 1, 0},
+		{240, 1, 1, 0, 0, 1},
+		{60, 1, 1, 1, 1, 0},
+		{300, 0.5, 1, 1, 0.5, 1},
+		{200, 0, 0.4, 0.4, 0.4, 0.4},
+	} {
+		red, green, blue := hsvToRgb(tc.hue, tc.saturation, tc.value)
+		assertFloats(t, round(red), tc.red)
+		assertFloats(t, round(green), tc.green)
+		assertFloats(t, round(blue), tc.blue)
+	}
+}
+
+func TestMiredToRgb(t *testing.T) {
+	// warm white (2000 K) has no blue, cold (~6500 K) is almost neutral
+	red, green, blue := miredToRgb(500)
+	assertFloats(t, red, 1)
+	assertBools(t, green > 0.5 && green < 0.6, true)
+	assertBools(t, blue < 0.2, true)
+
+	red, green, blue = miredToRgb(154)
+	assertBools(t, red > 0.95 && green > 0.95 && blue > 0.95, true)
+}
+
+func TestColorLightChannels(t *testing.T) {
+	light := &ColorLight{Name: "Strip", DriverName: "mock_driver", ChannelPins: []uint16{1, 2, 3, 4}}
+	md := colorLightTest(t, light)
+
+	light.SetValue(true)
+	light.SetSaturation(100)
+	light.SetHue(0)
+	assertInts(t, outputLevel(md, 1), 100)
+	assertInts(t, outputLevel(md, 2)+outputLevel(md, 3)+outputLevel(md, 4), 0)
+	light.Sync()
+	assertBools(t, light.hk.Lightbulb.On.Value(), true)
+
+	// pink: common part of red, green and blue goes to white channel
+	light.SetHue(300)
+	light.SetSaturation(50)
+	light.SetBrightness(50)
+	assertInts(t, outputLevel(md, 1), 25)
+	assertInts(t, outputLevel(md, 2), 0)
+	assertInts(t, outputLevel(md, 3), 25)
+	assertInts(t, outputLevel(md, 4), 25)
+
+	light.SetValue(false)
+	for pin := uint16(1); pin <= 4; pin++ {
+		assertInts(t, outputLevel(md, pin), 0)
+	}
+	light.Sync()
+	assertBools(t, light.State || light.hk.Lightbulb.On.Value(), false)
+	assertInts(t, light.Brightness, 50)
+
+	light.Toggle()
+	assertInts(t, outputLevel(md, 4), 25)
+}
+
+func TestColorLightController(t *testing.T) {
+	light := &ColorLight{Name: "Strip", DriverName: "mock_driver", OutPin: 5, Saturation: 100}
+	md := colorLightTest(t, light)
+
+	light.SetColorTemperature(500)
+	light.SetValue(true)
+	color := outputRgbw(md, 5)
+	assertInts(t, color.Red, 255)
+	assertBools(t, color.Blue < 50 && color.White == 0, true)
+	assertBools(t, light.WhiteMode, true)
+
+	light.SetHue(240)
+	assertBools(t, light.WhiteMode, false)
+	assertBools(t, outputRgbw(md, 5) == drivers.Rgbw{Blue: 255}, true)
+
+	light.SetValue(false)
+	assertBools(t, outputRgbw(md, 5).IsOff(), true)
+}
+
+func TestColorLightFade(t *testing.T) {
+	light := &ColorLight{Name: "Strip", DriverName: "mock_driver", ChannelPins: []uint16{1, 2, 3}, FadeDuration: "200ms"}
+	md := colorLightTest(t, light)
+
+	light.SetValue(true)
+	assertBools(t, outputLevel(md, 1) < 100, true)
+	waitFor(t, func() bool { return outputLevel(md, 3) == 100 }, "fade to white")
+
+	light.SetValue(false)
+	light.SetValue(true)
+	waitFor(t, func() bool { return outputLevel(md, 1) == 100 }, "fade back to white")
+}
+
+func TestColorLightInitErrors(t *testing.T) {
+	md := &drivers.MockIoDriver{}
+	err := md.Setup(context.Background(), nil, []uint16{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer md.Close()
+
+	light := &ColorLight{Name: "Strip", DriverName: "mock_driver", ChannelPins: []uint16{1, 2}}
+	assertBools(t, light.Init(md) != nil, true)
+}
@@ -0,0 +1,319 @@
+package swkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// includeKey is top level config key with list of included files (glob patterns, relative to including file).
+const includeKey = "Include"
+
+// expandEnvKey is top level config key switching environment variables substitution on or off, default is
+// on for yaml and toml, off for json, so existing json configs with $ in values are read unchanged.
+const expandEnvKey = "ExpandEnv"
+
+// secretFileSuffix marks key which value is path of file with the actual value, e.g. "Token_FILE".
+const secretFileSuffix = "_FILE"
+
+var envPattern = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// LoadConfig reads configuration file into new SwKit, see ReadConfig.
+func LoadConfig(path string) (*SwKit, error) {
+	data, err := ReadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	sw := &SwKit{}
+	err = json.Unmarshal(data, sw)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode config %s", path)
+	}
+	return sw, nil
+}
+
+// ReadConfig reads configuration file and returns it as json, which can be decoded into SwKit.
+// Format is detected by extension: .yaml/.yml, .toml, json otherwise.
+// In string values ${VAR} (or ${VAR:-default}) is replaced with environment variable, $$ with $ - in yaml and
+// toml files, in json only with top level "ExpandEnv": true.
+// Key with _FILE suffix (e.g. "Token_FILE") is replaced by key without suffix with content of the file.
+// Files listed in top level "Include" are loaded first and config is merged on top of them:
+// lists are appended, objects merged, other values overridden.
+func ReadConfig(path string) ([]byte, error) {
+	config, err := readConfigFile(path, map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(config, "", "\t")
+}
+
+func readConfigFile(path string, visited map[string]bool) (map[string]interface{}, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid config path %s", path)
+	}
+	if visited[absPath] {
+		return nil, errors.Errorf("config %s is included recursively", path)
+	}
+	visited[absPath] = true
+	defer delete(visited, absPath)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't read config file %s", path)
+	}
+
+	var raw interface{}
+	expand := true
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		var tomlConfig map[string]interface{}
+		_, err = toml.Decode(string(data), &tomlConfig)
+		raw = tomlConfig
+	default:
+		expand = false
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		err = decoder.Decode(&raw)
+		if syntaxErr, ok := err.(*json.SyntaxError); ok {
+			line, column := jsonPosition(data, syntaxErr.Offset)
+			err = errors.Errorf("line %d, column %d: %v", line, column, err)
+		}
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse config %s", path)
+	}
+	if raw == nil {
+		raw = map[string]interface{}{}
+	}
+
+	config, ok := normalizeConfigValue(raw).(map[string]interface{})
+	if !ok {
+		return nil, errors.Errorf("config %s: top level must be an object", path)
+	}
+	expand, err = configExpandEnv(config, expand)
+	if err != nil {
+		return nil, errors.Wrapf(err, "config %s", path)
+	}
+	_, err = resolveConfigValue(config, filepath.Dir(path), "", expand)
+	if err != nil {
+		return nil, errors.Wrapf(err, "config %s", path)
+	}
+
+	includes, err := configIncludes(config, filepath.Dir(path))
+	if err != nil {
+		return nil, errors.Wrapf(err, "config %s", path)
+	}
+	merged := map[string]interface{}{}
+	for _, include := range includes {
+		included, err := readConfigFile(include, visited)
+		if err != nil {
+			return nil, err
+		}
+		mergeConfig(merged, included)
+	}
+	mergeConfig(merged, config)
+
+	return merged, nil
+}
+
+// configExpandEnv removes expand env key from config and returns its value, fallback when not set.
+func configExpandEnv(config map[string]interface{}, fallback bool) (bool, error) {
+	for key, value := range config {
+		if !strings.EqualFold(key, expandEnvKey) {
+			continue
+		}
+		delete(config, key)
+
+		expand, isBool := value.(bool)
+		if !isBool {
+			return false, errors.Errorf("%s: expected true or false, got %v", expandEnvKey, value)
+		}
+		return expand, nil
+	}
+	return fallback, nil
+}
+
+// configIncludes removes include key from config and returns matching file paths.
+func configIncludes(config map[string]interface{}, dir string) (paths []string, err error) {
+	for key, value := range config {
+		if !strings.EqualFold(key, includeKey) {
+			continue
+		}
+		delete(config, key)
+
+		patterns := []interface{}{value}
+		if list, isList := value.([]interface{}); isList {
+			patterns = list
+		}
+		for _, item := range patterns {
+			pattern, isString := item.(string)
+			if !isString {
+				return nil, errors.Errorf("%s: expected file name, got %v", includeKey, item)
+			}
+			if !filepath.IsAbs(pattern) {
+				pattern = filepath.Join(dir, pattern)
+			}
+			matches, err := filepath.Glob(pattern)
+			if err != nil {
+				return nil, errors.Wrapf(err, "%s: invalid pattern", includeKey)
+			}
+			if len(matches) == 0 && !strings.ContainsAny(pattern, "*?[") {
+				return nil, errors.Errorf("%s: file %s not found", includeKey, pattern)
+			}
+			sort.Strings(matches)
+			paths = append(paths, matches...)
+		}
+	}
+	return
+}
+
+// normalizeConfigValue converts yaml and toml decoded values to json compatible types.
+func normalizeConfigValue(value interface{}) interface{} {
+	switch node := value.(type) {
+	case map[string]interface{}:
+		for key, item := range node {
+			node[key] = normalizeConfigValue(item)
+		}
+		return node
+	case map[interface{}]interface{}:
+		converted := map[string]interface{}{}
+		for key, item := range node {
+			converted[fmt.Sprint(key)] = normalizeConfigValue(item)
+		}
+		return converted
+	case []map[string]interface{}:
+		converted := []interface{}{}
+		for _, item := range node {
+			converted = append(converted, normalizeConfigValue(item))
+		}
+		return converted
+	case []interface{}:
+		for ix, item := range node {
+			node[ix] = normalizeConfigValue(item)
+		}
+		return node
+	}
+	return value
+}
+
+// resolveConfigValue substitutes environment variables in strings (when expand) and reads _FILE secrets.
+func resolveConfigValue(value interface{}, dir string, path string, expand bool) (interface{}, error) {
+	switch node := value.(type) {
+	case string:
+		if !expand {
+			return node, nil
+		}
+		return expandEnv(node, path)
+	case []interface{}:
+		for ix, item := range node {
+			resolved, err := resolveConfigValue(item, dir, fmt.Sprintf("%s[%d]", path, ix), expand)
+			if err != nil {
+				return nil, err
+			}
+			node[ix] = resolved
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(node))
+		for key := range node {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			keyPath := strings.TrimPrefix(path+"."+key, ".")
+			resolved, err := resolveConfigValue(node[key], dir, keyPath, expand)
+			if err != nil {
+				return nil, err
+			}
+			if !strings.HasSuffix(key, secretFileSuffix) || len(key) == len(secretFileSuffix) {
+				node[key] = resolved
+				continue
+			}
+
+			secretPath, isString := resolved.(string)
+			if !isString {
+				return nil, errors.Errorf("%s: expected file name", keyPath)
+			}
+			if !filepath.IsAbs(secretPath) {
+				secretPath = filepath.Join(dir, secretPath)
+			}
+			secret, err := os.ReadFile(secretPath)
+			if err != nil {
+				return nil, errors.Wrapf(err, "%s: can't read secret", keyPath)
+			}
+			name := strings.TrimSuffix(key, secretFileSuffix)
+			if _, exists := node[name]; exists {
+				return nil, errors.Errorf("%s: both %s and %s set", keyPath, name, key)
+			}
+			delete(node, key)
+			node[name] = strings.TrimRight(string(secret), "\r\n")
+		}
+	}
+	return value, nil
+}
+
+func expandEnv(text string, path string) (string, error) {
+	var err error
+	expanded := envPattern.ReplaceAllStringFunc(text, func(match string) string {
+		if match == "$$" {
+			return "$"
+		}
+		groups := envPattern.FindStringSubmatch(match)
+		value, found := os.LookupEnv(groups[1])
+		if found && len(value) > 0 {
+			return value
+		}
+		if len(groups[2]) > 0 {
+			return groups[3]
+		}
+		if !found && err == nil {
+			err = errors.Errorf("%s: environment variable %s not set", path, groups[1])
+		}
+		return value
+	})
+	return expanded, err
+}
+
+// mergeConfig merges overlay into base, keys are matched case insensitive (as json decoding does).
+func mergeConfig(base map[string]interface{}, overlay map[string]interface{}) {
+	for key, value := range overlay {
+		baseKey := key
+		for existing := range base {
+			if strings.EqualFold(existing, key) {
+				baseKey = existing
+				break
+			}
+		}
+
+		current, exists := base[baseKey]
+		if !exists {
+			base[baseKey] = value
+			continue
+		}
+		switch currentNode := current.(type) {
+		case []interface{}:
+			if list, isList := value.([]interface{}); isList {
+				base[baseKey] = append(currentNode, list...)
+				continue
+			}
+		case map[string]interface{}:
+			if object, isObject := value.(map[string]interface{}); isObject {
+				mergeConfig(currentNode, object)
+				continue
+			}
+		}
+		base[baseKey] = value
+	}
+}
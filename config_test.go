@@ -0,0 +1,137 @@
+package swkit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, dir string, name string, content string) string {
+	path := filepath.Join(dir, name)
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("SWKIT_TEST_PIN", "10293847")
+
+	writeConfigFile(t, dir, "secrets/influx_token", "secret-token\n")
+	writeConfigFile(t, dir, "rooms/hall.toml", `
+[[Lights]]
+Name = "Hall"
+DriverName = "mock_driver"
+OutPin = 1
+
+[[Buttons]]
+Name = "Hall button"
+DriverName = "mock_driver"
+InPin = 10
+`)
+	writeConfigFile(t, dir, "rooms/kitchen.json", `{
+	"lights": [{"Name": "Kitchen", "DriverName": "mock_driver", "OutPin": 2}],
+	"HkPin": "00000000"
+}`)
+	main := writeConfigFile(t, dir, "config.yaml", `
+# per room files
+Include:
+  - rooms/*
+HkPin: ${SWKIT_TEST_PIN}
+HkDirectory: ${SWKIT_TEST_MISSING:-./homekit}
+FakeDriver: {}
+InfluxSensors:
+  Token_FILE: secrets/influx_token
+  Host: http://influx:8086/$${path}
+Lights:
+  - Name: Porch
+    DriverName: mock_driver
+    OutPin: 3
+`)
+
+	sw, err := LoadConfig(main)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sw.HkPin != "10293847" || sw.HkDirectory != "./homekit" {
+		t.Errorf("unexpected HkPin/HkDirectory: %s, %s", sw.HkPin, sw.HkDirectory)
+	}
+	assertBools(t, sw.FakeDriver != nil, true)
+	if sw.InfluxSensors.Token != "secret-token" || sw.InfluxSensors.Host != "http://influx:8086/${path}" {
+		t.Errorf("unexpected influx config: %s, %s", sw.InfluxSensors.Token, sw.InfluxSensors.Host)
+	}
+	assertInts(t, len(sw.Lights), 3)
+	assertInts(t, len(sw.Buttons), 1)
+	names := []string{}
+	for _, li := range sw.Lights {
+		names = append(names, li.Name)
+	}
+	if strings.Join(names, ",") != "Hall,Kitchen,Porch" {
+		t.Errorf("unexpected lights order: %v", names)
+	}
+
+	_, err = ValidateConfigFile(main)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	missingEnv := writeConfigFile(t, dir, "env.json", `{"ExpandEnv": true, "Mqtt": {"Password": "${SWKIT_TEST_NOT_SET}"}}`)
+	_, err := LoadConfig(missingEnv)
+	assertBools(t, err != nil && strings.Contains(err.Error(), "Mqtt.Password"), true)
+
+	invalidExpand := writeConfigFile(t, dir, "expand.yaml", "ExpandEnv: yes please\n")
+	_, err = LoadConfig(invalidExpand)
+	assertBools(t, err != nil && strings.Contains(err.Error(), "ExpandEnv"), true)
+
+	writeConfigFile(t, dir, "a.yaml", "Include: b.yaml\n")
+	recursive := writeConfigFile(t, dir, "b.yaml", "Include: a.yaml\n")
+	_, err = LoadConfig(recursive)
+	assertBools(t, err != nil && strings.Contains(err.Error(), "recursively"), true)
+
+	missingInclude := writeConfigFile(t, dir, "c.toml", `Include = ["rooms/none.toml"]`)
+	_, err = LoadConfig(missingInclude)
+	assertBools(t, err != nil && strings.Contains(err.Error(), "not found"), true)
+
+	syntax := writeConfigFile(t, dir, "syntax.json", "{\n\t\"HkPin\": \"10293847\",\n}")
+	_, err = LoadConfig(syntax)
+	assertBools(t, err != nil && strings.Contains(err.Error(), "line 3"), true)
+}
+
+func TestLoadConfigExpandEnv(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("SWKIT_TEST_PASSWORD", "from-env")
+
+	// json is read as is, unless ExpandEnv is set
+	plain := writeConfigFile(t, dir, "plain.json", `{"Mqtt": {"Password": "pa$$${SWKIT_TEST_PASSWORD}"}}`)
+	sw, err := LoadConfig(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertBools(t, sw.Mqtt.Password == "pa$$${SWKIT_TEST_PASSWORD}", true)
+
+	expanded := writeConfigFile(t, dir, "expanded.json", `{"ExpandEnv": true, "Mqtt": {"Password": "pa$$${SWKIT_TEST_PASSWORD}"}}`)
+	sw, err = LoadConfig(expanded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertBools(t, sw.Mqtt.Password == "pa$from-env", true)
+
+	disabled := writeConfigFile(t, dir, "disabled.yaml", "ExpandEnv: false\nMqtt:\n  Password: pa$${SWKIT_TEST_PASSWORD}\n")
+	sw, err = LoadConfig(disabled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertBools(t, sw.Mqtt.Password == "pa$${SWKIT_TEST_PASSWORD}", true)
+}
@@ -0,0 +1,462 @@
+package drivers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const espRelayDriverName = "esp_relay"
+const espRelayDefaultPollInterval = 2 * time.Second
+const espRelayDefaultTimeout = 2 * time.Second
+
+const (
+	EspFirmwareTasmota = "tasmota"
+	EspFirmwareEsphome = "esphome"
+)
+
+// EspRelayOutput is a single relay of Tasmota or ESPHome device. Relay is relay number for Tasmota
+// ("1" for Power1) or switch object id for ESPHome (e.g. "relay_1").
+type EspRelayOutput struct {
+	Pin      uint16
+	Firmware string
+	Address  string // e.g. "http://192.168.1.50"
+	Relay    string
+	Username string
+	Password string
+
+	dev *espRelayDevice
+}
+
+func (eout *EspRelayOutput) GetState() (bool, error) {
+	if eout.dev == nil {
+		return false, errors.New("esp relay output device not initialized")
+	}
+	return eout.dev.getState(eout.Relay)
+}
+
+func (eout *EspRelayOutput) Set(state bool) error {
+	if eout.dev == nil {
+		return errors.New("esp relay output device not initialized")
+	}
+	err := eout.dev.set(eout.Relay, state)
+	if err != nil {
+		return errors.Wrapf(err, "failed to set relay %s of %s", eout.Relay, eout.dev.address)
+	}
+	return nil
+}
+
+// espRelayDevice is a single Tasmota/ESPHome device shared by all its outputs.
+type espRelayDevice struct {
+	address  string
+	firmware string
+	username string
+	password string
+	relays   []string
+	client   *http.Client
+
+	states map[string]bool
+	err    error
+	lock   sync.Mutex
+}
+
+func (dev *espRelayDevice) getState(relay string) (bool, error) {
+	dev.lock.Lock()
+	defer dev.lock.Unlock()
+
+	if dev.err != nil {
+		return false, errors.Wrapf(dev.err, "device %s not available", dev.address)
+	}
+	state, ok := dev.states[relay]
+	if !ok {
+		return false, errors.Errorf("relay %s state not reported by %s", relay, dev.address)
+	}
+	return state, nil
+}
+
+func (dev *espRelayDevice) update(states map[string]bool, err error) {
+	dev.lock.Lock()
+	defer dev.lock.Unlock()
+
+	dev.err = err
+	for relay, state := range states {
+		dev.states[relay] = state
+	}
+}
+
+func (dev *espRelayDevice) isAvailable() bool {
+	dev.lock.Lock()
+	defer dev.lock.Unlock()
+
+	return dev.err == nil
+}
+
+func (dev *espRelayDevice) getJson(method string, path string, query url.Values, target interface{}) error {
+	if dev.firmware == EspFirmwareTasmota && len(dev.password) > 0 {
+		query.Set("user", dev.username)
+		query.Set("password", dev.password)
+	}
+
+	reqUrl := strings.TrimSuffix(dev.address, "/") + path
+	if len(query) > 0 {
+		reqUrl += "?" + strings.ReplaceAll(query.Encode(), "+", "%20")
+	}
+	req, err := http.NewRequest(method, reqUrl, nil)
+	if err != nil {
+		return err
+	}
+	if dev.firmware == EspFirmwareEsphome && len(dev.password) > 0 {
+		req.SetBasicAuth(dev.username, dev.password)
+	}
+
+	resp, err := dev.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("%s %s responded with status %s", method, path, resp.Status)
+	}
+	if target == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(target)
+}
+
+// tasmotaPowerState finds relay state in Tasmota response, single relay devices report "POWER" instead of "POWER1".
+func tasmotaPowerState(response map[string]interface{}, relay string) (state bool, found bool) {
+	value, found := response["POWER"+relay]
+	if !found && relay == "1" {
+		value, found = response["POWER"]
+	}
+	if !found {
+		return
+	}
+	state = strings.EqualFold(fmt.Sprint(value), "ON")
+	return
+}
+
+type esphomeState struct {
+	Id    string
+	State string
+	Value bool
+}
+
+// getRelays returns relays of device, which are changed by Reconfigure.
+func (dev *espRelayDevice) getRelays() []string {
+	dev.lock.Lock()
+	defer dev.lock.Unlock()
+
+	return dev.relays
+}
+
+func (dev *espRelayDevice) poll() (states map[string]bool, err error) {
+	states = map[string]bool{}
+
+	switch dev.firmware {
+	case EspFirmwareTasmota:
+		response := map[string]interface{}{}
+		err = dev.getJson(http.MethodGet, "/cm", url.Values{"cmnd": {"State"}}, &response)
+		if err != nil {
+			return
+		}
+		for _, relay := range dev.getRelays() {
+			state, found := tasmotaPowerState(response, relay)
+			if found {
+				states[relay] = state
+			}
+		}
+	case EspFirmwareEsphome:
+		for _, relay := range dev.getRelays() {
+			response := esphomeState{}
+			err = dev.getJson(http.MethodGet, "/switch/"+url.PathEscape(relay), url.Values{}, &response)
+			if err != nil {
+				return
+			}
+			states[relay] = response.Value
+		}
+	}
+	return
+}
+
+func (dev *espRelayDevice) set(relay string, state bool) error {
+	var err error
+
+	switch dev.firmware {
+	case EspFirmwareTasmota:
+		command := fmt.Sprintf("Power%s %s", relay, map[bool]string{true: "On", false: "Off"}[state])
+		response := map[string]interface{}{}
+		err = dev.getJson(http.MethodGet, "/cm", url.Values{"cmnd": {command}}, &response)
+		if err == nil {
+			reported, found := tasmotaPowerState(response, relay)
+			if !found || reported != state {
+				err = errors.Errorf("unexpected response to %s: %v", command, response)
+			}
+		}
+	case EspFirmwareEsphome:
+		action := "/turn_off"
+		if state {
+			action = "/turn_on"
+		}
+		err = dev.getJson(http.MethodPost, "/switch/"+url.PathEscape(relay)+action, url.Values{}, nil)
+	}
+
+	if err != nil {
+		dev.update(nil, err)
+		return err
+	}
+	dev.update(map[string]bool{relay: state}, nil)
+	return nil
+}
+
+// EspRelayIO switches relays of Wi-Fi devices running Tasmota (http api /cm?cmnd=) or ESPHome (web server
+// rest api). Devices are polled, when device does not respond its outputs report error (fault).
+type EspRelayIO struct {
+	PollInterval string // default "2s"
+	Timeout      string // default "2s"
+
+	Outputs []EspRelayOutput
+
+	client  *http.Client
+	devices map[string]*espRelayDevice
+	done    chan bool
+	isReady bool
+	lock    sync.Mutex
+}
+
+// getDevices returns devices, which are changed by Reconfigure.
+func (eio *EspRelayIO) getDevices() map[string]*espRelayDevice {
+	eio.lock.Lock()
+	defer eio.lock.Unlock()
+
+	return eio.devices
+}
+
+func (eio *EspRelayIO) pollDevices() {
+	var wg sync.WaitGroup
+	for _, dev := range eio.getDevices() {
+		wg.Add(1)
+		go func(dev *espRelayDevice) {
+			defer wg.Done()
+			wasAvailable := dev.isAvailable()
+			states, err := dev.poll()
+			dev.update(states, err)
+			if err != nil && wasAvailable {
+				log.Printf("esp_relay | device %s not available: %v", dev.address, err)
+			}
+		}(dev)
+	}
+	wg.Wait()
+}
+
+func (eio *EspRelayIO) startPolling(ctx context.Context, done chan bool, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			eio.pollDevices()
+		}
+	}
+}
+
+func (eio *EspRelayIO) Setup(ctx context.Context, inputs []uint16, outputs []uint16) error {
+	if len(inputs) > 0 {
+		return errors.New("esp_relay driver does not support inputs")
+	}
+
+	interval := espRelayDefaultPollInterval
+	timeout := espRelayDefaultTimeout
+	var err error
+	if len(eio.PollInterval) > 0 {
+		interval, err = time.ParseDuration(eio.PollInterval)
+		if err != nil {
+			return errors.Wrap(err, "parsing PollInterval failed")
+		}
+	}
+	if len(eio.Timeout) > 0 {
+		timeout, err = time.ParseDuration(eio.Timeout)
+		if err != nil {
+			return errors.Wrap(err, "parsing Timeout failed")
+		}
+	}
+	eio.client = &http.Client{Timeout: timeout}
+
+	eio.devices, err = eio.setupDevices(eio.Outputs, nil)
+	if err != nil {
+		return err
+	}
+	for _, pin := range outputs {
+		_, err = eio.GetOutput(pin)
+		if err != nil {
+			return err
+		}
+	}
+
+	eio.pollDevices()
+
+	eio.done = make(chan bool)
+	go eio.startPolling(ctx, eio.done, interval)
+
+	eio.isReady = true
+	return nil
+}
+
+// setupDevices creates devices of outputs and assigns them to outputs, devices of existing with the same
+// address and settings are reused (running outputs keep their device).
+func (eio *EspRelayIO) setupDevices(outputs []EspRelayOutput, existing map[string]*espRelayDevice) (map[string]*espRelayDevice, error) {
+	devices := map[string]*espRelayDevice{}
+	relays := map[*espRelayDevice][]string{}
+	for ix := range outputs {
+		out := &outputs[ix]
+		firmware := strings.ToLower(out.Firmware)
+		if firmware != EspFirmwareTasmota && firmware != EspFirmwareEsphome {
+			return nil, errors.Errorf("output %d: unsupported firmware (%s)", out.Pin, out.Firmware)
+		}
+		address := out.Address
+		if !strings.Contains(address, "://") {
+			address = "http://" + address
+		}
+		username := out.Username
+		if firmware == EspFirmwareTasmota && len(username) == 0 {
+			username = "admin"
+		}
+
+		dev, exist := devices[address]
+		if !exist {
+			dev = existing[address]
+			if dev == nil || dev.firmware != firmware || dev.username != username || dev.password != out.Password {
+				dev = &espRelayDevice{
+					address:  address,
+					firmware: firmware,
+					username: username,
+					password: out.Password,
+					client:   eio.client,
+					states:   map[string]bool{},
+				}
+			}
+			devices[address] = dev
+		}
+		if dev.firmware != firmware {
+			return nil, errors.Errorf("output %d: device %s configured with different firmwares", out.Pin, address)
+		}
+		relays[dev] = append(relays[dev], out.Relay)
+		out.dev = dev
+	}
+
+	for dev, devRelays := range relays {
+		dev.lock.Lock()
+		dev.relays = devRelays
+		dev.lock.Unlock()
+	}
+	return devices, nil
+}
+
+func (eio *EspRelayIO) NameId() string {
+	return espRelayDriverName
+}
+
+// IsReady reports false when none of configured devices is available.
+func (eio *EspRelayIO) IsReady() bool {
+	if !eio.isReady {
+		return false
+	}
+	devices := eio.getDevices()
+	for _, dev := range devices {
+		if dev.isAvailable() {
+			return true
+		}
+	}
+	return len(devices) == 0
+}
+
+func (eio *EspRelayIO) Close() error {
+	if !eio.isReady {
+		return nil
+	}
+	eio.isReady = false
+	close(eio.done)
+	return nil
+}
+
+func (eio *EspRelayIO) GetInput(pin uint16) (DigitalInput, error) {
+	return nil, errors.New("esp_relay inputs not implemented")
+}
+
+func (eio *EspRelayIO) GetOutput(pin uint16) (DigitalOutput, error) {
+	for ix := range eio.Outputs {
+		if eio.Outputs[ix].Pin == pin {
+			return &eio.Outputs[ix], nil
+		}
+	}
+	return nil, fmt.Errorf("esp_relay output pin = %d not found", pin)
+}
+
+func (eio *EspRelayIO) GetAllIo() (inputs []uint16, outputs []uint16) {
+	for _, out := range eio.Outputs {
+		outputs = append(outputs, out.Pin)
+	}
+	return
+}
+
+// Reconfigure takes Outputs of next, devices of outputs with unchanged definition keep running. Removed
+// outputs are left as they are.
+func (eio *EspRelayIO) Reconfigure(ctx context.Context, next IoDriver, inputs []uint16, outputs []uint16) error {
+	err := checkReconfigure(eio, next, "Outputs")
+	if err != nil {
+		return err
+	}
+	if len(inputs) > 0 {
+		return errors.New("esp_relay driver does not support inputs")
+	}
+	nextEio := next.(*EspRelayIO)
+
+	// listed pins not defined by next keep current definition, definition of listed pin can't change
+	definitions := []EspRelayOutput{}
+	for _, out := range nextEio.Outputs {
+		current, _ := eio.GetOutput(out.Pin)
+		if current != nil && hasPin(outputs, out.Pin) && !sameDefinition(current, out) {
+			return errors.Errorf("definition of esp_relay output %d can't be changed while used, restart needed", out.Pin)
+		}
+		definitions = append(definitions, out)
+	}
+	for _, out := range eio.Outputs {
+		if hasPin(outputs, out.Pin) && !slices.ContainsFunc(definitions, func(defined EspRelayOutput) bool { return defined.Pin == out.Pin }) {
+			definitions = append(definitions, out)
+		}
+	}
+	reconfigured := &EspRelayIO{Outputs: definitions}
+	for _, pin := range outputs {
+		_, err = reconfigured.GetOutput(pin)
+		if err != nil {
+			return err
+		}
+	}
+
+	devices, err := eio.setupDevices(definitions, eio.getDevices())
+	if err != nil {
+		return err
+	}
+	eio.lock.Lock()
+	eio.devices = devices
+	eio.lock.Unlock()
+	eio.Outputs = definitions
+
+	eio.pollDevices()
+	return nil
+}
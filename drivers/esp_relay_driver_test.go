@@ -0,0 +1,215 @@
+package drivers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeEspDevice struct {
+	relays  map[string]bool
+	offline bool
+	lock    sync.Mutex
+}
+
+func (fed *fakeEspDevice) setOffline(offline bool) {
+	fed.lock.Lock()
+	defer fed.lock.Unlock()
+	fed.offline = offline
+}
+
+func (fed *fakeEspDevice) getRelay(relay string) bool {
+	fed.lock.Lock()
+	defer fed.lock.Unlock()
+	return fed.relays[relay]
+}
+
+// tasmotaHandler mimics Tasmota http api, password protected.
+func (fed *fakeEspDevice) tasmotaHandler(w http.ResponseWriter, r *http.Request) {
+	fed.lock.Lock()
+	defer fed.lock.Unlock()
+
+	if fed.offline {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	if r.URL.Path != "/cm" || r.URL.Query().Get("password") != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	response := map[string]string{}
+	command := strings.Fields(r.URL.Query().Get("cmnd"))
+	switch {
+	case len(command) == 1 && command[0] == "State":
+		for relay, state := range fed.relays {
+			response["POWER"+relay] = map[bool]string{true: "ON", false: "OFF"}[state]
+		}
+	case len(command) == 2 && strings.HasPrefix(command[0], "Power"):
+		relay := strings.TrimPrefix(command[0], "Power")
+		fed.relays[relay] = strings.EqualFold(command[1], "On")
+		response["POWER"+relay] = strings.ToUpper(command[1])
+	default:
+		response["Command"] = "Unknown"
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// esphomeHandler mimics ESPHome web_server rest api.
+func (fed *fakeEspDevice) esphomeHandler(w http.ResponseWriter, r *http.Request) {
+	fed.lock.Lock()
+	defer fed.lock.Unlock()
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if fed.offline || len(parts) < 2 || parts[0] != "switch" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	relay := parts[1]
+	if _, exist := fed.relays[relay]; !exist {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if len(parts) == 3 {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		fed.relays[relay] = parts[2] == "turn_on"
+		return
+	}
+	state := fed.relays[relay]
+	fmt.Fprintf(w, `{"id":"switch-%s","state":"%s","value":%v}`, relay, map[bool]string{true: "ON", false: "OFF"}[state], state)
+}
+
+func TestTasmotaPowerState(t *testing.T) {
+	state, found := tasmotaPowerState(map[string]interface{}{"POWER": "ON"}, "1")
+	assertBools(t, state && found, true)
+	state, found = tasmotaPowerState(map[string]interface{}{"POWER1": "OFF", "POWER2": "ON"}, "2")
+	assertBools(t, state && found, true)
+	_, found = tasmotaPowerState(map[string]interface{}{"POWER": "ON"}, "2")
+	assertBools(t, found, false)
+}
+
+func TestEspRelayIo(t *testing.T) {
+	tasmota := &fakeEspDevice{relays: map[string]bool{"1": true, "2": false}}
+	tasmotaServer := httptest.NewServer(http.HandlerFunc(tasmota.tasmotaHandler))
+	defer tasmotaServer.Close()
+	esphome := &fakeEspDevice{relays: map[string]bool{"relay_1": false}}
+	esphomeServer := httptest.NewServer(http.HandlerFunc(esphome.esphomeHandler))
+	defer esphomeServer.Close()
+
+	eio := &EspRelayIO{
+		PollInterval: "20ms",
+		Outputs: []EspRelayOutput{
+			{Pin: 1, Firmware: "tasmota", Address: tasmotaServer.URL, Relay: "1", Password: "secret"},
+			{Pin: 2, Firmware: "Tasmota", Address: tasmotaServer.URL, Relay: "2", Password: "secret"},
+			{Pin: 3, Firmware: "esphome", Address: strings.TrimPrefix(esphomeServer.URL, "http://"), Relay: "relay_1"},
+		},
+	}
+
+	err := eio.Setup(context.Background(), nil, []uint16{1, 2, 3, 4})
+	if err == nil {
+		t.Error("expected error for not configured output")
+	}
+	err = eio.Setup(context.Background(), nil, []uint16{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer eio.Close()
+	assertBools(t, eio.IsReady(), true)
+
+	for pin, want := range map[uint16]bool{1: true, 2: false, 3: false} {
+		out, _ := eio.GetOutput(pin)
+		state, err := out.GetState()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertBools(t, state, want)
+	}
+
+	out2, _ := eio.GetOutput(2)
+	out3, _ := eio.GetOutput(3)
+	err = out2.Set(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = out3.Set(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertBools(t, tasmota.getRelay("2"), true)
+	assertBools(t, esphome.getRelay("relay_1"), true)
+
+	esphome.setOffline(true)
+	time.Sleep(60 * time.Millisecond)
+	_, err = out3.GetState()
+	assertBools(t, err != nil, true)
+	assertBools(t, eio.IsReady(), true)
+	err = out3.Set(false)
+	assertBools(t, err != nil, true)
+
+	tasmota.setOffline(true)
+	time.Sleep(60 * time.Millisecond)
+	assertBools(t, eio.IsReady(), false)
+
+	tasmota.setOffline(false)
+	esphome.setOffline(false)
+	time.Sleep(60 * time.Millisecond)
+	state, err := out3.GetState()
+	assertBools(t, err == nil && state, true)
+	assertBools(t, eio.IsReady(), true)
+}
+
+func TestEspRelayReconfigure(t *testing.T) {
+	tasmota := &fakeEspDevice{relays: map[string]bool{"1": true, "2": false}}
+	tasmotaServer := httptest.NewServer(http.HandlerFunc(tasmota.tasmotaHandler))
+	defer tasmotaServer.Close()
+	esphome := &fakeEspDevice{relays: map[string]bool{"relay_1": true}}
+	esphomeServer := httptest.NewServer(http.HandlerFunc(esphome.esphomeHandler))
+	defer esphomeServer.Close()
+
+	relay1 := EspRelayOutput{Pin: 1, Firmware: "Tasmota", Address: strings.TrimPrefix(tasmotaServer.URL, "http://"), Relay: "1", Password: "secret"}
+	relay2 := EspRelayOutput{Pin: 2, Firmware: "Tasmota", Address: strings.TrimPrefix(tasmotaServer.URL, "http://"), Relay: "2", Password: "secret"}
+	relay3 := EspRelayOutput{Pin: 3, Firmware: "esphome", Address: esphomeServer.URL, Relay: "relay_1"}
+	eio := &EspRelayIO{PollInterval: "20ms", Outputs: []EspRelayOutput{relay1}}
+	err := eio.Setup(context.Background(), nil, []uint16{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer eio.Close()
+	// config is not changed by Setup, so unchanged config is recognized on reload
+	assertBools(t, eio.Outputs[0].Firmware == "Tasmota", true)
+	out1, _ := eio.GetOutput(1)
+
+	next := &EspRelayIO{PollInterval: "20ms", Outputs: []EspRelayOutput{relay1, relay2, relay3}}
+	err = eio.Reconfigure(context.Background(), next, nil, []uint16{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// device of output 1 is kept (also polled for added relay 2), relays are not switched
+	state, err := out1.GetState()
+	assertBools(t, err == nil && state, true)
+	for pin, want := range map[uint16]bool{2: false, 3: true} {
+		out, err := eio.GetOutput(pin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		state, err := out.GetState()
+		assertBools(t, err == nil && state == want, true)
+	}
+	assertInts(t, len(eio.getDevices()), 2)
+	assertBools(t, tasmota.getRelay("1") && !tasmota.getRelay("2") && esphome.getRelay("relay_1"), true)
+
+	relay1.Relay = "2"
+	err = eio.Reconfigure(context.Background(), &EspRelayIO{PollInterval: "20ms", Outputs: []EspRelayOutput{relay1}}, nil, []uint16{1})
+	assertBools(t, err != nil, true)
+	err = eio.Reconfigure(context.Background(), &EspRelayIO{PollInterval: "1s", Outputs: next.Outputs}, nil, []uint16{1})
+	assertBools(t, err != nil, true)
+}
@@ -3,19 +3,27 @@ package drivers
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	"github.com/pkg/errors"
 	"github.com/stianeikeland/go-rpio/v4"
 )
 
 const gpioDriverName = "gpio"
+const gpioPwmCycle = 100
+const gpioDefaultPwmFrequency = 1000
 
+// GpIO uses raspberry pi gpio pins. Outputs listed in PwmPins are dimmable (AnalogOutput) with hardware PWM,
+// only pins 12, 13, 18 and 19 are supported (12 and 18, 13 and 19 share the same channel).
 type GpIO struct {
-	inputs  []GpInput
-	outputs []GpOutput
+	inputs     []GpInput
+	outputs    []GpOutput
+	pwmOutputs []*GpPwmOutput
 
 	InvertInputs  bool
 	InvertOutputs bool
+	PwmPins       []uint16
+	PwmFrequency  int // Hz, default 1000
 
 	isReady bool
 }
@@ -68,28 +76,104 @@ func (gpo *GpOutput) GetState() (state bool, err error) {
 	return
 }
 
+// GpPwmOutput is output driven by hardware PWM, level 0-100 is its duty cycle.
+type GpPwmOutput struct {
+	pin    uint8
+	invert bool
+	level  int
+	lock   sync.Mutex
+}
+
+func (gpw *GpPwmOutput) GetLevel() (int, error) {
+	gpw.lock.Lock()
+	defer gpw.lock.Unlock()
+
+	return gpw.level, nil
+}
+
+func (gpw *GpPwmOutput) SetLevel(level int) error {
+	gpw.lock.Lock()
+	defer gpw.lock.Unlock()
+
+	gpw.level = clampPercent(level)
+	duty := uint32(gpw.level)
+	if gpw.invert {
+		duty = gpioPwmCycle - duty
+	}
+	rpio.Pin(gpw.pin).DutyCycle(duty, gpioPwmCycle)
+
+	return nil
+}
+
+func (gpw *GpPwmOutput) GetState() (bool, error) {
+	level, err := gpw.GetLevel()
+	return level > 0, err
+}
+
+func (gpw *GpPwmOutput) Set(state bool) error {
+	if state {
+		return gpw.SetLevel(100)
+	}
+	return gpw.SetLevel(0)
+}
+
+func (gp *GpIO) setupInput(inPin uint16) error {
+	if inPin > 255 {
+		return errors.Errorf("inpin out of range (gpio takes uint8 pin)")
+	}
+	pin := rpio.Pin(inPin)
+	pin.Input()
+	pin.PullUp()
+	gp.inputs = append(gp.inputs, GpInput{pin: uint8(inPin), invert: gp.InvertInputs})
+	return nil
+}
+
+func (gp *GpIO) setupOutput(outPin uint16) error {
+	if outPin > 255 {
+		return errors.Errorf("outpin out of range (gpio takes uint8 pin)")
+	}
+	pin := rpio.Pin(outPin)
+
+	if !hasPin(gp.PwmPins, outPin) {
+		pin.Output()
+		gp.outputs = append(gp.outputs, GpOutput{pin: uint8(outPin), invert: gp.InvertOutputs})
+		return nil
+	}
+
+	switch outPin {
+	case 12, 13, 18, 19:
+	default:
+		return errors.Errorf("pin %d does not support hardware PWM (use 12, 13, 18 or 19)", outPin)
+	}
+	frequency := gp.PwmFrequency
+	if frequency <= 0 {
+		frequency = gpioDefaultPwmFrequency
+	}
+	pin.Pwm()
+	pin.Freq(frequency * gpioPwmCycle)
+	output := &GpPwmOutput{pin: uint8(outPin), invert: gp.InvertOutputs}
+	output.SetLevel(0)
+	gp.pwmOutputs = append(gp.pwmOutputs, output)
+	return nil
+}
+
 func (gp *GpIO) Setup(ctx context.Context, inputs []uint16, outputs []uint16) error {
 	err := rpio.Open()
 	if err != nil {
 		return errors.Wrapf(err, "failed to Setup gpio driver for pins: %v, %v; ", inputs, outputs)
 	}
 	for _, inPin := range inputs {
-		if inPin > 255 {
-			return errors.Errorf("inpin out of range (gpio takes uint8 pin)")
+		err = gp.setupInput(inPin)
+		if err != nil {
+			return err
 		}
-		pin := rpio.Pin(inPin)
-		pin.Input()
-		pin.PullUp()
-		gp.inputs = append(gp.inputs, GpInput{pin: uint8(inPin), invert: gp.InvertInputs})
 	}
 
 	for _, outPin := range outputs {
-		if outPin > 255 {
-			return errors.Errorf("outpin out of range (gpio takes uint8 pin)")
+		err = gp.setupOutput(outPin)
+		if err != nil {
+			return err
 		}
-		pin := rpio.Pin(outPin)
-		pin.Output()
-		gp.outputs = append(gp.outputs, GpOutput{pin: uint8(outPin), invert: gp.InvertOutputs})
 	}
 
 	gp.isReady = true
@@ -100,6 +184,11 @@ func (gp *GpIO) NameId() string {
 	return gpioDriverName
 }
 
+func (gp *GpIO) GetUniqueId(ioPin uint16) uint64 {
+	baseId := uint64(1) << 56
+	return baseId + uint64(ioPin)
+}
+
 func (gp *GpIO) IsReady() bool {
 	return gp.isReady
 }
@@ -109,6 +198,9 @@ func (gp *GpIO) Close() error {
 	for _, output := range gp.outputs {
 		output.Set(false)
 	}
+	for _, output := range gp.pwmOutputs {
+		output.SetLevel(0)
+	}
 	return rpio.Close()
 }
 
@@ -133,6 +225,12 @@ func (gp *GpIO) GetOutput(id uint16) (output DigitalOutput, err error) {
 		err = errors.Errorf("pin id out of range (gpio takes uint8 pin)")
 		return
 	}
+	for _, out := range gp.pwmOutputs {
+		if out.pin == uint8(id) {
+			output = out
+			return
+		}
+	}
 	for _, out := range gp.outputs {
 		if out.pin == uint8(id) {
 			output = &out
@@ -152,6 +250,67 @@ func (gp *GpIO) GetAllIo() (inputs []uint16, outputs []uint16) {
 	for _, output := range gp.outputs {
 		outputs = append(outputs, uint16(output.pin))
 	}
+	for _, output := range gp.pwmOutputs {
+		outputs = append(outputs, uint16(output.pin))
+	}
 
 	return
 }
+
+// Reconfigure sets up added pins and switches removed outputs off, other pins are not touched.
+func (gp *GpIO) Reconfigure(ctx context.Context, next IoDriver, inputs []uint16, outputs []uint16) error {
+	err := checkReconfigure(gp, next)
+	if err != nil {
+		return err
+	}
+
+	currentInputs, currentOutputs := gp.GetAllIo()
+
+	keptInputs := []GpInput{}
+	for _, input := range gp.inputs {
+		if hasPin(inputs, uint16(input.pin)) {
+			keptInputs = append(keptInputs, input)
+		}
+	}
+	keptOutputs := []GpOutput{}
+	for _, output := range gp.outputs {
+		if hasPin(outputs, uint16(output.pin)) {
+			keptOutputs = append(keptOutputs, output)
+		} else {
+			output.Set(false)
+		}
+	}
+	keptPwmOutputs := []*GpPwmOutput{}
+	for _, output := range gp.pwmOutputs {
+		if hasPin(outputs, uint16(output.pin)) {
+			keptPwmOutputs = append(keptPwmOutputs, output)
+		} else {
+			output.SetLevel(0)
+		}
+	}
+	gp.inputs = keptInputs
+	gp.outputs = keptOutputs
+	gp.pwmOutputs = keptPwmOutputs
+
+	for _, inPin := range inputs {
+		if hasPin(currentInputs, inPin) {
+			continue
+		}
+		err := gp.setupInput(inPin)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, outPin := range outputs {
+		if hasPin(currentOutputs, outPin) {
+			continue
+		}
+		err := gp.setupOutput(outPin)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,227 @@
+//go:build linux
+
+package drivers
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"time"
+	"unsafe"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// gpio uAPI v2, see include/uapi/linux/gpio.h
+const (
+	gpioMaxNameSize       = 32
+	gpioV2LinesMax        = 64
+	gpioV2LineNumAttrsMax = 10
+
+	gpioV2LineFlagActiveLow    = 1 << 1
+	gpioV2LineFlagInput        = 1 << 2
+	gpioV2LineFlagOutput       = 1 << 3
+	gpioV2LineFlagEdgeRising   = 1 << 4
+	gpioV2LineFlagEdgeFalling  = 1 << 5
+	gpioV2LineFlagBiasPullUp   = 1 << 8
+	gpioV2LineFlagBiasPullDown = 1 << 9
+	gpioV2LineFlagBiasDisabled = 1 << 10
+
+	gpioV2LineAttrIdOutputValues = 2
+	gpioV2LineAttrIdDebounce     = 3
+
+	gpioV2LineEventRisingEdge = 1
+
+	gpioV2LineEventSize = 48
+)
+
+type gpioV2LineValues struct {
+	Bits uint64
+	Mask uint64
+}
+
+type gpioV2LineAttribute struct {
+	Id      uint32
+	Padding uint32
+	Value   uint64
+}
+
+type gpioV2LineConfigAttribute struct {
+	Attr gpioV2LineAttribute
+	Mask uint64
+}
+
+type gpioV2LineConfig struct {
+	Flags    uint64
+	NumAttrs uint32
+	Padding  [5]uint32
+	Attrs    [gpioV2LineNumAttrsMax]gpioV2LineConfigAttribute
+}
+
+type gpioV2LineRequest struct {
+	Offsets         [gpioV2LinesMax]uint32
+	Consumer        [gpioMaxNameSize]byte
+	Config          gpioV2LineConfig
+	NumLines        uint32
+	EventBufferSize uint32
+	Padding         [5]uint32
+	Fd              int32
+}
+
+func gpioIoctlNumber(dir uintptr, nr uintptr, size uintptr) uintptr {
+	return (dir << 30) | (size << 16) | (0xB4 << 8) | nr
+}
+
+var (
+	gpioV2GetLineIoctl       = gpioIoctlNumber(3, 0x07, unsafe.Sizeof(gpioV2LineRequest{}))
+	gpioV2LineGetValuesIoctl = gpioIoctlNumber(3, 0x0E, unsafe.Sizeof(gpioV2LineValues{}))
+	gpioV2LineSetValuesIoctl = gpioIoctlNumber(3, 0x0F, unsafe.Sizeof(gpioV2LineValues{}))
+)
+
+func gpioIoctl(fd uintptr, request uintptr, arg unsafe.Pointer) error {
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, fd, request, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+type gpiodCharDevChip struct {
+	file *os.File
+}
+
+func openGpiodChip(path string) (gpiodChip, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &gpiodCharDevChip{file: file}, nil
+}
+
+func (gc *gpiodCharDevChip) RequestLine(offset uint32, consumer string, config gpiodLineConfig) (gpiodLine, error) {
+	req := gpioV2LineRequest{NumLines: 1}
+	req.Offsets[0] = offset
+	copy(req.Consumer[:gpioMaxNameSize-1], consumer)
+
+	if config.ActiveLow {
+		req.Config.Flags |= gpioV2LineFlagActiveLow
+	}
+
+	if config.Output {
+		req.Config.Flags |= gpioV2LineFlagOutput
+		var initial uint64
+		if config.InitialValue {
+			initial = 1
+		}
+		req.Config.Attrs[req.Config.NumAttrs] = gpioV2LineConfigAttribute{
+			Attr: gpioV2LineAttribute{Id: gpioV2LineAttrIdOutputValues, Value: initial},
+			Mask: 1,
+		}
+		req.Config.NumAttrs++
+	} else {
+		req.Config.Flags |= gpioV2LineFlagInput
+		switch config.Bias {
+		case GpiodBiasPullUp:
+			req.Config.Flags |= gpioV2LineFlagBiasPullUp
+		case GpiodBiasPullDown:
+			req.Config.Flags |= gpioV2LineFlagBiasPullDown
+		case GpiodBiasDisabled:
+			req.Config.Flags |= gpioV2LineFlagBiasDisabled
+		}
+		if config.EdgeDetection {
+			req.Config.Flags |= gpioV2LineFlagEdgeRising | gpioV2LineFlagEdgeFalling
+		}
+		if config.Debounce > 0 {
+			req.Config.Attrs[req.Config.NumAttrs] = gpioV2LineConfigAttribute{
+				Attr: gpioV2LineAttribute{Id: gpioV2LineAttrIdDebounce, Value: uint64(config.Debounce.Microseconds())},
+				Mask: 1,
+			}
+			req.Config.NumAttrs++
+		}
+	}
+
+	err := gpioIoctl(gc.file.Fd(), gpioV2GetLineIoctl, unsafe.Pointer(&req))
+	if err != nil {
+		return nil, errors.Wrap(err, "GPIO_V2_GET_LINE ioctl failed")
+	}
+
+	// non blocking fd goes to runtime poller, so Close unblocks pending ReadEvent
+	err = unix.SetNonblock(int(req.Fd), true)
+	if err != nil {
+		unix.Close(int(req.Fd))
+		return nil, errors.Wrap(err, "failed to set line fd non blocking")
+	}
+
+	return &gpiodCharDevLine{file: os.NewFile(uintptr(req.Fd), "gpio-line")}, nil
+}
+
+func (gc *gpiodCharDevChip) Close() error {
+	return gc.file.Close()
+}
+
+type gpiodCharDevLine struct {
+	file *os.File
+}
+
+func (gl *gpiodCharDevLine) GetValue() (bool, error) {
+	values := gpioV2LineValues{Mask: 1}
+
+	conn, err := gl.file.SyscallConn()
+	if err != nil {
+		return false, err
+	}
+	var ioctlErr error
+	err = conn.Control(func(fd uintptr) {
+		ioctlErr = gpioIoctl(fd, gpioV2LineGetValuesIoctl, unsafe.Pointer(&values))
+	})
+	if err != nil {
+		return false, err
+	}
+	if ioctlErr != nil {
+		return false, errors.Wrap(ioctlErr, "GPIO_V2_LINE_GET_VALUES ioctl failed")
+	}
+
+	return values.Bits&1 == 1, nil
+}
+
+func (gl *gpiodCharDevLine) SetValue(state bool) error {
+	values := gpioV2LineValues{Mask: 1}
+	if state {
+		values.Bits = 1
+	}
+
+	conn, err := gl.file.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var ioctlErr error
+	err = conn.Control(func(fd uintptr) {
+		ioctlErr = gpioIoctl(fd, gpioV2LineSetValuesIoctl, unsafe.Pointer(&values))
+	})
+	if err != nil {
+		return err
+	}
+	if ioctlErr != nil {
+		return errors.Wrap(ioctlErr, "GPIO_V2_LINE_SET_VALUES ioctl failed")
+	}
+	return nil
+}
+
+func (gl *gpiodCharDevLine) ReadEvent() (event gpiodEdgeEvent, err error) {
+	buf := make([]byte, gpioV2LineEventSize)
+	_, err = io.ReadFull(gl.file, buf)
+	if err != nil {
+		return
+	}
+
+	// kernel reports CLOCK_MONOTONIC timestamps, push detection needs only intervals,
+	// so event is stamped with wall clock at the moment it was read
+	event.Rising = binary.NativeEndian.Uint32(buf[8:12]) == gpioV2LineEventRisingEdge
+	event.Timestamp = time.Now()
+	return
+}
+
+func (gl *gpiodCharDevLine) Close() error {
+	return gl.file.Close()
+}
@@ -0,0 +1,26 @@
+//go:build linux
+
+package drivers
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestGpiodUapiStructSizes(t *testing.T) {
+	sizes := map[string][2]uintptr{
+		"gpio_v2_line_values":  {unsafe.Sizeof(gpioV2LineValues{}), 16},
+		"gpio_v2_line_config":  {unsafe.Sizeof(gpioV2LineConfig{}), 272},
+		"gpio_v2_line_request": {unsafe.Sizeof(gpioV2LineRequest{}), 592},
+	}
+
+	for name, size := range sizes {
+		if size[0] != size[1] {
+			t.Errorf("%s size mismatch, got %d want %d", name, size[0], size[1])
+		}
+	}
+
+	if gpioV2GetLineIoctl != 0xC250B407 {
+		t.Errorf("GPIO_V2_GET_LINE_IOCTL mismatch, got %X", gpioV2GetLineIoctl)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package drivers
+
+import "github.com/pkg/errors"
+
+func openGpiodChip(path string) (gpiodChip, error) {
+	return nil, errors.Errorf("cannot open %s, gpio character device is supported only on linux", path)
+}
@@ -0,0 +1,358 @@
+package drivers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const gpiodDriverName = "gpiod"
+const gpiodDefaultChip = "gpiochip0"
+const gpiodDefaultConsumer = "swkit"
+const gpiodPushTickInterval = 50 * time.Millisecond
+
+const (
+	GpiodBiasPullUp   = "pull_up"
+	GpiodBiasPullDown = "pull_down"
+	GpiodBiasDisabled = "disabled"
+	GpiodBiasAsIs     = "as_is"
+)
+
+type gpiodLineConfig struct {
+	Output        bool
+	ActiveLow     bool
+	Bias          string
+	Debounce      time.Duration
+	EdgeDetection bool
+	InitialValue  bool
+}
+
+type gpiodEdgeEvent struct {
+	Rising    bool
+	Timestamp time.Time
+}
+
+// gpiodChip is a gpio character device, on linux it is /dev/gpiochipN (see gpiod_chip_linux.go),
+// it can be replaced with a fake one in tests.
+type gpiodChip interface {
+	RequestLine(offset uint32, consumer string, config gpiodLineConfig) (gpiodLine, error)
+	Close() error
+}
+
+type gpiodLine interface {
+	GetValue() (bool, error)
+	SetValue(bool) error
+	// ReadEvent blocks until edge event arrives or the line is closed.
+	ReadEvent() (gpiodEdgeEvent, error)
+	Close() error
+}
+
+type GpiodInput struct {
+	pin  uint16
+	line gpiodLine
+	push pushDetector
+}
+
+func (gdi *GpiodInput) GetState() (bool, error) {
+	return gdi.line.GetValue()
+}
+
+func (gdi *GpiodInput) SubscribeToPushEvent(listener EventListener) error {
+	gdi.push.SetListener(listener)
+	return nil
+}
+
+func (gdi *GpiodInput) watchEdges() {
+	for {
+		event, err := gdi.line.ReadEvent()
+		if err != nil {
+			return
+		}
+		gdi.push.Update(event.Rising, event.Timestamp)
+	}
+}
+
+type GpiodOutput struct {
+	pin  uint16
+	line gpiodLine
+}
+
+func (gdo *GpiodOutput) GetState() (bool, error) {
+	return gdo.line.GetValue()
+}
+
+func (gdo *GpiodOutput) Set(state bool) error {
+	return gdo.line.SetValue(state)
+}
+
+// GpiodIO drives gpio lines through linux gpio character device (uAPI v2), so it works on any board
+// exposing /dev/gpiochipN. Pin is a line offset on the Chip. Inputs get edge detection and kernel debounce.
+type GpiodIO struct {
+	Chip             string
+	Consumer         string
+	InvertInputs     bool
+	InvertOutputs    bool
+	InputBias        string
+	DebounceDuration string
+
+	inputs  []*GpiodInput
+	outputs []*GpiodOutput
+	chip    gpiodChip
+	done    chan bool
+	isReady bool
+	lock    sync.Mutex
+
+	openChip func(path string) (gpiodChip, error)
+}
+
+func (gd *GpiodIO) getChipPath() string {
+	if len(gd.Chip) == 0 {
+		return "/dev/" + gpiodDefaultChip
+	}
+	if strings.HasPrefix(gd.Chip, "/") {
+		return gd.Chip
+	}
+	return "/dev/" + gd.Chip
+}
+
+func (gd *GpiodIO) getInputConfig() (config gpiodLineConfig, err error) {
+	config.ActiveLow = gd.InvertInputs
+	config.EdgeDetection = true
+
+	switch strings.ToLower(gd.InputBias) {
+	case "", GpiodBiasPullUp:
+		config.Bias = GpiodBiasPullUp
+	case GpiodBiasPullDown, GpiodBiasDisabled, GpiodBiasAsIs:
+		config.Bias = strings.ToLower(gd.InputBias)
+	default:
+		err = errors.Errorf("unsupported input bias (%s)", gd.InputBias)
+		return
+	}
+
+	if len(gd.DebounceDuration) > 0 {
+		config.Debounce, err = time.ParseDuration(gd.DebounceDuration)
+		if err != nil {
+			err = errors.Wrap(err, "parsing DebounceDuration failed")
+		}
+	}
+	return
+}
+
+func (gd *GpiodIO) getConsumer() string {
+	if len(gd.Consumer) == 0 {
+		return gpiodDefaultConsumer
+	}
+	return gd.Consumer
+}
+
+func (gd *GpiodIO) Setup(ctx context.Context, inputs []uint16, outputs []uint16) (err error) {
+	inConfig, err := gd.getInputConfig()
+	if err != nil {
+		return errors.Wrap(err, "gpiod config error")
+	}
+
+	openChip := gd.openChip
+	if openChip == nil {
+		openChip = openGpiodChip
+	}
+	gd.chip, err = openChip(gd.getChipPath())
+	if err != nil {
+		return errors.Wrapf(err, "failed to open gpio chip %s", gd.getChipPath())
+	}
+
+	consumer := gd.getConsumer()
+
+	gd.inputs = []*GpiodInput{}
+	gd.outputs = []*GpiodOutput{}
+	defer func() {
+		if err != nil {
+			gd.releaseLines()
+			gd.chip.Close()
+		}
+	}()
+
+	for _, inPin := range inputs {
+		line, lineErr := gd.chip.RequestLine(uint32(inPin), consumer, inConfig)
+		if lineErr != nil {
+			err = errors.Wrapf(lineErr, "failed to request input line %d", inPin)
+			return
+		}
+		gd.inputs = append(gd.inputs, &GpiodInput{pin: inPin, line: line})
+	}
+
+	for _, outPin := range outputs {
+		line, lineErr := gd.chip.RequestLine(uint32(outPin), consumer, gpiodLineConfig{Output: true, ActiveLow: gd.InvertOutputs})
+		if lineErr != nil {
+			err = errors.Wrapf(lineErr, "failed to request output line %d", outPin)
+			return
+		}
+		gd.outputs = append(gd.outputs, &GpiodOutput{pin: outPin, line: line})
+	}
+
+	gd.done = make(chan bool)
+	for _, in := range gd.inputs {
+		go in.watchEdges()
+	}
+	go gd.tickPushDetectors(ctx, gd.done)
+
+	gd.isReady = true
+	return
+}
+
+func (gd *GpiodIO) tickPushDetectors(ctx context.Context, done chan bool) {
+	ticker := time.NewTicker(gpiodPushTickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			gd.lock.Lock()
+			inputs := gd.inputs
+			gd.lock.Unlock()
+			for _, in := range inputs {
+				in.push.Tick(now)
+			}
+		}
+	}
+}
+
+func (gd *GpiodIO) releaseLines() {
+	for _, in := range gd.inputs {
+		in.line.Close()
+	}
+	for _, out := range gd.outputs {
+		out.line.Close()
+	}
+}
+
+func (gd *GpiodIO) NameId() string {
+	return gpiodDriverName
+}
+
+func (gd *GpiodIO) IsReady() bool {
+	return gd.isReady
+}
+
+func (gd *GpiodIO) Close() error {
+	if !gd.isReady {
+		return nil
+	}
+	gd.isReady = false
+	close(gd.done)
+
+	for _, output := range gd.outputs {
+		err := output.Set(false)
+		if err != nil {
+			log.Printf("gpiod | failed to switch off output %d: %v", output.pin, err)
+		}
+	}
+	gd.releaseLines()
+
+	return gd.chip.Close()
+}
+
+func (gd *GpiodIO) GetInput(pin uint16) (DigitalInput, error) {
+	for _, in := range gd.inputs {
+		if in.pin == pin {
+			return in, nil
+		}
+	}
+	return nil, fmt.Errorf("gpiod input (line: %d) not found", pin)
+}
+
+func (gd *GpiodIO) GetOutput(pin uint16) (DigitalOutput, error) {
+	for _, out := range gd.outputs {
+		if out.pin == pin {
+			return out, nil
+		}
+	}
+	return nil, fmt.Errorf("gpiod output (line: %d) not found", pin)
+}
+
+func (gd *GpiodIO) GetAllIo() (inputs []uint16, outputs []uint16) {
+	for _, in := range gd.inputs {
+		inputs = append(inputs, in.pin)
+	}
+	for _, out := range gd.outputs {
+		outputs = append(outputs, out.pin)
+	}
+	return
+}
+
+// Reconfigure requests lines of added pins and releases lines of removed ones (outputs are switched off first),
+// lines of other pins are not touched.
+func (gd *GpiodIO) Reconfigure(ctx context.Context, next IoDriver, inputs []uint16, outputs []uint16) error {
+	err := checkReconfigure(gd, next)
+	if err != nil {
+		return err
+	}
+	inConfig, err := gd.getInputConfig()
+	if err != nil {
+		return errors.Wrap(err, "gpiod config error")
+	}
+
+	keptInputs := []*GpiodInput{}
+	for _, in := range gd.inputs {
+		if hasPin(inputs, in.pin) {
+			keptInputs = append(keptInputs, in)
+		} else {
+			in.line.Close()
+		}
+	}
+	keptOutputs := []*GpiodOutput{}
+	for _, out := range gd.outputs {
+		if hasPin(outputs, out.pin) {
+			keptOutputs = append(keptOutputs, out)
+			continue
+		}
+		err = out.Set(false)
+		if err != nil {
+			log.Printf("gpiod | failed to switch off output %d: %v", out.pin, err)
+		}
+		out.line.Close()
+	}
+	gd.lock.Lock()
+	currentInputs, currentOutputs := gd.GetAllIo()
+	gd.inputs = keptInputs
+	gd.outputs = keptOutputs
+	gd.lock.Unlock()
+
+	for _, inPin := range inputs {
+		if hasPin(currentInputs, inPin) {
+			continue
+		}
+		line, err := gd.chip.RequestLine(uint32(inPin), gd.getConsumer(), inConfig)
+		if err != nil {
+			return errors.Wrapf(err, "failed to request input line %d", inPin)
+		}
+		in := &GpiodInput{pin: inPin, line: line}
+		gd.lock.Lock()
+		gd.inputs = append(gd.inputs, in)
+		gd.lock.Unlock()
+		go in.watchEdges()
+	}
+
+	for _, outPin := range outputs {
+		if hasPin(currentOutputs, outPin) {
+			continue
+		}
+		line, err := gd.chip.RequestLine(uint32(outPin), gd.getConsumer(), gpiodLineConfig{Output: true, ActiveLow: gd.InvertOutputs})
+		if err != nil {
+			return errors.Wrapf(err, "failed to request output line %d", outPin)
+		}
+		gd.lock.Lock()
+		gd.outputs = append(gd.outputs, &GpiodOutput{pin: outPin, line: line})
+		gd.lock.Unlock()
+	}
+
+	return nil
+}
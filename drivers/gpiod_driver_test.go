@@ -0,0 +1,213 @@
+package drivers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeGpiodLine struct {
+	offset uint32
+	config gpiodLineConfig
+	value  bool
+	events chan gpiodEdgeEvent
+	closed chan bool
+	lock   sync.Mutex
+}
+
+func (fl *fakeGpiodLine) GetValue() (bool, error) {
+	fl.lock.Lock()
+	defer fl.lock.Unlock()
+	return fl.value, nil
+}
+
+func (fl *fakeGpiodLine) SetValue(state bool) error {
+	fl.lock.Lock()
+	defer fl.lock.Unlock()
+	fl.value = state
+	return nil
+}
+
+func (fl *fakeGpiodLine) ReadEvent() (gpiodEdgeEvent, error) {
+	select {
+	case event := <-fl.events:
+		return event, nil
+	case <-fl.closed:
+		return gpiodEdgeEvent{}, errors.New("line closed")
+	}
+}
+
+func (fl *fakeGpiodLine) Close() error {
+	close(fl.closed)
+	return nil
+}
+
+func (fl *fakeGpiodLine) edge(rising bool, at time.Time) {
+	fl.SetValue(rising)
+	fl.events <- gpiodEdgeEvent{Rising: rising, Timestamp: at}
+}
+
+type fakeGpiodChip struct {
+	path  string
+	lines map[uint32]*fakeGpiodLine
+	max   uint32
+}
+
+func (fc *fakeGpiodChip) RequestLine(offset uint32, consumer string, config gpiodLineConfig) (gpiodLine, error) {
+	if offset >= fc.max {
+		return nil, errors.New("invalid argument")
+	}
+	if _, busy := fc.lines[offset]; busy {
+		return nil, errors.New("device or resource busy")
+	}
+	line := &fakeGpiodLine{offset: offset, config: config, events: make(chan gpiodEdgeEvent), closed: make(chan bool)}
+	fc.lines[offset] = line
+	return line, nil
+}
+
+func (fc *fakeGpiodChip) Close() error {
+	return nil
+}
+
+func newFakeGpiodIO(chip *fakeGpiodChip) *GpiodIO {
+	return &GpiodIO{
+		openChip: func(path string) (gpiodChip, error) {
+			chip.path = path
+			return chip, nil
+		},
+	}
+}
+
+func TestGpiodSetup(t *testing.T) {
+	chip := &fakeGpiodChip{lines: map[uint32]*fakeGpiodLine{}, max: 28}
+	gd := newFakeGpiodIO(chip)
+	gd.Chip = "gpiochip4"
+	gd.InvertOutputs = true
+	gd.DebounceDuration = "15ms"
+
+	err := gd.Setup(context.Background(), []uint16{5, 30}, []uint16{17})
+	if err == nil {
+		t.Error("expected error from setup with line out of range")
+	}
+	assertBools(t, gd.IsReady(), false)
+
+	chip.lines = map[uint32]*fakeGpiodLine{}
+	gd.InputBias = "floating"
+	err = gd.Setup(context.Background(), []uint16{5}, []uint16{17})
+	if err == nil {
+		t.Error("expected error from setup with unsupported bias")
+	}
+
+	gd.InputBias = GpiodBiasPullDown
+	err = gd.Setup(context.Background(), []uint16{5, 6}, []uint16{17})
+	if err != nil {
+		t.Fatalf("received error from setup: %v", err)
+	}
+	defer gd.Close()
+
+	if chip.path != "/dev/gpiochip4" {
+		t.Errorf("got chip path %s, want /dev/gpiochip4", chip.path)
+	}
+
+	inputs, outputs := gd.GetAllIo()
+	assertUint16Slices(t, inputs, []uint16{5, 6})
+	assertUint16Slices(t, outputs, []uint16{17})
+
+	inConfig := chip.lines[5].config
+	if inConfig.Output || inConfig.Bias != GpiodBiasPullDown || !inConfig.EdgeDetection || inConfig.Debounce != 15*time.Millisecond {
+		t.Errorf("unexpected input line config: %+v", inConfig)
+	}
+	outConfig := chip.lines[17].config
+	if !outConfig.Output || !outConfig.ActiveLow {
+		t.Errorf("unexpected output line config: %+v", outConfig)
+	}
+
+	out, err := gd.GetOutput(17)
+	if err != nil {
+		t.Fatalf("output not found: %v", err)
+	}
+	out.Set(true)
+	state, _ := out.GetState()
+	assertBools(t, state, true)
+
+	_, err = gd.GetInput(17)
+	if err == nil {
+		t.Error("expected error when getting output line as input")
+	}
+}
+
+func TestGpiodPushEvents(t *testing.T) {
+	chip := &fakeGpiodChip{lines: map[uint32]*fakeGpiodLine{}, max: 28}
+	gd := newFakeGpiodIO(chip)
+
+	err := gd.Setup(context.Background(), []uint16{5}, []uint16{})
+	if err != nil {
+		t.Fatalf("received error from setup: %v", err)
+	}
+	defer gd.Close()
+
+	in, _ := gd.GetInput(5)
+	recorder := &eventRecorder{}
+	in.SubscribeToPushEvent(recorder)
+
+	line := chip.lines[5]
+	now := time.Now()
+	line.edge(true, now)
+	line.edge(false, now.Add(80*time.Millisecond))
+	line.edge(true, now.Add(160*time.Millisecond))
+	line.edge(false, now.Add(240*time.Millisecond))
+
+	state, _ := in.GetState()
+	assertBools(t, state, false)
+
+	deadline := time.Now().Add(time.Second)
+	for len(recorder.getEvents()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	events := recorder.getEvents()
+	if len(events) != 1 || events[0] != PushEventDoublePress {
+		t.Errorf("expected double press event, got %v", events)
+	}
+}
+
+func TestGpiodReconfigure(t *testing.T) {
+	chip := &fakeGpiodChip{lines: map[uint32]*fakeGpiodLine{}, max: 28}
+	gd := newFakeGpiodIO(chip)
+	gd.InvertOutputs = true
+
+	err := gd.Setup(context.Background(), []uint16{5}, []uint16{17, 18})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gd.Close()
+	out17, _ := gd.GetOutput(17)
+	out18, _ := gd.GetOutput(18)
+	out17.Set(true)
+	out18.Set(true)
+
+	err = gd.Reconfigure(context.Background(), &GpiodIO{InvertOutputs: true}, []uint16{5, 6}, []uint16{17})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputs, outputs := gd.GetAllIo()
+	assertUint16Slices(t, inputs, []uint16{5, 6})
+	assertUint16Slices(t, outputs, []uint16{17})
+
+	// line of output 17 is not requested again and stays on, removed output 18 is switched off and released
+	state, _ := out17.GetState()
+	assertBools(t, state, true)
+	assertBools(t, chip.lines[18].value, false)
+	select {
+	case <-chip.lines[18].closed:
+	default:
+		t.Error("line of removed output 18 was not released")
+	}
+	if chip.lines[6] == nil || chip.lines[6].config.Output {
+		t.Error("line of added input 6 was not requested as input")
+	}
+
+	err = gd.Reconfigure(context.Background(), &GpiodIO{}, []uint16{5, 6}, []uint16{17})
+	assertBools(t, err != nil, true)
+}
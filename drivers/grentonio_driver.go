@@ -2,33 +2,118 @@ package drivers
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
+	"net"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/julienschmidt/httprouter"
 	"github.com/pkg/errors"
 )
 
 const grentonioDriverName = "grenton"
 const grentonNetClientTimeout = 4500 * time.Millisecond
 const grentonSetStateWaitForCheck = 900 * time.Millisecond
+const grentonCoalesceWindow = 40 * time.Millisecond
 const grentonObjectFreshness = 20 * time.Second
+const grentonInputPollInterval = 200 * time.Millisecond
+const grentonPushTickInterval = 50 * time.Millisecond
+const grentonEventHttpTimeout = 3 * time.Second
+
+const grentonInputPrefix = "DIN"
+const grentonInputKind = "Input"
+const grentonCmdSet = "SET"
+const grentonCmdStop = "STOP"
+
+type GrentonKind string
+
+const (
+	GrentonKindLight         GrentonKind = "Light"
+	GrentonKindDimmer        GrentonKind = "Dimmer"
+	GrentonKindRollerShutter GrentonKind = "RollerShutter"
+	GrentonKindLedRgb        GrentonKind = "LedRgb"
+)
+
+var grentonKindPrefixes = map[GrentonKind]string{
+	GrentonKindLight:         "DOU",
+	GrentonKindDimmer:        "DIM",
+	GrentonKindRollerShutter: "ROL",
+	GrentonKindLedRgb:        "LED",
+}
+
+func parseGrentonKind(kind string) (GrentonKind, error) {
+	if len(kind) == 0 {
+		return GrentonKindLight, nil
+	}
+	for known := range grentonKindPrefixes {
+		if strings.EqualFold(string(known), kind) {
+			return known, nil
+		}
+	}
+	return "", errors.Errorf("unsupported grenton object kind (%s)", kind)
+}
+
+// GrentonObjectConfig describes outputs which are not plain DOU relays.
+// Id is optional, when empty it is made of kind prefix and pin (e.g. DIM0002).
+type GrentonObjectConfig struct {
+	Pin  uint16
+	Kind string
+	Id   string
+}
+
+// grentonValue holds every value the gate reports for supported object kinds,
+// Brightness, Position and Saturation are in 0-100 range, Hue in 0-360.
+type grentonValue struct {
+	State      bool
+	Brightness int
+	Position   int
+	Movement   int
+	Hue        int
+	Saturation int
+}
 
 type GrentonOutput struct {
 	Grenton *GrentonIO
 
-	state       bool
+	value       grentonValue
 	refreshedAt time.Time
 	id          uint16
+	kind        GrentonKind
+	objectId    string
+
+	expected *grentonValue
+	verifyAt time.Time
+	fault    error
+	lock     sync.Mutex
+}
+
+func (gro *GrentonOutput) getKind() GrentonKind {
+	if len(gro.kind) == 0 {
+		return GrentonKindLight
+	}
+	return gro.kind
+}
+
+func (gro *GrentonOutput) getObjectId() string {
+	if len(gro.objectId) > 0 {
+		return gro.objectId
+	}
+	return fmt.Sprintf("%s%04d", grentonKindPrefixes[gro.getKind()], gro.id)
 }
 
 func (gro *GrentonOutput) checkFreshness() (time.Duration, error) {
+	gro.lock.Lock()
+	defer gro.lock.Unlock()
+
 	if gro.refreshedAt.IsZero() {
 		return 0, errors.Errorf("output was not yet refreshed")
 	}
@@ -36,100 +121,295 @@ func (gro *GrentonOutput) checkFreshness() (time.Duration, error) {
 	return time.Since(gro.refreshedAt), nil
 }
 
-func (gro *GrentonOutput) GetState() (bool, error) {
-	if time.Since(gro.refreshedAt) > grentonObjectFreshness {
+// refresh updates state from the gate when it is older than object freshness and returns current value.
+func (gro *GrentonOutput) refresh() (grentonValue, error) {
+	gro.lock.Lock()
+	stale := time.Since(gro.refreshedAt) > gro.Grenton.objectFreshness
+	gro.lock.Unlock()
+
+	if stale {
 		err := gro.Grenton.updateState()
 		if err != nil {
-			return false, errors.Wrap(err, "failed to refresh state")
+			return grentonValue{}, errors.Wrap(err, "failed to refresh state")
 		}
 	}
-	return gro.state, nil
+
+	gro.lock.Lock()
+	defer gro.lock.Unlock()
+	return gro.value, nil
+}
+
+func (gro *GrentonOutput) stateOf(value grentonValue) bool {
+	if gro.getKind() == GrentonKindRollerShutter {
+		return value.Position > 0
+	}
+	return value.State
+}
+
+// GetState returns the fault reported by set verification, until the output
+// is set again or the gate reports the expected state.
+func (gro *GrentonOutput) GetState() (bool, error) {
+	value, err := gro.refresh()
+	if err != nil {
+		return false, err
+	}
+
+	gro.lock.Lock()
+	defer gro.lock.Unlock()
+	return gro.stateOf(value), gro.fault
+}
+
+// verify compares gate reported value against the last value set, roller shutter
+// still moving is not yet a mismatch, so it stays expected. Must be called with lock held.
+func (gro *GrentonOutput) verify(now time.Time) {
+	if gro.expected == nil || now.Before(gro.verifyAt) {
+		return
+	}
+
+	want := *gro.expected
+	got := gro.value
+
+	matched := got.State == want.State
+	switch gro.getKind() {
+	case GrentonKindDimmer:
+		matched = matched && (!want.State || got.Brightness == want.Brightness)
+	case GrentonKindLedRgb:
+		matched = matched && (!want.State || (got.Brightness == want.Brightness && got.Hue == want.Hue && got.Saturation == want.Saturation))
+	case GrentonKindRollerShutter:
+		if got.Movement != 0 {
+			return
+		}
+		matched = got.Position == want.Position
+	}
+
+	if matched {
+		gro.expected = nil
+		gro.fault = nil
+	} else {
+		gro.fault = errors.Errorf("state mismatch after setting %s (want: %+v, got: %+v)", gro.getObjectId(), want, got)
+	}
 }
 
 func (gro *GrentonOutput) Set(state bool) error {
-	currentState, err := gro.GetState()
+	value, err := gro.refresh()
 	if err != nil {
 		return errors.Wrap(err, "received error when getting current state")
 	}
 
-	if currentState == state {
+	if gro.stateOf(value) == state {
 		return nil
 	}
 
-	err = gro.Grenton.setState(state, gro)
+	value.State = state
+	if gro.getKind() == GrentonKindRollerShutter {
+		value.Position = 0
+		if state {
+			value.Position = 100
+		}
+	}
+
+	err = gro.Grenton.setObject(gro, grentonCmdSet, value)
 	if err != nil {
-		return errors.Wrap(err, "grenton setState returned error")
+		return errors.Wrap(err, "grenton setObject returned error")
 	}
 
-	// go func() {
-	// 	time.Sleep(grentonSetStateWaitForCheck)
+	return nil
+}
+
+type GrentonInput struct {
+	Grenton *GrentonIO
+
+	state       bool
+	refreshedAt time.Time
+	id          uint16
+	push        pushDetector
+	lock        sync.Mutex
+}
 
-	// 	err := gro.Grenton.updateState()
-	// 	if err != nil {
-	// 		err = errors.Wrap(err, "grenton returned error during refresh after setting new state")
-	// 	}
+func (gri *GrentonInput) getObjectId() string {
+	return fmt.Sprintf("%s%04d", grentonInputPrefix, gri.id)
+}
+
+func (gri *GrentonInput) checkFreshness() (time.Duration, error) {
+	gri.lock.Lock()
+	defer gri.lock.Unlock()
 
-	// }()
-	// if gro.state != state {
-	// 	return errors.Errorf("state mismatch after setting new state (want: %v, got: %v)", state, gro.state)
-	// }
+	if gri.refreshedAt.IsZero() {
+		return 0, errors.Errorf("input was not yet refreshed")
+	}
 
+	return time.Since(gri.refreshedAt), nil
+}
+
+func (gri *GrentonInput) GetState() (bool, error) {
+	gri.lock.Lock()
+	stale := time.Since(gri.refreshedAt) > gri.Grenton.objectFreshness
+	gri.lock.Unlock()
+
+	if stale {
+		err := gri.Grenton.updateState()
+		if err != nil {
+			return false, errors.Wrap(err, "failed to refresh state")
+		}
+	}
+
+	gri.lock.Lock()
+	defer gri.lock.Unlock()
+	return gri.state, nil
+}
+
+func (gri *GrentonInput) SubscribeToPushEvent(listener EventListener) error {
+	gri.push.SetListener(listener)
 	return nil
 }
 
+func (gri *GrentonInput) setState(state bool, at time.Time) {
+	gri.lock.Lock()
+	gri.state = state
+	gri.refreshedAt = at
+	gri.lock.Unlock()
+
+	gri.push.Update(state, at)
+}
+
 type GrentonIO struct {
 	GateAddress string
 	CluId       uint32
 
 	ObjectFreshnessDuration string
 
+	Objects []GrentonObjectConfig
+
+	CoalesceDuration string
+
+	InputPollInterval string
+	EventListenAddr   string
+	EventToken        string
+
 	setUrl          *url.URL
 	getUrl          *url.URL
+	multiSetUrl     *url.URL
 	ready           bool
 	outputs         []*GrentonOutput
+	inputs          []*GrentonInput
+	pinsLock        sync.Mutex
 	gateLock        *sync.Mutex
 	objectFreshness time.Duration
+	pollInterval    time.Duration
+	eventServer     *http.Server
+	done            chan bool
+	watching        sync.WaitGroup
+
+	queueLock      sync.Mutex
+	batch          *grentonBatch
+	coalesceWindow time.Duration
+	setCheckWait   time.Duration
+}
+
+type grentonObject struct {
+	Kind string
+	Clu  string
+	Id   string
+}
+
+// grentonObjectStatus is the gate representation of an object, value is kept under the kind key.
+type grentonObjectStatus struct {
+	Kind          string
+	Clu           string
+	Id            string
+	Light         grentonValue
+	Dimmer        grentonValue
+	RollerShutter grentonValue
+	LedRgb        grentonValue
+	Input         grentonValue
+}
+
+func (gos *grentonObjectStatus) getValue(kind GrentonKind) grentonValue {
+	switch kind {
+	case GrentonKindDimmer:
+		return gos.Dimmer
+	case GrentonKindRollerShutter:
+		return gos.RollerShutter
+	case GrentonKindLedRgb:
+		return gos.LedRgb
+	default:
+		return gos.Light
+	}
 }
 
 func (gio *GrentonIO) getCluString() string {
 	return fmt.Sprintf("CLU_%08x", gio.CluId)
 }
 
-func (gio *GrentonIO) getQueryBody() (b []byte) {
-	type GrentonObject struct {
-		Kind string
-		Clu  string
-		Id   string
-	}
+// getPins returns inputs and outputs, which are changed by Reconfigure.
+func (gio *GrentonIO) getPins() ([]*GrentonInput, []*GrentonOutput) {
+	gio.pinsLock.Lock()
+	defer gio.pinsLock.Unlock()
 
-	grentonSet := []GrentonObject{}
+	return gio.inputs, gio.outputs
+}
 
-	for _, out := range gio.outputs {
-		grentonSet = append(grentonSet, GrentonObject{"Light", gio.getCluString(), fmt.Sprintf("DOU%04d", out.id)})
+func (gio *GrentonIO) getOutputObjects() (objects []grentonObject) {
+	_, outputs := gio.getPins()
+	for _, out := range outputs {
+		objects = append(objects, grentonObject{string(out.getKind()), gio.getCluString(), out.getObjectId()})
+	}
+	return
+}
+
+func (gio *GrentonIO) getInputObjects() (objects []grentonObject) {
+	inputs, _ := gio.getPins()
+	for _, in := range inputs {
+		objects = append(objects, grentonObject{grentonInputKind, gio.getCluString(), in.getObjectId()})
 	}
+	return
+}
+
+func (gio *GrentonIO) getQueryBody() (b []byte) {
+	grentonSet := append(gio.getOutputObjects(), gio.getInputObjects()...)
 
 	b, _ = json.Marshal(grentonSet)
 	return
 }
 
-func (gio *GrentonIO) getSetBody(state bool, id uint16) (b []byte) {
-	type GrentonObject struct {
-		Kind  string
-		Clu   string
-		Id    string
-		Cmd   string
-		Light struct {
-			State bool
-		}
+func (gio *GrentonIO) getSetObject(cmd string, output *GrentonOutput, value grentonValue) map[string]interface{} {
+	kind := output.getKind()
+
+	var payload map[string]interface{}
+	switch kind {
+	case GrentonKindDimmer:
+		payload = map[string]interface{}{"State": value.State, "Brightness": value.Brightness}
+	case GrentonKindRollerShutter:
+		payload = map[string]interface{}{"Position": value.Position}
+	case GrentonKindLedRgb:
+		payload = map[string]interface{}{"State": value.State, "Brightness": value.Brightness, "Hue": value.Hue, "Saturation": value.Saturation}
+	default:
+		payload = map[string]interface{}{"State": value.State}
 	}
 
-	objSet := GrentonObject{"Light", gio.getCluString(), fmt.Sprintf("DOU%04d", id), "SET", struct{ State bool }{state}}
+	return map[string]interface{}{
+		"Kind":       string(kind),
+		"Clu":        gio.getCluString(),
+		"Id":         output.getObjectId(),
+		"Cmd":        cmd,
+		string(kind): payload,
+	}
+}
 
-	b, _ = json.Marshal(objSet)
+func (gio *GrentonIO) getSetBody(cmd string, output *GrentonOutput, value grentonValue) (b []byte) {
+	b, _ = json.Marshal(gio.getSetObject(cmd, output, value))
 	return
 }
 
-func (gio *GrentonIO) updateState() (err error) {
+func (gio *GrentonIO) updateState() error {
+	return gio.readObjects(append(gio.getOutputObjects(), gio.getInputObjects()...))
+}
+
+func (gio *GrentonIO) updateInputs() error {
+	return gio.readObjects(gio.getInputObjects())
+}
+
+func (gio *GrentonIO) readObjects(objects []grentonObject) (err error) {
 	gio.gateLock.Lock()
 	defer gio.gateLock.Unlock()
 
@@ -137,8 +417,8 @@ func (gio *GrentonIO) updateState() (err error) {
 		Timeout: grentonNetClientTimeout,
 	}
 
-	bodyReader := strings.NewReader(string(gio.getQueryBody()))
-	req, err := http.NewRequest("POST", gio.getUrl.String(), bodyReader)
+	query, _ := json.Marshal(objects)
+	req, err := http.NewRequest("POST", gio.getUrl.String(), strings.NewReader(string(query)))
 	if err != nil {
 		err = errors.Wrap(err, "preparing request failed")
 		return
@@ -155,20 +435,11 @@ func (gio *GrentonIO) updateState() (err error) {
 
 	if response.StatusCode > 200 {
 		respBody, _ := io.ReadAll(response.Body)
-		err = errors.Errorf("grenton gate returned non success status code (%d),\n query:\n%s\nresponse:\n%s", response.StatusCode, gio.getQueryBody(), respBody)
+		err = errors.Errorf("grenton gate returned non success status code (%d),\n query:\n%s\nresponse:\n%s", response.StatusCode, query, respBody)
 		return
 	}
 
-	type GrentonObject struct {
-		Kind  string
-		Clu   string
-		Id    string
-		Light struct {
-			State bool
-		}
-	}
-
-	statusResponse := []GrentonObject{}
+	statusResponse := []grentonObjectStatus{}
 
 	decoder := json.NewDecoder(response.Body)
 	err = decoder.Decode(&statusResponse)
@@ -177,59 +448,59 @@ func (gio *GrentonIO) updateState() (err error) {
 		return
 	}
 
+	now := time.Now()
+	inputs, outputs := gio.getPins()
 	for _, obj := range statusResponse {
-		idInt, convertErr := strconv.ParseInt(strings.TrimLeft(obj.Id, "DOU"), 10, 16)
-		if convertErr != nil {
-			err = errors.Wrapf(convertErr, "failed to convert grenton object id (%s) to int", obj.Id)
-			return
+		for _, out := range outputs {
+			if strings.EqualFold(out.getObjectId(), obj.Id) {
+				out.lock.Lock()
+				out.refreshedAt = now
+				if out.expected == nil || !now.Before(out.verifyAt) {
+					out.value = obj.getValue(out.getKind())
+					out.verify(now)
+				}
+				out.lock.Unlock()
+			}
 		}
-		for _, out := range gio.outputs {
-			if out.id == uint16(idInt) {
-				out.state = obj.Light.State
-				out.refreshedAt = time.Now()
+		for _, in := range inputs {
+			if strings.EqualFold(in.getObjectId(), obj.Id) {
+				in.setState(obj.Input.State, now)
 			}
 		}
 	}
 
-	for _, out := range gio.outputs {
+	for _, out := range outputs {
 		_, refreshedErr := out.checkFreshness()
 		if refreshedErr != nil {
-			err = errors.Errorf("output %d wasn't refreshed during setup", out.id)
+			err = errors.Errorf("output %d wasn't refreshed by gate response", out.id)
 			return
 		}
 	}
-	return
-}
-
-func (gio *GrentonIO) setState(state bool, output *GrentonOutput) (err error) {
-	gio.gateLock.Lock()
-	defer gio.gateLock.Unlock()
-
-	var netClient = &http.Client{
-		Timeout: grentonNetClientTimeout,
-	}
-
-	bodyReader := strings.NewReader(string(gio.getSetBody(state, output.id)))
-	req, err := http.NewRequest("POST", gio.setUrl.String(), bodyReader)
-	if err != nil {
-		err = errors.Wrap(err, "preparing request failed")
-		return
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-
-	_, err = netClient.Do(req)
-	if err != nil {
-		err = errors.Wrap(err, "sending request failed")
-		return
+	for _, in := range inputs {
+		_, refreshedErr := in.checkFreshness()
+		if refreshedErr != nil {
+			err = errors.Errorf("input %d wasn't refreshed by gate response", in.id)
+			return
+		}
 	}
-
 	return
 }
 
 func (gio *GrentonIO) Setup(ctx context.Context, inputs []uint16, outputs []uint16) (err error) {
 	gio.ready = false
+	gio.stopWatching()
 	gio.gateLock = &sync.Mutex{}
+	gio.batch = nil
+	gio.setCheckWait = grentonSetStateWaitForCheck
+
+	gio.coalesceWindow = grentonCoalesceWindow
+	if len(gio.CoalesceDuration) > 0 {
+		gio.coalesceWindow, err = time.ParseDuration(gio.CoalesceDuration)
+		if err != nil {
+			err = errors.Wrap(err, "parsing CoalesceDuration failed")
+			return
+		}
+	}
 
 	gio.objectFreshness = grentonObjectFreshness
 	if len(gio.ObjectFreshnessDuration) > 0 {
@@ -255,21 +526,39 @@ func (gio *GrentonIO) Setup(ctx context.Context, inputs []uint16, outputs []uint
 		err = errors.Wrapf(err, "parsing url error")
 		return
 	}
-
-	if len(inputs) > 0 {
-		err = errors.Errorf("received inputs slice, grenton io not supports inputs")
+	gio.multiSetUrl, err = gateUrl.Parse("/multi/write/")
+	if err != nil {
+		err = errors.Wrapf(err, "parsing url error")
 		return
 	}
 
-	if len(outputs) == 0 {
-		err = errors.Errorf("received 0 length output slice, nothing to setup")
+	gio.pollInterval = grentonInputPollInterval
+	if len(gio.InputPollInterval) > 0 {
+		gio.pollInterval, err = time.ParseDuration(gio.InputPollInterval)
+		if err != nil {
+			err = errors.Wrap(err, "parsing InputPollInterval failed")
+			return
+		}
+	}
+
+	if len(inputs) == 0 && len(outputs) == 0 {
+		err = errors.Errorf("received 0 length input and output slices, nothing to setup")
 		return
 	}
 
 	gio.outputs = []*GrentonOutput{}
+	gio.inputs = []*GrentonInput{}
 
 	for _, outId := range outputs {
-		gio.outputs = append(gio.outputs, &GrentonOutput{id: outId, Grenton: gio})
+		var out *GrentonOutput
+		out, err = gio.newOutput(outId, gio.Objects)
+		if err != nil {
+			return
+		}
+		gio.outputs = append(gio.outputs, out)
+	}
+	for _, inId := range inputs {
+		gio.inputs = append(gio.inputs, &GrentonInput{id: inId, Grenton: gio})
 	}
 
 	err = gio.updateState()
@@ -278,15 +567,168 @@ func (gio *GrentonIO) Setup(ctx context.Context, inputs []uint16, outputs []uint
 		return
 	}
 
+	if len(gio.inputs) > 0 {
+		err = gio.startWatching(ctx)
+		if err != nil {
+			return
+		}
+	}
+
 	gio.ready = true
 
 	return
 }
 
-func (gio *GrentonIO) Close() error {
+// newOutput creates output of pin with kind and object id from objects (plain DOU light by default).
+func (gio *GrentonIO) newOutput(pin uint16, objects []GrentonObjectConfig) (*GrentonOutput, error) {
+	out := &GrentonOutput{id: pin, Grenton: gio, kind: GrentonKindLight}
+	for _, objConfig := range objects {
+		if objConfig.Pin == pin {
+			kind, err := parseGrentonKind(objConfig.Kind)
+			if err != nil {
+				return nil, errors.Wrapf(err, "output %d config error", pin)
+			}
+			out.kind = kind
+			out.objectId = objConfig.Id
+		}
+	}
+	return out, nil
+}
+
+// startWatching starts event server (when EventListenAddr is set) and input watcher.
+func (gio *GrentonIO) startWatching(ctx context.Context) error {
+	if len(gio.EventListenAddr) > 0 {
+		err := gio.startEventServer()
+		if err != nil {
+			return err
+		}
+	}
+	gio.done = make(chan bool)
+	gio.watching.Add(1)
+	go gio.watchInputs(ctx, gio.done)
+	return nil
+}
+
+// watchInputs keeps push detection of inputs running. Inputs are polled from the gate,
+// unless EventListenAddr is set - then their state is delivered by gate event webhooks.
+func (gio *GrentonIO) watchInputs(ctx context.Context, done chan bool) {
+	defer gio.watching.Done()
+
+	pushTicker := time.NewTicker(grentonPushTickInterval)
+	defer pushTicker.Stop()
+
+	var pollChan <-chan time.Time
+	if len(gio.EventListenAddr) == 0 {
+		pollTicker := time.NewTicker(gio.pollInterval)
+		defer pollTicker.Stop()
+		pollChan = pollTicker.C
+	}
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case now := <-pushTicker.C:
+			inputs, _ := gio.getPins()
+			for _, in := range inputs {
+				in.push.Tick(now)
+			}
+		case <-pollChan:
+			err := gio.updateInputs()
+			if err != nil {
+				log.Println("grenton | failed to poll inputs:", err)
+			}
+		}
+	}
+}
+
+func (gio *GrentonIO) startEventServer() error {
+	listener, err := net.Listen("tcp", gio.EventListenAddr)
+	if err != nil {
+		return errors.Wrap(err, "failed to listen for gate events")
+	}
+
+	handler := httprouter.New()
+	handler.GET("/input/:pin_no/event/:event/token/:token", gio.handleEvent)
+	handler.POST("/input/:pin_no/event/:event/token/:token", gio.handleEvent)
+
+	gio.eventServer = &http.Server{
+		Addr:              gio.EventListenAddr,
+		Handler:           handler,
+		ReadTimeout:       grentonEventHttpTimeout,
+		ReadHeaderTimeout: grentonEventHttpTimeout,
+		WriteTimeout:      grentonEventHttpTimeout,
+		IdleTimeout:       2 * grentonEventHttpTimeout,
+	}
+
+	go func(server *http.Server) {
+		err := server.Serve(listener)
+		if err != nil && err != http.ErrServerClosed {
+			log.Println("grenton | event server stopped:", err)
+		}
+	}(gio.eventServer)
+
 	return nil
 }
 
+func (gio *GrentonIO) handleEvent(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	if subtle.ConstantTimeCompare([]byte(p.ByName("token")), []byte(gio.EventToken)) != 1 {
+		http.Error(w, "token mismatch", http.StatusUnauthorized)
+		return
+	}
+
+	var input *GrentonInput
+	pinNo, _ := strconv.Atoi(p.ByName("pin_no"))
+
+	inputs, _ := gio.getPins()
+	for _, in := range inputs {
+		if in.id == uint16(pinNo) {
+			input = in
+		}
+	}
+
+	if input == nil {
+		http.Error(w, "input not found", http.StatusNotFound)
+		return
+	}
+
+	switch p.ByName("event") {
+	case "on":
+		input.setState(true, time.Now())
+	case "off":
+		input.setState(false, time.Now())
+	case "single":
+		input.push.Fire(PushEventSinglePress)
+	case "double":
+		input.push.Fire(PushEventDoublePress)
+	case "long":
+		input.push.Fire(PushEventLongPress)
+	default:
+		http.Error(w, "unrecognized input event type", http.StatusBadRequest)
+	}
+}
+
+func (gio *GrentonIO) Close() error {
+	gio.ready = false
+	return gio.stopWatching()
+}
+
+// stopWatching stops input watcher and event server started by Setup, waits for watcher to return.
+func (gio *GrentonIO) stopWatching() (err error) {
+	if gio.done != nil {
+		close(gio.done)
+		gio.done = nil
+	}
+	gio.watching.Wait()
+	if gio.eventServer != nil {
+		err = gio.eventServer.Close()
+		gio.eventServer = nil
+	}
+	return
+}
+
 func (gio *GrentonIO) NameId() string {
 	return grentonioDriverName
 }
@@ -296,22 +738,111 @@ func (gio *GrentonIO) IsReady() bool {
 }
 
 func (gio *GrentonIO) GetInput(pin uint16) (DigitalInput, error) {
-	return nil, errors.Errorf("grenton io not supports inputs")
+	for _, in := range gio.inputs {
+		if in.id == pin {
+			return in, nil
+		}
+	}
+	return nil, errors.Errorf("input id %d not found", pin)
 }
 
 func (gio *GrentonIO) GetOutput(pin uint16) (DigitalOutput, error) {
 	for _, out := range gio.outputs {
 		if out.id == pin {
-			return out, nil
+			switch out.getKind() {
+			case GrentonKindDimmer:
+				return &GrentonDimmer{out}, nil
+			case GrentonKindRollerShutter:
+				return &GrentonRollerShutter{out}, nil
+			case GrentonKindLedRgb:
+				return &GrentonLedRgb{out}, nil
+			default:
+				return out, nil
+			}
 		}
 	}
 	return nil, errors.Errorf("output id %d not found", pin)
 }
 
 func (gio *GrentonIO) GetAllIo() (inputs []uint16, outputs []uint16) {
+	for _, in := range gio.inputs {
+		inputs = append(inputs, in.id)
+	}
 	for _, out := range gio.outputs {
 		outputs = append(outputs, out.id)
 	}
 
 	return
 }
+
+// Reconfigure takes Objects of next, added objects are read from the gate before they are used, input watcher
+// is started with first inputs. Kind and id of pins present before and after can't be changed, removed outputs
+// are left as they are.
+func (gio *GrentonIO) Reconfigure(ctx context.Context, next IoDriver, inputs []uint16, outputs []uint16) error {
+	err := checkReconfigure(gio, next, "Objects")
+	if err != nil {
+		return err
+	}
+	nextGio := next.(*GrentonIO)
+
+	// listed pins not defined by next keep current definition
+	objects := append([]GrentonObjectConfig{}, nextGio.Objects...)
+	for _, obj := range gio.Objects {
+		if hasPin(outputs, obj.Pin) && !slices.ContainsFunc(objects, func(defined GrentonObjectConfig) bool { return defined.Pin == obj.Pin }) {
+			objects = append(objects, obj)
+		}
+	}
+
+	currentInputs, currentOutputs := gio.getPins()
+	keptOutputs := []*GrentonOutput{}
+	addedOutputs := []*GrentonOutput{}
+	for _, pin := range outputs {
+		out, err := gio.newOutput(pin, objects)
+		if err != nil {
+			return err
+		}
+		ix := slices.IndexFunc(currentOutputs, func(current *GrentonOutput) bool { return current.id == pin })
+		if ix < 0 {
+			keptOutputs = append(keptOutputs, out)
+			addedOutputs = append(addedOutputs, out)
+			continue
+		}
+		current := currentOutputs[ix]
+		if current.getKind() != out.getKind() || current.getObjectId() != out.getObjectId() {
+			return errors.Errorf("kind or id of grenton output %d can't be changed while used, restart needed", pin)
+		}
+		keptOutputs = append(keptOutputs, current)
+	}
+	keptInputs := []*GrentonInput{}
+	addedInputs := []*GrentonInput{}
+	for _, pin := range inputs {
+		ix := slices.IndexFunc(currentInputs, func(current *GrentonInput) bool { return current.id == pin })
+		if ix < 0 {
+			in := &GrentonInput{id: pin, Grenton: gio}
+			keptInputs = append(keptInputs, in)
+			addedInputs = append(addedInputs, in)
+			continue
+		}
+		keptInputs = append(keptInputs, currentInputs[ix])
+	}
+
+	if len(addedOutputs)+len(addedInputs) > 0 {
+		// added objects are read on their own, so running reads do not miss them
+		added := &GrentonIO{CluId: gio.CluId, getUrl: gio.getUrl, gateLock: gio.gateLock, outputs: addedOutputs, inputs: addedInputs}
+		err = added.updateState()
+		if err != nil {
+			return errors.Wrap(err, "error when updating grenton states")
+		}
+	}
+
+	gio.pinsLock.Lock()
+	gio.outputs = keptOutputs
+	gio.inputs = keptInputs
+	gio.pinsLock.Unlock()
+	gio.Objects = objects
+
+	if len(keptInputs) > 0 && gio.done == nil {
+		return gio.startWatching(ctx)
+	}
+	return nil
+}
@@ -1,76 +1,137 @@
 package drivers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
 )
 
+type mockGrentonObject struct {
+	kind  string
+	value map[string]interface{}
+	stuck bool
+}
+
+type mockGrentonStats struct {
+	sets      int32
+	multiSets int32
+}
+
+func mockGrentonObjects() map[string]*mockGrentonObject {
+	return map[string]*mockGrentonObject{
+		"DOU0302": {kind: "Light", value: map[string]interface{}{"State": true}},
+		"DOU0303": {kind: "Light", value: map[string]interface{}{"State": false}},
+		"DOU0304": {kind: "Light", value: map[string]interface{}{"State": false}, stuck: true},
+		"DIN0011": {kind: "Input", value: map[string]interface{}{"State": true}},
+		"DIM0002": {kind: "Dimmer", value: map[string]interface{}{"State": true, "Brightness": 40}},
+		"ROL0005": {kind: "RollerShutter", value: map[string]interface{}{"Position": 70, "Movement": 0}},
+		"LED0107": {kind: "LedRgb", value: map[string]interface{}{"State": true, "Brightness": 80, "Hue": 120, "Saturation": 50}},
+	}
+}
+
 func mockGrentonIo() *httptest.Server {
+	return mockGrentonIoWithObjects(mockGrentonObjects(), nil)
+}
+
+func mockGrentonIoWithObjects(objects map[string]*mockGrentonObject, stats *mockGrentonStats) *httptest.Server {
+	if stats == nil {
+		stats = &mockGrentonStats{}
+	}
+
+	type GrentonObject map[string]interface{}
+
+	cluId := "CLU_0d1cf087"
+
+	checkObject := func(w http.ResponseWriter, obj GrentonObject) *mockGrentonObject {
+		if !strings.EqualFold(fmt.Sprint(obj["Clu"]), cluId) {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(w, "clu name mismatch (got %s)", obj["Clu"])
+			return nil
+		}
+		mockObj, exist := objects[strings.ToUpper(fmt.Sprint(obj["Id"]))]
+		if !exist {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(w, "unsupported object id (got %s)", obj["Id"])
+			return nil
+		}
+		if !strings.EqualFold(fmt.Sprint(obj["Kind"]), mockObj.kind) {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(w, "unsupported object kind (got %s)", obj["Kind"])
+			return nil
+		}
+		return mockObj
+	}
+
 	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if !strings.EqualFold(r.Header.Get("Content-Type"), "application/json") {
 			w.WriteHeader(http.StatusNotImplemented)
 			return
 		}
+		defer r.Body.Close()
 
-		type GrentonObject struct {
-			Clu   string
-			Id    string
-			Kind  string
-			Cmd   string
-			Light struct {
-				State bool
+		if r.URL.Path == "/homebridge" || r.URL.Path == "/multi/write/" {
+			commands := []GrentonObject{}
+			var err error
+			if r.URL.Path == "/homebridge" {
+				atomic.AddInt32(&stats.sets, 1)
+				command := GrentonObject{}
+				err = json.NewDecoder(r.Body).Decode(&command)
+				commands = append(commands, command)
+			} else {
+				atomic.AddInt32(&stats.multiSets, 1)
+				err = json.NewDecoder(r.Body).Decode(&commands)
 			}
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				fmt.Fprint(w, err)
+				return
+			}
+			for _, command := range commands {
+				mockObj := checkObject(w, command)
+				if mockObj == nil {
+					return
+				}
+				if command["Cmd"] == "SET" && !mockObj.stuck {
+					values, _ := command[mockObj.kind].(map[string]interface{})
+					for key, val := range values {
+						mockObj.value[key] = val
+					}
+				}
+			}
+			w.WriteHeader(http.StatusOK)
+			return
 		}
 
-		cluId := "CLU_0d1cf087"
-		objId := "DOU0302"
-
 		query := []GrentonObject{}
-		defer r.Body.Close()
-		jsonReader := json.NewDecoder(r.Body)
-		err := jsonReader.Decode(&query)
+		err := json.NewDecoder(r.Body).Decode(&query)
 		if err != nil {
-			fmt.Fprint(w, err)
 			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, err)
 			return
 		}
 
+		response := []GrentonObject{}
 		for _, obj := range query {
-			if !strings.EqualFold(strings.ToLower(obj.Clu), strings.ToLower(cluId)) {
-				w.WriteHeader(http.StatusInternalServerError)
-				fmt.Fprintf(w, "clu name mismatch (got %s)", obj.Clu)
-				return
-			}
-			if !strings.EqualFold(strings.ToLower(obj.Kind), "light") {
-				w.WriteHeader(http.StatusInternalServerError)
-				fmt.Fprintf(w, "unsupported object kind (got %s)", obj.Kind)
-				return
-			}
-			if !strings.EqualFold(strings.ToLower(obj.Id), strings.ToLower(objId)) {
-				w.WriteHeader(http.StatusInternalServerError)
-				fmt.Fprintf(w, "unsupported object id (got %s)", obj.Id)
+			mockObj := checkObject(w, obj)
+			if mockObj == nil {
 				return
 			}
+			response = append(response, GrentonObject{"Clu": obj["Clu"], "Id": obj["Id"], "Kind": obj["Kind"], mockObj.kind: mockObj.value})
 		}
 
-		exampleResponse := `[
-			{
-			  "Id": "DOU0302",
-			  "Kind": "Light",
-			  "Light": {
-				"State": true
-			  },
-			  "Clu": "CLU_0d1cf087"
-			}
-		  ]`
-
 		w.Header().Add("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(exampleResponse))
+		json.NewEncoder(w).Encode(response)
 	}))
 }
 
@@ -123,7 +184,7 @@ func TestGrentonioSetup(t *testing.T) {
 	grenton.GateAddress = "incorrect address"
 	grenton.CluId = 123
 
-	err := grenton.Setup([]uint16{}, []uint16{3, 4})
+	err := grenton.Setup(context.Background(), []uint16{}, []uint16{3, 4})
 	if err == nil {
 		t.Error("expected error from grenton io setup (incorrect address)")
 	}
@@ -131,23 +192,23 @@ func TestGrentonioSetup(t *testing.T) {
 	grentonMock := mockGrentonIo()
 	grenton.GateAddress = grentonMock.URL
 
-	err = grenton.Setup([]uint16{1}, []uint16{3, 4})
+	err = grenton.Setup(context.Background(), []uint16{}, []uint16{})
 	if err == nil {
-		t.Error("expected error from grenton io setup (inputs in setup - should be unsupported)")
+		t.Error("expected error from grenton io setup (nothing to setup)")
 	}
 
-	err = grenton.Setup([]uint16{}, []uint16{302})
+	err = grenton.Setup(context.Background(), []uint16{}, []uint16{302})
 	if err == nil {
 		t.Error("expected error from grenton io setup (wrong clu id provided)")
 	}
 
 	grenton.CluId = 0x0d1cf087
-	err = grenton.Setup([]uint16{}, []uint16{3, 2})
+	err = grenton.Setup(context.Background(), []uint16{}, []uint16{3, 2})
 	if err == nil {
 		t.Error("expected error from grenton io setup (wrong object id provided)")
 	}
 
-	err = grenton.Setup([]uint16{}, []uint16{302})
+	err = grenton.Setup(context.Background(), []uint16{}, []uint16{302})
 	if err != nil {
 		t.Errorf("received error from grenton io setup: %v", err)
 	}
@@ -169,3 +230,383 @@ func TestGrentonioSetup(t *testing.T) {
 	}
 
 }
+
+func assertInts(t testing.TB, got, want int) {
+	t.Helper()
+
+	if got != want {
+		t.Errorf("got: %d, want: %d", got, want)
+	}
+}
+
+type eventRecorder struct {
+	events []PushEvent
+	lock   sync.Mutex
+}
+
+func (er *eventRecorder) FireEvent(event PushEvent) {
+	er.lock.Lock()
+	defer er.lock.Unlock()
+	er.events = append(er.events, event)
+}
+
+func (er *eventRecorder) getEvents() []PushEvent {
+	er.lock.Lock()
+	defer er.lock.Unlock()
+	return append([]PushEvent{}, er.events...)
+}
+
+func eventParams(pinNo, event, token string) httprouter.Params {
+	return httprouter.Params{
+		{Key: "pin_no", Value: pinNo},
+		{Key: "event", Value: event},
+		{Key: "token", Value: token},
+	}
+}
+
+func TestGrentonioInputs(t *testing.T) {
+	grentonMock := mockGrentonIo()
+	defer grentonMock.Close()
+
+	grenton := GrentonIO{}
+	grenton.GateAddress = grentonMock.URL
+	grenton.CluId = 0x0d1cf087
+	grenton.EventToken = "event-token"
+
+	err := grenton.Setup(context.Background(), []uint16{12}, []uint16{302})
+	if err == nil {
+		t.Error("expected error from grenton io setup (wrong input id provided)")
+	}
+	grenton.Close()
+
+	err = grenton.Setup(context.Background(), []uint16{11}, []uint16{302})
+	if err != nil {
+		t.Fatalf("received error from grenton io setup: %v", err)
+	}
+	defer grenton.Close()
+
+	// repeated setup stops previous input watcher
+	done := grenton.done
+	err = grenton.Setup(context.Background(), []uint16{11}, []uint16{302})
+	if err != nil {
+		t.Fatalf("received error from repeated grenton io setup: %v", err)
+	}
+	select {
+	case <-done:
+	default:
+		t.Error("previous input watcher wasn't stopped by repeated setup")
+	}
+
+	inputs, outputs := grenton.GetAllIo()
+	assertUint16Slices(t, inputs, []uint16{11})
+	assertUint16Slices(t, outputs, []uint16{302})
+
+	in, err := grenton.GetInput(11)
+	if err != nil {
+		t.Fatalf("input not found: %v", err)
+	}
+
+	state, err := in.GetState()
+	if err != nil {
+		t.Errorf("failed to get input state %v", err)
+	}
+	assertBools(t, state, true)
+
+	recorder := &eventRecorder{}
+	in.SubscribeToPushEvent(recorder)
+
+	rec := httptest.NewRecorder()
+	grenton.handleEvent(rec, httptest.NewRequest("GET", "/", nil), eventParams("11", "double", "wrong-token"))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected unauthorized status, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	grenton.handleEvent(rec, httptest.NewRequest("GET", "/", nil), eventParams("11", "double", "EVENT-TOKEN"))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected unauthorized status for token in different case, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	grenton.handleEvent(rec, httptest.NewRequest("GET", "/", nil), eventParams("11", "double", "event-token"))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected ok status, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	grenton.handleEvent(rec, httptest.NewRequest("GET", "/", nil), eventParams("11", "off", "event-token"))
+	state, _ = in.GetState()
+	assertBools(t, state, false)
+
+	if len(recorder.events) < 1 || recorder.events[0] != PushEventDoublePress {
+		t.Errorf("expected double press event to be fired, got %v", recorder.events)
+	}
+}
+
+func TestPushDetector(t *testing.T) {
+	start := time.Now()
+	at := func(ms int) time.Time {
+		return start.Add(time.Duration(ms) * time.Millisecond)
+	}
+
+	t.Run("single", func(t *testing.T) {
+		recorder := &eventRecorder{}
+		pd := pushDetector{listener: recorder}
+
+		pd.Update(true, at(0))
+		pd.Update(false, at(100))
+		pd.Tick(at(200))
+		if len(recorder.events) != 0 {
+			t.Errorf("single press fired before double press window passed")
+		}
+		pd.Tick(at(100).Add(pushDoublePressWindow))
+		if len(recorder.events) != 1 || recorder.events[0] != PushEventSinglePress {
+			t.Errorf("expected single press, got %v", recorder.events)
+		}
+	})
+
+	t.Run("double", func(t *testing.T) {
+		recorder := &eventRecorder{}
+		pd := pushDetector{listener: recorder}
+
+		pd.Update(true, at(0))
+		pd.Update(false, at(100))
+		pd.Update(true, at(200))
+		pd.Update(false, at(300))
+		pd.Tick(at(2000))
+		if len(recorder.events) != 1 || recorder.events[0] != PushEventDoublePress {
+			t.Errorf("expected double press, got %v", recorder.events)
+		}
+	})
+
+	t.Run("long", func(t *testing.T) {
+		recorder := &eventRecorder{}
+		pd := pushDetector{listener: recorder}
+
+		pd.Update(true, at(0))
+		pd.Tick(at(0).Add(pushLongPressDuration))
+		pd.Update(false, at(3000))
+		pd.Tick(at(5000))
+		if len(recorder.events) != 1 || recorder.events[0] != PushEventLongPress {
+			t.Errorf("expected long press, got %v", recorder.events)
+		}
+	})
+}
+
+func TestGrentonioObjects(t *testing.T) {
+	objects := mockGrentonObjects()
+	grentonMock := mockGrentonIoWithObjects(objects, nil)
+	defer grentonMock.Close()
+
+	grenton := GrentonIO{}
+	grenton.GateAddress = grentonMock.URL
+	grenton.CluId = 0x0d1cf087
+	grenton.Objects = []GrentonObjectConfig{
+		{Pin: 2, Kind: "dimmer"},
+		{Pin: 5, Kind: "RollerShutter"},
+		{Pin: 7, Kind: "LedRgb", Id: "LED0107"},
+		{Pin: 9, Kind: "Thermo"},
+	}
+
+	err := grenton.Setup(context.Background(), []uint16{}, []uint16{2, 5, 7, 9})
+	if err == nil {
+		t.Error("expected error from grenton io setup (unsupported object kind)")
+	}
+
+	err = grenton.Setup(context.Background(), []uint16{}, []uint16{302, 2, 5, 7})
+	if err != nil {
+		t.Fatalf("received error from grenton io setup: %v", err)
+	}
+
+	out, _ := grenton.GetOutput(2)
+	dimmer, ok := out.(*GrentonDimmer)
+	if !ok {
+		t.Fatalf("output 2 is not a dimmer (got %T)", out)
+	}
+	level, err := dimmer.GetLevel()
+	if err != nil {
+		t.Errorf("failed to get dimmer level: %v", err)
+	}
+	assertInts(t, level, 40)
+
+	err = dimmer.SetLevel(65)
+	if err != nil {
+		t.Errorf("failed to set dimmer level: %v", err)
+	}
+	err = grenton.updateState()
+	if err != nil {
+		t.Errorf("failed to update state: %v", err)
+	}
+	level, _ = dimmer.GetLevel()
+	assertInts(t, level, 65)
+
+	out, _ = grenton.GetOutput(5)
+	shutter, ok := out.(*GrentonRollerShutter)
+	if !ok {
+		t.Fatalf("output 5 is not a roller shutter (got %T)", out)
+	}
+	position, _ := shutter.GetPosition()
+	assertInts(t, position, 70)
+	state, _ := shutter.GetState()
+	assertBools(t, state, true)
+
+	shutter.Set(false)
+	grenton.updateState()
+	position, _ = shutter.GetPosition()
+	assertInts(t, position, 0)
+
+	out, _ = grenton.GetOutput(7)
+	led, ok := out.(*GrentonLedRgb)
+	if !ok {
+		t.Fatalf("output 7 is not a led rgb (got %T)", out)
+	}
+	hue, saturation, brightness, _ := led.GetColor()
+	assertInts(t, hue, 120)
+	assertInts(t, saturation, 50)
+	assertInts(t, brightness, 80)
+
+	err = led.SetColor(400, 10, 10)
+	if err == nil {
+		t.Error("expected error when setting hue out of range")
+	}
+	led.SetColor(240, 100, 30)
+	grenton.updateState()
+	hue, saturation, brightness, _ = led.GetColor()
+	assertInts(t, hue, 240)
+	assertInts(t, saturation, 100)
+	assertInts(t, brightness, 30)
+
+	out, _ = grenton.GetOutput(302)
+	if _, ok := out.(*GrentonOutput); !ok {
+		t.Errorf("output 302 is not a plain grenton output (got %T)", out)
+	}
+}
+
+func TestGrentonioBatchedSet(t *testing.T) {
+	stats := &mockGrentonStats{}
+	grentonMock := mockGrentonIoWithObjects(mockGrentonObjects(), stats)
+	defer grentonMock.Close()
+
+	grenton := GrentonIO{}
+	grenton.GateAddress = grentonMock.URL
+	grenton.CluId = 0x0d1cf087
+	grenton.Objects = []GrentonObjectConfig{{Pin: 2, Kind: "Dimmer"}}
+
+	err := grenton.Setup(context.Background(), []uint16{}, []uint16{302, 303, 304, 2})
+	if err != nil {
+		t.Fatalf("received error from grenton io setup: %v", err)
+	}
+	grenton.setCheckWait = 50 * time.Millisecond
+
+	wg := sync.WaitGroup{}
+	for _, pin := range []uint16{302, 303, 304, 2} {
+		out, _ := grenton.GetOutput(pin)
+		wg.Add(1)
+		go func(out DigitalOutput) {
+			defer wg.Done()
+			state, _ := out.GetState()
+			err := out.Set(!state)
+			if err != nil {
+				t.Errorf("failed to set output: %v", err)
+			}
+		}(out)
+	}
+	wg.Wait()
+
+	if atomic.LoadInt32(&stats.multiSets) != 1 || atomic.LoadInt32(&stats.sets) != 0 {
+		t.Errorf("expected one multi set request, got %d multi and %d single", stats.multiSets, stats.sets)
+	}
+
+	out, _ := grenton.GetOutput(303)
+	out.Set(false)
+	if atomic.LoadInt32(&stats.sets) != 1 {
+		t.Errorf("expected single set request, got %d", stats.sets)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+
+	for _, pin := range []uint16{302, 303, 2} {
+		out, _ := grenton.GetOutput(pin)
+		_, err := out.GetState()
+		if err != nil {
+			t.Errorf("unexpected fault on output %d: %v", pin, err)
+		}
+	}
+
+	stuck, _ := grenton.GetOutput(304)
+	_, err = stuck.GetState()
+	if err == nil {
+		t.Error("expected fault on output which did not change state")
+	}
+}
+
+func TestGrentonioEventServer(t *testing.T) {
+	grentonMock := mockGrentonIo()
+	defer grentonMock.Close()
+
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+
+	grenton := GrentonIO{}
+	grenton.GateAddress = grentonMock.URL
+	grenton.CluId = 0x0d1cf087
+	grenton.EventListenAddr = busy.Addr().String()
+
+	err = grenton.Setup(context.Background(), []uint16{11}, []uint16{302})
+	if err == nil {
+		t.Error("expected error from grenton io setup when event listen address is in use")
+	}
+	grenton.Close()
+}
+
+func TestGrentonioReconfigure(t *testing.T) {
+	grentonMock := mockGrentonIo()
+	defer grentonMock.Close()
+
+	grenton := GrentonIO{GateAddress: grentonMock.URL, CluId: 0x0d1cf087}
+	err := grenton.Setup(context.Background(), nil, []uint16{302})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer grenton.Close()
+	out302, _ := grenton.GetOutput(302)
+
+	next := &GrentonIO{GateAddress: grentonMock.URL, CluId: 0x0d1cf087, Objects: []GrentonObjectConfig{{Pin: 2, Kind: "Dimmer"}}}
+	err = grenton.Reconfigure(context.Background(), next, []uint16{11}, []uint16{302, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, _ := grenton.GetOutput(302)
+	assertBools(t, current == out302, true)
+	state, err := out302.GetState()
+	assertBools(t, err == nil && state, true)
+
+	out, _ := grenton.GetOutput(2)
+	dimmer, ok := out.(*GrentonDimmer)
+	if !ok {
+		t.Fatalf("output 2 is not a dimmer (got %T)", out)
+	}
+	level, err := dimmer.GetLevel()
+	assertBools(t, err == nil && level == 40, true)
+	in11, err := grenton.GetInput(11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err = in11.GetState()
+	assertBools(t, err == nil && state, true)
+	assertBools(t, grenton.done != nil, true)
+
+	err = grenton.Reconfigure(context.Background(), next, []uint16{11}, []uint16{302, 2, 999})
+	assertBools(t, err != nil, true)
+	_, err = grenton.GetOutput(999)
+	assertBools(t, err != nil, true)
+	redefined := &GrentonIO{GateAddress: grentonMock.URL, CluId: 0x0d1cf087, Objects: []GrentonObjectConfig{{Pin: 2, Kind: "Light"}}}
+	err = grenton.Reconfigure(context.Background(), redefined, []uint16{11}, []uint16{302, 2})
+	assertBools(t, err != nil, true)
+	other := &GrentonIO{GateAddress: grentonMock.URL, CluId: 0x0d1cf088, Objects: next.Objects}
+	err = grenton.Reconfigure(context.Background(), other, nil, []uint16{302})
+	assertBools(t, err != nil, true)
+}
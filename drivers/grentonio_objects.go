@@ -0,0 +1,149 @@
+package drivers
+
+import (
+	"github.com/pkg/errors"
+)
+
+func clampPercent(value int) int {
+	if value < 0 {
+		return 0
+	}
+	if value > 100 {
+		return 100
+	}
+	return value
+}
+
+// GrentonDimmer is a DIMmer object, on top of on/off it has brightness level (0-100).
+type GrentonDimmer struct {
+	*GrentonOutput
+}
+
+func (gd *GrentonDimmer) GetLevel() (int, error) {
+	value, err := gd.refresh()
+	if err != nil {
+		return 0, err
+	}
+	return value.Brightness, nil
+}
+
+func (gd *GrentonDimmer) SetLevel(level int) error {
+	value, err := gd.refresh()
+	if err != nil {
+		return err
+	}
+	value.Brightness = clampPercent(level)
+	value.State = value.Brightness > 0
+
+	err = gd.Grenton.setObject(gd.GrentonOutput, grentonCmdSet, value)
+	if err != nil {
+		return errors.Wrap(err, "failed to set dimmer level")
+	}
+	return nil
+}
+
+// GrentonRollerShutter is a ROLLER_SHUTTER object, position is 0 (closed) to 100 (open).
+type GrentonRollerShutter struct {
+	*GrentonOutput
+}
+
+func (grs *GrentonRollerShutter) GetPosition() (int, error) {
+	value, err := grs.refresh()
+	if err != nil {
+		return 0, err
+	}
+	return value.Position, nil
+}
+
+func (grs *GrentonRollerShutter) SetPosition(position int) error {
+	value, err := grs.refresh()
+	if err != nil {
+		return err
+	}
+	value.Position = clampPercent(position)
+
+	err = grs.Grenton.setObject(grs.GrentonOutput, grentonCmdSet, value)
+	if err != nil {
+		return errors.Wrap(err, "failed to set roller shutter position")
+	}
+	return nil
+}
+
+// IsMoving returns true when gate reports the roller shutter is going up or down.
+func (grs *GrentonRollerShutter) IsMoving() (bool, error) {
+	value, err := grs.refresh()
+	if err != nil {
+		return false, err
+	}
+	return value.Movement != 0, nil
+}
+
+func (grs *GrentonRollerShutter) Stop() error {
+	value, err := grs.refresh()
+	if err != nil {
+		return err
+	}
+
+	err = grs.Grenton.setObject(grs.GrentonOutput, grentonCmdStop, value)
+	if err != nil {
+		return errors.Wrap(err, "failed to stop roller shutter")
+	}
+	return nil
+}
+
+// GrentonLedRgb is a LEDRGB object, colour is kept as hue (0-360), saturation and brightness (0-100).
+type GrentonLedRgb struct {
+	*GrentonOutput
+}
+
+func (gl *GrentonLedRgb) GetLevel() (int, error) {
+	value, err := gl.refresh()
+	if err != nil {
+		return 0, err
+	}
+	return value.Brightness, nil
+}
+
+func (gl *GrentonLedRgb) SetLevel(level int) error {
+	value, err := gl.refresh()
+	if err != nil {
+		return err
+	}
+	value.Brightness = clampPercent(level)
+	value.State = value.Brightness > 0
+
+	err = gl.Grenton.setObject(gl.GrentonOutput, grentonCmdSet, value)
+	if err != nil {
+		return errors.Wrap(err, "failed to set led rgb level")
+	}
+	return nil
+}
+
+func (gl *GrentonLedRgb) GetColor() (hue int, saturation int, brightness int, err error) {
+	value, err := gl.refresh()
+	if err != nil {
+		return
+	}
+	return value.Hue, value.Saturation, value.Brightness, nil
+}
+
+func (gl *GrentonLedRgb) SetColor(hue int, saturation int, brightness int) error {
+	if hue < 0 || hue > 360 {
+		return errors.Errorf("hue (%d) out of range [0, 360]", hue)
+	}
+
+	value, err := gl.refresh()
+	if err != nil {
+		return err
+	}
+	value.Hue = hue
+	value.Saturation = clampPercent(saturation)
+	value.Brightness = clampPercent(brightness)
+	value.State = value.Brightness > 0
+
+	err = gl.Grenton.setObject(gl.GrentonOutput, grentonCmdSet, value)
+	if err != nil {
+		return errors.Wrap(err, "failed to set led rgb color")
+	}
+	return nil
+}
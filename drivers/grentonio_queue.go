@@ -0,0 +1,147 @@
+package drivers
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type grentonCommand struct {
+	output *GrentonOutput
+	cmd    string
+	value  grentonValue
+}
+
+// grentonBatch collects commands queued within coalesce window, later command
+// for the same object replaces the earlier one.
+type grentonBatch struct {
+	commands []grentonCommand
+	done     chan struct{}
+	err      error
+}
+
+func (gb *grentonBatch) add(command grentonCommand) {
+	for ix, queued := range gb.commands {
+		if queued.output == command.output {
+			gb.commands[ix] = command
+			return
+		}
+	}
+	gb.commands = append(gb.commands, command)
+}
+
+// setObject queues command and waits until the batch it landed in is sent to the gate.
+func (gio *GrentonIO) setObject(output *GrentonOutput, cmd string, value grentonValue) error {
+	gio.queueLock.Lock()
+	if gio.batch == nil {
+		gio.batch = &grentonBatch{done: make(chan struct{})}
+		time.AfterFunc(gio.coalesceWindow, gio.flushBatch)
+	}
+	batch := gio.batch
+	batch.add(grentonCommand{output: output, cmd: cmd, value: value})
+	gio.queueLock.Unlock()
+
+	<-batch.done
+	return batch.err
+}
+
+func (gio *GrentonIO) flushBatch() {
+	gio.queueLock.Lock()
+	batch := gio.batch
+	gio.batch = nil
+	gio.queueLock.Unlock()
+
+	if batch == nil {
+		return
+	}
+
+	batch.err = gio.sendCommands(batch.commands)
+	close(batch.done)
+
+	if batch.err != nil {
+		return
+	}
+
+	objects := []grentonObject{}
+	for _, command := range batch.commands {
+		if command.cmd == grentonCmdSet {
+			objects = append(objects, grentonObject{string(command.output.getKind()), gio.getCluString(), command.output.getObjectId()})
+		}
+	}
+	if len(objects) == 0 {
+		return
+	}
+
+	time.AfterFunc(gio.setCheckWait, func() {
+		err := gio.readObjects(objects)
+		if err != nil {
+			log.Println("grenton | failed to verify state after set:", err)
+		}
+	})
+}
+
+func (gio *GrentonIO) sendCommands(commands []grentonCommand) (err error) {
+	gio.gateLock.Lock()
+	defer gio.gateLock.Unlock()
+
+	var netClient = &http.Client{
+		Timeout: grentonNetClientTimeout,
+	}
+
+	var body []byte
+	var setUrl *url.URL
+	if len(commands) == 1 {
+		body = gio.getSetBody(commands[0].cmd, commands[0].output, commands[0].value)
+		setUrl = gio.setUrl
+	} else {
+		objSet := []map[string]interface{}{}
+		for _, command := range commands {
+			objSet = append(objSet, gio.getSetObject(command.cmd, command.output, command.value))
+		}
+		body, _ = json.Marshal(objSet)
+		setUrl = gio.multiSetUrl
+	}
+
+	req, err := http.NewRequest("POST", setUrl.String(), strings.NewReader(string(body)))
+	if err != nil {
+		err = errors.Wrap(err, "preparing request failed")
+		return
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	response, err := netClient.Do(req)
+	if err != nil {
+		err = errors.Wrap(err, "sending request failed")
+		return
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode > 200 {
+		respBody, _ := io.ReadAll(response.Body)
+		err = errors.Errorf("grenton gate returned non success status code (%d),\n command:\n%s\nresponse:\n%s", response.StatusCode, body, respBody)
+		return
+	}
+
+	verifyAt := time.Now().Add(gio.setCheckWait)
+	for _, command := range commands {
+		if command.cmd != grentonCmdSet {
+			continue
+		}
+		expected := command.value
+		command.output.lock.Lock()
+		command.output.value = command.value
+		command.output.expected = &expected
+		command.output.verifyAt = verifyAt
+		command.output.fault = nil
+		command.output.lock.Unlock()
+	}
+
+	return
+}
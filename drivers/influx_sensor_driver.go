@@ -22,14 +22,15 @@ type InfluxSensors struct {
 	Token        string
 
 	GroupByTag []string
+	Fields     map[string]string // influx field of measurement, default measurement name (e.g. "humidity")
 
 	Debug bool
 
-	sensors []TemperatureSensor
+	sensors []Sensor
 	ready   bool
 }
 
-func (is *InfluxSensors) Setup(tss []TemperatureSensor) error {
+func (is *InfluxSensors) Setup(tss []Sensor) error {
 	is.sensors = tss
 
 	_, err := is.runQuery(is.prepareQuery())
@@ -79,7 +80,7 @@ func (is *InfluxSensors) Sync() error {
 			return errors.Wrap(err, "got error parsing result table")
 		}
 		for _, t := range is.sensors {
-			if checkTagsRecordMatch(tableResult.Record(), t.GetTags()) {
+			if tableResult.Record().Field() == is.getField(t.GetMeasurement()) && checkTagsRecordMatch(tableResult.Record(), t.GetTags()) {
 				if is.Debug {
 					log.Println("matched t.sensor: ", t.GetId())
 				}
@@ -99,7 +100,7 @@ func (is *InfluxSensors) Sync() error {
 	return nil
 }
 
-func (is *InfluxSensors) FindTemperatureSensor(id string) (sensor TemperatureSensor, err error) {
+func (is *InfluxSensors) FindSensor(id string) (sensor Sensor, err error) {
 	for _, t := range is.sensors {
 		if strings.EqualFold(t.GetId(), id) {
 			sensor = t
@@ -110,15 +111,46 @@ func (is *InfluxSensors) FindTemperatureSensor(id string) (sensor TemperatureSen
 	return
 }
 
+// getField returns influx field of measurement.
+func (is *InfluxSensors) getField(measurement Measurement) string {
+	field, found := is.Fields[string(measurement)]
+	if found {
+		return field
+	}
+	return string(measurement)
+}
+
+// getFields returns fields of all sensors, temperature field when there are no sensors.
+func (is *InfluxSensors) getFields() (fields []string) {
+	used := map[string]bool{}
+	for _, t := range is.sensors {
+		field := is.getField(t.GetMeasurement())
+		if !used[field] {
+			used[field] = true
+			fields = append(fields, field)
+		}
+	}
+	if len(fields) == 0 {
+		fields = []string{is.getField(MeasurementTemperature)}
+	}
+	return
+}
+
 func (is *InfluxSensors) prepareQuery() string {
+	filters := []string{}
+	for _, field := range is.getFields() {
+		filters = append(filters, fmt.Sprintf(`r["_field"] == "%s"`, field))
+	}
+	columns := append(append([]string{}, is.GroupByTag...), "_field")
+
 	return fmt.Sprintf(`
 from(bucket: "%s")
 |> range(start: -10m)
 |> filter(fn: (r) => r["_measurement"] == "%s")
-|> filter(fn: (r) => r["_field"] == "temperature")
+|> filter(fn: (r) => %s)
 |> group(columns: ["%s"])
 |> aggregateWindow(every: 25m, fn: mean, createEmpty: false)
-`, is.Bucket, is.Measurement, strings.Join(is.GroupByTag, ","))
+`, is.Bucket, is.Measurement, strings.Join(filters, " or "), strings.Join(columns, `", "`))
 }
 
 func checkTagsRecordMatch(record *query.FluxRecord, tags map[string]string) (match bool) {
@@ -16,8 +16,8 @@ func TestPrepareInfluxQuery(t *testing.T) {
 |> range(start: -10m)
 |> filter(fn: (r) => r["_measurement"] == "measure")
 |> filter(fn: (r) => r["_field"] == "temperature")
-|> group(columns: ["one", "this-is-two"])
-|> aggregateWindow(every: 20m, fn: mean, createEmpty: false)`
+|> group(columns: ["one", "this-is-two", "_field"])
+|> aggregateWindow(every: 25m, fn: mean, createEmpty: false)`
 
 	got := strings.TrimSpace(inf.prepareQuery())
 
@@ -25,3 +25,48 @@ func TestPrepareInfluxQuery(t *testing.T) {
 		t.Errorf("prepared influx query mismatch, got:\n%s\nwant:\n%s\n", got, want)
 	}
 }
+
+type influxTestSensor struct {
+	id          string
+	measurement Measurement
+}
+
+func (its *influxTestSensor) GetValue() (float64, error)   { return 0, nil }
+func (its *influxTestSensor) SetValue(value float64) error { return nil }
+func (its *influxTestSensor) GetTags() map[string]string   { return nil }
+func (its *influxTestSensor) GetId() string                { return its.id }
+func (its *influxTestSensor) GetMeasurement() Measurement  { return its.measurement }
+
+func TestPrepareInfluxQueryFields(t *testing.T) {
+	inf := InfluxSensors{Bucket: "some-bucket", Measurement: "bme280", Fields: map[string]string{"humidity": "hum"}}
+	inf.sensors = []Sensor{
+		&influxTestSensor{"living-t", MeasurementTemperature},
+		&influxTestSensor{"living-h", MeasurementHumidity},
+		&influxTestSensor{"office-h", MeasurementHumidity},
+		&influxTestSensor{"office-p", MeasurementPressure},
+	}
+
+	want := `|> filter(fn: (r) => r["_field"] == "temperature" or r["_field"] == "hum" or r["_field"] == "pressure")`
+	got := inf.prepareQuery()
+	if !strings.Contains(got, want) {
+		t.Errorf("prepared influx query mismatch, got:\n%s\nwant line:\n%s\n", got, want)
+	}
+	if !strings.Contains(got, `|> group(columns: ["_field"])`) {
+		t.Errorf("prepared influx query is not grouped by field:\n%s", got)
+	}
+}
+
+func TestParseMeasurement(t *testing.T) {
+	measurement, err := ParseMeasurement("CO2")
+	if err != nil || measurement != MeasurementCo2 {
+		t.Errorf("unexpected measurement %s (%v)", measurement, err)
+	}
+	measurement, _ = ParseMeasurement("")
+	if measurement != MeasurementTemperature {
+		t.Errorf("default measurement should be temperature, got %s", measurement)
+	}
+	_, err = ParseMeasurement("radiation")
+	if err == nil {
+		t.Errorf("unknown measurement should fail")
+	}
+}
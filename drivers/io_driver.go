@@ -1,6 +1,15 @@
 package drivers
 
-import "context"
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+)
 
 type IoDriver interface {
 	Setup(ctx context.Context, inputs []uint16, outputs []uint16) error
@@ -22,6 +31,32 @@ type DigitalOutput interface {
 	Set(bool) error
 }
 
+// AnalogOutput is output with level 0-100 (dimmer, PWM), level 0 is off. Drivers return it from GetOutput,
+// output which can be dimmed implements both DigitalOutput and AnalogOutput.
+type AnalogOutput interface {
+	GetLevel() (int, error)
+	SetLevel(int) error
+}
+
+// Rgbw is colour of ColorOutput, channels are 0-255, White is ignored by RGB outputs.
+type Rgbw struct {
+	Red   int
+	Green int
+	Blue  int
+	White int
+}
+
+// IsOff is true when all channels are 0.
+func (c Rgbw) IsOff() bool {
+	return c.Red <= 0 && c.Green <= 0 && c.Blue <= 0 && c.White <= 0
+}
+
+// ColorOutput is output of colour (RGB/RGBW) controller, drivers return it from GetOutput like AnalogOutput.
+type ColorOutput interface {
+	GetRgbw() (Rgbw, error)
+	SetRgbw(Rgbw) error
+}
+
 type PushEvent int
 
 const (
@@ -33,3 +68,55 @@ const (
 type EventListener interface {
 	FireEvent(PushEvent)
 }
+
+// ReconfigurableIoDriver is IoDriver which pins can be changed while running (config reload), so its
+// connection, lines or listener are not opened twice. Reconfigure takes pin definitions of next (driver of the
+// same kind, not set up, e.g. webhook Outputs) and sets up inputs and outputs, listed pins not defined by next
+// keep current definition. Pins present before and after are left untouched, removed outputs are switched off
+// (by drivers switching outputs off on Close). Other settings of next and definition of a listed pin can't be
+// changed while running, error is returned then.
+type ReconfigurableIoDriver interface {
+	IoDriver
+	Reconfigure(ctx context.Context, next IoDriver, inputs []uint16, outputs []uint16) error
+}
+
+// checkReconfigure returns error when next is not driver of the same kind as running or its exported fields,
+// but pin definitions (taken by Reconfigure), differ.
+func checkReconfigure(running IoDriver, next IoDriver, definitions ...string) error {
+	if reflect.TypeOf(running) != reflect.TypeOf(next) {
+		return errors.Errorf("%s driver can't be reconfigured with %T", running.NameId(), next)
+	}
+
+	current := reflect.ValueOf(running).Elem()
+	other := reflect.ValueOf(next).Elem()
+	changed := []string{}
+	for i := 0; i < current.NumField(); i++ {
+		field := current.Type().Field(i)
+		if !field.IsExported() || slices.Contains(definitions, field.Name) {
+			continue
+		}
+		if !sameDefinition(current.Field(i).Interface(), other.Field(i).Interface()) {
+			changed = append(changed, field.Name)
+		}
+	}
+	if len(changed) > 0 {
+		return errors.Errorf("%s of %s driver can't be changed while running, restart needed", strings.Join(changed, ", "), running.NameId())
+	}
+	return nil
+}
+
+// sameDefinition compares exported fields of a and b.
+func sameDefinition(a, b interface{}) bool {
+	aJson, aErr := json.Marshal(a)
+	bJson, bErr := json.Marshal(b)
+	return aErr == nil && bErr == nil && bytes.Equal(aJson, bJson)
+}
+
+func hasPin(pins []uint16, pin uint16) bool {
+	for _, p := range pins {
+		if p == pin {
+			return true
+		}
+	}
+	return false
+}
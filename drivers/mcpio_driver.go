@@ -114,6 +114,13 @@ func (mcpio *McpIO) NameId() string {
 	return mcpioDriverName
 }
 
+func (mcpio *McpIO) GetUniqueId(ioPin uint16) uint64 {
+	baseId := uint64(2) << 56
+	baseId += uint64(mcpio.BusNo) << 16
+	baseId += uint64(mcpio.DevNo) << 8
+	return baseId + uint64(ioPin)
+}
+
 func (mcpio *McpIO) IsReady() bool {
 	return mcpio.isReady
 }
@@ -200,3 +207,66 @@ func (mcp *McpIO) GetAllIo() (inputs []uint16, outputs []uint16) {
 
 	return
 }
+
+// Reconfigure sets up added pins and switches removed outputs off, other pins are not touched.
+func (mcp *McpIO) Reconfigure(ctx context.Context, next IoDriver, inputs []uint16, outputs []uint16) (err error) {
+	err = checkReconfigure(mcp, next)
+	if err != nil {
+		return
+	}
+
+	currentInputs, currentOutputs := mcp.GetAllIo()
+
+	keptInputs := []McpInput{}
+	for _, input := range mcp.inputs {
+		if hasPin(inputs, uint16(input.pin)) {
+			keptInputs = append(keptInputs, input)
+		}
+	}
+	keptOutputs := []McpOutput{}
+	for _, output := range mcp.outputs {
+		if hasPin(outputs, uint16(output.pin)) {
+			keptOutputs = append(keptOutputs, output)
+		} else {
+			output.Set(false)
+		}
+	}
+	mcp.inputs = keptInputs
+	mcp.outputs = keptOutputs
+
+	for _, inputPin := range inputs {
+		if hasPin(currentInputs, inputPin) {
+			continue
+		}
+		if inputPin > 255 {
+			err = fmt.Errorf("input pin out of range (mcpio takes uint8 pin id)")
+			return
+		}
+		err = mcp.device.PinMode(uint8(inputPin), mcp23017.INPUT)
+		if err != nil {
+			return
+		}
+		err = mcp.device.SetPullUp(uint8(inputPin), true)
+		if err != nil {
+			return
+		}
+		mcp.inputs = append(mcp.inputs, McpInput{pin: uint8(inputPin), invert: mcp.InvertInputs, device: mcp.device})
+	}
+
+	for _, outputPin := range outputs {
+		if hasPin(currentOutputs, outputPin) {
+			continue
+		}
+		if outputPin > 255 {
+			err = fmt.Errorf("output pin out of range (mcpio takes uint8 pin id)")
+			return
+		}
+		err = mcp.device.PinMode(uint8(outputPin), mcp23017.OUTPUT)
+		if err != nil {
+			return
+		}
+		mcp.outputs = append(mcp.outputs, McpOutput{pin: uint8(outputPin), invert: mcp.InvertOutputs, device: mcp.device})
+	}
+
+	return
+}
@@ -4,46 +4,176 @@ import (
 	"context"
 	"fmt"
 	"io"
-
-	"errors"
+	"sync"
+	"time"
 )
 
+const mockPushTickInterval = 50 * time.Millisecond
+
+// MockWrite is a single output write recorded by MockIoDriver, Err is set when write failed (injected failure).
+// Level is level of SetLevel write, Set writes have level 0 or 100. Rgbw is set by SetRgbw writes only.
+type MockWrite struct {
+	At    time.Time
+	Pin   uint16
+	State bool
+	Level int
+	Rgbw  Rgbw
+	Err   error
+}
+
+// MockOutput is digital, analog (dimmable) and colour output at once, Set(true) sets level 100.
 type MockOutput struct {
 	state            bool
+	level            int
+	rgbw             Rgbw
 	pin              uint16
 	writeTo          io.Writer
 	writeStateChange bool
+
+	driver  *MockIoDriver
+	failErr error
+	latency time.Duration
+	lock    sync.Mutex
 }
 
 func (mo *MockOutput) GetState() (bool, error) {
-	return mo.state, nil
+	mo.lock.Lock()
+	defer mo.lock.Unlock()
+
+	return mo.state, mo.failErr
 }
 
 func (mo *MockOutput) Set(state bool) error {
-	if mo.writeStateChange && state != mo.state {
-		fmt.Fprintf(mo.writeTo, "[pin %d] state changed to %v\n", mo.pin, mo.state)
+	level := 0
+	if state {
+		level = 100
 	}
-	mo.state = state
-	return nil
+	return mo.write(level, nil)
+}
+
+func (mo *MockOutput) GetLevel() (int, error) {
+	mo.lock.Lock()
+	defer mo.lock.Unlock()
+
+	return mo.level, mo.failErr
+}
+
+func (mo *MockOutput) SetLevel(level int) error {
+	return mo.write(clampPercent(level), nil)
+}
+
+// GetRgbw returns colour set by SetRgbw, switched off output has all channels 0.
+func (mo *MockOutput) GetRgbw() (Rgbw, error) {
+	mo.lock.Lock()
+	defer mo.lock.Unlock()
+
+	if !mo.state {
+		return Rgbw{}, mo.failErr
+	}
+	return mo.rgbw, mo.failErr
+}
+
+// SetRgbw sets colour, level of output is its brightest channel.
+func (mo *MockOutput) SetRgbw(color Rgbw) error {
+	brightest := min(max(color.Red, color.Green, color.Blue, color.White, 0), 255)
+	return mo.write((brightest*100+254)/255, &color)
+}
+
+func (mo *MockOutput) write(level int, color *Rgbw) error {
+	mo.lock.Lock()
+	latency := mo.latency
+	mo.lock.Unlock()
+
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+
+	state := level > 0
+	mo.lock.Lock()
+	err := mo.failErr
+	if err == nil {
+		if mo.writeStateChange && level != mo.level {
+			if level > 0 && level < 100 {
+				fmt.Fprintf(mo.writeTo, "[pin %d] level changed to %d\n", mo.pin, level)
+			} else {
+				fmt.Fprintf(mo.writeTo, "[pin %d] state changed to %v\n", mo.pin, state)
+			}
+		}
+		mo.state = state
+		mo.level = level
+		if color != nil {
+			mo.rgbw = *color
+		}
+	}
+	mo.lock.Unlock()
+
+	if mo.driver != nil {
+		write := MockWrite{At: time.Now(), Pin: mo.pin, State: state, Level: level, Err: err}
+		if color != nil {
+			write.Rgbw = *color
+		}
+		mo.driver.recordWrite(write)
+	}
+	return err
+}
+
+// setFailure makes output fail all writes and reads with err, nil restores it.
+func (mo *MockOutput) setFailure(err error) {
+	mo.lock.Lock()
+	defer mo.lock.Unlock()
+
+	mo.failErr = err
+}
+
+func (mo *MockOutput) setLatency(latency time.Duration) {
+	mo.lock.Lock()
+	defer mo.lock.Unlock()
+
+	mo.latency = latency
 }
 
 type MockInput struct {
 	State bool
 	pin   uint16
+
+	push pushDetector
+	lock sync.Mutex
 }
 
 func (mi *MockInput) GetState() (bool, error) {
+	mi.lock.Lock()
+	defer mi.lock.Unlock()
+
 	return mi.State, nil
 }
 
 func (mi *MockInput) SubscribeToPushEvent(listener EventListener) error {
-	return errors.New("SubscribeToPushEvent not implemented")
+	mi.push.SetListener(listener)
+	return nil
+}
+
+// setState changes input state, push events are detected from state changes as on real inputs.
+func (mi *MockInput) setState(state bool) {
+	mi.lock.Lock()
+	mi.State = state
+	mi.lock.Unlock()
+
+	mi.push.Update(state, time.Now())
 }
 
+// MockIoDriver is in-memory driver for tests and simulation. Inputs can be set, toggled and pushed, output
+// failures and latency can be injected through its methods or a scenario (see RunScenario).
+// All output writes are recorded, see Writes.
 type MockIoDriver struct {
+	DriverName string // NameId, default "mock_driver"
+
 	inputs  []*MockInput
 	outputs []*MockOutput
 	ready   bool
+
+	writes []MockWrite
+	lock   sync.Mutex
+	done   chan bool
 }
 
 func (md *MockIoDriver) Setup(ctx context.Context, inputs []uint16, outputs []uint16) error {
@@ -51,17 +181,114 @@ func (md *MockIoDriver) Setup(ctx context.Context, inputs []uint16, outputs []ui
 		md.inputs = append(md.inputs, &MockInput{pin: inPin})
 	}
 	for _, outPin := range outputs {
-		md.outputs = append(md.outputs, &MockOutput{pin: outPin})
+		md.outputs = append(md.outputs, &MockOutput{pin: outPin, driver: md})
+	}
+	if len(inputs) > 0 && md.done == nil {
+		md.done = make(chan bool)
+		go md.tickPushDetectors(ctx, md.done)
 	}
 	md.ready = true
 	return nil
 }
 
+// Reconfigure adds new pins and removes (and switches off) pins no longer used, other pins keep their state.
+func (md *MockIoDriver) Reconfigure(ctx context.Context, next IoDriver, inputs []uint16, outputs []uint16) error {
+	err := checkReconfigure(md, next)
+	if err != nil {
+		return err
+	}
+
+	md.lock.Lock()
+	keptInputs := []*MockInput{}
+	for _, input := range md.inputs {
+		if hasPin(inputs, input.pin) {
+			keptInputs = append(keptInputs, input)
+		}
+	}
+	for _, inPin := range inputs {
+		if !md.hasInput(inPin) {
+			keptInputs = append(keptInputs, &MockInput{pin: inPin})
+		}
+	}
+	keptOutputs := []*MockOutput{}
+	removedOutputs := []*MockOutput{}
+	for _, output := range md.outputs {
+		if hasPin(outputs, output.pin) {
+			keptOutputs = append(keptOutputs, output)
+		} else {
+			removedOutputs = append(removedOutputs, output)
+		}
+	}
+	for _, outPin := range outputs {
+		if !md.hasOutput(outPin) {
+			keptOutputs = append(keptOutputs, &MockOutput{pin: outPin, driver: md})
+		}
+	}
+	md.inputs = keptInputs
+	md.outputs = keptOutputs
+	md.lock.Unlock()
+
+	for _, output := range removedOutputs {
+		output.Set(false)
+	}
+	if len(inputs) > 0 && md.done == nil {
+		md.done = make(chan bool)
+		go md.tickPushDetectors(ctx, md.done)
+	}
+	return nil
+}
+
+func (md *MockIoDriver) hasInput(pin uint16) bool {
+	for _, input := range md.inputs {
+		if input.pin == pin {
+			return true
+		}
+	}
+	return false
+}
+
+func (md *MockIoDriver) hasOutput(pin uint16) bool {
+	for _, output := range md.outputs {
+		if output.pin == pin {
+			return true
+		}
+	}
+	return false
+}
+
+func (md *MockIoDriver) tickPushDetectors(ctx context.Context, done chan bool) {
+	ticker := time.NewTicker(mockPushTickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			md.lock.Lock()
+			inputs := md.inputs
+			md.lock.Unlock()
+			for _, input := range inputs {
+				input.push.Tick(now)
+			}
+		}
+	}
+}
+
 func (md *MockIoDriver) Close() error {
+	if md.done != nil {
+		close(md.done)
+		md.done = nil
+	}
 	return nil
 }
 
 func (md *MockIoDriver) NameId() string {
+	if len(md.DriverName) > 0 {
+		return md.DriverName
+	}
 	return "mock_driver"
 }
 
@@ -74,7 +301,10 @@ func (md *MockIoDriver) IsReady() bool {
 	return md.ready
 }
 
-func (md *MockIoDriver) GetInput(pin uint16) (DigitalInput, error) {
+func (md *MockIoDriver) getInput(pin uint16) (*MockInput, error) {
+	md.lock.Lock()
+	defer md.lock.Unlock()
+
 	for _, input := range md.inputs {
 		if pin == input.pin {
 			return input, nil
@@ -83,7 +313,10 @@ func (md *MockIoDriver) GetInput(pin uint16) (DigitalInput, error) {
 	return nil, fmt.Errorf("mock input %d not found", pin)
 }
 
-func (md *MockIoDriver) GetOutput(pin uint16) (DigitalOutput, error) {
+func (md *MockIoDriver) getOutput(pin uint16) (*MockOutput, error) {
+	md.lock.Lock()
+	defer md.lock.Unlock()
+
 	for _, output := range md.outputs {
 		if pin == output.pin {
 			return output, nil
@@ -92,7 +325,26 @@ func (md *MockIoDriver) GetOutput(pin uint16) (DigitalOutput, error) {
 	return nil, fmt.Errorf("mock output %d not found", pin)
 }
 
+func (md *MockIoDriver) GetInput(pin uint16) (DigitalInput, error) {
+	input, err := md.getInput(pin)
+	if err != nil {
+		return nil, err
+	}
+	return input, nil
+}
+
+func (md *MockIoDriver) GetOutput(pin uint16) (DigitalOutput, error) {
+	output, err := md.getOutput(pin)
+	if err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
 func (md *MockIoDriver) GetAllIo() (inputs []uint16, outputs []uint16) {
+	md.lock.Lock()
+	defer md.lock.Unlock()
+
 	for _, input := range md.inputs {
 		inputs = append(inputs, input.pin)
 	}
@@ -104,7 +356,96 @@ func (md *MockIoDriver) GetAllIo() (inputs []uint16, outputs []uint16) {
 
 func (md *MockIoDriver) MonitorStateChanges(writer io.Writer) {
 	for _, out := range md.outputs {
+		out.lock.Lock()
 		out.writeTo = writer
 		out.writeStateChange = true
+		out.lock.Unlock()
+	}
+}
+
+// SetInput sets state of input, push events are detected like on real hardware.
+func (md *MockIoDriver) SetInput(pin uint16, state bool) error {
+	input, err := md.getInput(pin)
+	if err != nil {
+		return err
+	}
+	input.setState(state)
+	return nil
+}
+
+// ToggleInput flips state of input.
+func (md *MockIoDriver) ToggleInput(pin uint16) error {
+	input, err := md.getInput(pin)
+	if err != nil {
+		return err
+	}
+	state, _ := input.GetState()
+	input.setState(!state)
+	return nil
+}
+
+// PressInput holds input on for duration and releases it, short press results in single press event
+// (after double press window), press longer than long press duration in long press event.
+func (md *MockIoDriver) PressInput(pin uint16, duration time.Duration) error {
+	input, err := md.getInput(pin)
+	if err != nil {
+		return err
+	}
+	input.setState(true)
+	time.Sleep(duration)
+	input.setState(false)
+	return nil
+}
+
+// Push passes push event directly to input listener.
+func (md *MockIoDriver) Push(pin uint16, event PushEvent) error {
+	input, err := md.getInput(pin)
+	if err != nil {
+		return err
+	}
+	input.push.Fire(event)
+	return nil
+}
+
+// FailOutput makes output return err on every Set and GetState, nil err restores the output.
+func (md *MockIoDriver) FailOutput(pin uint16, err error) error {
+	output, getErr := md.getOutput(pin)
+	if getErr != nil {
+		return getErr
+	}
+	output.setFailure(err)
+	return nil
+}
+
+// SetOutputLatency delays every Set of output.
+func (md *MockIoDriver) SetOutputLatency(pin uint16, latency time.Duration) error {
+	output, err := md.getOutput(pin)
+	if err != nil {
+		return err
 	}
+	output.setLatency(latency)
+	return nil
+}
+
+func (md *MockIoDriver) recordWrite(write MockWrite) {
+	md.lock.Lock()
+	defer md.lock.Unlock()
+
+	md.writes = append(md.writes, write)
+}
+
+// Writes returns copy of all output writes recorded so far.
+func (md *MockIoDriver) Writes() []MockWrite {
+	md.lock.Lock()
+	defer md.lock.Unlock()
+
+	return append([]MockWrite{}, md.writes...)
+}
+
+// ClearWrites empties writes log.
+func (md *MockIoDriver) ClearWrites() {
+	md.lock.Lock()
+	defer md.lock.Unlock()
+
+	md.writes = nil
 }
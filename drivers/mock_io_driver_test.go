@@ -1,6 +1,13 @@
 package drivers
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
 
 func assertBools(t testing.TB, got, want bool) {
 	t.Helper()
@@ -78,7 +85,7 @@ func TestMockIoSetup(t *testing.T) {
 	got := md.IsReady()
 	assertBools(t, got, want)
 
-	md.Setup([]uint16{1, 3, 5}, []uint16{2, 4})
+	md.Setup(context.Background(), []uint16{1, 3, 5}, []uint16{2, 4})
 	want = true
 	got = md.IsReady()
 	assertBools(t, got, want)
@@ -86,7 +93,7 @@ func TestMockIoSetup(t *testing.T) {
 
 func TestMockIoGetAllIo(t *testing.T) {
 	md := MockIoDriver{}
-	md.Setup([]uint16{1, 3, 5}, []uint16{2, 4})
+	md.Setup(context.Background(), []uint16{1, 3, 5}, []uint16{2, 4})
 	inputs, outputs := md.GetAllIo()
 	assertUint16Slices(t, inputs, []uint16{1, 3, 5})
 	assertUint16Slices(t, outputs, []uint16{2, 4})
@@ -105,7 +112,7 @@ func TestMockIoGetUniqueId(t *testing.T) {
 
 func TestMockGetOutput(t *testing.T) {
 	md := MockIoDriver{}
-	md.Setup([]uint16{}, []uint16{3})
+	md.Setup(context.Background(), []uint16{}, []uint16{3})
 	output, err := md.GetOutput(3)
 	if err != nil {
 		t.Errorf("GetOutput returned err: %v", err)
@@ -125,3 +132,121 @@ func TestMockGetOutput(t *testing.T) {
 	got, _ = output.GetState()
 	assertBools(t, got, want)
 }
+
+func TestMockIoInputsAndFailures(t *testing.T) {
+	md := MockIoDriver{}
+	md.Setup(context.Background(), []uint16{1}, []uint16{2})
+	defer md.Close()
+
+	events := &eventRecorder{}
+	input, _ := md.GetInput(1)
+	err := input.SubscribeToPushEvent(events)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md.ToggleInput(1)
+	state, _ := input.GetState()
+	assertBools(t, state, true)
+	md.SetInput(1, false)
+	md.Push(1, PushEventLongPress)
+	time.Sleep(pushDoublePressWindow + 100*time.Millisecond)
+	got := events.getEvents()
+	if len(got) != 2 || got[0] != PushEventLongPress || got[1] != PushEventSinglePress {
+		t.Errorf("unexpected push events: %v", got)
+	}
+	assertBools(t, md.SetInput(5, true) != nil, true)
+
+	output, _ := md.GetOutput(2)
+	output.Set(true)
+	md.FailOutput(2, errors.New("relay stuck"))
+	err = output.Set(false)
+	assertBools(t, err != nil, true)
+	state, err = output.GetState()
+	assertBools(t, state && err != nil, true)
+	md.FailOutput(2, nil)
+	md.SetOutputLatency(2, 30*time.Millisecond)
+	start := time.Now()
+	err = output.Set(false)
+	assertBools(t, err == nil && time.Since(start) >= 30*time.Millisecond, true)
+
+	writes := md.Writes()
+	assertInts(t, len(writes), 3)
+	assertBools(t, writes[0].State && writes[0].Err == nil, true)
+	assertBools(t, writes[1].Err != nil, true)
+	assertBools(t, !writes[2].State && writes[2].Pin == 2, true)
+	md.ClearWrites()
+	assertInts(t, len(md.Writes()), 0)
+}
+
+func TestMockScenario(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scenario.json")
+	err := os.WriteFile(path, []byte(`{"Steps": [
+		{"Action": "set", "Pin": 1, "State": true},
+		{"After": "20ms", "Action": "fail", "Pin": 2, "Error": "broken"},
+		{"Action": "press", "Pin": 1, "Duration": "10ms"},
+		{"After": "10ms", "Action": "push", "Pin": 1, "Event": "double"}
+	]}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scenario, err := LoadMockScenario(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md := MockIoDriver{}
+	md.Setup(context.Background(), []uint16{1}, []uint16{2})
+	defer md.Close()
+	events := &eventRecorder{}
+	input, _ := md.GetInput(1)
+	input.SubscribeToPushEvent(events)
+
+	err = md.RunScenario(context.Background(), scenario)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, _ := md.GetOutput(2)
+	_, err = output.GetState()
+	assertBools(t, err != nil, true)
+	state, _ := input.GetState()
+	assertBools(t, state, false)
+	got := events.getEvents()
+	if len(got) == 0 || got[len(got)-1] != PushEventDoublePress {
+		t.Errorf("unexpected push events: %v", got)
+	}
+
+	err = md.RunScenario(context.Background(), &MockScenario{Steps: []MockStep{{Action: "jump", Pin: 1}}})
+	assertBools(t, err != nil, true)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = md.RunScenario(ctx, &MockScenario{Steps: []MockStep{{After: "1s", Action: "toggle", Pin: 1}}})
+	assertBools(t, err != nil, true)
+}
+
+func TestMockOutputLevel(t *testing.T) {
+	md := &MockIoDriver{}
+	err := md.Setup(context.Background(), nil, []uint16{3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, _ := md.GetOutput(3)
+	analog, ok := out.(AnalogOutput)
+	assertBools(t, ok, true)
+
+	analog.SetLevel(40)
+	level, _ := analog.GetLevel()
+	state, _ := out.GetState()
+	assertBools(t, level == 40 && state, true)
+
+	analog.SetLevel(120)
+	level, _ = analog.GetLevel()
+	assertBools(t, level == 100, true)
+
+	out.Set(false)
+	level, _ = analog.GetLevel()
+	assertBools(t, level == 0, true)
+
+	writes := md.Writes()
+	assertBools(t, len(writes) == 3 && writes[0].Level == 40 && writes[0].State, true)
+}
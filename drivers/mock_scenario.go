@@ -0,0 +1,122 @@
+package drivers
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const mockDefaultPressDuration = 100 * time.Millisecond
+
+// MockStep is a single scenario step executed After given duration since previous step.
+// Actions: "set" (input State), "toggle", "press" (for Duration, default 100ms), "push" (Event: single,
+// double or long), "fail" (output fails with Error), "recover" (output) and "latency" (output Set delay).
+type MockStep struct {
+	After    string
+	Action   string
+	Pin      uint16
+	State    bool
+	Event    string
+	Duration string
+	Error    string
+	Latency  string
+}
+
+// MockScenario is a list of timed steps, usually loaded from json file: {"Steps": [...]}.
+type MockScenario struct {
+	Steps []MockStep
+}
+
+// LoadMockScenario reads scenario from json file.
+func LoadMockScenario(path string) (*MockScenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read scenario")
+	}
+	scenario := &MockScenario{}
+	err = json.Unmarshal(data, scenario)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode scenario")
+	}
+	return scenario, nil
+}
+
+func parseMockDuration(value string, defaultValue time.Duration) (time.Duration, error) {
+	if len(value) == 0 {
+		return defaultValue, nil
+	}
+	return time.ParseDuration(value)
+}
+
+func parseMockPushEvent(event string) (PushEvent, error) {
+	switch strings.ToLower(event) {
+	case "single":
+		return PushEventSinglePress, nil
+	case "double":
+		return PushEventDoublePress, nil
+	case "long":
+		return PushEventLongPress, nil
+	}
+	return 0, errors.Errorf("unknown push event (%s)", event)
+}
+
+func (md *MockIoDriver) runStep(step MockStep) error {
+	switch strings.ToLower(step.Action) {
+	case "set":
+		return md.SetInput(step.Pin, step.State)
+	case "toggle":
+		return md.ToggleInput(step.Pin)
+	case "press":
+		duration, err := parseMockDuration(step.Duration, mockDefaultPressDuration)
+		if err != nil {
+			return errors.Wrap(err, "invalid Duration")
+		}
+		return md.PressInput(step.Pin, duration)
+	case "push":
+		event, err := parseMockPushEvent(step.Event)
+		if err != nil {
+			return err
+		}
+		return md.Push(step.Pin, event)
+	case "fail":
+		return md.FailOutput(step.Pin, errors.New(step.Error))
+	case "recover":
+		return md.FailOutput(step.Pin, nil)
+	case "latency":
+		latency, err := parseMockDuration(step.Latency, 0)
+		if err != nil {
+			return errors.Wrap(err, "invalid Latency")
+		}
+		return md.SetOutputLatency(step.Pin, latency)
+	}
+	return errors.Errorf("unknown action (%s)", step.Action)
+}
+
+// RunScenario executes scenario steps in order, it blocks until all steps are done or ctx is cancelled.
+func (md *MockIoDriver) RunScenario(ctx context.Context, scenario *MockScenario) error {
+	for ix, step := range scenario.Steps {
+		after, err := parseMockDuration(step.After, 0)
+		if err != nil {
+			return errors.Wrapf(err, "step %d: invalid After", ix)
+		}
+		if after > 0 {
+			timer := time.NewTimer(after)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+
+		err = md.runStep(step)
+		if err != nil {
+			return errors.Wrapf(err, "step %d (%s) failed", ix, step.Action)
+		}
+	}
+	return nil
+}
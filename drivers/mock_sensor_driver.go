@@ -0,0 +1,84 @@
+package drivers
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// MockSensorDriver is in-memory sensor driver for tests and simulation, values are set with SetValue
+// and re-applied on every Sync, so they do not get too old.
+type MockSensorDriver struct {
+	DriverName string // Name, default "mock_sensors"
+
+	sensors []Sensor
+	values  map[string]float64
+	ready   bool
+	lock    sync.Mutex
+}
+
+func (msd *MockSensorDriver) Setup(tss []Sensor) error {
+	msd.lock.Lock()
+	defer msd.lock.Unlock()
+
+	msd.sensors = tss
+	msd.values = map[string]float64{}
+	msd.ready = true
+	return nil
+}
+
+func (msd *MockSensorDriver) Close() error {
+	msd.ready = false
+	return nil
+}
+
+func (msd *MockSensorDriver) IsReady() bool {
+	return msd.ready
+}
+
+func (msd *MockSensorDriver) Name() string {
+	if len(msd.DriverName) > 0 {
+		return msd.DriverName
+	}
+	return "mock_sensors"
+}
+
+func (msd *MockSensorDriver) Sync() error {
+	msd.lock.Lock()
+	defer msd.lock.Unlock()
+
+	for _, sensor := range msd.sensors {
+		value, isSet := msd.values[sensor.GetId()]
+		if isSet {
+			sensor.SetValue(value)
+		}
+	}
+	return nil
+}
+
+// SetValue sets value of sensor with given id.
+func (msd *MockSensorDriver) SetValue(id string, value float64) error {
+	sensor, err := msd.FindSensor(id)
+	if err != nil {
+		return err
+	}
+
+	msd.lock.Lock()
+	defer msd.lock.Unlock()
+
+	msd.values[sensor.GetId()] = value
+	return sensor.SetValue(value)
+}
+
+func (msd *MockSensorDriver) FindSensor(id string) (Sensor, error) {
+	msd.lock.Lock()
+	defer msd.lock.Unlock()
+
+	for _, s := range msd.sensors {
+		if strings.EqualFold(id, s.GetId()) {
+			return s, nil
+		}
+	}
+	return nil, errors.Errorf("sensor %s was not found in driver %s", id, msd.Name())
+}
@@ -0,0 +1,568 @@
+package drivers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/simonvetter/modbus"
+)
+
+const modbusDriverName = "modbus"
+const modbusDefaultTimeout = time.Second
+const modbusDefaultPollInterval = 100 * time.Millisecond
+const modbusDefaultCoalesceWindow = 20 * time.Millisecond
+
+// reading few unused coils is cheaper than another request
+const modbusMaxRangeGap = 8
+const modbusMaxReadBits = 2000
+const modbusMaxWriteBits = 1968
+
+type modbusKey struct {
+	unitId  uint8
+	address uint16
+}
+
+type modbusRange struct {
+	start uint16
+	count uint16
+}
+
+// modbusRanges groups sorted, unique addresses into ranges read (or written) with single request.
+func modbusRanges(addresses []uint16, maxGap uint16, maxCount uint16) (ranges []modbusRange) {
+	sorted := append([]uint16{}, addresses...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	for _, address := range sorted {
+		if len(ranges) > 0 {
+			last := &ranges[len(ranges)-1]
+			end := last.start + last.count
+			if address < end {
+				continue
+			}
+			if address-end <= maxGap && address-last.start < maxCount {
+				last.count = address - last.start + 1
+				continue
+			}
+		}
+		ranges = append(ranges, modbusRange{start: address, count: 1})
+	}
+	return
+}
+
+// ModbusUnit maps pins to coils and discrete inputs of a single device (unit id) on the bus,
+// pin CoilPinOffset + n is coil n, pin InputPinOffset + n is discrete input n.
+type ModbusUnit struct {
+	UnitId         uint8
+	CoilPinOffset  uint16
+	CoilCount      uint16
+	InputPinOffset uint16
+	InputCount     uint16
+}
+
+type ModbusInput struct {
+	key    modbusKey
+	pin    uint16
+	driver *ModbusIO
+	push   pushDetector
+}
+
+func (mbin *ModbusInput) GetState() (bool, error) {
+	state, err := mbin.driver.getCached(mbin.driver.inputStates, mbin.key)
+	if mbin.driver.InvertInputs {
+		state = !state
+	}
+	return state, err
+}
+
+func (mbin *ModbusInput) SubscribeToPushEvent(listener EventListener) error {
+	mbin.push.SetListener(listener)
+	return nil
+}
+
+type ModbusOutput struct {
+	key    modbusKey
+	pin    uint16
+	driver *ModbusIO
+}
+
+func (mout *ModbusOutput) GetState() (bool, error) {
+	return mout.driver.getCached(mout.driver.coilStates, mout.key)
+}
+
+// Set writes coil together with other outputs set within CoalesceDuration, see ModbusIO.writeCoils.
+func (mout *ModbusOutput) Set(state bool) error {
+	return mout.driver.writeCoil(mout.key, state)
+}
+
+// ModbusIO drives coils and discrete inputs of modbus relay modules. Url selects transport:
+// "tcp://host:502", "rtuovertcp://host:502" or "rtu:///dev/ttyUSB0" (with Speed, Parity, StopBits).
+// All units are polled with batched reads, inputs feed push detection. Outputs set within CoalesceDuration
+// are written together. Holding registers can be used for temperature sensors, see ModbusSensors.
+type ModbusIO struct {
+	Url          string
+	Speed        uint
+	DataBits     uint
+	Parity       string // "none" (default), "even", "odd"
+	StopBits     uint
+	Timeout      string // default "1s"
+	PollInterval string // default "100ms"
+	InvertInputs bool
+
+	CoalesceDuration string // default "20ms"
+
+	Units []ModbusUnit
+
+	client    *modbus.ModbusClient
+	connected bool
+	lock      sync.Mutex
+
+	inputs      []*ModbusInput
+	outputs     []*ModbusOutput
+	coilStates  map[modbusKey]bool
+	inputStates map[modbusKey]bool
+	unitErrors  map[uint8]error
+	stateLock   sync.Mutex
+
+	queueLock      sync.Mutex
+	batch          *modbusBatch
+	coalesceWindow time.Duration
+
+	sensors *ModbusSensors
+	done    chan bool
+	isReady bool
+}
+
+func (mio *ModbusIO) getClientConfig() (config modbus.ClientConfiguration, err error) {
+	config = modbus.ClientConfiguration{
+		URL:      mio.Url,
+		Speed:    mio.Speed,
+		DataBits: mio.DataBits,
+		StopBits: mio.StopBits,
+		Timeout:  modbusDefaultTimeout,
+		Logger:   log.Default(),
+	}
+
+	switch strings.ToLower(mio.Parity) {
+	case "", "none":
+		config.Parity = modbus.PARITY_NONE
+	case "even":
+		config.Parity = modbus.PARITY_EVEN
+	case "odd":
+		config.Parity = modbus.PARITY_ODD
+	default:
+		err = errors.Errorf("unsupported parity (%s)", mio.Parity)
+		return
+	}
+
+	if len(mio.Timeout) > 0 {
+		config.Timeout, err = time.ParseDuration(mio.Timeout)
+		if err != nil {
+			err = errors.Wrap(err, "parsing Timeout failed")
+		}
+	}
+	return
+}
+
+// connect opens modbus client, it is shared by io and sensor part of the driver.
+func (mio *ModbusIO) connect() error {
+	mio.lock.Lock()
+	defer mio.lock.Unlock()
+
+	if mio.client != nil {
+		return nil
+	}
+
+	config, err := mio.getClientConfig()
+	if err != nil {
+		return err
+	}
+	mio.client, err = modbus.NewClient(&config)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create modbus client (%s)", mio.Url)
+	}
+	return nil
+}
+
+// request runs fn for given unit id, connection is (re)opened when needed and closed after i/o error,
+// so the next request reconnects.
+func (mio *ModbusIO) request(unitId uint8, fn func(client *modbus.ModbusClient) error) error {
+	mio.lock.Lock()
+	defer mio.lock.Unlock()
+
+	if mio.client == nil {
+		return errors.New("modbus client not connected")
+	}
+
+	if !mio.connected {
+		err := mio.client.Open()
+		if err != nil {
+			return errors.Wrapf(err, "failed to open modbus connection (%s)", mio.Url)
+		}
+		mio.connected = true
+	}
+
+	mio.client.SetUnitId(unitId)
+	err := fn(mio.client)
+	if err != nil && !isModbusException(err) {
+		mio.client.Close()
+		mio.connected = false
+	}
+	return err
+}
+
+func isModbusException(err error) bool {
+	switch err {
+	case modbus.ErrIllegalFunction, modbus.ErrIllegalDataAddress, modbus.ErrIllegalDataValue,
+		modbus.ErrServerDeviceFailure, modbus.ErrAcknowledge, modbus.ErrServerDeviceBusy,
+		modbus.ErrGWPathUnavailable, modbus.ErrGWTargetFailedToRespond:
+		return true
+	}
+	return false
+}
+
+func (mio *ModbusIO) getCached(states map[modbusKey]bool, key modbusKey) (bool, error) {
+	mio.stateLock.Lock()
+	defer mio.stateLock.Unlock()
+
+	if err := mio.unitErrors[key.unitId]; err != nil {
+		return states[key], errors.Wrapf(err, "modbus unit %d failed", key.unitId)
+	}
+	return states[key], nil
+}
+
+func (mio *ModbusIO) findUnit(pin uint16, isInput bool) (key modbusKey, err error) {
+	for _, unit := range mio.Units {
+		offset, count := unit.CoilPinOffset, unit.CoilCount
+		if isInput {
+			offset, count = unit.InputPinOffset, unit.InputCount
+		}
+		if pin >= offset && pin < offset+count {
+			return modbusKey{unitId: unit.UnitId, address: pin - offset}, nil
+		}
+	}
+	err = errors.Errorf("pin %d not mapped to any modbus unit", pin)
+	return
+}
+
+// getPins returns inputs and outputs, which are changed by Reconfigure.
+func (mio *ModbusIO) getPins() ([]*ModbusInput, []*ModbusOutput) {
+	mio.stateLock.Lock()
+	defer mio.stateLock.Unlock()
+
+	return mio.inputs, mio.outputs
+}
+
+func (mio *ModbusIO) getUnitAddresses(isInput bool) map[uint8][]uint16 {
+	inputs, outputs := mio.getPins()
+	addresses := map[uint8][]uint16{}
+	if isInput {
+		for _, in := range inputs {
+			addresses[in.key.unitId] = append(addresses[in.key.unitId], in.key.address)
+		}
+	} else {
+		for _, out := range outputs {
+			addresses[out.key.unitId] = append(addresses[out.key.unitId], out.key.address)
+		}
+	}
+	return addresses
+}
+
+func (mio *ModbusIO) readBits(unitId uint8, addresses []uint16, isInput bool) (map[modbusKey]bool, error) {
+	states := map[modbusKey]bool{}
+	for _, r := range modbusRanges(addresses, modbusMaxRangeGap, modbusMaxReadBits) {
+		var values []bool
+		err := mio.request(unitId, func(client *modbus.ModbusClient) (err error) {
+			if isInput {
+				values, err = client.ReadDiscreteInputs(r.start, r.count)
+			} else {
+				values, err = client.ReadCoils(r.start, r.count)
+			}
+			return
+		})
+		if err != nil {
+			return nil, err
+		}
+		for i, value := range values {
+			states[modbusKey{unitId: unitId, address: r.start + uint16(i)}] = value
+		}
+	}
+	return states, nil
+}
+
+// poll reads all used coils and discrete inputs, returns first error.
+func (mio *ModbusIO) poll() (pollErr error) {
+	unitErrors := map[uint8]error{}
+	coils := map[modbusKey]bool{}
+	inputs := map[modbusKey]bool{}
+
+	for _, isInput := range []bool{false, true} {
+		for unitId, addresses := range mio.getUnitAddresses(isInput) {
+			if unitErrors[unitId] != nil {
+				continue
+			}
+			states, err := mio.readBits(unitId, addresses, isInput)
+			if err != nil {
+				unitErrors[unitId] = err
+				if pollErr == nil {
+					pollErr = errors.Wrapf(err, "failed to read unit %d", unitId)
+				}
+				continue
+			}
+			for key, state := range states {
+				if isInput {
+					inputs[key] = state
+				} else {
+					coils[key] = state
+				}
+			}
+		}
+	}
+
+	mio.stateLock.Lock()
+	mio.unitErrors = unitErrors
+	for key, state := range coils {
+		mio.coilStates[key] = state
+	}
+	for key, state := range inputs {
+		mio.inputStates[key] = state
+	}
+	mio.stateLock.Unlock()
+
+	now := time.Now()
+	pins, _ := mio.getPins()
+	for _, in := range pins {
+		state, err := in.GetState()
+		if err == nil {
+			in.push.Update(state, now)
+		} else {
+			in.push.Tick(now)
+		}
+	}
+	return
+}
+
+func (mio *ModbusIO) pollInputs(ctx context.Context, done chan bool, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastErr error
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := mio.poll()
+			if err != nil && lastErr == nil {
+				log.Printf("modbus | polling failed: %v", err)
+			}
+			lastErr = err
+		}
+	}
+}
+
+func (mio *ModbusIO) Setup(ctx context.Context, inputs []uint16, outputs []uint16) error {
+	interval := modbusDefaultPollInterval
+	if len(mio.PollInterval) > 0 {
+		var err error
+		interval, err = time.ParseDuration(mio.PollInterval)
+		if err != nil {
+			return errors.Wrap(err, "parsing PollInterval failed")
+		}
+	}
+	mio.coalesceWindow = modbusDefaultCoalesceWindow
+	if len(mio.CoalesceDuration) > 0 {
+		var err error
+		mio.coalesceWindow, err = time.ParseDuration(mio.CoalesceDuration)
+		if err != nil {
+			return errors.Wrap(err, "parsing CoalesceDuration failed")
+		}
+	}
+
+	mio.inputs = []*ModbusInput{}
+	mio.outputs = []*ModbusOutput{}
+	mio.coilStates = map[modbusKey]bool{}
+	mio.inputStates = map[modbusKey]bool{}
+
+	for _, inPin := range inputs {
+		key, err := mio.findUnit(inPin, true)
+		if err != nil {
+			return err
+		}
+		mio.inputs = append(mio.inputs, &ModbusInput{key: key, pin: inPin, driver: mio})
+	}
+	for _, outPin := range outputs {
+		key, err := mio.findUnit(outPin, false)
+		if err != nil {
+			return err
+		}
+		mio.outputs = append(mio.outputs, &ModbusOutput{key: key, pin: outPin, driver: mio})
+	}
+
+	err := mio.connect()
+	if err != nil {
+		return err
+	}
+	err = mio.poll()
+	if err != nil {
+		return errors.Wrap(err, "initial modbus read failed")
+	}
+
+	mio.done = make(chan bool)
+	go mio.pollInputs(ctx, mio.done, interval)
+
+	mio.isReady = true
+	return nil
+}
+
+func (mio *ModbusIO) NameId() string {
+	return modbusDriverName
+}
+
+func (mio *ModbusIO) IsReady() bool {
+	return mio.isReady
+}
+
+// Close switches all outputs off, coils are written in contiguous ranges (write multiple coils).
+func (mio *ModbusIO) Close() error {
+	if mio.isReady {
+		mio.isReady = false
+		close(mio.done)
+
+		coils := map[modbusKey]bool{}
+		for _, out := range mio.outputs {
+			coils[out.key] = false
+		}
+		for _, err := range mio.writeCoils(coils) {
+			log.Printf("modbus | failed to switch off outputs: %v", err)
+		}
+	}
+
+	if mio.sensors != nil && mio.sensors.ready {
+		return nil
+	}
+	return mio.disconnect()
+}
+
+func (mio *ModbusIO) disconnect() error {
+	mio.lock.Lock()
+	defer mio.lock.Unlock()
+
+	if mio.client == nil {
+		return nil
+	}
+	var err error
+	if mio.connected {
+		err = mio.client.Close()
+	}
+	mio.client = nil
+	mio.connected = false
+	return err
+}
+
+func (mio *ModbusIO) GetInput(pin uint16) (DigitalInput, error) {
+	for _, in := range mio.inputs {
+		if in.pin == pin {
+			return in, nil
+		}
+	}
+	return nil, fmt.Errorf("modbus input (pin: %d) not found", pin)
+}
+
+func (mio *ModbusIO) GetOutput(pin uint16) (DigitalOutput, error) {
+	for _, out := range mio.outputs {
+		if out.pin == pin {
+			return out, nil
+		}
+	}
+	return nil, fmt.Errorf("modbus output (pin: %d) not found", pin)
+}
+
+func (mio *ModbusIO) GetAllIo() (inputs []uint16, outputs []uint16) {
+	for _, in := range mio.inputs {
+		inputs = append(inputs, in.pin)
+	}
+	for _, out := range mio.outputs {
+		outputs = append(outputs, out.pin)
+	}
+	return
+}
+
+// Reconfigure takes Units of next, adds pins and switches removed outputs off. Pins present before and after
+// have to stay mapped to the same coil or discrete input.
+func (mio *ModbusIO) Reconfigure(ctx context.Context, next IoDriver, inputs []uint16, outputs []uint16) error {
+	err := checkReconfigure(mio, next, "Units")
+	if err != nil {
+		return err
+	}
+	nextMio := next.(*ModbusIO)
+
+	keptInputs := []*ModbusInput{}
+	for _, in := range mio.inputs {
+		if !hasPin(inputs, in.pin) {
+			continue
+		}
+		key, err := nextMio.findUnit(in.pin, true)
+		if err == nil && key != in.key {
+			return errors.Errorf("input %d mapped to other discrete input, restart needed", in.pin)
+		}
+		keptInputs = append(keptInputs, in)
+	}
+	for _, inPin := range inputs {
+		if _, err := mio.GetInput(inPin); err == nil {
+			continue
+		}
+		key, err := nextMio.findUnit(inPin, true)
+		if err != nil {
+			return err
+		}
+		keptInputs = append(keptInputs, &ModbusInput{key: key, pin: inPin, driver: mio})
+	}
+
+	keptOutputs := []*ModbusOutput{}
+	removedCoils := map[modbusKey]bool{}
+	for _, out := range mio.outputs {
+		if !hasPin(outputs, out.pin) {
+			removedCoils[out.key] = false
+			continue
+		}
+		key, err := nextMio.findUnit(out.pin, false)
+		if err == nil && key != out.key {
+			return errors.Errorf("output %d mapped to other coil, restart needed", out.pin)
+		}
+		keptOutputs = append(keptOutputs, out)
+	}
+	for _, outPin := range outputs {
+		if _, err := mio.GetOutput(outPin); err == nil {
+			continue
+		}
+		key, err := nextMio.findUnit(outPin, false)
+		if err != nil {
+			return err
+		}
+		keptOutputs = append(keptOutputs, &ModbusOutput{key: key, pin: outPin, driver: mio})
+	}
+
+	for _, err := range mio.writeCoils(removedCoils) {
+		log.Printf("modbus | failed to switch off removed outputs: %v", err)
+	}
+
+	mio.stateLock.Lock()
+	mio.inputs = keptInputs
+	mio.outputs = keptOutputs
+	mio.stateLock.Unlock()
+	mio.Units = nextMio.Units
+
+	err = mio.poll()
+	if err != nil {
+		return errors.Wrap(err, "modbus read after reconfigure failed")
+	}
+	return nil
+}
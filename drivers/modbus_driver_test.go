@@ -0,0 +1,348 @@
+package drivers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/simonvetter/modbus"
+)
+
+type mockModbusUnit struct {
+	coils     [32]bool
+	inputs    [32]bool
+	registers map[uint16]uint16
+}
+
+type mockModbusHandler struct {
+	units      map[uint8]*mockModbusUnit
+	reads      int
+	multiWrite int
+	lock       sync.Mutex
+}
+
+func (mmh *mockModbusHandler) getUnit(unitId uint8) (*mockModbusUnit, error) {
+	unit, ok := mmh.units[unitId]
+	if !ok {
+		return nil, modbus.ErrGWTargetFailedToRespond
+	}
+	return unit, nil
+}
+
+func (mmh *mockModbusHandler) HandleCoils(req *modbus.CoilsRequest) (res []bool, err error) {
+	mmh.lock.Lock()
+	defer mmh.lock.Unlock()
+
+	unit, err := mmh.getUnit(req.UnitId)
+	if err != nil {
+		return
+	}
+	if int(req.Addr)+int(req.Quantity) > len(unit.coils) {
+		return nil, modbus.ErrIllegalDataAddress
+	}
+	if req.IsWrite {
+		if req.Quantity > 1 {
+			mmh.multiWrite++
+		}
+		copy(unit.coils[req.Addr:], req.Args)
+		return
+	}
+	mmh.reads++
+	return append(res, unit.coils[req.Addr:req.Addr+req.Quantity]...), nil
+}
+
+func (mmh *mockModbusHandler) HandleDiscreteInputs(req *modbus.DiscreteInputsRequest) (res []bool, err error) {
+	mmh.lock.Lock()
+	defer mmh.lock.Unlock()
+
+	unit, err := mmh.getUnit(req.UnitId)
+	if err != nil {
+		return
+	}
+	if int(req.Addr)+int(req.Quantity) > len(unit.inputs) {
+		return nil, modbus.ErrIllegalDataAddress
+	}
+	mmh.reads++
+	return append(res, unit.inputs[req.Addr:req.Addr+req.Quantity]...), nil
+}
+
+func (mmh *mockModbusHandler) HandleHoldingRegisters(req *modbus.HoldingRegistersRequest) (res []uint16, err error) {
+	mmh.lock.Lock()
+	defer mmh.lock.Unlock()
+
+	unit, err := mmh.getUnit(req.UnitId)
+	if err != nil {
+		return
+	}
+	for i := uint16(0); i < req.Quantity; i++ {
+		value, ok := unit.registers[req.Addr+i]
+		if !ok {
+			return nil, modbus.ErrIllegalDataAddress
+		}
+		res = append(res, value)
+	}
+	return
+}
+
+func (mmh *mockModbusHandler) HandleInputRegisters(req *modbus.InputRegistersRequest) (res []uint16, err error) {
+	return nil, modbus.ErrIllegalFunction
+}
+
+func (mmh *mockModbusHandler) setInput(unitId uint8, address int, state bool) {
+	mmh.lock.Lock()
+	defer mmh.lock.Unlock()
+	mmh.units[unitId].inputs[address] = state
+}
+
+func (mmh *mockModbusHandler) setUnit(unitId uint8, unit *mockModbusUnit) {
+	mmh.lock.Lock()
+	defer mmh.lock.Unlock()
+	if unit == nil {
+		delete(mmh.units, unitId)
+	} else {
+		mmh.units[unitId] = unit
+	}
+}
+
+func (mmh *mockModbusHandler) getCoil(unitId uint8, address int) bool {
+	mmh.lock.Lock()
+	defer mmh.lock.Unlock()
+	return mmh.units[unitId].coils[address]
+}
+
+func (mmh *mockModbusHandler) getCounters() (reads int, multiWrite int) {
+	mmh.lock.Lock()
+	defer mmh.lock.Unlock()
+	return mmh.reads, mmh.multiWrite
+}
+
+func startMockModbusServer(t testing.TB, handler *mockModbusHandler) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	server, err := modbus.NewServer(&modbus.ServerConfiguration{
+		URL:        "tcp://" + address,
+		Timeout:    10 * time.Second,
+		MaxClients: 4,
+		Logger:     log.New(io.Discard, "", 0),
+	}, handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Stop() })
+
+	return "tcp://" + address
+}
+
+type modbusTestSensor struct {
+	id    string
+	tags  map[string]string
+	value float64
+}
+
+func (mts *modbusTestSensor) GetValue() (float64, error)   { return mts.value, nil }
+func (mts *modbusTestSensor) SetValue(value float64) error { mts.value = value; return nil }
+func (mts *modbusTestSensor) GetTags() map[string]string   { return mts.tags }
+func (mts *modbusTestSensor) GetId() string                { return mts.id }
+func (mts *modbusTestSensor) GetMeasurement() Measurement  { return MeasurementTemperature }
+
+func TestModbusRanges(t *testing.T) {
+	ranges := modbusRanges([]uint16{5, 1, 2, 3, 20, 3, 40, 48}, 8, 2000)
+	got := fmt.Sprint(ranges)
+	want := "[{1 5} {20 1} {40 9}]"
+	if got != want {
+		t.Errorf("got %s want %s", got, want)
+	}
+
+	ranges = modbusRanges([]uint16{1, 2, 4}, 0, 2000)
+	got = fmt.Sprint(ranges)
+	want = "[{1 2} {4 1}]"
+	if got != want {
+		t.Errorf("got %s want %s", got, want)
+	}
+
+	ranges = modbusRanges([]uint16{0, 1, 2, 3}, 0, 3)
+	assertInts(t, len(ranges), 2)
+}
+
+func TestModbusIo(t *testing.T) {
+	handler := &mockModbusHandler{units: map[uint8]*mockModbusUnit{
+		1: {registers: map[uint16]uint16{}},
+		2: {registers: map[uint16]uint16{100: 215, 101: 0xFFEC}},
+	}}
+	handler.units[1].coils[2] = true
+	url := startMockModbusServer(t, handler)
+
+	mio := &ModbusIO{
+		Url:              url,
+		PollInterval:     "10ms",
+		CoalesceDuration: "50ms",
+		Units: []ModbusUnit{
+			{UnitId: 1, CoilPinOffset: 0, CoilCount: 8, InputPinOffset: 100, InputCount: 8},
+			{UnitId: 2, CoilPinOffset: 8, CoilCount: 8},
+		},
+	}
+
+	err := mio.Setup(context.Background(), []uint16{100}, []uint16{20})
+	if err == nil {
+		t.Error("expected error for not mapped pin")
+	}
+
+	err = mio.Setup(context.Background(), []uint16{100, 103}, []uint16{0, 1, 2, 5, 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mio.Close()
+
+	// unit 1 coils 0-5 and inputs 0-3 are read with single request each, unit 2 with another one
+	reads, _ := handler.getCounters()
+	assertInts(t, reads, 3)
+
+	out2, _ := mio.GetOutput(2)
+	state, err := out2.GetState()
+	assertBools(t, err == nil && state, true)
+
+	out8, _ := mio.GetOutput(8)
+	err = out8.Set(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertBools(t, handler.getCoil(2, 0), true)
+	state, _ = out8.GetState()
+	assertBools(t, state, true)
+
+	// outputs set together are written with single write multiple coils request
+	_, multiWrite := handler.getCounters()
+	var wg sync.WaitGroup
+	for _, pin := range []uint16{0, 1} {
+		wg.Add(1)
+		go func(pin uint16) {
+			defer wg.Done()
+			out, _ := mio.GetOutput(pin)
+			err := out.Set(true)
+			if err != nil {
+				t.Error(err)
+			}
+		}(pin)
+	}
+	wg.Wait()
+	_, multiWriteAfter := handler.getCounters()
+	assertInts(t, multiWriteAfter-multiWrite, 1)
+	assertBools(t, handler.getCoil(1, 0) && handler.getCoil(1, 1) && handler.getCoil(1, 2), true)
+
+	events := &eventRecorder{}
+	in103, _ := mio.GetInput(103)
+	in103.SubscribeToPushEvent(events)
+	handler.setInput(1, 3, true)
+	time.Sleep(50 * time.Millisecond)
+	handler.setInput(1, 3, false)
+	time.Sleep(pushDoublePressWindow + 100*time.Millisecond)
+	got := events.getEvents()
+	if len(got) != 1 || got[0] != PushEventSinglePress {
+		t.Errorf("unexpected push events: %v", got)
+	}
+
+	sensors := mio.GetSensorDriver()
+	temperature := &modbusTestSensor{id: "2:100"}
+	negative := &modbusTestSensor{id: "2:0x65"}
+	err = sensors.Setup([]Sensor{temperature, negative})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if temperature.value != 21.5 || negative.value != -2 {
+		t.Errorf("unexpected sensor values: %f, %f", temperature.value, negative.value)
+	}
+	err = sensors.Setup([]Sensor{&modbusTestSensor{id: "2:100", tags: map[string]string{"register": "input"}}})
+	if err == nil {
+		t.Error("expected error reading unsupported input register")
+	}
+
+	handler.setUnit(2, nil)
+	time.Sleep(50 * time.Millisecond)
+	_, err = out8.GetState()
+	assertBools(t, err != nil, true)
+	_, err = out2.GetState()
+	assertBools(t, err == nil, true)
+	handler.setUnit(2, &mockModbusUnit{})
+
+	mio.Close()
+	_, multiWriteAfter = handler.getCounters()
+	assertBools(t, multiWriteAfter > multiWrite+1, true)
+	assertBools(t, handler.getCoil(1, 2), false)
+}
+
+func TestModbusReconfigure(t *testing.T) {
+	handler := &mockModbusHandler{units: map[uint8]*mockModbusUnit{
+		1: {registers: map[uint16]uint16{}},
+		2: {registers: map[uint16]uint16{}},
+	}}
+	url := startMockModbusServer(t, handler)
+
+	mio := &ModbusIO{
+		Url:          url,
+		PollInterval: "10ms",
+		Units:        []ModbusUnit{{UnitId: 1, CoilPinOffset: 0, CoilCount: 8, InputPinOffset: 100, InputCount: 8}},
+	}
+	err := mio.Setup(context.Background(), nil, []uint16{0, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mio.Close()
+	out0, _ := mio.GetOutput(0)
+	out1, _ := mio.GetOutput(1)
+	out0.Set(true)
+	out1.Set(true)
+
+	next := &ModbusIO{
+		Url:          url,
+		PollInterval: "10ms",
+		Units: []ModbusUnit{
+			{UnitId: 1, CoilPinOffset: 0, CoilCount: 8, InputPinOffset: 100, InputCount: 8},
+			{UnitId: 2, CoilPinOffset: 8, CoilCount: 8},
+		},
+	}
+	err = mio.Reconfigure(context.Background(), next, []uint16{101}, []uint16{0, 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// output 0 stays on, removed output 1 is switched off, added pins of new unit work
+	assertBools(t, handler.getCoil(1, 0), true)
+	assertBools(t, handler.getCoil(1, 1), false)
+	state, _ := out0.GetState()
+	assertBools(t, state, true)
+	out8, err := mio.GetOutput(8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = out8.Set(true)
+	assertBools(t, err == nil && handler.getCoil(2, 0), true)
+
+	in101, _ := mio.GetInput(101)
+	handler.setInput(1, 1, true)
+	waitFor(t, func() bool {
+		state, _ := in101.GetState()
+		return state
+	}, "added input 101 polled")
+
+	moved := &ModbusIO{Url: url, PollInterval: "10ms", Units: []ModbusUnit{{UnitId: 1, CoilPinOffset: 4, CoilCount: 8}}}
+	err = mio.Reconfigure(context.Background(), moved, nil, []uint16{8})
+	assertBools(t, err != nil, true)
+	slower := &ModbusIO{Url: url, PollInterval: "1s", Units: next.Units}
+	err = mio.Reconfigure(context.Background(), slower, nil, []uint16{0})
+	assertBools(t, err != nil, true)
+}
@@ -0,0 +1,81 @@
+package drivers
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/simonvetter/modbus"
+)
+
+// modbusBatch collects coil writes queued within coalesce window, later write of the same coil
+// replaces the earlier one.
+type modbusBatch struct {
+	coils map[modbusKey]bool
+	done  chan struct{}
+	errs  map[uint8]error
+}
+
+// writeCoil queues coil write and waits until the batch it landed in is written.
+func (mio *ModbusIO) writeCoil(key modbusKey, state bool) error {
+	mio.queueLock.Lock()
+	if mio.batch == nil {
+		mio.batch = &modbusBatch{coils: map[modbusKey]bool{}, done: make(chan struct{})}
+		time.AfterFunc(mio.coalesceWindow, mio.flushBatch)
+	}
+	batch := mio.batch
+	batch.coils[key] = state
+	mio.queueLock.Unlock()
+
+	<-batch.done
+	return batch.errs[key.unitId]
+}
+
+func (mio *ModbusIO) flushBatch() {
+	mio.queueLock.Lock()
+	batch := mio.batch
+	mio.batch = nil
+	mio.queueLock.Unlock()
+
+	if batch == nil {
+		return
+	}
+
+	batch.errs = mio.writeCoils(batch.coils)
+	close(batch.done)
+}
+
+// writeCoils writes coils of each unit, contiguous coils with single request (write multiple coils).
+// Errors are returned by unit id.
+func (mio *ModbusIO) writeCoils(coils map[modbusKey]bool) map[uint8]error {
+	addresses := map[uint8][]uint16{}
+	for key := range coils {
+		addresses[key.unitId] = append(addresses[key.unitId], key.address)
+	}
+
+	errs := map[uint8]error{}
+	for unitId, unitAddresses := range addresses {
+		for _, r := range modbusRanges(unitAddresses, 0, modbusMaxWriteBits) {
+			values := make([]bool, r.count)
+			for i := range values {
+				values[i] = coils[modbusKey{unitId: unitId, address: r.start + uint16(i)}]
+			}
+			err := mio.request(unitId, func(client *modbus.ModbusClient) error {
+				if len(values) == 1 {
+					return client.WriteCoil(r.start, values[0])
+				}
+				return client.WriteCoils(r.start, values)
+			})
+			if err != nil {
+				errs[unitId] = errors.Wrapf(err, "failed to write coils %d-%d of unit %d", r.start, r.start+r.count-1, unitId)
+				break
+			}
+
+			mio.stateLock.Lock()
+			for i, value := range values {
+				mio.coilStates[modbusKey{unitId: unitId, address: r.start + uint16(i)}] = value
+			}
+			mio.stateLock.Unlock()
+		}
+	}
+	return errs
+}
@@ -0,0 +1,150 @@
+package drivers
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/simonvetter/modbus"
+)
+
+const modbusSensorDriverName = "modbus"
+const modbusDefaultSensorScale = 0.1
+
+type modbusRegister struct {
+	unitId   uint8
+	address  uint16
+	regType  modbus.RegType
+	scale    float64
+	unsigned bool
+}
+
+// parseModbusRegister parses sensor Id "<unit id>:<register address>" and optional tags:
+// "register" ("holding" default or "input"), "scale" (default 0.1) and "unsigned" ("true").
+func parseModbusRegister(sensor Sensor) (reg modbusRegister, err error) {
+	parts := strings.Split(sensor.GetId(), ":")
+	if len(parts) != 2 {
+		err = errors.Errorf("invalid modbus sensor id (%s), expected <unit id>:<register address>", sensor.GetId())
+		return
+	}
+	unitId, err := strconv.ParseUint(parts[0], 10, 8)
+	if err != nil {
+		err = errors.Wrapf(err, "invalid unit id in sensor id (%s)", sensor.GetId())
+		return
+	}
+	address, err := strconv.ParseUint(parts[1], 0, 16)
+	if err != nil {
+		err = errors.Wrapf(err, "invalid register address in sensor id (%s)", sensor.GetId())
+		return
+	}
+	reg = modbusRegister{unitId: uint8(unitId), address: uint16(address), regType: modbus.HOLDING_REGISTER, scale: modbusDefaultSensorScale}
+
+	tags := sensor.GetTags()
+	switch strings.ToLower(tags["register"]) {
+	case "", "holding":
+	case "input":
+		reg.regType = modbus.INPUT_REGISTER
+	default:
+		err = errors.Errorf("unsupported register type (%s) of sensor %s", tags["register"], sensor.GetId())
+		return
+	}
+	if len(tags["scale"]) > 0 {
+		reg.scale, err = strconv.ParseFloat(tags["scale"], 64)
+		if err != nil {
+			err = errors.Wrapf(err, "invalid scale of sensor %s", sensor.GetId())
+			return
+		}
+	}
+	reg.unsigned = strings.EqualFold(tags["unsigned"], "true")
+	return
+}
+
+func (reg modbusRegister) getValue(raw uint16) float64 {
+	if reg.unsigned {
+		return float64(raw) * reg.scale
+	}
+	return float64(int16(raw)) * reg.scale
+}
+
+// ModbusSensors is the sensor driver part of ModbusIO, it shares its connection.
+// Values of any measurement are read from holding (or input) registers, see parseModbusRegister for sensor Id format.
+type ModbusSensors struct {
+	driver  *ModbusIO
+	sensors []Sensor
+	ready   bool
+}
+
+// GetSensorDriver returns sensor driver using the same modbus connection.
+func (mio *ModbusIO) GetSensorDriver() *ModbusSensors {
+	if mio.sensors == nil {
+		mio.sensors = &ModbusSensors{driver: mio}
+	}
+	return mio.sensors
+}
+
+func (ms *ModbusSensors) Setup(tss []Sensor) error {
+	for _, sensor := range tss {
+		_, err := parseModbusRegister(sensor)
+		if err != nil {
+			return errors.Wrap(err, "failed to init modbus sensor driver")
+		}
+	}
+	ms.sensors = tss
+
+	err := ms.driver.connect()
+	if err != nil {
+		return errors.Wrap(err, "failed to init modbus sensor driver")
+	}
+	err = ms.Sync()
+	if err != nil {
+		return errors.Wrap(err, "failed to init modbus sensor driver")
+	}
+
+	ms.ready = true
+	return nil
+}
+
+func (ms *ModbusSensors) Close() error {
+	ms.ready = false
+	if ms.driver.isReady {
+		return nil
+	}
+	return ms.driver.disconnect()
+}
+
+func (ms *ModbusSensors) IsReady() bool {
+	return ms.ready
+}
+
+func (ms *ModbusSensors) Name() string {
+	return modbusSensorDriverName
+}
+
+func (ms *ModbusSensors) Sync() error {
+	for _, sensor := range ms.sensors {
+		reg, err := parseModbusRegister(sensor)
+		if err != nil {
+			return err
+		}
+
+		var raw uint16
+		err = ms.driver.request(reg.unitId, func(client *modbus.ModbusClient) (err error) {
+			raw, err = client.ReadRegister(reg.address, reg.regType)
+			return
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to read register of sensor %s", sensor.GetId())
+		}
+		sensor.SetValue(reg.getValue(raw))
+	}
+	return nil
+}
+
+func (ms *ModbusSensors) FindSensor(id string) (Sensor, error) {
+	for _, s := range ms.sensors {
+		if strings.EqualFold(id, s.GetId()) {
+			return s, nil
+		}
+	}
+	return nil, errors.Errorf("sensor %s was not found in driver %s", id, ms.Name())
+}
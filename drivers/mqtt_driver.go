@@ -0,0 +1,784 @@
+package drivers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/pkg/errors"
+)
+
+const mqttDriverName = "mqtt"
+const mqttDefaultClientId = "swkit"
+const mqttConnectTimeout = 10 * time.Second
+const mqttPublishTimeout = 3 * time.Second
+const mqttPushTickInterval = 50 * time.Millisecond
+
+const (
+	mqttDefaultPayloadOn           = "ON"
+	mqttDefaultPayloadOff          = "OFF"
+	mqttDefaultPayloadAvailable    = "online"
+	mqttDefaultPayloadNotAvailable = "offline"
+	mqttDefaultEventSingle         = "single"
+	mqttDefaultEventDouble         = "double"
+	mqttDefaultEventLong           = "long"
+)
+
+// MqttPinConfig maps a single swkit pin to mqtt topics. Output needs CommandTopic, input needs
+// StateTopic and/or EventTopic. ValuePath and EventPath select value from json payload with
+// dot separated keys (e.g. "POWER1" or "switch.0.output"), when empty whole payload is used.
+type MqttPinConfig struct {
+	Pin uint16
+
+	CommandTopic  string
+	PayloadOn     string // default "ON"
+	PayloadOff    string // default "OFF"
+	RetainCommand bool
+
+	StateTopic string
+	ValuePath  string
+	StateOn    string // default PayloadOn
+	StateOff   string // default PayloadOff
+
+	AvailabilityTopic   string
+	PayloadAvailable    string // default "online"
+	PayloadNotAvailable string // default "offline"
+
+	EventTopic  string
+	EventPath   string
+	EventSingle string // default "single"
+	EventDouble string // default "double"
+	EventLong   string // default "long"
+
+	// dimmable output: level 0-100 is scaled to LevelScale and published with LevelTemplate
+	// (fmt format, e.g. {"brightness": %d}), level state is read from LevelStateTopic (LevelPath in json)
+	LevelCommandTopic string
+	LevelTemplate     string // default "%d"
+	LevelScale        int    // default 100, e.g. 255 for zigbee2mqtt brightness
+	LevelStateTopic   string
+	LevelPath         string
+
+	// colour output: channels 0-255 replace {red}, {green}, {blue} and {white} in ColorTemplate,
+	// colour state is optimistic
+	ColorCommandTopic string
+	ColorTemplate     string // default "{red},{green},{blue}"
+}
+
+func (mpc *MqttPinConfig) getColorPayload(color Rgbw) string {
+	replacer := strings.NewReplacer(
+		"{red}", strconv.Itoa(color.Red),
+		"{green}", strconv.Itoa(color.Green),
+		"{blue}", strconv.Itoa(color.Blue),
+		"{white}", strconv.Itoa(color.White),
+	)
+	return replacer.Replace(withDefault(mpc.ColorTemplate, "{red},{green},{blue}"))
+}
+
+func (mpc *MqttPinConfig) getLevelScale() int {
+	if mpc.LevelScale <= 0 {
+		return 100
+	}
+	return mpc.LevelScale
+}
+
+func (mpc *MqttPinConfig) getLevelPayload(level int) string {
+	scaled := (clampPercent(level)*mpc.getLevelScale() + 50) / 100
+	return fmt.Sprintf(withDefault(mpc.LevelTemplate, "%d"), scaled)
+}
+
+// parseLevel returns level 0-100 from payload with value scaled to LevelScale.
+func (mpc *MqttPinConfig) parseLevel(payload []byte) (int, error) {
+	value, err := mqttValueAtPath(payload, mpc.LevelPath)
+	if err != nil {
+		return 0, err
+	}
+
+	var scaled float64
+	switch v := value.(type) {
+	case float64:
+		scaled = v
+	case string:
+		scaled, err = strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, errors.Errorf("unexpected level payload (%s)", v)
+		}
+	default:
+		return 0, errors.Errorf("unsupported level value type (%T)", value)
+	}
+	return clampPercent(int(scaled*100/float64(mpc.getLevelScale()) + 0.5)), nil
+}
+
+func (mpc *MqttPinConfig) getPayload(state bool) string {
+	if state {
+		return withDefault(mpc.PayloadOn, mqttDefaultPayloadOn)
+	}
+	return withDefault(mpc.PayloadOff, mqttDefaultPayloadOff)
+}
+
+func (mpc *MqttPinConfig) parseState(payload []byte) (bool, error) {
+	value, err := mqttValueAtPath(payload, mpc.ValuePath)
+	if err != nil {
+		return false, err
+	}
+
+	return stateFromValue(value, withDefault(mpc.StateOn, mpc.getPayload(true)), withDefault(mpc.StateOff, mpc.getPayload(false)))
+}
+
+// stateFromValue converts decoded payload value to state, strings are compared with stateOn/stateOff.
+func stateFromValue(value interface{}, stateOn, stateOff string) (bool, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case float64:
+		return v != 0, nil
+	case string:
+		switch {
+		case strings.EqualFold(v, stateOn):
+			return true, nil
+		case strings.EqualFold(v, stateOff):
+			return false, nil
+		}
+		return false, errors.Errorf("unexpected state payload (%s)", v)
+	default:
+		return false, errors.Errorf("unsupported state value type (%T)", value)
+	}
+}
+
+func (mpc *MqttPinConfig) parseEvent(payload []byte) (PushEvent, error) {
+	value, err := mqttValueAtPath(payload, mpc.EventPath)
+	if err != nil {
+		return 0, err
+	}
+
+	event := fmt.Sprint(value)
+	switch {
+	case strings.EqualFold(event, withDefault(mpc.EventSingle, mqttDefaultEventSingle)):
+		return PushEventSinglePress, nil
+	case strings.EqualFold(event, withDefault(mpc.EventDouble, mqttDefaultEventDouble)):
+		return PushEventDoublePress, nil
+	case strings.EqualFold(event, withDefault(mpc.EventLong, mqttDefaultEventLong)):
+		return PushEventLongPress, nil
+	}
+	return 0, errors.Errorf("unknown event payload (%s)", event)
+}
+
+func withDefault(value, defaultValue string) string {
+	if len(value) == 0 {
+		return defaultValue
+	}
+	return value
+}
+
+// mqttValueAtPath returns raw payload as string when path is empty, otherwise payload is decoded
+// as json and value under dot separated path is returned (numeric parts index arrays).
+func mqttValueAtPath(payload []byte, path string) (interface{}, error) {
+	if len(path) == 0 {
+		return strings.TrimSpace(string(payload)), nil
+	}
+
+	var value interface{}
+	err := json.Unmarshal(payload, &value)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode json payload")
+	}
+
+	for _, key := range strings.Split(path, ".") {
+		switch node := value.(type) {
+		case map[string]interface{}:
+			var found bool
+			value, found = node[key]
+			if !found {
+				return nil, errors.Errorf("key %s not found in payload", key)
+			}
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, errors.Errorf("invalid array index %s in payload", key)
+			}
+			value = node[index]
+		default:
+			return nil, errors.Errorf("cannot get %s from non object value", key)
+		}
+	}
+	return value, nil
+}
+
+type mqttPin struct {
+	config *MqttPinConfig
+	driver *MqttIO
+
+	state     bool
+	level     int
+	rgbw      Rgbw
+	available bool
+	push      pushDetector
+	lock      sync.Mutex
+}
+
+func (pin *mqttPin) getState() (bool, error) {
+	if !pin.driver.client.IsConnected() {
+		return false, errors.New("mqtt broker not connected")
+	}
+
+	pin.lock.Lock()
+	defer pin.lock.Unlock()
+
+	if !pin.available {
+		return pin.state, errors.Errorf("device behind pin %d is offline", pin.config.Pin)
+	}
+	return pin.state, nil
+}
+
+func (pin *mqttPin) setState(state bool) {
+	pin.lock.Lock()
+	defer pin.lock.Unlock()
+
+	pin.state = state
+}
+
+func (pin *mqttPin) getLevel() (int, error) {
+	state, err := pin.getState()
+	if !state {
+		return 0, err
+	}
+
+	pin.lock.Lock()
+	defer pin.lock.Unlock()
+
+	return pin.level, err
+}
+
+func (pin *mqttPin) setLevel(level int) {
+	pin.lock.Lock()
+	defer pin.lock.Unlock()
+
+	pin.level = level
+}
+
+func (pin *mqttPin) setAvailable(available bool) {
+	pin.lock.Lock()
+	defer pin.lock.Unlock()
+
+	pin.available = available
+}
+
+type MqttInput struct {
+	*mqttPin
+}
+
+func (mqin *MqttInput) GetState() (bool, error) {
+	return mqin.getState()
+}
+
+func (mqin *MqttInput) SubscribeToPushEvent(listener EventListener) error {
+	mqin.push.SetListener(listener)
+	return nil
+}
+
+type MqttOutput struct {
+	*mqttPin
+}
+
+func (mout *MqttOutput) GetState() (bool, error) {
+	return mout.getState()
+}
+
+// Set publishes command, state is updated optimistically and corrected when state topic reports.
+func (mout *MqttOutput) Set(state bool) error {
+	err := mout.driver.publish(mout.config.CommandTopic, mout.config.getPayload(state), mout.config.RetainCommand)
+	if err != nil {
+		return errors.Wrapf(err, "failed to set output %d", mout.config.Pin)
+	}
+
+	mout.setState(state)
+	return nil
+}
+
+// MqttDimmer is output with LevelCommandTopic configured, on top of on/off it has level (AnalogOutput).
+type MqttDimmer struct {
+	*MqttOutput
+}
+
+func (md *MqttDimmer) GetLevel() (int, error) {
+	return md.getLevel()
+}
+
+// SetLevel publishes level command (level 0 as off command), state and level are updated optimistically.
+func (md *MqttDimmer) SetLevel(level int) error {
+	level = clampPercent(level)
+	if level == 0 {
+		return md.Set(false)
+	}
+
+	err := md.driver.publish(md.config.LevelCommandTopic, md.config.getLevelPayload(level), md.config.RetainCommand)
+	if err != nil {
+		return errors.Wrapf(err, "failed to set output %d level", md.config.Pin)
+	}
+
+	md.setState(true)
+	md.setLevel(level)
+	return nil
+}
+
+// MqttColor is output with ColorCommandTopic configured (e.g. tasmota or shelly RGBW2 led controller).
+type MqttColor struct {
+	*MqttOutput
+}
+
+func (mc *MqttColor) GetRgbw() (Rgbw, error) {
+	state, err := mc.getState()
+
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+
+	if !state {
+		return Rgbw{}, err
+	}
+	return mc.rgbw, err
+}
+
+// SetRgbw publishes colour command (all channels 0 as off command).
+func (mc *MqttColor) SetRgbw(color Rgbw) error {
+	if color.IsOff() {
+		return mc.Set(false)
+	}
+
+	err := mc.driver.publish(mc.config.ColorCommandTopic, mc.config.getColorPayload(color), mc.config.RetainCommand)
+	if err != nil {
+		return errors.Wrapf(err, "failed to set output %d colour", mc.config.Pin)
+	}
+
+	mc.lock.Lock()
+	mc.state = true
+	mc.rgbw = color
+	mc.lock.Unlock()
+	return nil
+}
+
+type mqttHandler func(payload []byte, retained bool)
+
+// MqttIO maps pins to topics on a mqtt broker. Retained state messages set initial state, retained
+// event messages are ignored, so old button presses are not replayed after reconnect.
+// swkit own availability is published to AvailabilityTopic (with last will "offline").
+type MqttIO struct {
+	Broker            string // e.g. "tcp://192.168.1.10:1883"
+	ClientId          string
+	Username          string
+	Password          string
+	Qos               byte
+	AvailabilityTopic string
+
+	Pins []MqttPinConfig
+
+	client   mqtt.Client
+	inputs   []*MqttInput
+	outputs  []*MqttOutput
+	handlers map[string][]mqttHandler
+	done     chan bool
+	isReady  bool
+	lock     sync.Mutex
+}
+
+func (mio *MqttIO) getPinConfig(pin uint16) (*MqttPinConfig, error) {
+	return findMqttPinConfig(mio.Pins, pin)
+}
+
+func findMqttPinConfig(pins []MqttPinConfig, pin uint16) (*MqttPinConfig, error) {
+	for i := range pins {
+		if pins[i].Pin == pin {
+			return &pins[i], nil
+		}
+	}
+	return nil, errors.Errorf("mqtt pin %d not configured", pin)
+}
+
+func newMqttPin(config *MqttPinConfig, driver *MqttIO) *mqttPin {
+	// with AvailabilityTopic unknown until device reports, its retained LWT message usually arrives right after subscribe
+	return &mqttPin{config: config, driver: driver, available: len(config.AvailabilityTopic) == 0}
+}
+
+func addMqttHandler(handlers map[string][]mqttHandler, topic string, handler mqttHandler) {
+	if len(topic) == 0 {
+		return
+	}
+	handlers[topic] = append(handlers[topic], handler)
+}
+
+func addMqttPinHandlers(handlers map[string][]mqttHandler, pin *mqttPin, isInput bool) {
+	config := pin.config
+
+	if len(config.AvailabilityTopic) > 0 {
+		addMqttHandler(handlers, config.AvailabilityTopic, func(payload []byte, retained bool) {
+			switch strings.TrimSpace(string(payload)) {
+			case withDefault(config.PayloadAvailable, mqttDefaultPayloadAvailable):
+				pin.setAvailable(true)
+			case withDefault(config.PayloadNotAvailable, mqttDefaultPayloadNotAvailable):
+				pin.setAvailable(false)
+			}
+		})
+	}
+
+	addMqttHandler(handlers, config.StateTopic, func(payload []byte, retained bool) {
+		state, err := config.parseState(payload)
+		if err != nil {
+			log.Printf("mqtt | pin %d state from %s: %v", config.Pin, config.StateTopic, err)
+			return
+		}
+		pin.setState(state)
+		if isInput && !retained {
+			pin.push.Update(state, time.Now())
+		}
+	})
+
+	addMqttHandler(handlers, config.LevelStateTopic, func(payload []byte, retained bool) {
+		level, err := config.parseLevel(payload)
+		if err != nil {
+			log.Printf("mqtt | pin %d level from %s: %v", config.Pin, config.LevelStateTopic, err)
+			return
+		}
+		pin.setLevel(level)
+		if len(config.StateTopic) == 0 {
+			pin.setState(level > 0)
+		}
+	})
+
+	if isInput {
+		addMqttHandler(handlers, config.EventTopic, func(payload []byte, retained bool) {
+			if retained {
+				return
+			}
+			event, err := config.parseEvent(payload)
+			if err != nil {
+				log.Printf("mqtt | pin %d event from %s: %v", config.Pin, config.EventTopic, err)
+				return
+			}
+			pin.push.Fire(event)
+		})
+	}
+}
+
+func (mio *MqttIO) onMessage(client mqtt.Client, msg mqtt.Message) {
+	mio.lock.Lock()
+	handlers := mio.handlers[msg.Topic()]
+	mio.lock.Unlock()
+
+	for _, handler := range handlers {
+		handler(msg.Payload(), msg.Retained())
+	}
+}
+
+func (mio *MqttIO) onConnect(client mqtt.Client) {
+	if len(mio.AvailabilityTopic) > 0 {
+		client.Publish(mio.AvailabilityTopic, mio.Qos, true, mqttDefaultPayloadAvailable)
+	}
+
+	mio.lock.Lock()
+	filters := map[string]byte{}
+	for topic := range mio.handlers {
+		filters[topic] = mio.Qos
+	}
+	mio.lock.Unlock()
+	if len(filters) == 0 {
+		return
+	}
+
+	token := client.SubscribeMultiple(filters, mio.onMessage)
+	if token.WaitTimeout(mqttConnectTimeout) && token.Error() != nil {
+		log.Printf("mqtt | subscribe failed: %v", token.Error())
+	}
+}
+
+func (mio *MqttIO) publish(topic string, payload string, retain bool) error {
+	token := mio.client.Publish(topic, mio.Qos, retain, payload)
+	if !token.WaitTimeout(mqttPublishTimeout) {
+		return errors.Errorf("publish to %s timed out", topic)
+	}
+	return token.Error()
+}
+
+func (mio *MqttIO) getClientOptions() *mqtt.ClientOptions {
+	opts := mqtt.NewClientOptions().
+		AddBroker(mio.Broker).
+		SetClientID(withDefault(mio.ClientId, mqttDefaultClientId)).
+		SetUsername(mio.Username).
+		SetPassword(mio.Password).
+		SetCleanSession(true).
+		SetAutoReconnect(true).
+		SetConnectTimeout(mqttConnectTimeout).
+		SetOrderMatters(false).
+		SetOnConnectHandler(mio.onConnect).
+		SetConnectionLostHandler(func(client mqtt.Client, err error) {
+			log.Printf("mqtt | connection lost: %v", err)
+		})
+
+	if len(mio.AvailabilityTopic) > 0 {
+		opts.SetWill(mio.AvailabilityTopic, mqttDefaultPayloadNotAvailable, mio.Qos, true)
+	}
+	return opts
+}
+
+func (mio *MqttIO) newInput(pins []MqttPinConfig, pin uint16) (*MqttInput, error) {
+	config, err := findMqttPinConfig(pins, pin)
+	if err != nil {
+		return nil, err
+	}
+	if len(config.StateTopic) == 0 && len(config.EventTopic) == 0 {
+		return nil, errors.Errorf("mqtt input %d needs StateTopic or EventTopic", pin)
+	}
+	return &MqttInput{newMqttPin(config, mio)}, nil
+}
+
+func (mio *MqttIO) newOutput(pins []MqttPinConfig, pin uint16) (*MqttOutput, error) {
+	config, err := findMqttPinConfig(pins, pin)
+	if err != nil {
+		return nil, err
+	}
+	if len(config.CommandTopic) == 0 {
+		return nil, errors.Errorf("mqtt output %d needs CommandTopic", pin)
+	}
+	return &MqttOutput{newMqttPin(config, mio)}, nil
+}
+
+func (mio *MqttIO) Setup(ctx context.Context, inputs []uint16, outputs []uint16) error {
+	if len(mio.Broker) == 0 {
+		return errors.New("mqtt Broker address is empty")
+	}
+
+	mio.inputs = []*MqttInput{}
+	mio.outputs = []*MqttOutput{}
+	mio.handlers = map[string][]mqttHandler{}
+
+	for _, inPin := range inputs {
+		input, err := mio.newInput(mio.Pins, inPin)
+		if err != nil {
+			return err
+		}
+		mio.inputs = append(mio.inputs, input)
+	}
+
+	for _, outPin := range outputs {
+		output, err := mio.newOutput(mio.Pins, outPin)
+		if err != nil {
+			return err
+		}
+		mio.outputs = append(mio.outputs, output)
+	}
+	for _, in := range mio.inputs {
+		addMqttPinHandlers(mio.handlers, in.mqttPin, true)
+	}
+	for _, out := range mio.outputs {
+		addMqttPinHandlers(mio.handlers, out.mqttPin, false)
+	}
+
+	mio.client = mqtt.NewClient(mio.getClientOptions())
+	token := mio.client.Connect()
+	if !token.WaitTimeout(mqttConnectTimeout) {
+		mio.client.Disconnect(0)
+		return errors.Errorf("connecting to mqtt broker %s timed out", mio.Broker)
+	}
+	if token.Error() != nil {
+		return errors.Wrapf(token.Error(), "failed to connect to mqtt broker %s", mio.Broker)
+	}
+
+	mio.done = make(chan bool)
+	go mio.tickPushDetectors(ctx, mio.done)
+
+	mio.isReady = true
+	return nil
+}
+
+func (mio *MqttIO) tickPushDetectors(ctx context.Context, done chan bool) {
+	ticker := time.NewTicker(mqttPushTickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			mio.lock.Lock()
+			inputs := mio.inputs
+			mio.lock.Unlock()
+			for _, in := range inputs {
+				in.push.Tick(now)
+			}
+		}
+	}
+}
+
+func (mio *MqttIO) NameId() string {
+	return mqttDriverName
+}
+
+func (mio *MqttIO) IsReady() bool {
+	return mio.isReady && mio.client.IsConnected()
+}
+
+func (mio *MqttIO) Close() error {
+	if !mio.isReady {
+		return nil
+	}
+	mio.isReady = false
+	close(mio.done)
+
+	if len(mio.AvailabilityTopic) > 0 {
+		mio.publish(mio.AvailabilityTopic, mqttDefaultPayloadNotAvailable, true)
+	}
+	mio.client.Disconnect(250)
+	return nil
+}
+
+func (mio *MqttIO) GetInput(pin uint16) (DigitalInput, error) {
+	for _, in := range mio.inputs {
+		if in.config.Pin == pin {
+			return in, nil
+		}
+	}
+	return nil, fmt.Errorf("mqtt input (pin: %d) not found", pin)
+}
+
+func (mio *MqttIO) GetOutput(pin uint16) (DigitalOutput, error) {
+	for _, out := range mio.outputs {
+		if out.config.Pin == pin {
+			if len(out.config.ColorCommandTopic) > 0 {
+				return &MqttColor{out}, nil
+			}
+			if len(out.config.LevelCommandTopic) > 0 {
+				return &MqttDimmer{out}, nil
+			}
+			return out, nil
+		}
+	}
+	return nil, fmt.Errorf("mqtt output (pin: %d) not found", pin)
+}
+
+func (mio *MqttIO) GetAllIo() (inputs []uint16, outputs []uint16) {
+	for _, in := range mio.inputs {
+		inputs = append(inputs, in.config.Pin)
+	}
+	for _, out := range mio.outputs {
+		outputs = append(outputs, out.config.Pin)
+	}
+	return
+}
+
+// Reconfigure takes Pins of next, subscribes topics of added pins and unsubscribes topics no longer used,
+// other pins are not touched.
+func (mio *MqttIO) Reconfigure(ctx context.Context, next IoDriver, inputs []uint16, outputs []uint16) error {
+	err := checkReconfigure(mio, next, "Pins")
+	if err != nil {
+		return err
+	}
+
+	// listed pins not defined by next keep current definition, definition of pin in use can't change
+	pins := append([]MqttPinConfig{}, next.(*MqttIO).Pins...)
+	for _, pin := range append(append([]uint16{}, inputs...), outputs...) {
+		current, err := mio.getPinConfig(pin)
+		if err != nil {
+			continue
+		}
+		defined, err := findMqttPinConfig(pins, pin)
+		if err != nil {
+			pins = append(pins, *current)
+		} else if mio.isSetUp(pin) && !sameDefinition(current, defined) {
+			return errors.Errorf("definition of mqtt pin %d can't be changed while used, restart needed", pin)
+		}
+	}
+
+	keptInputs := []*MqttInput{}
+	for _, inPin := range inputs {
+		input := mio.findInput(inPin)
+		if input == nil {
+			input, err = mio.newInput(pins, inPin)
+			if err != nil {
+				return err
+			}
+		}
+		keptInputs = append(keptInputs, input)
+	}
+	keptOutputs := []*MqttOutput{}
+	for _, outPin := range outputs {
+		output := mio.findOutput(outPin)
+		if output == nil {
+			output, err = mio.newOutput(pins, outPin)
+			if err != nil {
+				return err
+			}
+		}
+		keptOutputs = append(keptOutputs, output)
+	}
+
+	handlers := map[string][]mqttHandler{}
+	for _, in := range keptInputs {
+		addMqttPinHandlers(handlers, in.mqttPin, true)
+	}
+	for _, out := range keptOutputs {
+		addMqttPinHandlers(handlers, out.mqttPin, false)
+	}
+
+	mio.lock.Lock()
+	previous := mio.handlers
+	mio.handlers = handlers
+	mio.inputs = keptInputs
+	mio.outputs = keptOutputs
+	mio.lock.Unlock()
+	mio.Pins = pins
+
+	removed := []string{}
+	for topic := range previous {
+		if _, used := handlers[topic]; !used {
+			removed = append(removed, topic)
+		}
+	}
+	if len(removed) > 0 {
+		token := mio.client.Unsubscribe(removed...)
+		if token.WaitTimeout(mqttConnectTimeout) && token.Error() != nil {
+			log.Printf("mqtt | unsubscribe failed: %v", token.Error())
+		}
+	}
+
+	added := map[string]byte{}
+	for topic := range handlers {
+		if _, subscribed := previous[topic]; !subscribed {
+			added[topic] = mio.Qos
+		}
+	}
+	if len(added) == 0 {
+		return nil
+	}
+	token := mio.client.SubscribeMultiple(added, mio.onMessage)
+	if !token.WaitTimeout(mqttConnectTimeout) {
+		return errors.New("subscribing topics of added pins timed out")
+	}
+	return errors.Wrap(token.Error(), "failed to subscribe topics of added pins")
+}
+
+func (mio *MqttIO) isSetUp(pin uint16) bool {
+	return mio.findInput(pin) != nil || mio.findOutput(pin) != nil
+}
+
+func (mio *MqttIO) findInput(pin uint16) *MqttInput {
+	for _, in := range mio.inputs {
+		if in.config.Pin == pin {
+			return in
+		}
+	}
+	return nil
+}
+
+func (mio *MqttIO) findOutput(pin uint16) *MqttOutput {
+	for _, out := range mio.outputs {
+		if out.config.Pin == pin {
+			return out
+		}
+	}
+	return nil
+}
@@ -0,0 +1,277 @@
+package drivers
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+)
+
+type mqttRecorder struct {
+	messages map[string][]string
+	lock     sync.Mutex
+}
+
+func (mr *mqttRecorder) record(cl *mochi.Client, sub packets.Subscription, pk packets.Packet) {
+	mr.lock.Lock()
+	defer mr.lock.Unlock()
+	mr.messages[pk.TopicName] = append(mr.messages[pk.TopicName], string(pk.Payload))
+}
+
+func (mr *mqttRecorder) last(topic string) string {
+	mr.lock.Lock()
+	defer mr.lock.Unlock()
+	messages := mr.messages[topic]
+	if len(messages) == 0 {
+		return ""
+	}
+	return messages[len(messages)-1]
+}
+
+// startMqttBroker runs in-process broker on random local port, all published messages are recorded.
+func startMqttBroker(t testing.TB) (*mochi.Server, string, *mqttRecorder) {
+	t.Helper()
+
+	server := mochi.New(&mochi.Options{
+		InlineClient: true,
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	server.AddHook(new(auth.AllowHook), nil)
+
+	tcp := listeners.NewTCP(listeners.Config{ID: "test", Address: "127.0.0.1:0"})
+	err := server.AddListener(tcp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve()
+	t.Cleanup(func() { server.Close() })
+
+	recorder := &mqttRecorder{messages: map[string][]string{}}
+	server.Subscribe("#", 1, recorder.record)
+
+	return server, "tcp://" + tcp.Address(), recorder
+}
+
+func waitFor(t testing.TB, condition func() bool, what string) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestMqttPayloadParsing(t *testing.T) {
+	config := MqttPinConfig{}
+
+	state, err := config.parseState([]byte("ON"))
+	assertBools(t, err == nil && state, true)
+	state, err = config.parseState([]byte(" off\n"))
+	assertBools(t, err == nil && !state, true)
+	_, err = config.parseState([]byte("toggle"))
+	assertBools(t, err != nil, true)
+
+	config.ValuePath = "switch.1.output"
+	state, err = config.parseState([]byte(`{"switch":[{"output":false},{"output":true}]}`))
+	assertBools(t, err == nil && state, true)
+	_, err = config.parseState([]byte(`{"switch":[]}`))
+	assertBools(t, err != nil, true)
+
+	config = MqttPinConfig{ValuePath: "state", StateOn: "1", StateOff: "0"}
+	state, err = config.parseState([]byte(`{"state":"1"}`))
+	assertBools(t, err == nil && state, true)
+	state, err = config.parseState([]byte(`{"state":0}`))
+	assertBools(t, err == nil && !state, true)
+
+	config = MqttPinConfig{EventPath: "action", EventDouble: "double_click"}
+	event, err := config.parseEvent([]byte(`{"action":"double_click"}`))
+	assertBools(t, err == nil && event == PushEventDoublePress, true)
+	event, err = config.parseEvent([]byte(`{"action":"long"}`))
+	assertBools(t, err == nil && event == PushEventLongPress, true)
+	_, err = config.parseEvent([]byte(`{"action":"double"}`))
+	assertBools(t, err != nil, true)
+
+	config = MqttPinConfig{LevelTemplate: `{"brightness":%d}`, LevelScale: 255, LevelPath: "brightness"}
+	assertBools(t, config.getLevelPayload(50) == `{"brightness":128}`, true)
+	level, err := config.parseLevel([]byte(`{"brightness":255}`))
+	assertBools(t, err == nil && level == 100, true)
+	level, err = config.parseLevel([]byte(`{"brightness":"64"}`))
+	assertBools(t, err == nil && level == 25, true)
+	_, err = config.parseLevel([]byte(`{"brightness":true}`))
+	assertBools(t, err != nil, true)
+	config = MqttPinConfig{}
+	assertBools(t, config.getLevelPayload(150) == "100", true)
+	assertBools(t, config.getColorPayload(Rgbw{255, 128, 0, 30}) == "255,128,0", true)
+	config.ColorTemplate = `{"turn":"on","red":{red},"green":{green},"blue":{blue},"white":{white}}`
+	assertBools(t, config.getColorPayload(Rgbw{255, 128, 0, 30}) == `{"turn":"on","red":255,"green":128,"blue":0,"white":30}`, true)
+}
+
+func TestMqttIo(t *testing.T) {
+	server, broker, recorder := startMqttBroker(t)
+
+	server.Publish("relay/1/state", []byte("ON"), true, 0)
+	server.Publish("relay/1/LWT", []byte("Online"), true, 0)
+	server.Publish("button/10/action", []byte(`{"action":"single"}`), true, 0)
+
+	mio := &MqttIO{
+		Broker:            broker,
+		ClientId:          "swkit_test",
+		AvailabilityTopic: "swkit/status",
+		Pins: []MqttPinConfig{
+			{Pin: 1, CommandTopic: "relay/1/set", StateTopic: "relay/1/state", AvailabilityTopic: "relay/1/LWT", PayloadAvailable: "Online", PayloadNotAvailable: "Offline"},
+			{Pin: 2, CommandTopic: "cmnd/relay/POWER2", StateTopic: "tele/relay/STATE", ValuePath: "POWER2"},
+			{Pin: 10, EventTopic: "button/10/action", EventPath: "action"},
+			{Pin: 11, StateTopic: "button/11/state"},
+			{Pin: 3, CommandTopic: "z2m/lamp/set", PayloadOn: `{"state":"ON"}`, PayloadOff: `{"state":"OFF"}`,
+				LevelCommandTopic: "z2m/lamp/set", LevelTemplate: `{"brightness":%d}`, LevelScale: 255,
+				LevelStateTopic: "z2m/lamp", LevelPath: "brightness"},
+		},
+	}
+
+	err := mio.Setup(context.Background(), []uint16{12}, []uint16{1})
+	if err == nil {
+		t.Error("expected error for not configured pin")
+	}
+	err = mio.Setup(context.Background(), nil, []uint16{10})
+	if err == nil {
+		t.Error("expected error for output without command topic")
+	}
+
+	err = mio.Setup(context.Background(), []uint16{10, 11}, []uint16{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mio.Close()
+	assertBools(t, mio.IsReady(), true)
+	waitFor(t, func() bool { return recorder.last("swkit/status") == "online" }, "swkit availability")
+
+	out1, _ := mio.GetOutput(1)
+	waitFor(t, func() bool {
+		state, err := out1.GetState()
+		return err == nil && state
+	}, "retained state of output 1")
+
+	err = out1.Set(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return recorder.last("relay/1/set") == "OFF" }, "command for output 1")
+	state, _ := out1.GetState()
+	assertBools(t, state, false)
+
+	server.Publish("relay/1/LWT", []byte("Offline"), true, 0)
+	waitFor(t, func() bool {
+		_, err := out1.GetState()
+		return err != nil
+	}, "output 1 offline")
+
+	out2, _ := mio.GetOutput(2)
+	server.Publish("tele/relay/STATE", []byte(`{"POWER1":"OFF","POWER2":"ON"}`), false, 0)
+	waitFor(t, func() bool {
+		state, err := out2.GetState()
+		return err == nil && state
+	}, "json state of output 2")
+	out2.Set(false)
+	waitFor(t, func() bool { return recorder.last("cmnd/relay/POWER2") == "OFF" }, "command for output 2")
+
+	out3, _ := mio.GetOutput(3)
+	dimmer, ok := out3.(AnalogOutput)
+	assertBools(t, ok, true)
+	_, ok = out2.(AnalogOutput)
+	assertBools(t, ok, false)
+	dimmer.SetLevel(40)
+	waitFor(t, func() bool { return recorder.last("z2m/lamp/set") == `{"brightness":102}` }, "level command for output 3")
+	server.Publish("z2m/lamp", []byte(`{"state":"ON","brightness":51}`), false, 0)
+	waitFor(t, func() bool {
+		level, err := dimmer.GetLevel()
+		return err == nil && level == 20
+	}, "level state of output 3")
+	dimmer.SetLevel(0)
+	waitFor(t, func() bool { return recorder.last("z2m/lamp/set") == `{"state":"OFF"}` }, "off command for output 3")
+
+	events := &eventRecorder{}
+	in10, _ := mio.GetInput(10)
+	in10.SubscribeToPushEvent(events)
+	server.Publish("button/10/action", []byte(`{"action":"double"}`), false, 0)
+	waitFor(t, func() bool { return len(events.getEvents()) > 0 }, "push event")
+
+	in11, _ := mio.GetInput(11)
+	in11.SubscribeToPushEvent(events)
+	server.Publish("button/11/state", []byte("ON"), false, 0)
+	time.Sleep(50 * time.Millisecond)
+	server.Publish("button/11/state", []byte("OFF"), false, 0)
+	waitFor(t, func() bool { return len(events.getEvents()) > 1 }, "push event from state")
+
+	got := events.getEvents()
+	if len(got) != 2 || got[0] != PushEventDoublePress || got[1] != PushEventSinglePress {
+		t.Errorf("unexpected push events (retained event should be ignored): %v", got)
+	}
+
+	mio.Close()
+	assertBools(t, mio.IsReady(), false)
+	waitFor(t, func() bool { return recorder.last("swkit/status") == "offline" }, "swkit offline status")
+}
+
+func TestMqttReconfigure(t *testing.T) {
+	server, broker, recorder := startMqttBroker(t)
+	server.Publish("relay/1/state", []byte("ON"), true, 0)
+
+	relay1 := MqttPinConfig{Pin: 1, CommandTopic: "relay/1/set", StateTopic: "relay/1/state"}
+	relay2 := MqttPinConfig{Pin: 2, CommandTopic: "relay/2/set", StateTopic: "relay/2/state"}
+	button := MqttPinConfig{Pin: 10, StateTopic: "button/10/state"}
+	mio := &MqttIO{Broker: broker, ClientId: "swkit_test", Pins: []MqttPinConfig{relay1}}
+	err := mio.Setup(context.Background(), nil, []uint16{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mio.Close()
+	out1, _ := mio.GetOutput(1)
+	waitFor(t, func() bool {
+		state, err := out1.GetState()
+		return err == nil && state
+	}, "retained state of output 1")
+
+	next := &MqttIO{Broker: broker, ClientId: "swkit_test", Pins: []MqttPinConfig{relay1, relay2, button}}
+	err = mio.Reconfigure(context.Background(), next, []uint16{10}, []uint16{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertBools(t, mio.IsReady(), true)
+	state, err := out1.GetState()
+	assertBools(t, err == nil && state, true)
+	assertBools(t, recorder.last("relay/1/set") == "", true)
+
+	out2, err := mio.GetOutput(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Publish("relay/2/state", []byte("ON"), false, 0)
+	waitFor(t, func() bool {
+		state, err := out2.GetState()
+		return err == nil && state
+	}, "state of added output 2")
+	in10, _ := mio.GetInput(10)
+	server.Publish("button/10/state", []byte("ON"), false, 0)
+	waitFor(t, func() bool {
+		state, err := in10.GetState()
+		return err == nil && state
+	}, "state of added input 10")
+
+	relay2.StateTopic = "relay/2/status"
+	changed := &MqttIO{Broker: broker, ClientId: "swkit_test", Pins: []MqttPinConfig{relay1, relay2}}
+	err = mio.Reconfigure(context.Background(), changed, nil, []uint16{1, 2})
+	assertBools(t, err != nil, true)
+	other := &MqttIO{Broker: broker, ClientId: "swkit_other", Pins: next.Pins}
+	err = mio.Reconfigure(context.Background(), other, nil, []uint16{1})
+	assertBools(t, err != nil, true)
+}
@@ -0,0 +1,428 @@
+package drivers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/racerxdl/go-mcp23017/i2c"
+)
+
+const pcfDriverName = "pcf8574"
+const pcfDefaultPollInterval = 20 * time.Millisecond
+
+const (
+	PcfModel8574 = "pcf8574"
+	PcfModel8575 = "pcf8575"
+)
+
+// i2cDevice is a single device on i2c bus, opened at its address.
+type i2cDevice interface {
+	ReadBytes(buf []byte) (int, error)
+	WriteBytes(buf []byte) (int, error)
+	Close() error
+}
+
+func openI2cDevice(busNo uint8, address uint8) (i2cDevice, error) {
+	return i2c.NewI2C(address, int(busNo))
+}
+
+type PcfInput struct {
+	pin    uint16
+	driver *PcfIO
+	push   pushDetector
+}
+
+func (pin *PcfInput) GetState() (bool, error) {
+	return pin.driver.readPin(pin.pin, pin.driver.InvertInputs)
+}
+
+func (pin *PcfInput) SubscribeToPushEvent(listener EventListener) error {
+	pin.push.SetListener(listener)
+	return nil
+}
+
+type PcfOutput struct {
+	pin    uint16
+	driver *PcfIO
+}
+
+func (pout *PcfOutput) GetState() (bool, error) {
+	return pout.driver.getLatch(pout.pin), nil
+}
+
+func (pout *PcfOutput) Set(state bool) error {
+	return pout.driver.setLatch(pout.pin, state)
+}
+
+// PcfIO drives PCF8574 (8 pins) or PCF8575 (16 pins) i2c expander. The expander has quasi-bidirectional
+// pins: input pins are kept high (weak pull-up) and polled, outputs can only sink current, so relay boards
+// are usually active low (InvertOutputs). Address is the actual i2c address (e.g. 0x20 or 0x38).
+// Several expanders can be configured, each with its own DriverName.
+type PcfIO struct {
+	DriverName    string
+	Model         string
+	BusNo         uint8
+	Address       uint8
+	InvertInputs  bool
+	InvertOutputs bool
+	PollInterval  string
+
+	device     i2cDevice
+	openDevice func(busNo uint8, address uint8) (i2cDevice, error)
+
+	inputs  []*PcfInput
+	outputs []*PcfOutput
+	isReady bool
+	done    chan bool
+
+	latch    uint16
+	port     uint16
+	portErr  error
+	readAt   time.Time
+	pollTime time.Duration
+	lock     sync.Mutex
+}
+
+func (pcf *PcfIO) getPinCount() (uint16, error) {
+	switch strings.ToLower(pcf.Model) {
+	case "", PcfModel8574:
+		return 8, nil
+	case PcfModel8575:
+		return 16, nil
+	default:
+		return 0, errors.Errorf("unsupported pcf model (%s)", pcf.Model)
+	}
+}
+
+// readPort reads levels of all pins, must be called with lock held.
+func (pcf *PcfIO) readPort() (uint16, error) {
+	count, _ := pcf.getPinCount()
+	buf := make([]byte, count/8)
+	_, err := pcf.device.ReadBytes(buf)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to read pcf port")
+	}
+
+	port := uint16(buf[0])
+	if len(buf) > 1 {
+		port |= uint16(buf[1]) << 8
+	}
+	return port, nil
+}
+
+// writeLatch writes outputs latch, input pins are always written high, must be called with lock held.
+func (pcf *PcfIO) writeLatch() error {
+	count, _ := pcf.getPinCount()
+	value := pcf.latch | pcf.getInputMask()
+
+	buf := []byte{byte(value)}
+	if count > 8 {
+		buf = append(buf, byte(value>>8))
+	}
+	_, err := pcf.device.WriteBytes(buf)
+	if err != nil {
+		return errors.Wrap(err, "failed to write pcf port")
+	}
+	return nil
+}
+
+func (pcf *PcfIO) getInputMask() (mask uint16) {
+	for _, in := range pcf.inputs {
+		mask |= 1 << in.pin
+	}
+	return
+}
+
+func (pcf *PcfIO) readPin(pin uint16, invert bool) (bool, error) {
+	pcf.lock.Lock()
+	defer pcf.lock.Unlock()
+
+	if time.Since(pcf.readAt) > pcf.pollTime {
+		pcf.port, pcf.portErr = pcf.readPort()
+		pcf.readAt = time.Now()
+	}
+	if pcf.portErr != nil {
+		return false, pcf.portErr
+	}
+
+	state := pcf.port&(1<<pin) != 0
+	if invert {
+		state = !state
+	}
+	return state, nil
+}
+
+func (pcf *PcfIO) getLatch(pin uint16) bool {
+	pcf.lock.Lock()
+	defer pcf.lock.Unlock()
+
+	state := pcf.latch&(1<<pin) != 0
+	if pcf.InvertOutputs {
+		state = !state
+	}
+	return state
+}
+
+func (pcf *PcfIO) setLatch(pin uint16, state bool) error {
+	pcf.lock.Lock()
+	defer pcf.lock.Unlock()
+
+	if pcf.InvertOutputs {
+		state = !state
+	}
+	previous := pcf.latch
+	if state {
+		pcf.latch |= 1 << pin
+	} else {
+		pcf.latch &^= 1 << pin
+	}
+
+	err := pcf.writeLatch()
+	if err != nil {
+		pcf.latch = previous
+	}
+	return err
+}
+
+func (pcf *PcfIO) pollInputs(ctx context.Context, done chan bool) {
+	ticker := time.NewTicker(pcf.pollTime)
+	defer ticker.Stop()
+
+	var lastErr error
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			pcf.lock.Lock()
+			inputs := pcf.inputs
+			pcf.lock.Unlock()
+			for _, in := range inputs {
+				state, err := in.GetState()
+				if err != nil {
+					if lastErr == nil {
+						log.Printf("%s | failed to poll inputs: %v", pcf.NameId(), err)
+					}
+					lastErr = err
+					break
+				}
+				lastErr = nil
+				in.push.Update(state, now)
+			}
+		}
+	}
+}
+
+func (pcf *PcfIO) Setup(ctx context.Context, inputs []uint16, outputs []uint16) (err error) {
+	count, err := pcf.getPinCount()
+	if err != nil {
+		return
+	}
+
+	pcf.pollTime = pcfDefaultPollInterval
+	if len(pcf.PollInterval) > 0 {
+		pcf.pollTime, err = time.ParseDuration(pcf.PollInterval)
+		if err != nil {
+			return errors.Wrap(err, "parsing PollInterval failed")
+		}
+	}
+
+	pcf.inputs = []*PcfInput{}
+	pcf.outputs = []*PcfOutput{}
+	used := map[uint16]bool{}
+	for _, inPin := range inputs {
+		if inPin >= count || used[inPin] {
+			return errors.Errorf("input pin %d out of range or already used (%s has %d pins)", inPin, pcf.Model, count)
+		}
+		used[inPin] = true
+		pcf.inputs = append(pcf.inputs, &PcfInput{pin: inPin, driver: pcf})
+	}
+	for _, outPin := range outputs {
+		if outPin >= count || used[outPin] {
+			return errors.Errorf("output pin %d out of range or already used (%s has %d pins)", outPin, pcf.Model, count)
+		}
+		used[outPin] = true
+		pcf.outputs = append(pcf.outputs, &PcfOutput{pin: outPin, driver: pcf})
+	}
+
+	openDevice := pcf.openDevice
+	if openDevice == nil {
+		openDevice = openI2cDevice
+	}
+	pcf.device, err = openDevice(pcf.BusNo, pcf.Address)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open i2c device (bus: %d, address: 0x%02x)", pcf.BusNo, pcf.Address)
+	}
+
+	pcf.lock.Lock()
+	defer pcf.lock.Unlock()
+
+	// outputs keep their current levels, so restart does not toggle relays
+	port, err := pcf.readPort()
+	if err != nil {
+		pcf.device.Close()
+		return
+	}
+	pcf.latch = port &^ pcf.getInputMask()
+	err = pcf.writeLatch()
+	if err != nil {
+		pcf.device.Close()
+		return
+	}
+
+	if len(pcf.inputs) > 0 {
+		pcf.done = make(chan bool)
+		go pcf.pollInputs(ctx, pcf.done)
+	}
+
+	pcf.isReady = true
+	return
+}
+
+func (pcf *PcfIO) NameId() string {
+	if len(pcf.DriverName) > 0 {
+		return pcf.DriverName
+	}
+	return pcfDriverName
+}
+
+func (pcf *PcfIO) IsReady() bool {
+	return pcf.isReady
+}
+
+func (pcf *PcfIO) Close() error {
+	if !pcf.isReady {
+		return nil
+	}
+	pcf.isReady = false
+	if pcf.done != nil {
+		close(pcf.done)
+	}
+
+	for _, output := range pcf.outputs {
+		output.Set(false)
+	}
+	return pcf.device.Close()
+}
+
+func (pcf *PcfIO) GetInput(pin uint16) (DigitalInput, error) {
+	for _, in := range pcf.inputs {
+		if in.pin == pin {
+			return in, nil
+		}
+	}
+	return nil, fmt.Errorf("%s input (pin: %d) not found", pcf.NameId(), pin)
+}
+
+func (pcf *PcfIO) GetOutput(pin uint16) (DigitalOutput, error) {
+	for _, out := range pcf.outputs {
+		if out.pin == pin {
+			return out, nil
+		}
+	}
+	return nil, fmt.Errorf("%s output (pin: %d) not found", pcf.NameId(), pin)
+}
+
+func (pcf *PcfIO) GetAllIo() (inputs []uint16, outputs []uint16) {
+	for _, in := range pcf.inputs {
+		inputs = append(inputs, in.pin)
+	}
+	for _, out := range pcf.outputs {
+		outputs = append(outputs, out.pin)
+	}
+	return
+}
+
+// Reconfigure adds pins and switches removed outputs off, removed inputs are left high (as unused pins),
+// other pins are not touched.
+func (pcf *PcfIO) Reconfigure(ctx context.Context, next IoDriver, inputs []uint16, outputs []uint16) error {
+	err := checkReconfigure(pcf, next)
+	if err != nil {
+		return err
+	}
+
+	count, _ := pcf.getPinCount()
+	used := map[uint16]bool{}
+	for _, pin := range append(append([]uint16{}, inputs...), outputs...) {
+		if pin >= count || used[pin] {
+			return errors.Errorf("pin %d out of range or already used (%s has %d pins)", pin, pcf.Model, count)
+		}
+		used[pin] = true
+	}
+
+	for _, out := range pcf.outputs {
+		if hasPin(outputs, out.pin) {
+			continue
+		}
+		err = out.Set(false)
+		if err != nil {
+			return errors.Wrapf(err, "failed to switch off output %d", out.pin)
+		}
+	}
+
+	pcf.lock.Lock()
+	defer pcf.lock.Unlock()
+
+	keptInputs := []*PcfInput{}
+	for _, in := range pcf.inputs {
+		if hasPin(inputs, in.pin) {
+			keptInputs = append(keptInputs, in)
+		} else {
+			pcf.latch |= 1 << in.pin
+		}
+	}
+	for _, inPin := range inputs {
+		if !pcf.hasInput(inPin) {
+			keptInputs = append(keptInputs, &PcfInput{pin: inPin, driver: pcf})
+		}
+	}
+	keptOutputs := []*PcfOutput{}
+	for _, out := range pcf.outputs {
+		if hasPin(outputs, out.pin) {
+			keptOutputs = append(keptOutputs, out)
+		}
+	}
+	for _, outPin := range outputs {
+		if !pcf.hasOutput(outPin) {
+			// new output keeps its current level, as on Setup
+			keptOutputs = append(keptOutputs, &PcfOutput{pin: outPin, driver: pcf})
+		}
+	}
+	pcf.inputs = keptInputs
+	pcf.outputs = keptOutputs
+
+	err = pcf.writeLatch()
+	if err != nil {
+		return err
+	}
+	if len(pcf.inputs) > 0 && pcf.done == nil {
+		pcf.done = make(chan bool)
+		go pcf.pollInputs(ctx, pcf.done)
+	}
+	return nil
+}
+
+func (pcf *PcfIO) hasInput(pin uint16) bool {
+	for _, in := range pcf.inputs {
+		if in.pin == pin {
+			return true
+		}
+	}
+	return false
+}
+
+func (pcf *PcfIO) hasOutput(pin uint16) bool {
+	for _, out := range pcf.outputs {
+		if out.pin == pin {
+			return true
+		}
+	}
+	return false
+}
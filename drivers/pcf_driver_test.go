@@ -0,0 +1,233 @@
+package drivers
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakePcfDevice emulates quasi-bidirectional port: pin reads high only when
+// latch is high and nothing external pulls it low.
+type fakePcfDevice struct {
+	latch    uint16
+	external uint16
+	writes   [][]byte
+	closed   bool
+	lock     sync.Mutex
+}
+
+func (fd *fakePcfDevice) ReadBytes(buf []byte) (int, error) {
+	fd.lock.Lock()
+	defer fd.lock.Unlock()
+
+	port := fd.latch & fd.external
+	for i := range buf {
+		buf[i] = byte(port >> (8 * i))
+	}
+	return len(buf), nil
+}
+
+func (fd *fakePcfDevice) WriteBytes(buf []byte) (int, error) {
+	fd.lock.Lock()
+	defer fd.lock.Unlock()
+
+	fd.writes = append(fd.writes, append([]byte{}, buf...))
+	fd.latch = 0
+	for i, b := range buf {
+		fd.latch |= uint16(b) << (8 * i)
+	}
+	return len(buf), nil
+}
+
+func (fd *fakePcfDevice) Close() error {
+	fd.closed = true
+	return nil
+}
+
+func (fd *fakePcfDevice) setExternal(pin uint16, high bool) {
+	fd.lock.Lock()
+	defer fd.lock.Unlock()
+
+	if high {
+		fd.external |= 1 << pin
+	} else {
+		fd.external &^= 1 << pin
+	}
+}
+
+func (fd *fakePcfDevice) lastWrite() []byte {
+	fd.lock.Lock()
+	defer fd.lock.Unlock()
+
+	return fd.writes[len(fd.writes)-1]
+}
+
+func newFakePcf(pcf *PcfIO, device *fakePcfDevice) *PcfIO {
+	pcf.openDevice = func(busNo uint8, address uint8) (i2cDevice, error) {
+		return device, nil
+	}
+	return pcf
+}
+
+func TestPcfSetup(t *testing.T) {
+	device := &fakePcfDevice{latch: 0xFF, external: 0xFFFF}
+	pcf := newFakePcf(&PcfIO{InvertOutputs: true}, device)
+
+	err := pcf.Setup(context.Background(), []uint16{0, 1}, []uint16{4, 5, 8})
+	if err == nil {
+		t.Error("expected error for pin 8 on pcf8574")
+	}
+
+	err = pcf.Setup(context.Background(), []uint16{0, 1}, []uint16{4, 0})
+	if err == nil {
+		t.Error("expected error for pin used twice")
+	}
+
+	pcf.PollInterval = "5ms"
+	err = pcf.Setup(context.Background(), []uint16{0, 1}, []uint16{4, 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pcf.Close()
+
+	if pcf.NameId() != "pcf8574" {
+		t.Errorf("unexpected driver name: %s", pcf.NameId())
+	}
+
+	out, err := pcf.GetOutput(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, _ := out.GetState()
+	if state {
+		t.Error("inverted output should be off after setup when latch is high")
+	}
+
+	err = out.Set(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertBools(t, device.lastWrite()[0] == 0xEF, true)
+
+	state, _ = out.GetState()
+	assertBools(t, state, true)
+
+	in, err := pcf.GetInput(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	device.setExternal(1, false)
+	time.Sleep(10 * time.Millisecond)
+	state, err = in.GetState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertBools(t, state, false)
+
+	_, err = pcf.GetInput(4)
+	if err == nil {
+		t.Error("output pin should not be found as input")
+	}
+
+	pcf.Close()
+	assertBools(t, device.closed, true)
+	assertBools(t, device.lastWrite()[0] == 0xFF, true)
+}
+
+func TestPcf8575(t *testing.T) {
+	device := &fakePcfDevice{external: 0xFFFF}
+	pcf := newFakePcf(&PcfIO{Model: "PCF8575", DriverName: "relays_2", InvertInputs: true}, device)
+
+	err := pcf.Setup(context.Background(), []uint16{15}, []uint16{0, 9})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pcf.Close()
+
+	if pcf.NameId() != "relays_2" {
+		t.Errorf("unexpected driver name: %s", pcf.NameId())
+	}
+	assertBools(t, len(device.lastWrite()) == 2, true)
+	assertBools(t, device.lastWrite()[1] == 0x80, true)
+
+	out, _ := pcf.GetOutput(9)
+	out.Set(true)
+	assertBools(t, device.lastWrite()[0] == 0x00, true)
+	assertBools(t, device.lastWrite()[1] == 0x82, true)
+
+	in, _ := pcf.GetInput(15)
+	state, _ := in.GetState()
+	assertBools(t, state, false)
+}
+
+func TestPcfPushEvents(t *testing.T) {
+	device := &fakePcfDevice{external: 0xFF}
+	pcf := newFakePcf(&PcfIO{InvertInputs: true, PollInterval: "5ms"}, device)
+
+	err := pcf.Setup(context.Background(), []uint16{3}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pcf.Close()
+
+	in, _ := pcf.GetInput(3)
+	recorder := &eventRecorder{}
+	in.SubscribeToPushEvent(recorder)
+
+	device.setExternal(3, false)
+	time.Sleep(50 * time.Millisecond)
+	device.setExternal(3, true)
+	time.Sleep(pushDoublePressWindow + 100*time.Millisecond)
+
+	device.setExternal(3, false)
+	time.Sleep(pushLongPressDuration + 100*time.Millisecond)
+	device.setExternal(3, true)
+	time.Sleep(50 * time.Millisecond)
+
+	events := recorder.getEvents()
+	if len(events) != 2 || events[0] != PushEventSinglePress || events[1] != PushEventLongPress {
+		t.Errorf("unexpected push events: %v", events)
+	}
+}
+
+func TestPcfReconfigure(t *testing.T) {
+	device := &fakePcfDevice{latch: 0xFF, external: 0xFFFF}
+	pcf := newFakePcf(&PcfIO{InvertOutputs: true}, device)
+
+	err := pcf.Setup(context.Background(), nil, []uint16{4, 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pcf.Close()
+	out4, _ := pcf.GetOutput(4)
+	out5, _ := pcf.GetOutput(5)
+	out4.Set(true)
+	out5.Set(true)
+
+	err = pcf.Reconfigure(context.Background(), &PcfIO{InvertOutputs: true}, []uint16{1}, []uint16{4, 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// output 4 stays on, removed 5 is switched off, new output 6 keeps its level (off), input 1 is high
+	assertBools(t, device.lastWrite()[0] == 0xEF, true)
+	state, _ := out4.GetState()
+	assertBools(t, state, true)
+	_, err = pcf.GetOutput(5)
+	assertBools(t, err != nil, true)
+
+	in, err := pcf.GetInput(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	device.setExternal(1, false)
+	state, _ = in.GetState()
+	assertBools(t, state, false)
+
+	err = pcf.Reconfigure(context.Background(), &PcfIO{InvertOutputs: false}, nil, []uint16{4})
+	assertBools(t, err != nil, true)
+	err = pcf.Reconfigure(context.Background(), &PcfIO{InvertOutputs: true}, nil, []uint16{4, 9})
+	assertBools(t, err != nil, true)
+	state, _ = out4.GetState()
+	assertBools(t, state, true)
+}
@@ -0,0 +1,103 @@
+package drivers
+
+import (
+	"sync"
+	"time"
+)
+
+const pushDoublePressWindow = 400 * time.Millisecond
+const pushLongPressDuration = 800 * time.Millisecond
+
+// pushDetector turns a stream of raw input states into push events.
+// It is fed with samples (Update) and needs to be ticked (Tick) regularly,
+// so pending single presses can be resolved after the double press window.
+type pushDetector struct {
+	listener EventListener
+
+	pressed    bool
+	longFired  bool
+	clicks     int
+	pressedAt  time.Time
+	releasedAt time.Time
+
+	lock sync.Mutex
+}
+
+func (pd *pushDetector) SetListener(listener EventListener) {
+	pd.lock.Lock()
+	defer pd.lock.Unlock()
+
+	pd.listener = listener
+}
+
+func (pd *pushDetector) Update(state bool, at time.Time) {
+	pd.lock.Lock()
+	events := pd.update(state, at)
+	listener := pd.listener
+	pd.lock.Unlock()
+
+	fireEvents(listener, events)
+}
+
+func (pd *pushDetector) Tick(at time.Time) {
+	pd.lock.Lock()
+	events := pd.tick(at)
+	listener := pd.listener
+	pd.lock.Unlock()
+
+	fireEvents(listener, events)
+}
+
+// Fire passes an already recognized event directly to the listener.
+func (pd *pushDetector) Fire(event PushEvent) {
+	pd.lock.Lock()
+	listener := pd.listener
+	pd.lock.Unlock()
+
+	fireEvents(listener, []PushEvent{event})
+}
+
+func (pd *pushDetector) update(state bool, at time.Time) (events []PushEvent) {
+	switch {
+	case state && !pd.pressed:
+		pd.pressed = true
+		pd.longFired = false
+		pd.pressedAt = at
+	case !state && pd.pressed:
+		pd.pressed = false
+		pd.releasedAt = at
+		if !pd.longFired {
+			pd.clicks++
+		}
+		if pd.clicks >= 2 {
+			pd.clicks = 0
+			events = append(events, PushEventDoublePress)
+		}
+	}
+
+	return append(events, pd.tick(at)...)
+}
+
+func (pd *pushDetector) tick(at time.Time) (events []PushEvent) {
+	if pd.pressed && !pd.longFired && at.Sub(pd.pressedAt) >= pushLongPressDuration {
+		pd.longFired = true
+		pd.clicks = 0
+		events = append(events, PushEventLongPress)
+	}
+
+	if !pd.pressed && pd.clicks > 0 && at.Sub(pd.releasedAt) >= pushDoublePressWindow {
+		pd.clicks = 0
+		events = append(events, PushEventSinglePress)
+	}
+
+	return
+}
+
+func fireEvents(listener EventListener, events []PushEvent) {
+	if listener == nil {
+		return
+	}
+	for _, event := range events {
+		listener.FireEvent(event)
+	}
+}
@@ -1,6 +1,7 @@
 package drivers
 
 import (
+	"context"
 	"encoding/json"
 	"log"
 	"net/http"
@@ -40,7 +41,7 @@ func TestRemoteIoSetup(t *testing.T) {
 	ins := []uint8{1, 3}
 	ous := []uint8{}
 
-	err := remoteBad.Setup(ins, ous)
+	err := remoteBad.Setup(context.Background(), ins, ous)
 	log.Printf("error expected: %v\n", err)
 	if err == nil {
 		t.Error("error expected, got nil")
@@ -60,7 +61,7 @@ func TestRemoteIoSetup(t *testing.T) {
 		Token: validToken,
 	}
 
-	err = remote.Setup(ins, ous)
+	err = remote.Setup(context.Background(), ins, ous)
 	if err != nil {
 		t.Errorf("received error: %v", err)
 	}
@@ -70,14 +71,14 @@ func TestRemoteIoSetup(t *testing.T) {
 		Outputs []uint8
 	}{})
 	remote.Host = emptyServer.URL
-	err = remote.Setup(ins, ous)
+	err = remote.Setup(context.Background(), ins, ous)
 	log.Printf("error expected: %v\n", err)
 	if err == nil {
 		t.Error("expected error on response from empty remoteio server")
 	}
 
 	remote.Token = "not valid"
-	err = remote.Setup(ins, ous)
+	err = remote.Setup(context.Background(), ins, ous)
 	log.Printf("error expected: %v\n", err)
 	if err == nil {
 		t.Error("error expected, got nil")
@@ -1,17 +1,53 @@
 package drivers
 
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Measurement is quantity measured by sensor.
+type Measurement string
+
+const (
+	MeasurementTemperature Measurement = "temperature" // °C
+	MeasurementHumidity    Measurement = "humidity"    // % relative humidity
+	MeasurementPressure    Measurement = "pressure"    // hPa
+	MeasurementCo2         Measurement = "co2"         // ppm
+	MeasurementIlluminance Measurement = "illuminance" // lux
+	MeasurementVoc         Measurement = "voc"         // µg/m³
+)
+
+// Measurements are all supported measurements.
+var Measurements = []Measurement{MeasurementTemperature, MeasurementHumidity, MeasurementPressure, MeasurementCo2, MeasurementIlluminance, MeasurementVoc}
+
+// ParseMeasurement returns measurement by name (case insensitive), empty name is temperature.
+func ParseMeasurement(name string) (Measurement, error) {
+	if len(name) == 0 {
+		return MeasurementTemperature, nil
+	}
+	for _, measurement := range Measurements {
+		if strings.EqualFold(name, string(measurement)) {
+			return measurement, nil
+		}
+	}
+	return "", errors.Errorf("unknown measurement %s", name)
+}
+
 type SensorDriver interface {
-	Setup([]TemperatureSensor) error
+	Setup([]Sensor) error
 	Close() error
 	IsReady() bool
 	Name() string
 	Sync() error
-	FindTemperatureSensor(string) (TemperatureSensor, error)
+	FindSensor(string) (Sensor, error)
 }
 
-type TemperatureSensor interface {
+// Sensor is single value read by sensor driver.
+type Sensor interface {
 	GetValue() (float64, error)
 	SetValue(float64) error
 	GetTags() map[string]string
 	GetId() string
+	GetMeasurement() Measurement
 }
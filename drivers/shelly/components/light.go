@@ -0,0 +1,41 @@
+package components
+
+// Light is dimmer component (e.g. Shelly Plus Dimmer, Plus 0-10V Dimmer), brightness is 0-100.
+type Light struct {
+	Config LightConfig
+	Status LightStatus
+}
+
+type LightConfig struct {
+	ID                    int      `json:"id"`
+	Name                  *string  `json:"name,omitempty"`
+	InitialState          string   `json:"initial_state"`
+	AutoOn                bool     `json:"auto_on"`
+	AutoOnDelay           float64  `json:"auto_on_delay"`
+	AutoOff               bool     `json:"auto_off"`
+	AutoOffDelay          float64  `json:"auto_off_delay"`
+	TransitionDuration    float64  `json:"transition_duration"`
+	MinBrightnessOnToggle *float64 `json:"min_brightness_on_toggle,omitempty"`
+	NightMode             *struct {
+		Enable     bool    `json:"enable"`
+		Brightness float64 `json:"brightness"`
+	} `json:"night_mode,omitempty"`
+}
+
+type LightStatus struct {
+	ID         int     `json:"id"`
+	Source     string  `json:"source,omitempty"`
+	Output     bool    `json:"output,omitempty"`
+	Brightness float64 `json:"brightness,omitempty"`
+
+	TimerStartedAt *int `json:"timer_started_at,omitempty"`
+	TimerDuration  *int `json:"timer_duration,omitempty"`
+
+	APower      *float64     `json:"apower,omitempty"`
+	Voltage     *float64     `json:"voltage,omitempty"`
+	Current     *float64     `json:"current,omitempty"`
+	AEnergy     *EnergyStats `json:"aenergy,omitempty"`
+	Temperature *Temperature `json:"temperature,omitempty"`
+
+	Errors *[]string `json:"errors,omitempty"`
+}
@@ -0,0 +1,25 @@
+package components
+
+// Rgbw is colour component of led controller (Shelly Plus RGBW PM in rgb or rgbw profile),
+// rgb and white channels are 0-255, brightness 0-100.
+type Rgbw struct {
+	HasWhite bool
+	Status   RgbwStatus
+}
+
+type RgbwStatus struct {
+	ID         int     `json:"id"`
+	Source     string  `json:"source,omitempty"`
+	Output     bool    `json:"output,omitempty"`
+	Rgb        []int   `json:"rgb,omitempty"`
+	Brightness float64 `json:"brightness,omitempty"`
+	White      *int    `json:"white,omitempty"`
+
+	APower      *float64     `json:"apower,omitempty"`
+	Voltage     *float64     `json:"voltage,omitempty"`
+	Current     *float64     `json:"current,omitempty"`
+	AEnergy     *EnergyStats `json:"aenergy,omitempty"`
+	Temperature *Temperature `json:"temperature,omitempty"`
+
+	Errors *[]string `json:"errors,omitempty"`
+}
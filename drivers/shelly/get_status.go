@@ -19,6 +19,12 @@ type GetStatus struct {
 	Input1  json.RawMessage `json:"input:1"`
 	Input2  json.RawMessage `json:"input:2"`
 	Input3  json.RawMessage `json:"input:3"`
+	Light0  json.RawMessage `json:"light:0"`
+	Light1  json.RawMessage `json:"light:1"`
+	Light2  json.RawMessage `json:"light:2"`
+	Light3  json.RawMessage `json:"light:3"`
+	Rgb0    json.RawMessage `json:"rgb:0"`
+	Rgbw0   json.RawMessage `json:"rgbw:0"`
 }
 
 func (gs *GetStatus) rawSwitchSlice() [][]byte {
@@ -29,6 +35,10 @@ func (gs *GetStatus) rawInputSlice() [][]byte {
 	return [][]byte{gs.Input0, gs.Input1, gs.Input2, gs.Input3}
 }
 
+func (gs *GetStatus) rawLightSlice() [][]byte {
+	return [][]byte{gs.Light0, gs.Light1, gs.Light2, gs.Light3}
+}
+
 func (gs *GetStatus) GetSwitches() (switches []components.SwitchStatus) {
 	for _, rawSwitch := range gs.rawSwitchSlice() {
 		if len(rawSwitch) > 0 {
@@ -42,6 +52,33 @@ func (gs *GetStatus) GetSwitches() (switches []components.SwitchStatus) {
 	return
 }
 
+func (gs *GetStatus) GetLights() (lights []components.LightStatus) {
+	for _, rawLight := range gs.rawLightSlice() {
+		if len(rawLight) > 0 {
+			var li components.LightStatus
+			if json.Unmarshal(rawLight, &li) == nil {
+				lights = append(lights, li)
+			}
+		}
+	}
+
+	return
+}
+
+// GetColors returns rgb and rgbw components, only one of them is present depending on device profile.
+func (gs *GetStatus) GetColors() (colors []components.Rgbw) {
+	for ix, rawColor := range [][]byte{gs.Rgb0, gs.Rgbw0} {
+		if len(rawColor) > 0 {
+			var status components.RgbwStatus
+			if json.Unmarshal(rawColor, &status) == nil {
+				colors = append(colors, components.Rgbw{HasWhite: ix == 1, Status: status})
+			}
+		}
+	}
+
+	return
+}
+
 func (gs *GetStatus) GetInputs() (inputs []components.InputStatus) {
 	for _, rawInput := range gs.rawInputSlice() {
 		if len(rawInput) > 0 {
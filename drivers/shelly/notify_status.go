@@ -16,6 +16,12 @@ type NotifyStatus struct {
 	Input1  json.RawMessage `json:"input:1"`
 	Input2  json.RawMessage `json:"input:2"`
 	Input3  json.RawMessage `json:"input:3"`
+	Light0  json.RawMessage `json:"light:0"`
+	Light1  json.RawMessage `json:"light:1"`
+	Light2  json.RawMessage `json:"light:2"`
+	Light3  json.RawMessage `json:"light:3"`
+	Rgb0    json.RawMessage `json:"rgb:0"`
+	Rgbw0   json.RawMessage `json:"rgbw:0"`
 }
 
 func (ns *NotifyStatus) rawSwitchSlice() [][]byte {
@@ -40,3 +46,47 @@ func (ns *NotifyStatus) FillSwitches(switches []components.Switch) error {
 
 	return nil
 }
+
+func (ns *NotifyStatus) rawLightSlice() [][]byte {
+	return [][]byte{ns.Light0, ns.Light1, ns.Light2, ns.Light3}
+}
+
+func (ns *NotifyStatus) FillLights(lights []components.Light) error {
+	for ix, li := range lights {
+		liId := li.Status.ID
+		if liId < 0 || liId > 3 {
+			return errors.New("light id out of range [0, 3]")
+		}
+		rawLight := ns.rawLightSlice()[liId]
+		if len(rawLight) > 0 {
+			err := json.Unmarshal(rawLight, &li.Status)
+			if err != nil {
+				return errors.Join(errors.New("failed to unmarshal light"), err)
+			}
+			lights[ix] = li
+		}
+	}
+
+	return nil
+}
+
+func (ns *NotifyStatus) FillColors(colors []components.Rgbw) error {
+	for ix, color := range colors {
+		if color.Status.ID != 0 {
+			return errors.New("colour id out of range [0, 0]")
+		}
+		rawColor := ns.Rgb0
+		if color.HasWhite {
+			rawColor = ns.Rgbw0
+		}
+		if len(rawColor) > 0 {
+			err := json.Unmarshal(rawColor, &color.Status)
+			if err != nil {
+				return errors.Join(errors.New("failed to unmarshal colour"), err)
+			}
+			colors[ix] = color
+		}
+	}
+
+	return nil
+}
@@ -27,6 +27,8 @@ type ShellyDevice struct {
 	Ethernet *components.Ethernet
 
 	Switches []components.Switch
+	Lights   []components.Light
+	Colors   []components.Rgbw
 	Inputs   []components.Input
 
 	setError      error
@@ -84,6 +86,20 @@ func (sd *ShellyDevice) String() string {
 			str.WriteString("\n")
 		}
 	}
+	for _, li := range sd.Lights {
+		stateString := "[ ] off"
+		if li.Status.Output {
+			stateString = "[x]  on"
+		}
+		str.WriteString(fmt.Sprintf("## Light:%d %s\t[Brightness: %.0f%%]\n", li.Status.ID, stateString, li.Status.Brightness))
+	}
+	for _, color := range sd.Colors {
+		stateString := "[ ] off"
+		if color.Status.Output {
+			stateString = "[x]  on"
+		}
+		str.WriteString(fmt.Sprintf("## Color:%d %s\t[Rgb: %v, Brightness: %.0f%%]\n", color.Status.ID, stateString, color.Status.Rgb, color.Status.Brightness))
+	}
 	str.WriteString("## Inputs:\n")
 	for _, in := range sd.Inputs {
 		if in.Status.State == nil {
@@ -108,6 +124,46 @@ func (sd *ShellyDevice) SetSwitch(id int, state bool) error {
 	return nil
 }
 
+// SetLight switches light component, brightness (0-100) is set only when switching on with brightness > 0.
+func (sd *ShellyDevice) SetLight(id int, state bool, brightness int) error {
+	params := map[string]interface{}{"id": id, "on": state}
+	if state && brightness > 0 {
+		params["brightness"] = brightness
+	}
+	err := sd.rpcClient.SendJson("Light.Set", params)
+	sd.setError = err
+
+	if err != nil {
+		return errors.Join(errors.New("failed to send rpc Light.Set message"), err)
+	}
+
+	return nil
+}
+
+// SetColor sets colour component, white is sent only to rgbw component.
+func (sd *ShellyDevice) SetColor(color components.Rgbw, state bool, rgb [3]int, white int) error {
+	method := "RGB.Set"
+	params := map[string]interface{}{"id": color.Status.ID, "on": state}
+	if state {
+		params["rgb"] = rgb
+		params["brightness"] = 100
+	}
+	if color.HasWhite {
+		method = "RGBW.Set"
+		if state {
+			params["white"] = white
+		}
+	}
+	err := sd.rpcClient.SendJson(method, params)
+	sd.setError = err
+
+	if err != nil {
+		return errors.Join(errors.New("failed to send rpc "+method+" message"), err)
+	}
+
+	return nil
+}
+
 func (sd *ShellyDevice) ListenForNotifications() {
 	errChan := make(chan error)
 	msgChan := make(chan RpcMessage)
@@ -149,8 +205,14 @@ func (sd *ShellyDevice) ListenForNotifications() {
 					log.Println("failed to unmarshal params", err)
 				} else {
 					err = notify.FillSwitches(sd.Switches)
+					if err == nil {
+						err = notify.FillLights(sd.Lights)
+					}
+					if err == nil {
+						err = notify.FillColors(sd.Colors)
+					}
 					if err != nil {
-						log.Println("failed to fill switches", err)
+						log.Println("failed to fill switches, lights and colours", err)
 					} else {
 						sd.lastRefreshed = time.Now()
 						log.Println("[she] filled switches for device:\n", sd.String())
@@ -220,6 +282,12 @@ func DiscoverShelly(ctx context.Context, addr *url.URL, origin *url.URL) (device
 		device.Switches = append(device.Switches, components.Switch{Status: sw})
 	}
 
+	for _, li := range getStatus.GetLights() {
+		device.Lights = append(device.Lights, components.Light{Status: li})
+	}
+
+	device.Colors = getStatus.GetColors()
+
 	for _, in := range getStatus.GetInputs() {
 		device.Inputs = append(device.Inputs, components.Input{Status: in})
 	}
@@ -31,6 +31,8 @@ type ShellyIO struct {
 	IpEnd   string
 
 	Outputs []ShellyOutput
+	Lights  []ShellyLight
+	Colors  []ShellyColor
 	Inputs  []ShellyInput
 
 	Devices map[string]*shelly.ShellyDevice
@@ -251,6 +253,35 @@ func (she *ShellyIO) matchIOs() error {
 		she.Outputs[ix] = out
 	}
 
+	for ix, li := range she.Lights {
+		dev, exist := she.Devices[li.Id]
+		if !exist {
+			return fmt.Errorf("device with id %s not found", li.Id)
+		}
+		li.dev = dev
+
+		if li.LightNo >= len(dev.Lights) {
+			return fmt.Errorf("device %s does not have light %d", li.Id, li.LightNo)
+		}
+		li.light = &dev.Lights[li.LightNo]
+
+		she.Lights[ix] = li
+	}
+
+	for ix, color := range she.Colors {
+		dev, exist := she.Devices[color.Id]
+		if !exist {
+			return fmt.Errorf("device with id %s not found", color.Id)
+		}
+		if len(dev.Colors) == 0 {
+			return fmt.Errorf("device %s does not have rgb or rgbw component", color.Id)
+		}
+		color.dev = dev
+		color.color = &dev.Colors[0]
+
+		she.Colors[ix] = color
+	}
+
 	// TODO: inputs
 	// for _, in := range dev.Inputs {
 	// 	she.Inputs = append(she.Inputs, ShellyInput{
@@ -288,6 +319,16 @@ func (she *ShellyIO) GetOutput(pin uint16) (DigitalOutput, error) {
 			return &out, nil
 		}
 	}
+	for _, li := range she.Lights {
+		if li.Pin == pin {
+			return &li, nil
+		}
+	}
+	for _, color := range she.Colors {
+		if color.Pin == pin {
+			return &color, nil
+		}
+	}
 
 	return nil, fmt.Errorf("shelly output pin = %d not found", pin)
 }
@@ -296,6 +337,12 @@ func (she *ShellyIO) GetAllIo() (inputs []uint16, outputs []uint16) {
 	for _, out := range she.Outputs {
 		outputs = append(outputs, out.Pin)
 	}
+	for _, li := range she.Lights {
+		outputs = append(outputs, li.Pin)
+	}
+	for _, color := range she.Colors {
+		outputs = append(outputs, color.Pin)
+	}
 	return
 }
 
@@ -332,6 +379,129 @@ func (sout *ShellyOutput) Set(state bool) error {
 	return nil
 }
 
+// ShellyLight is dimmable output (AnalogOutput) backed by light component of shelly dimmer.
+type ShellyLight struct {
+	Pin     uint16
+	Id      string
+	LightNo int
+
+	light *components.Light
+	dev   *shelly.ShellyDevice
+}
+
+func (sli *ShellyLight) GetLevel() (int, error) {
+	if sli.light == nil || sli.dev == nil {
+		return 0, errors.New("shelly light internal Light/Device nil error")
+	}
+
+	healthy, err := sli.dev.HealthCheck()
+	if !healthy {
+		return 0, errors.Join(errors.New("shelly light is not healthy"), err)
+	}
+	if !sli.light.Status.Output {
+		return 0, nil
+	}
+	return int(sli.light.Status.Brightness), nil
+}
+
+func (sli *ShellyLight) SetLevel(level int) error {
+	if sli.light == nil || sli.dev == nil {
+		return errors.New("shelly light internal Light/Device nil error")
+	}
+	level = clampPercent(level)
+	err := sli.dev.SetLight(sli.light.Status.ID, level > 0, level)
+	if err != nil {
+		return errors.Join(errors.New("failed to set shelly light level"), err)
+	}
+	return nil
+}
+
+func (sli *ShellyLight) GetState() (bool, error) {
+	level, err := sli.GetLevel()
+	return level > 0, err
+}
+
+func (sli *ShellyLight) Set(state bool) error {
+	if sli.light == nil || sli.dev == nil {
+		return errors.New("shelly light internal Light/Device nil error")
+	}
+	err := sli.dev.SetLight(sli.light.Status.ID, state, 0)
+	if err != nil {
+		return errors.Join(errors.New("failed to set shelly light state"), err)
+	}
+	return nil
+}
+
+// ShellyColor is colour output (ColorOutput) backed by rgb/rgbw component of shelly led controller.
+type ShellyColor struct {
+	Pin uint16
+	Id  string
+
+	color *components.Rgbw
+	dev   *shelly.ShellyDevice
+}
+
+func (sco *ShellyColor) GetRgbw() (color Rgbw, err error) {
+	if sco.color == nil || sco.dev == nil {
+		return color, errors.New("shelly colour internal Rgbw/Device nil error")
+	}
+
+	healthy, err := sco.dev.HealthCheck()
+	if !healthy {
+		return color, errors.Join(errors.New("shelly colour is not healthy"), err)
+	}
+	status := sco.color.Status
+	if !status.Output {
+		return
+	}
+	scale := func(value int) int {
+		return int(float64(value)*status.Brightness/100 + 0.5)
+	}
+	if len(status.Rgb) == 3 {
+		color.Red, color.Green, color.Blue = scale(status.Rgb[0]), scale(status.Rgb[1]), scale(status.Rgb[2])
+	}
+	if status.White != nil {
+		color.White = scale(*status.White)
+	}
+	return
+}
+
+func (sco *ShellyColor) SetRgbw(color Rgbw) error {
+	if sco.color == nil || sco.dev == nil {
+		return errors.New("shelly colour internal Rgbw/Device nil error")
+	}
+	err := sco.dev.SetColor(*sco.color, !color.IsOff(), [3]int{color.Red, color.Green, color.Blue}, color.White)
+	if err != nil {
+		return errors.Join(errors.New("failed to set shelly colour"), err)
+	}
+	return nil
+}
+
+func (sco *ShellyColor) GetState() (bool, error) {
+	color, err := sco.GetRgbw()
+	return !color.IsOff(), err
+}
+
+func (sco *ShellyColor) Set(state bool) error {
+	if sco.color == nil || sco.dev == nil {
+		return errors.New("shelly colour internal Rgbw/Device nil error")
+	}
+	status := sco.color.Status
+	rgb := [3]int{255, 255, 255}
+	if len(status.Rgb) == 3 {
+		rgb = [3]int{status.Rgb[0], status.Rgb[1], status.Rgb[2]}
+	}
+	white := 0
+	if status.White != nil {
+		white = *status.White
+	}
+	err := sco.dev.SetColor(*sco.color, state, rgb, white)
+	if err != nil {
+		return errors.Join(errors.New("failed to set shelly colour state"), err)
+	}
+	return nil
+}
+
 type ShellyInput struct {
 	Pin     uint16
 	Id      string
@@ -0,0 +1,409 @@
+package drivers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const virtualDriverName = "virtual"
+const virtualDefaultEvalInterval = 100 * time.Millisecond
+
+// VirtualOutput is an in-memory output, not wired to anything, e.g. "guest mode" switch.
+type VirtualOutput struct {
+	Pin     uint16
+	Default bool // state used when StateFile has no entry
+
+	driver *VirtualIO
+	state  bool
+}
+
+func (vout *VirtualOutput) GetState() (bool, error) {
+	vout.driver.lock.Lock()
+	defer vout.driver.lock.Unlock()
+
+	return vout.state, nil
+}
+
+func (vout *VirtualOutput) Set(state bool) error {
+	vout.driver.lock.Lock()
+	defer vout.driver.lock.Unlock()
+
+	if vout.state == state {
+		return nil
+	}
+	vout.state = state
+	err := vout.driver.saveStates()
+	if err != nil {
+		vout.state = !state
+	}
+	return err
+}
+
+// VirtualInput state is computed from Expression over states of other swkit accessories, see parseBoolExpression.
+type VirtualInput struct {
+	Pin        uint16
+	Expression string
+
+	expr  boolExpr
+	state bool
+	err   error
+	push  pushDetector
+	lock  sync.Mutex
+}
+
+func (vin *VirtualInput) GetState() (bool, error) {
+	vin.lock.Lock()
+	defer vin.lock.Unlock()
+
+	if vin.err != nil {
+		return vin.state, errors.Wrapf(vin.err, "failed to evaluate virtual input %d", vin.Pin)
+	}
+	return vin.state, nil
+}
+
+func (vin *VirtualInput) SubscribeToPushEvent(listener EventListener) error {
+	vin.push.SetListener(listener)
+	return nil
+}
+
+func (vin *VirtualInput) parse() (err error) {
+	vin.expr, err = parseBoolExpression(vin.Expression)
+	if err != nil {
+		return errors.Wrapf(err, "invalid expression of virtual input %d", vin.Pin)
+	}
+	return
+}
+
+func (vin *VirtualInput) evaluate(resolve StateResolver, at time.Time) {
+	state, err := vin.expr.eval(resolve)
+
+	vin.lock.Lock()
+	vin.err = err
+	if err == nil {
+		vin.state = state
+	}
+	vin.lock.Unlock()
+
+	if err == nil {
+		vin.push.Update(state, at)
+	}
+}
+
+// VirtualIO is a software only driver for production use: outputs are kept in memory and persisted
+// to StateFile, inputs are computed from other accessories' states (see ResolveInputs).
+type VirtualIO struct {
+	StateFile    string // optional, e.g. "./virtual.json"
+	EvalInterval string // inputs evaluation, default "100ms"
+
+	Outputs []*VirtualOutput
+	Inputs  []*VirtualInput
+
+	resolve  StateResolver
+	interval time.Duration
+	done     chan bool
+	ready    bool
+	lock     sync.Mutex
+}
+
+// ResolveInputs sets function used by inputs to read states of accessories referenced in expressions
+// and evaluates all inputs, so unknown accessory is reported. Until it is called inputs are off.
+func (vio *VirtualIO) ResolveInputs(resolve StateResolver) error {
+	vio.lock.Lock()
+	vio.resolve = resolve
+	vio.lock.Unlock()
+
+	now := time.Now()
+	for _, in := range vio.Inputs {
+		in.evaluate(resolve, now)
+		_, err := in.GetState()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (vio *VirtualIO) getResolver() StateResolver {
+	vio.lock.Lock()
+	defer vio.lock.Unlock()
+
+	return vio.resolve
+}
+
+// getInputs returns inputs, which are changed by Reconfigure.
+func (vio *VirtualIO) getInputs() []*VirtualInput {
+	vio.lock.Lock()
+	defer vio.lock.Unlock()
+
+	return vio.Inputs
+}
+
+// loadStates sets states of outputs from StateFile, outputs without entry get Default.
+func (vio *VirtualIO) loadStates(outputs []*VirtualOutput) error {
+	states := map[string]bool{}
+	if len(vio.StateFile) > 0 {
+		data, err := os.ReadFile(vio.StateFile)
+		if err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "failed to read StateFile")
+		}
+		if err == nil {
+			err = json.Unmarshal(data, &states)
+			if err != nil {
+				return errors.Wrap(err, "failed to decode StateFile")
+			}
+		}
+	}
+
+	for _, out := range outputs {
+		state, found := states[strconv.Itoa(int(out.Pin))]
+		if !found {
+			state = out.Default
+		}
+		out.state = state
+	}
+	return nil
+}
+
+// saveStates writes all outputs states to StateFile, must be called with lock held.
+func (vio *VirtualIO) saveStates() error {
+	if len(vio.StateFile) == 0 {
+		return nil
+	}
+
+	states := map[string]bool{}
+	for _, out := range vio.Outputs {
+		states[strconv.Itoa(int(out.Pin))] = out.state
+	}
+	data, err := json.MarshalIndent(states, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(vio.StateFile), filepath.Base(vio.StateFile)+".*")
+	if err != nil {
+		return errors.Wrap(err, "failed to save virtual outputs state")
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), vio.StateFile)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return errors.Wrap(err, "failed to save virtual outputs state")
+	}
+	return nil
+}
+
+func (vio *VirtualIO) Setup(ctx context.Context, inputs []uint16, outputs []uint16) error {
+	vio.interval = virtualDefaultEvalInterval
+	var err error
+	if len(vio.EvalInterval) > 0 {
+		vio.interval, err = time.ParseDuration(vio.EvalInterval)
+		if err != nil {
+			return errors.Wrap(err, "parsing EvalInterval failed")
+		}
+	}
+
+	for _, out := range vio.Outputs {
+		out.driver = vio
+	}
+	for _, pin := range outputs {
+		_, err = vio.GetOutput(pin)
+		if err != nil {
+			return err
+		}
+	}
+	for _, pin := range inputs {
+		_, err = vio.GetInput(pin)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = vio.loadStates(vio.Outputs)
+	if err != nil {
+		return err
+	}
+
+	for _, in := range vio.Inputs {
+		err = in.parse()
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(vio.Inputs) > 0 {
+		vio.done = make(chan bool)
+		go vio.evaluateInputs(ctx, vio.done, vio.interval)
+	}
+
+	vio.ready = true
+	return nil
+}
+
+func (vio *VirtualIO) evaluateInputs(ctx context.Context, done chan bool, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			resolve := vio.getResolver()
+			if resolve == nil {
+				continue
+			}
+			for _, in := range vio.getInputs() {
+				_, prevErr := in.GetState()
+				in.evaluate(resolve, now)
+				in.push.Tick(now)
+				_, err := in.GetState()
+				if err != nil && prevErr == nil {
+					log.Println("virtual |", err)
+				}
+			}
+		}
+	}
+}
+
+func (vio *VirtualIO) Close() error {
+	vio.ready = false
+	if vio.done != nil {
+		close(vio.done)
+		vio.done = nil
+	}
+	return nil
+}
+
+func (vio *VirtualIO) NameId() string {
+	return virtualDriverName
+}
+
+func (vio *VirtualIO) IsReady() bool {
+	return vio.ready
+}
+
+func (vio *VirtualIO) GetInput(pin uint16) (DigitalInput, error) {
+	for _, in := range vio.Inputs {
+		if in.Pin == pin {
+			return in, nil
+		}
+	}
+	return nil, fmt.Errorf("virtual input pin = %d not found", pin)
+}
+
+func (vio *VirtualIO) GetOutput(pin uint16) (DigitalOutput, error) {
+	for _, out := range vio.Outputs {
+		if out.Pin == pin {
+			return out, nil
+		}
+	}
+	return nil, fmt.Errorf("virtual output pin = %d not found", pin)
+}
+
+func (vio *VirtualIO) GetAllIo() (inputs []uint16, outputs []uint16) {
+	for _, in := range vio.Inputs {
+		inputs = append(inputs, in.Pin)
+	}
+	for _, out := range vio.Outputs {
+		outputs = append(outputs, out.Pin)
+	}
+	return
+}
+
+// Reconfigure takes Outputs and Inputs of next, outputs and inputs with unchanged definition keep their state.
+// Added outputs get state from StateFile (or Default).
+func (vio *VirtualIO) Reconfigure(ctx context.Context, next IoDriver, inputs []uint16, outputs []uint16) error {
+	err := checkReconfigure(vio, next, "Outputs", "Inputs")
+	if err != nil {
+		return err
+	}
+	nextVio := next.(*VirtualIO)
+
+	// listed pins not defined by next keep current definition, definition of listed pin can't change
+	keptOutputs := []*VirtualOutput{}
+	added := []*VirtualOutput{}
+	for _, out := range nextVio.Outputs {
+		current, _ := vio.GetOutput(out.Pin)
+		switch {
+		case current != nil && sameDefinition(current, out):
+			keptOutputs = append(keptOutputs, current.(*VirtualOutput))
+		case current != nil && hasPin(outputs, out.Pin):
+			return errors.Errorf("definition of virtual output %d can't be changed while used, restart needed", out.Pin)
+		default:
+			out.driver = vio
+			keptOutputs = append(keptOutputs, out)
+			added = append(added, out)
+		}
+	}
+	for _, out := range vio.Outputs {
+		if hasPin(outputs, out.Pin) && !slices.ContainsFunc(keptOutputs, func(kept *VirtualOutput) bool { return kept.Pin == out.Pin }) {
+			keptOutputs = append(keptOutputs, out)
+		}
+	}
+	keptInputs := []*VirtualInput{}
+	for _, in := range nextVio.Inputs {
+		current, _ := vio.GetInput(in.Pin)
+		switch {
+		case current != nil && sameDefinition(current, in):
+			keptInputs = append(keptInputs, current.(*VirtualInput))
+		case current != nil && hasPin(inputs, in.Pin):
+			return errors.Errorf("definition of virtual input %d can't be changed while used, restart needed", in.Pin)
+		default:
+			err = in.parse()
+			if err != nil {
+				return err
+			}
+			keptInputs = append(keptInputs, in)
+		}
+	}
+	for _, in := range vio.Inputs {
+		if hasPin(inputs, in.Pin) && !slices.ContainsFunc(keptInputs, func(kept *VirtualInput) bool { return kept.Pin == in.Pin }) {
+			keptInputs = append(keptInputs, in)
+		}
+	}
+	reconfigured := &VirtualIO{Outputs: keptOutputs, Inputs: keptInputs}
+	for _, pin := range outputs {
+		_, err = reconfigured.GetOutput(pin)
+		if err != nil {
+			return err
+		}
+	}
+	for _, pin := range inputs {
+		_, err = reconfigured.GetInput(pin)
+		if err != nil {
+			return err
+		}
+	}
+	err = vio.loadStates(added)
+	if err != nil {
+		return err
+	}
+
+	vio.lock.Lock()
+	vio.Outputs = keptOutputs
+	vio.Inputs = keptInputs
+	vio.lock.Unlock()
+
+	if len(keptInputs) > 0 && vio.done == nil {
+		vio.done = make(chan bool)
+		go vio.evaluateInputs(ctx, vio.done, vio.interval)
+	}
+	return nil
+}
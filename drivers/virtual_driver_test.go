@@ -0,0 +1,206 @@
+package drivers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type fakeStates struct {
+	states map[string]bool
+	lock   sync.Mutex
+}
+
+func (fs *fakeStates) set(name string, state bool) {
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
+	fs.states[name] = state
+}
+
+func (fs *fakeStates) resolve(name string) (bool, error) {
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
+	state, found := fs.states[name]
+	if !found {
+		return false, errors.Errorf("accessory %s not found", name)
+	}
+	return state, nil
+}
+
+func TestBoolExpression(t *testing.T) {
+	states := &fakeStates{states: map[string]bool{"a": true, "b": false, "Kitchen window": true, "light:c": false}}
+
+	for expression, want := range map[string]bool{
+		"a":                          true,
+		"!a":                         false,
+		"a && b":                     false,
+		"a || b":                     true,
+		"a and not b":                true,
+		"!(a && b) && light:c":       false,
+		`"Kitchen window" || b`:      true,
+		"b || b && a":                false,
+		"(b || b) or (a and true)":   true,
+		"not not a and (false || a)": true,
+	} {
+		expr, err := parseBoolExpression(expression)
+		if err != nil {
+			t.Errorf("failed to parse %s: %v", expression, err)
+			continue
+		}
+		got, err := expr.eval(states.resolve)
+		if err != nil || got != want {
+			t.Errorf("%s: got %v (%v) want %v", expression, got, err, want)
+		}
+	}
+
+	for _, invalid := range []string{"", "a &&", "a & b", "(a || b", "a b", `"a`, "a || )", "a = b"} {
+		_, err := parseBoolExpression(invalid)
+		if err == nil {
+			t.Errorf("expected error parsing %s", invalid)
+		}
+	}
+
+	expr, _ := parseBoolExpression("a || missing")
+	_, err := expr.eval(states.resolve)
+	assertBools(t, err != nil, true)
+}
+
+func TestVirtualIo(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "virtual.json")
+	states := &fakeStates{states: map[string]bool{"window_1": false, "window_2": false}}
+
+	vio := &VirtualIO{
+		StateFile:    stateFile,
+		EvalInterval: "10ms",
+		Outputs:      []*VirtualOutput{{Pin: 1}, {Pin: 2, Default: true}},
+		Inputs:       []*VirtualInput{{Pin: 10, Expression: "window_1 || window_2"}},
+	}
+	err := vio.Setup(context.Background(), []uint16{10}, []uint16{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vio.Close()
+	err = vio.ResolveInputs(states.resolve)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out1, _ := vio.GetOutput(1)
+	out2, _ := vio.GetOutput(2)
+	state, _ := out2.GetState()
+	assertBools(t, state, true)
+	err = out1.Set(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = os.Stat(stateFile)
+	assertBools(t, err == nil, true)
+
+	in10, _ := vio.GetInput(10)
+	state, err = in10.GetState()
+	assertBools(t, err == nil && !state, true)
+	states.set("window_2", true)
+	time.Sleep(50 * time.Millisecond)
+	state, _ = in10.GetState()
+	assertBools(t, state, true)
+
+	vio.Close()
+
+	// states are restored from file
+	restored := &VirtualIO{
+		StateFile: stateFile,
+		Outputs:   []*VirtualOutput{{Pin: 1}, {Pin: 2, Default: false}},
+	}
+	err = restored.Setup(context.Background(), nil, []uint16{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out1, _ = restored.GetOutput(1)
+	out2, _ = restored.GetOutput(2)
+	state, _ = out1.GetState()
+	assertBools(t, state, true)
+	state, _ = out2.GetState()
+	assertBools(t, state, true)
+
+	// state is kept when it can't be saved
+	restored.StateFile = filepath.Join(t.TempDir(), "missing", "virtual.json")
+	err = out1.Set(false)
+	assertBools(t, err != nil, true)
+	state, _ = out1.GetState()
+	assertBools(t, state, true)
+
+	invalid := &VirtualIO{Inputs: []*VirtualInput{{Pin: 1, Expression: "window_1 &&"}}}
+	err = invalid.Setup(context.Background(), []uint16{1}, nil)
+	assertBools(t, err != nil, true)
+
+	unknown := &VirtualIO{Inputs: []*VirtualInput{{Pin: 1, Expression: "window_1 && unknown"}}}
+	err = unknown.Setup(context.Background(), []uint16{1}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unknown.Close()
+	err = unknown.ResolveInputs(states.resolve)
+	assertBools(t, err != nil, true)
+}
+
+func TestVirtualReconfigure(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "virtual.json")
+	states := &fakeStates{states: map[string]bool{"window_1": true}}
+
+	vio := &VirtualIO{StateFile: stateFile, EvalInterval: "10ms", Outputs: []*VirtualOutput{{Pin: 1}, {Pin: 2}}}
+	err := vio.Setup(context.Background(), nil, []uint16{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vio.Close()
+	err = vio.ResolveInputs(states.resolve)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out1, _ := vio.GetOutput(1)
+	out1.Set(true)
+
+	next := &VirtualIO{
+		StateFile:    stateFile,
+		EvalInterval: "10ms",
+		Outputs:      []*VirtualOutput{{Pin: 1}, {Pin: 3, Default: true}},
+		Inputs:       []*VirtualInput{{Pin: 10, Expression: "window_1"}},
+	}
+	err = vio.Reconfigure(context.Background(), next, []uint16{10}, []uint16{1, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// output 1 keeps its state, added output 3 gets Default, added input is evaluated
+	current, _ := vio.GetOutput(1)
+	assertBools(t, current == out1, true)
+	state, _ := out1.GetState()
+	assertBools(t, state, true)
+	out3, err := vio.GetOutput(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, _ = out3.GetState()
+	assertBools(t, state, true)
+	_, err = vio.GetOutput(2)
+	assertBools(t, err != nil, true)
+	in10, _ := vio.GetInput(10)
+	waitFor(t, func() bool {
+		state, err := in10.GetState()
+		return err == nil && state
+	}, "added input 10 evaluated")
+
+	changed := &VirtualIO{StateFile: stateFile, EvalInterval: "10ms", Outputs: next.Outputs, Inputs: []*VirtualInput{{Pin: 10, Expression: "!window_1"}}}
+	err = vio.Reconfigure(context.Background(), changed, []uint16{10}, []uint16{1, 3})
+	assertBools(t, err != nil, true)
+	invalid := &VirtualIO{StateFile: stateFile, EvalInterval: "10ms", Outputs: next.Outputs, Inputs: []*VirtualInput{{Pin: 11, Expression: "window_1 &&"}}}
+	err = vio.Reconfigure(context.Background(), invalid, []uint16{11}, []uint16{1})
+	assertBools(t, err != nil, true)
+	other := &VirtualIO{StateFile: "other.json", EvalInterval: "10ms", Outputs: next.Outputs}
+	err = vio.Reconfigure(context.Background(), other, nil, []uint16{1})
+	assertBools(t, err != nil, true)
+}
@@ -0,0 +1,202 @@
+package drivers
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// StateResolver returns current state of accessory referenced by name in virtual input expression.
+type StateResolver func(name string) (bool, error)
+
+type boolExpr interface {
+	eval(resolve StateResolver) (bool, error)
+}
+
+type boolConst bool
+
+func (bc boolConst) eval(resolve StateResolver) (bool, error) {
+	return bool(bc), nil
+}
+
+type boolRef string
+
+func (br boolRef) eval(resolve StateResolver) (bool, error) {
+	if resolve == nil {
+		return false, errors.Errorf("cannot resolve %s, state resolver not set", string(br))
+	}
+	return resolve(string(br))
+}
+
+type boolNot struct {
+	expr boolExpr
+}
+
+func (bn boolNot) eval(resolve StateResolver) (bool, error) {
+	value, err := bn.expr.eval(resolve)
+	return !value, err
+}
+
+type boolBinary struct {
+	and         bool
+	left, right boolExpr
+}
+
+// eval does not short-circuit, so missing accessory is reported regardless of other states.
+func (bb boolBinary) eval(resolve StateResolver) (bool, error) {
+	left, err := bb.left.eval(resolve)
+	if err != nil {
+		return false, err
+	}
+	right, err := bb.right.eval(resolve)
+	if err != nil {
+		return false, err
+	}
+	if bb.and {
+		return left && right, nil
+	}
+	return left || right, nil
+}
+
+type exprToken struct {
+	value  string
+	quoted bool
+}
+
+func tokenizeExpression(text string) (tokens []exprToken, err error) {
+	runes := []rune(text)
+	for ix := 0; ix < len(runes); {
+		r := runes[ix]
+		switch {
+		case unicode.IsSpace(r):
+			ix++
+		case r == '(' || r == ')' || r == '!':
+			tokens = append(tokens, exprToken{value: string(r)})
+			ix++
+		case r == '&' || r == '|':
+			if ix+1 >= len(runes) || runes[ix+1] != r {
+				return nil, errors.Errorf("expected %c%c at position %d", r, r, ix)
+			}
+			tokens = append(tokens, exprToken{value: string([]rune{r, r})})
+			ix += 2
+		case r == '"':
+			end := ix + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end >= len(runes) {
+				return nil, errors.Errorf("unterminated quote at position %d", ix)
+			}
+			tokens = append(tokens, exprToken{value: string(runes[ix+1 : end]), quoted: true})
+			ix = end + 1
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			end := ix
+			for end < len(runes) && (unicode.IsLetter(runes[end]) || unicode.IsDigit(runes[end]) || strings.ContainsRune("_-.:", runes[end])) {
+				end++
+			}
+			tokens = append(tokens, exprToken{value: string(runes[ix:end])})
+			ix = end
+		default:
+			return nil, errors.Errorf("unexpected character %c at position %d", r, ix)
+		}
+	}
+	return
+}
+
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+}
+
+func (ep *exprParser) peek(values ...string) bool {
+	if ep.pos >= len(ep.tokens) || ep.tokens[ep.pos].quoted {
+		return false
+	}
+	for _, value := range values {
+		if strings.EqualFold(ep.tokens[ep.pos].value, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func (ep *exprParser) parseOr() (boolExpr, error) {
+	left, err := ep.parseAnd()
+	for err == nil && ep.peek("||", "or") {
+		ep.pos++
+		var right boolExpr
+		right, err = ep.parseAnd()
+		left = boolBinary{left: left, right: right}
+	}
+	return left, err
+}
+
+func (ep *exprParser) parseAnd() (boolExpr, error) {
+	left, err := ep.parseUnary()
+	for err == nil && ep.peek("&&", "and") {
+		ep.pos++
+		var right boolExpr
+		right, err = ep.parseUnary()
+		left = boolBinary{and: true, left: left, right: right}
+	}
+	return left, err
+}
+
+func (ep *exprParser) parseUnary() (boolExpr, error) {
+	if ep.peek("!", "not") {
+		ep.pos++
+		expr, err := ep.parseUnary()
+		return boolNot{expr: expr}, err
+	}
+	return ep.parsePrimary()
+}
+
+func (ep *exprParser) parsePrimary() (boolExpr, error) {
+	if ep.pos >= len(ep.tokens) {
+		return nil, errors.New("unexpected end of expression")
+	}
+	token := ep.tokens[ep.pos]
+	ep.pos++
+
+	if token.quoted {
+		return boolRef(token.value), nil
+	}
+	switch strings.ToLower(token.value) {
+	case "(":
+		expr, err := ep.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !ep.peek(")") {
+			return nil, errors.New("missing closing parenthesis")
+		}
+		ep.pos++
+		return expr, nil
+	case "true":
+		return boolConst(true), nil
+	case "false":
+		return boolConst(false), nil
+	case ")", "!", "&&", "||", "and", "or", "not":
+		return nil, errors.Errorf("unexpected %s", token.value)
+	}
+	return boolRef(token.value), nil
+}
+
+// parseBoolExpression parses expression like `"Kitchen window" || bedroom_window` or `!(guest_mode and away)`.
+// Operators: !, not, &&, and, ||, or and parentheses. Names with spaces must be quoted.
+func parseBoolExpression(text string) (boolExpr, error) {
+	tokens, err := tokenizeExpression(text)
+	if err != nil {
+		return nil, err
+	}
+	parser := &exprParser{tokens: tokens}
+	expr, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if parser.pos < len(tokens) {
+		return nil, errors.Errorf("unexpected %s", tokens[parser.pos].value)
+	}
+	return expr, nil
+}
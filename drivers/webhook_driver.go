@@ -0,0 +1,573 @@
+package drivers
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+)
+
+const webhookDriverName = "webhook"
+const webhookDefaultTimeout = 3 * time.Second
+const webhookDefaultPollInterval = 5 * time.Second
+const webhookPushTickInterval = 50 * time.Millisecond
+const webhookEventHttpTimeout = 3 * time.Second
+const webhookMaxBodySize = 64 * 1024
+
+// WebhookRequest is a http request sent by output. Url and Body are text/template templates,
+// executed with .Pin and .State (bool).
+type WebhookRequest struct {
+	Method  string // default "GET", "POST" when Body is set
+	Url     string
+	Headers map[string]string
+	Body    string
+}
+
+type webhookTemplateData struct {
+	Pin   uint16
+	State bool
+}
+
+func (wr *WebhookRequest) getMethod() string {
+	if len(wr.Method) > 0 {
+		return strings.ToUpper(wr.Method)
+	}
+	if len(wr.Body) > 0 {
+		return http.MethodPost
+	}
+	return http.MethodGet
+}
+
+func (wr *WebhookRequest) validate() error {
+	if len(wr.Url) == 0 {
+		return errors.New("missing Url")
+	}
+	_, err := template.New("url").Parse(wr.Url)
+	if err != nil {
+		return errors.Wrap(err, "invalid Url template")
+	}
+	_, err = template.New("body").Parse(wr.Body)
+	if err != nil {
+		return errors.Wrap(err, "invalid Body template")
+	}
+	return nil
+}
+
+func executeWebhookTemplate(text string, data webhookTemplateData) (string, error) {
+	tmpl, err := template.New("").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	buf := &bytes.Buffer{}
+	err = tmpl.Execute(buf, data)
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// do sends the request and returns response body, non 2xx status is an error.
+func (wr *WebhookRequest) do(client *http.Client, data webhookTemplateData) ([]byte, error) {
+	reqUrl, err := executeWebhookTemplate(wr.Url, data)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to execute Url template")
+	}
+	body, err := executeWebhookTemplate(wr.Body, data)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to execute Body template")
+	}
+
+	req, err := http.NewRequest(wr.getMethod(), reqUrl, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range wr.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, webhookMaxBodySize))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, errors.Errorf("%s %s responded with status %s", req.Method, req.URL.Redacted(), resp.Status)
+	}
+	return respBody, nil
+}
+
+// WebhookOutput switches device with On/Off requests. When State request is set, it is polled and
+// value under StatePath (see mqttValueAtPath) is the output state, otherwise last set state is reported.
+type WebhookOutput struct {
+	Pin uint16
+	On  WebhookRequest
+	Off WebhookRequest
+
+	State     *WebhookRequest
+	StatePath string
+	StateOn   string // default "on"
+	StateOff  string // default "off"
+
+	driver *WebhookIO
+	state  bool
+	err    error
+	lock   sync.Mutex
+}
+
+func (wout *WebhookOutput) validate() error {
+	err := wout.On.validate()
+	if err != nil {
+		return errors.Wrapf(err, "output %d On request", wout.Pin)
+	}
+	err = wout.Off.validate()
+	if err != nil {
+		return errors.Wrapf(err, "output %d Off request", wout.Pin)
+	}
+	if wout.State != nil {
+		err = wout.State.validate()
+		if err != nil {
+			return errors.Wrapf(err, "output %d State request", wout.Pin)
+		}
+	}
+	return nil
+}
+
+func (wout *WebhookOutput) GetState() (bool, error) {
+	wout.lock.Lock()
+	defer wout.lock.Unlock()
+
+	if wout.err != nil {
+		return wout.state, errors.Wrapf(wout.err, "webhook output %d is not healthy", wout.Pin)
+	}
+	return wout.state, nil
+}
+
+func (wout *WebhookOutput) Set(state bool) error {
+	request := &wout.Off
+	if state {
+		request = &wout.On
+	}
+
+	_, err := request.do(wout.driver.client, webhookTemplateData{Pin: wout.Pin, State: state})
+	if err != nil {
+		return errors.Wrapf(err, "failed to set webhook output %d", wout.Pin)
+	}
+
+	wout.update(state, nil)
+	return nil
+}
+
+func (wout *WebhookOutput) update(state bool, err error) {
+	wout.lock.Lock()
+	defer wout.lock.Unlock()
+
+	if err == nil {
+		wout.state = state
+	}
+	wout.err = err
+}
+
+func (wout *WebhookOutput) readState() (bool, error) {
+	body, err := wout.State.do(wout.driver.client, webhookTemplateData{Pin: wout.Pin})
+	if err != nil {
+		return false, err
+	}
+	value, err := mqttValueAtPath(body, wout.StatePath)
+	if err != nil {
+		return false, err
+	}
+	return stateFromValue(value, withDefault(wout.StateOn, "on"), withDefault(wout.StateOff, "off"))
+}
+
+func (wout *WebhookOutput) poll() {
+	if wout.State == nil {
+		return
+	}
+	state, err := wout.readState()
+	if err != nil {
+		_, prevErr := wout.GetState()
+		if prevErr == nil {
+			log.Printf("webhook | failed to read state of output %d: %v", wout.Pin, err)
+		}
+	}
+	wout.update(state, err)
+}
+
+// WebhookInput is fed by incoming requests on /input/<pin>/event/<event>/token/<token>, where event is
+// on, off, single, double or long. Event "state" takes the state from request body (StatePath, StateOn, StateOff).
+type WebhookInput struct {
+	Pin       uint16
+	StatePath string
+	StateOn   string // default "on"
+	StateOff  string // default "off"
+
+	state bool
+	push  pushDetector
+	lock  sync.Mutex
+}
+
+func (win *WebhookInput) GetState() (bool, error) {
+	win.lock.Lock()
+	defer win.lock.Unlock()
+
+	return win.state, nil
+}
+
+func (win *WebhookInput) SubscribeToPushEvent(listener EventListener) error {
+	win.push.SetListener(listener)
+	return nil
+}
+
+func (win *WebhookInput) setState(state bool, at time.Time) {
+	win.lock.Lock()
+	win.state = state
+	win.lock.Unlock()
+
+	win.push.Update(state, at)
+}
+
+// WebhookIO turns any http controllable device into swkit outputs, inputs are fed by incoming webhooks
+// served on ListenAddr (only when inputs are configured).
+type WebhookIO struct {
+	ListenAddr   string // e.g. ":8090"
+	Token        string
+	Timeout      string // default "3s"
+	PollInterval string // state polling, default "5s"
+
+	Outputs []*WebhookOutput
+	Inputs  []*WebhookInput
+
+	client      *http.Client
+	eventServer *http.Server
+	done        chan bool
+	ready       bool
+	lock        sync.Mutex
+}
+
+func (wio *WebhookIO) Setup(ctx context.Context, inputs []uint16, outputs []uint16) error {
+	timeout := webhookDefaultTimeout
+	pollInterval := webhookDefaultPollInterval
+	var err error
+	if len(wio.Timeout) > 0 {
+		timeout, err = time.ParseDuration(wio.Timeout)
+		if err != nil {
+			return errors.Wrap(err, "parsing Timeout failed")
+		}
+	}
+	if len(wio.PollInterval) > 0 {
+		pollInterval, err = time.ParseDuration(wio.PollInterval)
+		if err != nil {
+			return errors.Wrap(err, "parsing PollInterval failed")
+		}
+	}
+
+	for _, out := range wio.Outputs {
+		err = out.validate()
+		if err != nil {
+			return err
+		}
+		out.driver = wio
+	}
+	for _, pin := range outputs {
+		_, err = wio.GetOutput(pin)
+		if err != nil {
+			return err
+		}
+	}
+	for _, pin := range inputs {
+		_, err = wio.GetInput(pin)
+		if err != nil {
+			return err
+		}
+	}
+	if len(wio.Inputs) > 0 && (len(wio.ListenAddr) == 0 || len(wio.Token) == 0) {
+		return errors.New("webhook inputs configured, but ListenAddr or Token is missing")
+	}
+
+	wio.client = &http.Client{Timeout: timeout}
+	for _, out := range wio.Outputs {
+		out.poll()
+	}
+
+	if len(wio.Inputs) > 0 {
+		err = wio.startEventServer()
+		if err != nil {
+			return err
+		}
+	}
+	wio.done = make(chan bool)
+	go wio.run(ctx, wio.done, pollInterval)
+
+	wio.ready = true
+	return nil
+}
+
+func (wio *WebhookIO) run(ctx context.Context, done chan bool, pollInterval time.Duration) {
+	pushTicker := time.NewTicker(webhookPushTickInterval)
+	defer pushTicker.Stop()
+	pollTicker := time.NewTicker(pollInterval)
+	defer pollTicker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case now := <-pushTicker.C:
+			inputs, _ := wio.getPins()
+			for _, in := range inputs {
+				in.push.Tick(now)
+			}
+		case <-pollTicker.C:
+			_, outputs := wio.getPins()
+			for _, out := range outputs {
+				out.poll()
+			}
+		}
+	}
+}
+
+func (wio *WebhookIO) eventHandler() http.Handler {
+	handler := httprouter.New()
+	handler.GET("/input/:pin_no/event/:event/token/:token", wio.handleEvent)
+	handler.POST("/input/:pin_no/event/:event/token/:token", wio.handleEvent)
+	return handler
+}
+
+func (wio *WebhookIO) startEventServer() error {
+	listener, err := net.Listen("tcp", wio.ListenAddr)
+	if err != nil {
+		return errors.Wrap(err, "failed to listen for input events")
+	}
+
+	wio.eventServer = &http.Server{
+		Addr:              wio.ListenAddr,
+		Handler:           wio.eventHandler(),
+		ReadTimeout:       webhookEventHttpTimeout,
+		ReadHeaderTimeout: webhookEventHttpTimeout,
+		WriteTimeout:      webhookEventHttpTimeout,
+		IdleTimeout:       2 * webhookEventHttpTimeout,
+	}
+
+	go func(server *http.Server) {
+		err := server.Serve(listener)
+		if err != nil && err != http.ErrServerClosed {
+			log.Println("webhook | event server stopped:", err)
+		}
+	}(wio.eventServer)
+
+	return nil
+}
+
+func (wio *WebhookIO) handleEvent(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	if subtle.ConstantTimeCompare([]byte(p.ByName("token")), []byte(wio.Token)) != 1 {
+		http.Error(w, "token mismatch", http.StatusUnauthorized)
+		return
+	}
+
+	pinNo, _ := strconv.Atoi(p.ByName("pin_no"))
+	input, err := wio.getInput(uint16(pinNo))
+	if err != nil {
+		http.Error(w, "input not found", http.StatusNotFound)
+		return
+	}
+
+	switch p.ByName("event") {
+	case "on":
+		input.setState(true, time.Now())
+	case "off":
+		input.setState(false, time.Now())
+	case "single":
+		input.push.Fire(PushEventSinglePress)
+	case "double":
+		input.push.Fire(PushEventDoublePress)
+	case "long":
+		input.push.Fire(PushEventLongPress)
+	case "state":
+		body, err := io.ReadAll(io.LimitReader(r.Body, webhookMaxBodySize))
+		if err != nil {
+			http.Error(w, "failed to read body", http.StatusBadRequest)
+			return
+		}
+		value, err := mqttValueAtPath(body, input.StatePath)
+		if err == nil {
+			var state bool
+			state, err = stateFromValue(value, withDefault(input.StateOn, "on"), withDefault(input.StateOff, "off"))
+			if err == nil {
+				input.setState(state, time.Now())
+				return
+			}
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "unrecognized input event type", http.StatusBadRequest)
+	}
+}
+
+func (wio *WebhookIO) Close() (err error) {
+	wio.ready = false
+	if wio.done != nil {
+		close(wio.done)
+		wio.done = nil
+	}
+	if wio.eventServer != nil {
+		err = wio.eventServer.Close()
+		wio.eventServer = nil
+	}
+	return
+}
+
+func (wio *WebhookIO) NameId() string {
+	return webhookDriverName
+}
+
+func (wio *WebhookIO) IsReady() bool {
+	return wio.ready
+}
+
+// getPins returns inputs and outputs, which are changed by Reconfigure.
+func (wio *WebhookIO) getPins() ([]*WebhookInput, []*WebhookOutput) {
+	wio.lock.Lock()
+	defer wio.lock.Unlock()
+
+	return wio.Inputs, wio.Outputs
+}
+
+func (wio *WebhookIO) getInput(pin uint16) (*WebhookInput, error) {
+	inputs, _ := wio.getPins()
+	for _, in := range inputs {
+		if in.Pin == pin {
+			return in, nil
+		}
+	}
+	return nil, fmt.Errorf("webhook input pin = %d not found", pin)
+}
+
+func (wio *WebhookIO) GetInput(pin uint16) (DigitalInput, error) {
+	in, err := wio.getInput(pin)
+	if err != nil {
+		return nil, err
+	}
+	return in, nil
+}
+
+func (wio *WebhookIO) GetOutput(pin uint16) (DigitalOutput, error) {
+	_, outputs := wio.getPins()
+	for _, out := range outputs {
+		if out.Pin == pin {
+			return out, nil
+		}
+	}
+	return nil, fmt.Errorf("webhook output pin = %d not found", pin)
+}
+
+func (wio *WebhookIO) GetAllIo() (inputs []uint16, outputs []uint16) {
+	for _, in := range wio.Inputs {
+		inputs = append(inputs, in.Pin)
+	}
+	for _, out := range wio.Outputs {
+		outputs = append(outputs, out.Pin)
+	}
+	return
+}
+
+// Reconfigure takes Outputs and Inputs of next, outputs and inputs with unchanged definition are not touched.
+// Event server is started with first inputs.
+func (wio *WebhookIO) Reconfigure(ctx context.Context, next IoDriver, inputs []uint16, outputs []uint16) error {
+	err := checkReconfigure(wio, next, "Outputs", "Inputs")
+	if err != nil {
+		return err
+	}
+	nextWio := next.(*WebhookIO)
+
+	// listed pins not defined by next keep current definition, definition of listed pin can't change
+	keptOutputs := []*WebhookOutput{}
+	added := []*WebhookOutput{}
+	for _, out := range nextWio.Outputs {
+		current, _ := wio.GetOutput(out.Pin)
+		switch {
+		case current != nil && sameDefinition(current, out):
+			keptOutputs = append(keptOutputs, current.(*WebhookOutput))
+		case current != nil && hasPin(outputs, out.Pin):
+			return errors.Errorf("definition of webhook output %d can't be changed while used, restart needed", out.Pin)
+		default:
+			err = out.validate()
+			if err != nil {
+				return err
+			}
+			out.driver = wio
+			keptOutputs = append(keptOutputs, out)
+			added = append(added, out)
+		}
+	}
+	for _, out := range wio.Outputs {
+		if hasPin(outputs, out.Pin) && !slices.ContainsFunc(keptOutputs, func(kept *WebhookOutput) bool { return kept.Pin == out.Pin }) {
+			keptOutputs = append(keptOutputs, out)
+		}
+	}
+	keptInputs := []*WebhookInput{}
+	for _, in := range nextWio.Inputs {
+		current, _ := wio.getInput(in.Pin)
+		switch {
+		case current != nil && sameDefinition(current, in):
+			keptInputs = append(keptInputs, current)
+		case current != nil && hasPin(inputs, in.Pin):
+			return errors.Errorf("definition of webhook input %d can't be changed while used, restart needed", in.Pin)
+		default:
+			keptInputs = append(keptInputs, in)
+		}
+	}
+	for _, in := range wio.Inputs {
+		if hasPin(inputs, in.Pin) && !slices.ContainsFunc(keptInputs, func(kept *WebhookInput) bool { return kept.Pin == in.Pin }) {
+			keptInputs = append(keptInputs, in)
+		}
+	}
+	reconfigured := &WebhookIO{Outputs: keptOutputs, Inputs: keptInputs}
+	for _, pin := range outputs {
+		_, err = reconfigured.GetOutput(pin)
+		if err != nil {
+			return err
+		}
+	}
+	for _, pin := range inputs {
+		_, err = reconfigured.GetInput(pin)
+		if err != nil {
+			return err
+		}
+	}
+	if len(keptInputs) > 0 && (len(wio.ListenAddr) == 0 || len(wio.Token) == 0) {
+		return errors.New("webhook inputs configured, but ListenAddr or Token is missing")
+	}
+
+	for _, out := range added {
+		out.poll()
+	}
+	wio.lock.Lock()
+	wio.Outputs = keptOutputs
+	wio.Inputs = keptInputs
+	wio.lock.Unlock()
+
+	if len(keptInputs) > 0 && wio.eventServer == nil {
+		return wio.startEventServer()
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+package drivers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type fakeWebhookDevice struct {
+	power    bool
+	broken   bool
+	requests []string
+	lock     sync.Mutex
+}
+
+func (fwd *fakeWebhookDevice) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fwd.lock.Lock()
+	defer fwd.lock.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+	fwd.requests = append(fwd.requests, fmt.Sprintf("%s %s %s %s", r.Method, r.URL.RequestURI(), r.Header.Get("X-Api-Key"), body))
+
+	if fwd.broken {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	switch r.URL.Path {
+	case "/power":
+		fwd.power = strings.Contains(string(body), `"on":true`)
+	case "/status":
+		fmt.Fprintf(w, `{"relay":{"power":"%s"}}`, map[bool]string{true: "ON", false: "OFF"}[fwd.power])
+	}
+}
+
+func (fwd *fakeWebhookDevice) setBroken(broken bool) {
+	fwd.lock.Lock()
+	defer fwd.lock.Unlock()
+	fwd.broken = broken
+}
+
+func (fwd *fakeWebhookDevice) getRequests() []string {
+	fwd.lock.Lock()
+	defer fwd.lock.Unlock()
+	return append([]string{}, fwd.requests...)
+}
+
+// hasRequest checks requests received since from, state polls may be received in between.
+func (fwd *fakeWebhookDevice) hasRequest(from int, request string) bool {
+	requests := fwd.getRequests()
+	for _, received := range requests[min(from, len(requests)):] {
+		if received == request {
+			return true
+		}
+	}
+	return false
+}
+
+func TestWebhookOutputs(t *testing.T) {
+	device := &fakeWebhookDevice{power: true}
+	server := httptest.NewServer(device)
+	defer server.Close()
+
+	power := WebhookRequest{
+		Method:  "put",
+		Url:     server.URL + "/power?pin={{.Pin}}",
+		Headers: map[string]string{"X-Api-Key": "abc"},
+		Body:    `{"on":{{.State}}}`,
+	}
+	wio := &WebhookIO{
+		PollInterval: "20ms",
+		Outputs: []*WebhookOutput{
+			{Pin: 1, On: power, Off: power, State: &WebhookRequest{Url: server.URL + "/status"}, StatePath: "relay.power"},
+			{Pin: 2, On: WebhookRequest{Url: server.URL + "/on"}, Off: WebhookRequest{Url: server.URL + "/off"}},
+		},
+	}
+
+	err := wio.Setup(context.Background(), nil, []uint16{3})
+	if err == nil {
+		t.Error("expected error for not configured output")
+	}
+	err = wio.Setup(context.Background(), nil, []uint16{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wio.Close()
+
+	out1, _ := wio.GetOutput(1)
+	state, err := out1.GetState()
+	assertBools(t, err == nil && state, true)
+
+	sent := len(device.getRequests())
+	err = out1.Set(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !device.hasRequest(sent, `PUT /power?pin=1 abc {"on":false}`) {
+		t.Errorf("power request not sent, got: %v", device.getRequests()[sent:])
+	}
+	waitFor(t, func() bool {
+		state, err := out1.GetState()
+		return err == nil && !state
+	}, "output 1 state polled off")
+
+	out2, _ := wio.GetOutput(2)
+	sent = len(device.getRequests())
+	err = out2.Set(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !device.hasRequest(sent, "GET /on  ") {
+		t.Errorf("on request not sent, got: %v", device.getRequests()[sent:])
+	}
+	state, _ = out2.GetState()
+	assertBools(t, state, true)
+
+	device.setBroken(true)
+	waitFor(t, func() bool {
+		_, err := out1.GetState()
+		return err != nil
+	}, "output 1 poll error")
+	err = out2.Set(false)
+	assertBools(t, err != nil, true)
+
+	device.setBroken(false)
+	waitFor(t, func() bool {
+		_, err := out1.GetState()
+		return err == nil
+	}, "output 1 poll recovered")
+}
+
+func TestWebhookInputs(t *testing.T) {
+	wio := &WebhookIO{
+		ListenAddr: "127.0.0.1:0",
+		Token:      "secret",
+		Inputs: []*WebhookInput{
+			{Pin: 10},
+			{Pin: 11, StatePath: "contact", StateOn: "open", StateOff: "closed"},
+		},
+	}
+	err := wio.Setup(context.Background(), []uint16{10, 11}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wio.Close()
+
+	server := httptest.NewServer(wio.eventHandler())
+	defer server.Close()
+
+	send := func(method, path, body string) int {
+		t.Helper()
+		req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	events := &eventRecorder{}
+	in10, _ := wio.GetInput(10)
+	in10.SubscribeToPushEvent(events)
+
+	assertInts(t, send("GET", "/input/10/event/double/token/wrong", ""), http.StatusUnauthorized)
+	assertInts(t, send("GET", "/input/10/event/double/token/secre", ""), http.StatusUnauthorized)
+	assertInts(t, send("GET", "/input/10/event/double/token/secret2", ""), http.StatusUnauthorized)
+	assertInts(t, send("GET", "/input/10/event/double/token/SECRET", ""), http.StatusUnauthorized)
+	assertInts(t, send("GET", "/input/12/event/on/token/secret", ""), http.StatusNotFound)
+	assertInts(t, send("GET", "/input/10/event/double/token/secret", ""), http.StatusOK)
+	assertInts(t, send("POST", "/input/10/event/on/token/secret", ""), http.StatusOK)
+	state, _ := in10.GetState()
+	assertBools(t, state, true)
+
+	got := events.getEvents()
+	if len(got) != 1 || got[0] != PushEventDoublePress {
+		t.Errorf("unexpected push events: %v", got)
+	}
+
+	in11, _ := wio.GetInput(11)
+	assertInts(t, send("POST", "/input/11/event/state/token/secret", `{"contact":"open"}`), http.StatusOK)
+	state, _ = in11.GetState()
+	assertBools(t, state, true)
+	assertInts(t, send("POST", "/input/11/event/state/token/secret", `{"contact":"ajar"}`), http.StatusBadRequest)
+	assertInts(t, send("POST", "/input/11/event/state/token/secret", `{"contact":"closed"}`), http.StatusOK)
+	state, _ = in11.GetState()
+	assertBools(t, state, false)
+}
+
+func TestWebhookListenError(t *testing.T) {
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+
+	wio := &WebhookIO{ListenAddr: busy.Addr().String(), Token: "secret", Inputs: []*WebhookInput{{Pin: 10}}}
+	err = wio.Setup(context.Background(), []uint16{10}, nil)
+	assertBools(t, err != nil, true)
+	assertBools(t, wio.IsReady(), false)
+	wio.Close()
+}
+
+func TestWebhookReconfigure(t *testing.T) {
+	device := &fakeWebhookDevice{power: true}
+	server := httptest.NewServer(device)
+	defer server.Close()
+
+	power := WebhookRequest{Url: server.URL + "/power", Body: `{"on":{{.State}}}`}
+	relay1 := &WebhookOutput{Pin: 1, On: power, Off: power, State: &WebhookRequest{Url: server.URL + "/status"}, StatePath: "relay.power"}
+	wio := &WebhookIO{ListenAddr: "127.0.0.1:0", Token: "secret", Outputs: []*WebhookOutput{relay1}}
+	err := wio.Setup(context.Background(), nil, []uint16{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wio.Close()
+	out1, _ := wio.GetOutput(1)
+
+	sent := len(device.getRequests())
+	next := &WebhookIO{
+		ListenAddr: "127.0.0.1:0",
+		Token:      "secret",
+		Outputs: []*WebhookOutput{
+			{Pin: 1, On: power, Off: power, State: &WebhookRequest{Url: server.URL + "/status"}, StatePath: "relay.power"},
+			{Pin: 2, On: WebhookRequest{Url: server.URL + "/on"}, Off: WebhookRequest{Url: server.URL + "/off"}},
+		},
+		Inputs: []*WebhookInput{{Pin: 10}},
+	}
+	err = wio.Reconfigure(context.Background(), next, []uint16{10}, []uint16{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// output 1 is the same and stays on, event server is started for added input
+	current, _ := wio.GetOutput(1)
+	assertBools(t, current == out1, true)
+	state, err := out1.GetState()
+	assertBools(t, err == nil && state, true)
+	assertBools(t, device.hasRequest(sent, `POST /power  {"on":false}`), false)
+	assertBools(t, wio.eventServer != nil, true)
+
+	out2, err := wio.GetOutput(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = out2.Set(true)
+	assertBools(t, err == nil && device.hasRequest(sent, "GET /on  "), true)
+	_, err = wio.GetInput(10)
+	assertBools(t, err == nil, true)
+
+	changed := &WebhookIO{ListenAddr: "127.0.0.1:0", Token: "secret", Outputs: []*WebhookOutput{{Pin: 1, On: power, Off: power}}}
+	err = wio.Reconfigure(context.Background(), changed, nil, []uint16{1})
+	assertBools(t, err != nil, true)
+	other := &WebhookIO{ListenAddr: "127.0.0.1:0", Token: "other", Outputs: next.Outputs}
+	err = wio.Reconfigure(context.Background(), other, nil, []uint16{1})
+	assertBools(t, err != nil, true)
+}
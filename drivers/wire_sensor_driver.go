@@ -21,12 +21,12 @@ type Wire struct {
 	BoundMinimumMillis int
 	BoundMaximumMillis int
 
-	sensors []TemperatureSensor
+	sensors []Sensor
 	ready   bool
 }
 
-func (w1 *Wire) getSensorPathSlice() (pathSlice map[TemperatureSensor]string, err error) {
-	pathSlice = make(map[TemperatureSensor]string)
+func (w1 *Wire) getSensorPathSlice() (pathSlice map[Sensor]string, err error) {
+	pathSlice = make(map[Sensor]string)
 	for _, s := range w1.sensors {
 		var intBase int
 		var numId int64
@@ -49,13 +49,19 @@ func (w1 *Wire) getSensorPathSlice() (pathSlice map[TemperatureSensor]string, er
 	return
 }
 
-func (w1 *Wire) Setup(tempSensors []TemperatureSensor) (err error) {
+func (w1 *Wire) Setup(tempSensors []Sensor) (err error) {
 	_, err = ioutil.ReadDir(wireSystemPath)
 	if err != nil {
 		err = errors.Wrapf(err, "failed to init Wire sensor driver: error reading dir (%s):", wireSystemPath)
 		return
 	}
 
+	for _, s := range tempSensors {
+		if s.GetMeasurement() != MeasurementTemperature {
+			err = errors.Errorf("failed to init wire sensor driver, sensor %s: wire sensors measure temperature only, not %s", s.GetId(), s.GetMeasurement())
+			return
+		}
+	}
 	w1.sensors = tempSensors
 
 	pathSlice, err := w1.getSensorPathSlice()
@@ -118,7 +124,7 @@ func (w1 *Wire) Sync() error {
 	return nil
 }
 
-func (w1 *Wire) FindTemperatureSensor(id string) (TemperatureSensor, error) {
+func (w1 *Wire) FindSensor(id string) (Sensor, error) {
 	for _, s := range w1.sensors {
 		if strings.EqualFold(id, s.GetId()) {
 			return s, nil
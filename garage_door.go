@@ -0,0 +1,435 @@
+package swkit
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brutella/hap/accessory"
+	"github.com/brutella/hap/characteristic"
+	drivers "github.com/hubertat/swkit/drivers"
+	"github.com/pkg/errors"
+)
+
+const defaultGarageDoorPulseDuration = 500 * time.Millisecond
+const defaultGarageDoorTravelDuration = 20 * time.Second
+
+// garageDoorStates are names of characteristic.CurrentDoorState values.
+var garageDoorStates = []string{"open", "closed", "opening", "closing", "stopped"}
+
+// GarageDoor is garage door or gate opener controlled by pulse of OutPin (opener button input), every pulse
+// starts, stops or reverses the door. Optional reed switches (OpenSensor, ClosedSensor) are read from
+// SensorDriverName (default DriverName). Door state is computed from sensor transitions and travel time:
+// door without sensor at its end position is considered there after TravelDuration, door which does not
+// reach its sensor within TravelDuration is stopped and reports obstruction.
+type GarageDoor struct {
+	Name           string
+	Id             string
+	DriverName     string
+	OutPin         uint16
+	PulseDuration  string // default "500ms"
+	TravelDuration string // default "20s"
+	DisableHomekit bool
+	IsFaulty       bool
+
+	SensorDriverName string // default DriverName
+	OpenSensor       bool
+	OpenPin          uint16
+	ClosedSensor     bool
+	ClosedPin        uint16
+	InvertSensors    bool // sensor input off means door is at the position
+
+	ControlBy []ControllingDevice
+
+	output      drivers.DigitalOutput
+	openInput   drivers.DigitalInput
+	closedInput drivers.DigitalInput
+	driver      drivers.IoDriver
+
+	hk         *accessory.GarageDoorOpener
+	fault      *characteristic.StatusFault
+	lock       sync.Mutex
+	pulseLock  sync.Mutex
+	pulseTime  time.Duration
+	travelTime time.Duration
+
+	state      int // characteristic.CurrentDoorState
+	target     int // characteristic.TargetDoorState
+	lastMove   int // opening or closing
+	movedAt    time.Time
+	obstructed bool
+	stateKnown bool
+	wasOpen    bool
+	wasClosed  bool
+
+	uniqueId uint64
+}
+
+func (gd *GarageDoor) GetDriverName() string {
+	return gd.DriverName
+}
+
+func (gd *GarageDoor) GetUniqueId() uint64 {
+	if gd.uniqueId != 0 {
+		return gd.uniqueId
+	}
+	return gd.identity().hash()
+}
+
+func (gd *GarageDoor) identity() accessoryIdentity {
+	return accessoryIdentity{Kind: "GarageDoor", Id: gd.Id, Name: gd.Name, Serial: gd.serialNumber()}
+}
+
+func (gd *GarageDoor) serialNumber() string {
+	return fmt.Sprintf("garage_door:%s:%02d", gd.DriverName, gd.OutPin)
+}
+
+func (gd *GarageDoor) setUniqueId(id uint64) {
+	gd.uniqueId = id
+}
+
+func (gd *GarageDoor) GetSensorDriverName() string {
+	if len(gd.SensorDriverName) > 0 {
+		return gd.SensorDriverName
+	}
+	return gd.DriverName
+}
+
+func (gd *GarageDoor) hasSensors() bool {
+	return gd.OpenSensor || gd.ClosedSensor
+}
+
+// getSensorPins returns pins of configured sensors.
+func (gd *GarageDoor) getSensorPins() (pins []uint16) {
+	if gd.OpenSensor {
+		pins = append(pins, gd.OpenPin)
+	}
+	if gd.ClosedSensor {
+		pins = append(pins, gd.ClosedPin)
+	}
+	return
+}
+
+func (gd *GarageDoor) Init(driver drivers.IoDriver) error {
+	if !strings.EqualFold(driver.NameId(), gd.DriverName) {
+		return fmt.Errorf("Init failed, mismatched or incorrect driver")
+	}
+
+	if !driver.IsReady() {
+		return fmt.Errorf("Init failed, driver not ready")
+	}
+
+	var err error
+	gd.pulseTime = defaultGarageDoorPulseDuration
+	if len(gd.PulseDuration) > 0 {
+		gd.pulseTime, err = time.ParseDuration(gd.PulseDuration)
+		if err != nil {
+			return errors.Wrap(err, "Init failed, failed to parse PulseDuration")
+		}
+	}
+	gd.travelTime = defaultGarageDoorTravelDuration
+	if len(gd.TravelDuration) > 0 {
+		gd.travelTime, err = time.ParseDuration(gd.TravelDuration)
+		if err != nil {
+			return errors.Wrap(err, "Init failed, failed to parse TravelDuration")
+		}
+	}
+
+	gd.driver = driver
+	gd.output, err = driver.GetOutput(gd.OutPin)
+	if err != nil {
+		return errors.Wrap(err, "Init failed")
+	}
+
+	if gd.DisableHomekit {
+		return nil
+	}
+
+	gd.hk = accessory.NewGarageDoorOpener(accessory.Info{
+		Name:         gd.Name,
+		SerialNumber: gd.serialNumber(),
+	})
+
+	gd.fault = characteristic.NewStatusFault()
+	gd.fault.SetValue(characteristic.StatusFaultNoFault)
+	gd.hk.GarageDoorOpener.AddC(gd.fault.C)
+
+	gd.hk.GarageDoorOpener.TargetDoorState.OnValueRemoteUpdate(gd.SetTarget)
+
+	return nil
+}
+
+// initSensors gets sensor inputs from sensor driver and sets initial state (door without sensors is closed).
+func (gd *GarageDoor) initSensors(driver drivers.IoDriver) (err error) {
+	gd.lock.Lock()
+	defer gd.lock.Unlock()
+
+	gd.openInput, gd.closedInput = nil, nil
+	if gd.hasSensors() {
+		if driver == nil || !driver.IsReady() {
+			return errors.Errorf("sensor driver %s not ready", gd.GetSensorDriverName())
+		}
+		if gd.OpenSensor {
+			gd.openInput, err = driver.GetInput(gd.OpenPin)
+			if err != nil {
+				return errors.Wrap(err, "failed to get open sensor input")
+			}
+		}
+		if gd.ClosedSensor {
+			gd.closedInput, err = driver.GetInput(gd.ClosedPin)
+			if err != nil {
+				return errors.Wrap(err, "failed to get closed sensor input")
+			}
+		}
+	}
+
+	if !gd.stateKnown {
+		gd.stateKnown = true
+		gd.setState(characteristic.CurrentDoorStateClosed)
+		open, closed, err := gd.readSensors()
+		switch {
+		case err != nil:
+			log.Printf("garage door %s | failed to read sensors: %v", gd.Name, err)
+		case open:
+			gd.setState(characteristic.CurrentDoorStateOpen)
+		case gd.ClosedSensor && !closed && gd.OpenSensor:
+			gd.setState(characteristic.CurrentDoorStateStopped)
+		case gd.ClosedSensor && !closed:
+			gd.setState(characteristic.CurrentDoorStateOpen)
+		}
+		gd.wasOpen, gd.wasClosed = open, closed
+	}
+	gd.updateHk()
+
+	return nil
+}
+
+// readSensors returns true for sensors detecting the door, not configured sensor returns false.
+func (gd *GarageDoor) readSensors() (open bool, closed bool, err error) {
+	if gd.openInput != nil {
+		open, err = gd.openInput.GetState()
+		if err != nil {
+			return
+		}
+		open = open != gd.InvertSensors
+	}
+	if gd.closedInput != nil {
+		closed, err = gd.closedInput.GetState()
+		closed = closed != gd.InvertSensors
+	}
+	return
+}
+
+func (gd *GarageDoor) Sync() error {
+	gd.lock.Lock()
+	defer gd.lock.Unlock()
+
+	open, closed, err := gd.readSensors()
+	if gd.hk != nil {
+		if err != nil {
+			gd.fault.SetValue(characteristic.StatusFaultGeneralFault)
+			gd.IsFaulty = true
+		} else {
+			gd.fault.SetValue(characteristic.StatusFaultNoFault)
+			gd.IsFaulty = false
+		}
+	}
+	if err != nil {
+		return errors.Wrap(err, "Sync failed on reading sensors")
+	}
+
+	// sensor of position the door is leaving is ignored until travel time passes
+	moving := gd.state == characteristic.CurrentDoorStateOpening || gd.state == characteristic.CurrentDoorStateClosing
+	timedOut := moving && time.Since(gd.movedAt) > gd.travelTime
+	switch {
+	case closed && (!gd.wasClosed || gd.state != characteristic.CurrentDoorStateOpening || timedOut):
+		gd.setState(characteristic.CurrentDoorStateClosed)
+	case open && (!gd.wasOpen || gd.state != characteristic.CurrentDoorStateClosing || timedOut):
+		gd.setState(characteristic.CurrentDoorStateOpen)
+	case !closed && gd.wasClosed && gd.state == characteristic.CurrentDoorStateClosed:
+		// moved by other opener (remote)
+		gd.setState(characteristic.CurrentDoorStateOpening)
+	case !open && gd.wasOpen && gd.state == characteristic.CurrentDoorStateOpen:
+		gd.setState(characteristic.CurrentDoorStateClosing)
+	case timedOut:
+		endSensor := gd.ClosedSensor
+		if gd.state == characteristic.CurrentDoorStateOpening {
+			endSensor = gd.OpenSensor
+		}
+		if endSensor {
+			log.Printf("garage door %s | travel takes too long, obstruction detected", gd.Name)
+			gd.setState(characteristic.CurrentDoorStateStopped)
+			gd.obstructed = true
+		} else if gd.state == characteristic.CurrentDoorStateOpening {
+			gd.setState(characteristic.CurrentDoorStateOpen)
+		} else {
+			gd.setState(characteristic.CurrentDoorStateClosed)
+		}
+	}
+	gd.wasOpen, gd.wasClosed = open, closed
+	gd.updateHk()
+
+	return nil
+}
+
+// setState changes current state, target follows movement. Has to be called with lock held.
+func (gd *GarageDoor) setState(state int) {
+	if state == gd.state {
+		return
+	}
+	gd.state = state
+	switch state {
+	case characteristic.CurrentDoorStateOpening, characteristic.CurrentDoorStateClosing:
+		gd.lastMove = state
+		gd.movedAt = time.Now()
+		gd.obstructed = false
+		fallthrough
+	case characteristic.CurrentDoorStateOpen, characteristic.CurrentDoorStateClosed:
+		gd.target = characteristic.TargetDoorStateOpen
+		if state == characteristic.CurrentDoorStateClosing || state == characteristic.CurrentDoorStateClosed {
+			gd.target = characteristic.TargetDoorStateClosed
+		}
+	}
+	if state == characteristic.CurrentDoorStateOpen || state == characteristic.CurrentDoorStateClosed {
+		gd.obstructed = false
+	}
+}
+
+// nextDoorState returns state after opener pulse and direction of last movement.
+func nextDoorState(state int, lastMove int) (int, int) {
+	switch state {
+	case characteristic.CurrentDoorStateClosed:
+		return characteristic.CurrentDoorStateOpening, characteristic.CurrentDoorStateOpening
+	case characteristic.CurrentDoorStateOpen:
+		return characteristic.CurrentDoorStateClosing, characteristic.CurrentDoorStateClosing
+	case characteristic.CurrentDoorStateOpening, characteristic.CurrentDoorStateClosing:
+		return characteristic.CurrentDoorStateStopped, lastMove
+	}
+	if lastMove == characteristic.CurrentDoorStateOpening {
+		return characteristic.CurrentDoorStateClosing, characteristic.CurrentDoorStateClosing
+	}
+	return characteristic.CurrentDoorStateOpening, characteristic.CurrentDoorStateOpening
+}
+
+// SetTarget opens (characteristic.TargetDoorStateOpen) or closes the door with as many pulses as needed.
+func (gd *GarageDoor) SetTarget(target int) {
+	gd.lock.Lock()
+	defer gd.lock.Unlock()
+
+	moving, done := characteristic.CurrentDoorStateOpening, characteristic.CurrentDoorStateOpen
+	if target == characteristic.TargetDoorStateClosed {
+		moving, done = characteristic.CurrentDoorStateClosing, characteristic.CurrentDoorStateClosed
+	}
+
+	state, lastMove := gd.state, gd.lastMove
+	pulses := 0
+	for ; pulses < 3 && state != moving && state != done; pulses++ {
+		state, lastMove = nextDoorState(state, lastMove)
+	}
+	gd.lastMove = lastMove
+	gd.setState(state)
+	gd.target = target
+	gd.updateHk()
+
+	if pulses > 0 {
+		go gd.pulse(pulses)
+	}
+}
+
+// Stop stops moving door.
+func (gd *GarageDoor) Stop() {
+	gd.lock.Lock()
+	moving := gd.state == characteristic.CurrentDoorStateOpening || gd.state == characteristic.CurrentDoorStateClosing
+	gd.lock.Unlock()
+
+	if moving {
+		gd.Toggle()
+	}
+}
+
+// pulse switches output on for pulse duration count times.
+func (gd *GarageDoor) pulse(count int) {
+	gd.pulseLock.Lock()
+	defer gd.pulseLock.Unlock()
+
+	for ix := 0; ix < count; ix++ {
+		if ix > 0 {
+			time.Sleep(gd.pulseTime)
+		}
+		err := gd.output.Set(true)
+		if err == nil {
+			time.Sleep(gd.pulseTime)
+			err = gd.output.Set(false)
+		}
+		if err != nil {
+			log.Printf("garage door %s | pulse failed: %v", gd.Name, err)
+			return
+		}
+	}
+}
+
+// updateHk has to be called with lock held.
+func (gd *GarageDoor) updateHk() {
+	if gd.hk == nil {
+		return
+	}
+
+	service := gd.hk.GarageDoorOpener
+	if service.CurrentDoorState.Value() != gd.state {
+		service.CurrentDoorState.SetValue(gd.state)
+	}
+	if service.TargetDoorState.Value() != gd.target {
+		service.TargetDoorState.SetValue(gd.target)
+	}
+	if service.ObstructionDetected.Value() != gd.obstructed {
+		service.ObstructionDetected.SetValue(gd.obstructed)
+	}
+}
+
+// GetDoorState returns current door state (characteristic.CurrentDoorState) and obstruction.
+func (gd *GarageDoor) GetDoorState() (state int, obstructed bool) {
+	gd.lock.Lock()
+	defer gd.lock.Unlock()
+
+	return gd.state, gd.obstructed
+}
+
+// GetState is true when door is not closed.
+func (gd *GarageDoor) GetState() (bool, error) {
+	state, _ := gd.GetDoorState()
+	return state != characteristic.CurrentDoorStateClosed, nil
+}
+
+func (gd *GarageDoor) GetControllers() []ControllingDevice {
+	return gd.ControlBy
+}
+
+func (gd *GarageDoor) GetHk() *accessory.A {
+	if gd.hk == nil {
+		return nil
+	}
+	return gd.hk.A
+}
+
+// SetValue opens (true) or closes (false) the door.
+func (gd *GarageDoor) SetValue(open bool) {
+	if open {
+		gd.SetTarget(characteristic.TargetDoorStateOpen)
+	} else {
+		gd.SetTarget(characteristic.TargetDoorStateClosed)
+	}
+}
+
+// Toggle sends single pulse, like opener button.
+func (gd *GarageDoor) Toggle() {
+	gd.lock.Lock()
+	state, lastMove := nextDoorState(gd.state, gd.lastMove)
+	gd.lastMove = lastMove
+	gd.setState(state)
+	gd.updateHk()
+	gd.lock.Unlock()
+
+	go gd.pulse(1)
+}
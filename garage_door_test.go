@@ -0,0 +1,119 @@
+package swkit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brutella/hap/characteristic"
+	drivers "github.com/hubertat/swkit/drivers"
+)
+
+func garageDoorTest(t *testing.T, door *GarageDoor) *drivers.MockIoDriver {
+	t.Helper()
+
+	sw := newMockKit(t, func(sw *SwKit) {
+		sw.GarageDoors = []*GarageDoor{door}
+	})
+	md, _ := sw.GetMockIoDriver("mock_driver")
+	return md
+}
+
+func countPulses(md *drivers.MockIoDriver, pin uint16) (pulses int) {
+	for _, write := range md.Writes() {
+		if write.Pin == pin && !write.State {
+			pulses++
+		}
+	}
+	return
+}
+
+func doorState(door *GarageDoor) int {
+	state, _ := door.GetDoorState()
+	return state
+}
+
+func TestNextDoorState(t *testing.T) {
+	state, lastMove := nextDoorState(characteristic.CurrentDoorStateClosed, 0)
+	assertInts(t, state, characteristic.CurrentDoorStateOpening)
+	state, lastMove = nextDoorState(state, lastMove)
+	assertInts(t, state, characteristic.CurrentDoorStateStopped)
+	state, lastMove = nextDoorState(state, lastMove)
+	assertInts(t, state, characteristic.CurrentDoorStateClosing)
+	assertInts(t, lastMove, characteristic.CurrentDoorStateClosing)
+	state, _ = nextDoorState(characteristic.CurrentDoorStateOpen, lastMove)
+	assertInts(t, state, characteristic.CurrentDoorStateClosing)
+}
+
+func TestGarageDoorTravelTime(t *testing.T) {
+	door := &GarageDoor{Name: "Garage", DriverName: "mock_driver", OutPin: 1, PulseDuration: "10ms", TravelDuration: "100ms"}
+	md := garageDoorTest(t, door)
+	assertInts(t, doorState(door), characteristic.CurrentDoorStateClosed)
+
+	door.SetValue(true)
+	assertInts(t, doorState(door), characteristic.CurrentDoorStateOpening)
+	assertInts(t, door.hk.GarageDoorOpener.TargetDoorState.Value(), characteristic.TargetDoorStateOpen)
+	waitFor(t, func() bool { return countPulses(md, 1) == 1 }, "single pulse")
+	time.Sleep(110 * time.Millisecond)
+	door.Sync()
+	assertInts(t, doorState(door), characteristic.CurrentDoorStateOpen)
+	assertInts(t, door.hk.GarageDoorOpener.CurrentDoorState.Value(), characteristic.CurrentDoorStateOpen)
+
+	// opened door is not pulsed again
+	door.SetValue(true)
+	time.Sleep(30 * time.Millisecond)
+	assertInts(t, countPulses(md, 1), 1)
+
+	door.SetValue(false)
+	waitFor(t, func() bool { return countPulses(md, 1) == 2 }, "closing pulse")
+	door.Toggle()
+	assertInts(t, doorState(door), characteristic.CurrentDoorStateStopped)
+	waitFor(t, func() bool { return countPulses(md, 1) == 3 }, "stop pulse")
+
+	// stopped while closing: next pulse opens, so closing needs three pulses
+	md.ClearWrites()
+	door.SetValue(false)
+	assertInts(t, doorState(door), characteristic.CurrentDoorStateClosing)
+	waitFor(t, func() bool { return countPulses(md, 1) == 3 }, "three pulses")
+	assertBools(t, outputState(md, 1), false)
+}
+
+func TestGarageDoorSensors(t *testing.T) {
+	door := &GarageDoor{Name: "Garage", DriverName: "mock_driver", OutPin: 1, PulseDuration: "10ms", TravelDuration: "100ms",
+		ClosedSensor: true, ClosedPin: 10, OpenSensor: true, OpenPin: 11}
+	md := garageDoorTest(t, door)
+	assertInts(t, doorState(door), characteristic.CurrentDoorStateStopped)
+
+	md.SetInput(10, true)
+	door.Sync()
+	assertInts(t, doorState(door), characteristic.CurrentDoorStateClosed)
+
+	// closed sensor is still active right after start
+	door.SetValue(true)
+	door.Sync()
+	assertInts(t, doorState(door), characteristic.CurrentDoorStateOpening)
+	md.SetInput(10, false)
+	door.Sync()
+	assertInts(t, doorState(door), characteristic.CurrentDoorStateOpening)
+	md.SetInput(11, true)
+	door.Sync()
+	assertInts(t, doorState(door), characteristic.CurrentDoorStateOpen)
+
+	// moved by remote
+	md.SetInput(11, false)
+	door.Sync()
+	assertInts(t, doorState(door), characteristic.CurrentDoorStateClosing)
+	assertInts(t, door.hk.GarageDoorOpener.TargetDoorState.Value(), characteristic.TargetDoorStateClosed)
+
+	time.Sleep(110 * time.Millisecond)
+	door.Sync()
+	state, obstructed := door.GetDoorState()
+	assertInts(t, state, characteristic.CurrentDoorStateStopped)
+	assertBools(t, obstructed, true)
+	assertBools(t, door.hk.GarageDoorOpener.ObstructionDetected.Value(), true)
+
+	md.SetInput(10, true)
+	door.Sync()
+	state, obstructed = door.GetDoorState()
+	assertInts(t, state, characteristic.CurrentDoorStateClosed)
+	assertBools(t, obstructed, false)
+}
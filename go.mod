@@ -3,34 +3,40 @@ module github.com/hubertat/swkit
 go 1.22
 
 require (
+	github.com/BurntSushi/toml v1.6.0
 	github.com/brutella/dnssd v1.2.10
 	github.com/brutella/hap v0.0.33
+	github.com/eclipse/paho.mqtt.golang v1.4.3
 	github.com/gorilla/websocket v1.5.0
 	github.com/hubertat/servicemaker v0.1.2
 	github.com/influxdata/influxdb-client-go/v2 v2.5.1
 	github.com/julienschmidt/httprouter v1.3.0
+	github.com/mochi-mqtt/server/v2 v2.7.9
 	github.com/pkg/errors v0.9.1
 	github.com/racerxdl/go-mcp23017 v0.0.0-20200119181255-c8f9b9777b0e
+	github.com/simonvetter/modbus v1.6.4
 	github.com/stianeikeland/go-rpio/v4 v4.6.0
+	golang.org/x/sys v0.28.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/deepmap/oapi-codegen v1.8.2 // indirect
 	github.com/go-chi/chi v1.5.4 // indirect
+	github.com/goburrow/serial v0.1.0 // indirect
 	github.com/influxdata/line-protocol v0.0.0-20210311194329-9aa0e372d097 // indirect
-	github.com/kr/pretty v0.3.1 // indirect
 	github.com/logrusorgru/aurora v0.0.0-20181002194514-a7b3b318ed4e // indirect
 	github.com/miekg/dns v1.1.54 // indirect
 	github.com/quan-to/slog v0.0.0-20190414172229-8bce0937f2c1 // indirect
+	github.com/rs/xid v1.4.0 // indirect
 	github.com/tadglines/go-pkgs v0.0.0-20210623144937-b983b20f54f9 // indirect
 	github.com/xiam/to v0.0.0-20200126224905-d60d31e03561 // indirect
-	golang.org/x/crypto v0.9.0 // indirect
-	golang.org/x/mod v0.10.0 // indirect
-	golang.org/x/net v0.10.0 // indirect
-	golang.org/x/sync v0.3.0 // indirect
-	golang.org/x/sys v0.9.0 // indirect
-	golang.org/x/text v0.9.0 // indirect
-	golang.org/x/tools v0.9.1 // indirect
+	golang.org/x/crypto v0.31.0 // indirect
+	golang.org/x/mod v0.17.0 // indirect
+	golang.org/x/net v0.33.0 // indirect
+	golang.org/x/sync v0.10.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
+	golang.org/x/tools v0.21.1-0.20240508182429-e35e4ccd0d2d // indirect
 	gopkg.in/Regis24GmbH/go-diacritics.v2 v2.0.3 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
 )
@@ -2,9 +2,10 @@ package swkit
 
 import (
 	"fmt"
-	"hash/fnv"
+	"log"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/brutella/hap/accessory"
 	"github.com/brutella/hap/characteristic"
@@ -12,21 +13,45 @@ import (
 	"github.com/pkg/errors"
 )
 
+const lightFadeStep = 50 * time.Millisecond
+const lightRampStep = 100 * time.Millisecond
+const defaultLightRampDuration = 4 * time.Second
+
+// Light is on/off light, with Dimmable set its output has to be AnalogOutput (PWM, dimmer) and light has
+// brightness. Brightness changes are faded over FadeDuration, long press of controlling button ramps
+// brightness up or down (alternately) while the button is held, full range takes RampDuration.
 type Light struct {
 	Name           string
+	Id             string
 	State          bool
 	DriverName     string
 	OutPin         uint16
 	DisableHomekit bool
 	IsFaulty       bool
 
+	Dimmable     bool
+	Brightness   int    // 0-100, kept when light is switched off
+	FadeDuration string // e.g. "500ms", default no fade
+	RampDuration string // default "4s"
+
 	ControlBy []ControllingDevice
 
-	output drivers.DigitalOutput
-	driver drivers.IoDriver
-	hk     *accessory.Lightbulb
-	fault  *characteristic.StatusFault
-	lock   sync.Mutex
+	output     drivers.DigitalOutput
+	analog     drivers.AnalogOutput
+	driver     drivers.IoDriver
+	hk         *accessory.Lightbulb
+	brightness *characteristic.Brightness
+	fault      *characteristic.StatusFault
+	lock       sync.Mutex
+
+	fadeTime time.Duration
+	rampTime time.Duration
+	level    int
+	levelId  int
+	fading   bool
+	rampUp   bool
+
+	uniqueId uint64
 }
 
 func (li *Light) GetDriverName() string {
@@ -34,9 +59,22 @@ func (li *Light) GetDriverName() string {
 }
 
 func (li *Light) GetUniqueId() uint64 {
-	hash := fnv.New64()
-	hash.Write([]byte("Light_" + li.Name))
-	return hash.Sum64()
+	if li.uniqueId != 0 {
+		return li.uniqueId
+	}
+	return li.identity().hash()
+}
+
+func (li *Light) identity() accessoryIdentity {
+	return accessoryIdentity{Kind: "Light", Id: li.Id, Name: li.Name, Serial: li.serialNumber()}
+}
+
+func (li *Light) serialNumber() string {
+	return fmt.Sprintf("light:%s:%02d", li.DriverName, li.OutPin)
+}
+
+func (li *Light) setUniqueId(id uint64) {
+	li.uniqueId = id
 }
 
 func (li *Light) Init(driver drivers.IoDriver) error {
@@ -58,13 +96,20 @@ func (li *Light) Init(driver drivers.IoDriver) error {
 		return errors.Wrap(err, "Init failed")
 	}
 
+	if li.Dimmable {
+		err = li.initDimming()
+		if err != nil {
+			return errors.Wrap(err, "Init failed")
+		}
+	}
+
 	if li.DisableHomekit {
 		return nil
 	}
 
 	info := accessory.Info{
 		Name:         li.Name,
-		SerialNumber: fmt.Sprintf("light:%s:%02d", li.DriverName, li.OutPin),
+		SerialNumber: li.serialNumber(),
 	}
 	li.hk = accessory.NewLightbulb(info)
 
@@ -74,6 +119,41 @@ func (li *Light) Init(driver drivers.IoDriver) error {
 
 	li.hk.Lightbulb.On.OnValueRemoteUpdate(li.SetValue)
 
+	if li.analog != nil {
+		li.brightness = characteristic.NewBrightness()
+		li.brightness.SetValue(li.Brightness)
+		li.hk.Lightbulb.AddC(li.brightness.C)
+		li.brightness.OnValueRemoteUpdate(li.SetBrightness)
+	}
+
+	return nil
+}
+
+func (li *Light) initDimming() (err error) {
+	var ok bool
+	li.analog, ok = li.output.(drivers.AnalogOutput)
+	if !ok {
+		return errors.Errorf("output %d of driver %s is not dimmable", li.OutPin, li.DriverName)
+	}
+
+	li.fadeTime = 0
+	if len(li.FadeDuration) > 0 {
+		li.fadeTime, err = time.ParseDuration(li.FadeDuration)
+		if err != nil {
+			return errors.Wrap(err, "failed to parse FadeDuration")
+		}
+	}
+	li.rampTime = defaultLightRampDuration
+	if len(li.RampDuration) > 0 {
+		li.rampTime, err = time.ParseDuration(li.RampDuration)
+		if err != nil {
+			return errors.Wrap(err, "failed to parse RampDuration")
+		}
+	}
+	if li.Brightness <= 0 || li.Brightness > 100 {
+		li.Brightness = 100
+	}
+
 	return nil
 }
 
@@ -81,7 +161,11 @@ func (li *Light) Sync() (err error) {
 	li.lock.Lock()
 	defer li.lock.Unlock()
 
-	li.State, err = li.output.GetState()
+	if li.analog != nil {
+		err = li.syncLevel()
+	} else {
+		li.State, err = li.output.GetState()
+	}
 	if li.hk != nil {
 		if err != nil {
 			li.fault.SetValue(characteristic.StatusFaultGeneralFault)
@@ -96,10 +180,31 @@ func (li *Light) Sync() (err error) {
 		return errors.Wrap(err, "Sync failed on output.GetState()")
 	}
 
-	if li.State != li.hk.Lightbulb.On.Value() && li.hk != nil {
+	if li.hk != nil && li.State != li.hk.Lightbulb.On.Value() {
 		li.hk.Lightbulb.On.SetValue(li.State)
 	}
+	if li.brightness != nil && li.Brightness != li.brightness.Value() {
+		li.brightness.SetValue(li.Brightness)
+	}
+
+	return nil
+}
+
+// syncLevel reads level of analog output, it is skipped while fading or ramping. Has to be called with lock held.
+func (li *Light) syncLevel() error {
+	if li.fading {
+		return nil
+	}
 
+	level, err := li.analog.GetLevel()
+	if err != nil {
+		return err
+	}
+	li.level = level
+	li.State = level > 0
+	if level > 0 {
+		li.Brightness = level
+	}
 	return nil
 }
 
@@ -115,10 +220,180 @@ func (li *Light) GetHk() *accessory.A {
 }
 
 func (li *Light) SetValue(state bool) {
+	li.lock.Lock()
+	if li.analog == nil {
+		defer li.lock.Unlock()
+
+		li.State = state
+		li.output.Set(li.State)
+		return
+	}
+
 	li.State = state
-	li.output.Set(li.State)
+	level := 0
+	if state {
+		level = li.Brightness
+	}
+	li.lock.Unlock()
+
+	li.fadeTo(level)
+}
+
+// SetBrightness switches dimmable light on with brightness (0-100), brightness 0 switches it off.
+func (li *Light) SetBrightness(brightness int) {
+	if li.analog == nil {
+		li.SetValue(brightness > 0)
+		return
+	}
+	if brightness <= 0 {
+		li.SetValue(false)
+		return
+	}
+
+	li.lock.Lock()
+	li.State = true
+	li.Brightness = min(brightness, 100)
+	level := li.Brightness
+	li.lock.Unlock()
+
+	li.fadeTo(level)
+}
+
+// fadeTo changes level of analog output in steps over fadeTime, fade in progress is replaced.
+func (li *Light) fadeTo(level int) {
+	li.lock.Lock()
+	li.levelId++
+	id := li.levelId
+	from := li.level
+	steps := int(li.fadeTime / lightFadeStep)
+	li.fading = steps > 1 && from != level
+	fading := li.fading
+	if !fading {
+		li.level = level
+	}
+	li.lock.Unlock()
+
+	if !fading {
+		err := li.analog.SetLevel(level)
+		if err != nil {
+			log.Printf("light %s | failed to set level: %v", li.Name, err)
+		}
+		return
+	}
+
+	go func() {
+		defer func() {
+			li.lock.Lock()
+			if li.levelId == id {
+				li.fading = false
+			}
+			li.lock.Unlock()
+		}()
+
+		for step := 1; step <= steps; step++ {
+			if step > 1 {
+				time.Sleep(lightFadeStep)
+			}
+			current := from + (level-from)*step/steps
+
+			li.lock.Lock()
+			replaced := li.levelId != id
+			if !replaced {
+				li.level = current
+			}
+			li.lock.Unlock()
+			if replaced {
+				return
+			}
+
+			err := li.analog.SetLevel(current)
+			if err != nil {
+				log.Printf("light %s | fade failed: %v", li.Name, err)
+				return
+			}
+		}
+	}()
+}
+
+// IsRampable is true for dimmable light.
+func (li *Light) IsRampable() bool {
+	return li.analog != nil
+}
+
+// Ramp changes brightness while held returns true, direction alternates with every ramp. Switched off
+// light is ramped up from the lowest level, ramp stops at 1 and 100.
+func (li *Light) Ramp(held func() bool) {
+	if li.analog == nil {
+		return
+	}
+
+	li.lock.Lock()
+	li.levelId++
+	id := li.levelId
+	level := li.level
+	up := !li.rampUp
+	if level <= 1 {
+		up = true
+	} else if level >= 100 {
+		up = false
+	}
+	li.rampUp = up
+	li.fading = true
+	li.lock.Unlock()
+
+	step := int(100 * lightRampStep / li.rampTime)
+	if step < 1 {
+		step = 1
+	}
+	for {
+		if up {
+			level = min(level+step, 100)
+		} else {
+			level = max(level-step, 1)
+		}
+		err := li.analog.SetLevel(level)
+		if err != nil {
+			log.Printf("light %s | ramp failed: %v", li.Name, err)
+			break
+		}
+		if level == 1 || level == 100 {
+			break
+		}
+
+		time.Sleep(lightRampStep)
+		li.lock.Lock()
+		replaced := li.levelId != id
+		li.lock.Unlock()
+		if replaced || !held() {
+			break
+		}
+	}
+
+	li.lock.Lock()
+	defer li.lock.Unlock()
+	if li.levelId == id {
+		li.level = level
+		li.State = true
+		li.Brightness = level
+		li.fading = false
+	}
+}
+
+// GetState is true when light is on (dimmable light also while fading on).
+func (li *Light) GetState() (bool, error) {
+	if li.analog == nil {
+		return li.output.GetState()
+	}
+
+	li.lock.Lock()
+	defer li.lock.Unlock()
+	return li.State, nil
 }
 
 func (li *Light) Toggle() {
-	li.SetValue(!li.State)
+	li.lock.Lock()
+	state := li.State
+	li.lock.Unlock()
+
+	li.SetValue(!state)
 }
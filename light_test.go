@@ -0,0 +1,119 @@
+package swkit
+
+import (
+	"testing"
+	"time"
+
+	drivers "github.com/hubertat/swkit/drivers"
+)
+
+func waitFor(t testing.TB, condition func() bool, what string) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func dimmableLightTest(t *testing.T, light *Light) (*SwKit, *drivers.MockIoDriver) {
+	t.Helper()
+
+	sw := newMockKit(t, func(sw *SwKit) {
+		sw.Buttons = []*Button{{Name: "Hall button", DriverName: "mock_driver", InPin: 10}}
+		sw.Lights = []*Light{light}
+	})
+	md, _ := sw.GetMockIoDriver("mock_driver")
+	return sw, md
+}
+
+func outputLevel(md *drivers.MockIoDriver, pin uint16) int {
+	out, _ := md.GetOutput(pin)
+	level, _ := out.(drivers.AnalogOutput).GetLevel()
+	return level
+}
+
+func TestDimmableLight(t *testing.T) {
+	light := &Light{Name: "Hall", DriverName: "mock_driver", OutPin: 1, Dimmable: true}
+	_, md := dimmableLightTest(t, light)
+	assertInts(t, light.brightness.Value(), 100)
+
+	light.SetBrightness(40)
+	assertInts(t, outputLevel(md, 1), 40)
+	assertBools(t, light.State, true)
+
+	light.SetValue(false)
+	assertInts(t, outputLevel(md, 1), 0)
+	light.Sync()
+	assertBools(t, light.State || light.hk.Lightbulb.On.Value(), false)
+	assertInts(t, light.Brightness, 40)
+
+	light.Toggle()
+	assertInts(t, outputLevel(md, 1), 40)
+
+	// brightness set directly on the dimmer is synced to HomeKit
+	out, _ := md.GetOutput(1)
+	out.(drivers.AnalogOutput).SetLevel(65)
+	light.Sync()
+	assertInts(t, light.Brightness, 65)
+	assertInts(t, light.brightness.Value(), 65)
+
+	light.SetBrightness(0)
+	assertInts(t, outputLevel(md, 1), 0)
+	assertBools(t, light.State, false)
+}
+
+func TestDimmableLightFade(t *testing.T) {
+	light := &Light{Name: "Hall", DriverName: "mock_driver", OutPin: 1, Dimmable: true, FadeDuration: "200ms"}
+	_, md := dimmableLightTest(t, light)
+
+	light.SetBrightness(80)
+	assertBools(t, outputLevel(md, 1) < 80, true)
+	waitFor(t, func() bool { return outputLevel(md, 1) == 80 }, "fade to 80")
+
+	levels := []int{}
+	for _, write := range md.Writes() {
+		levels = append(levels, write.Level)
+	}
+	assertInts(t, len(levels), 4)
+	for ix := 1; ix < len(levels); ix++ {
+		assertBools(t, levels[ix] > levels[ix-1], true)
+	}
+
+	// new target replaces fade in progress
+	light.SetValue(false)
+	light.SetBrightness(30)
+	waitFor(t, func() bool { return outputLevel(md, 1) == 30 }, "fade to 30")
+	time.Sleep(250 * time.Millisecond)
+	assertInts(t, outputLevel(md, 1), 30)
+}
+
+func TestDimmableLightRamp(t *testing.T) {
+	light := &Light{Name: "Hall", DriverName: "mock_driver", OutPin: 1, Dimmable: true, RampDuration: "1s",
+		ControlBy: []ControllingDevice{{Pin: 10}}}
+	sw, md := dimmableLightTest(t, light)
+	assertInts(t, len(sw.Buttons[0].rampSlice), 1)
+
+	light.SetBrightness(40)
+	md.SetInput(10, true)
+	waitFor(t, func() bool { return outputLevel(md, 1) > 50 }, "ramp up")
+	md.SetInput(10, false)
+	waitFor(t, func() bool {
+		light.lock.Lock()
+		defer light.lock.Unlock()
+		return !light.fading
+	}, "ramp end")
+	brightness := light.Brightness
+	assertBools(t, brightness > 50 && brightness < 100, true)
+	assertInts(t, outputLevel(md, 1), brightness)
+
+	// next long press ramps down, single press still toggles
+	md.SetInput(10, true)
+	waitFor(t, func() bool { return outputLevel(md, 1) < brightness }, "ramp down")
+	md.SetInput(10, false)
+	md.Push(10, drivers.PushEventSinglePress)
+	waitFor(t, func() bool { return outputLevel(md, 1) == 0 }, "toggle off")
+}
@@ -0,0 +1,239 @@
+package swkit
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/brutella/hap/accessory"
+	"github.com/brutella/hap/characteristic"
+	"github.com/brutella/hap/service"
+	"github.com/hubertat/swkit/drivers"
+	"github.com/pkg/errors"
+)
+
+const oldDataDuration = 10 * time.Minute
+const defaultCo2AlarmLevel = 1000
+
+// vocAirQuality are upper VOC density (µg/m³) limits of HomeKit air quality levels, excellent to inferior.
+var vocAirQuality = []float64{300, 500, 1000, 3000}
+
+// MeasurementSensor is single value (Measurement, default temperature) read by sensor driver, exposed as
+// matching HomeKit sensor: temperature, humidity, light (illuminance), carbon dioxide (abnormal above
+// AlarmLevel ppm, default 1000) or air quality (VOC density). HomeKit has no pressure sensor, pressure is
+// published by mqtt bridge only.
+type MeasurementSensor struct {
+	Id             string
+	Name           string
+	DriverName     string
+	Measurement    string
+	Tags           map[string]string
+	AlarmLevel     float64
+	DisableHomekit bool
+
+	driver        drivers.SensorDriver
+	value         float64
+	lastSync      time.Time
+	lock          sync.Mutex
+	hkA           *accessory.A
+	hkSetValue    func(float64)
+	hkStatusFault *characteristic.StatusFault
+
+	uniqueId uint64
+}
+
+func (ms *MeasurementSensor) GetDriverName() string {
+	return ms.DriverName
+}
+
+func (ms *MeasurementSensor) GetUniqueId() uint64 {
+	if ms.uniqueId != 0 {
+		return ms.uniqueId
+	}
+	return ms.identity().hash()
+}
+
+// identity depends on measurement kind: temperature sensors keep identity (and unique id) of former
+// TemperatureSensor, other measurements have their own kind and serial number prefix.
+func (ms *MeasurementSensor) identity() accessoryIdentity {
+	if ms.GetMeasurement() == drivers.MeasurementTemperature {
+		return accessoryIdentity{Kind: "TemperatureSensor", Id: "", Name: ms.Name, Serial: ms.serialNumber()}
+	}
+	return accessoryIdentity{Kind: "MeasurementSensor", Id: "", Name: ms.Name, Serial: ms.serialNumber()}
+}
+
+func (ms *MeasurementSensor) serialNumber() string {
+	if ms.GetMeasurement() == drivers.MeasurementTemperature {
+		return fmt.Sprintf("temp_sensor:%s:%s", ms.DriverName, ms.Id)
+	}
+	return fmt.Sprintf("%s_sensor:%s:%s", ms.GetMeasurement(), ms.DriverName, ms.Id)
+}
+
+func (ms *MeasurementSensor) setUniqueId(id uint64) {
+	ms.uniqueId = id
+}
+
+func (ms *MeasurementSensor) GetId() string {
+	return ms.Id
+}
+
+func (ms *MeasurementSensor) GetTags() map[string]string {
+	return ms.Tags
+}
+
+// GetMeasurement returns parsed Measurement, unknown one is returned as is (see Validate).
+func (ms *MeasurementSensor) GetMeasurement() drivers.Measurement {
+	measurement, err := drivers.ParseMeasurement(ms.Measurement)
+	if err != nil {
+		return drivers.Measurement(ms.Measurement)
+	}
+	return measurement
+}
+
+func (ms *MeasurementSensor) Init(driver drivers.SensorDriver) error {
+	if len(ms.Name) < 2 {
+		return errors.Errorf("name of %s sensor (%s) is too short", ms.GetMeasurement(), ms.Name)
+	}
+
+	_, err := drivers.ParseMeasurement(ms.Measurement)
+	if err != nil {
+		return errors.Wrapf(err, "failed to init sensor %s", ms.Name)
+	}
+
+	ms.driver = driver
+	ms.hkA = nil
+
+	if ms.DisableHomekit {
+		return nil
+	}
+
+	info := accessory.Info{
+		Name:         ms.Name,
+		SerialNumber: ms.serialNumber(),
+	}
+	var hkService *service.S
+	switch ms.GetMeasurement() {
+	case drivers.MeasurementTemperature:
+		thermometer := accessory.NewTemperatureSensor(info)
+		ms.hkA = thermometer.A
+		hkService = thermometer.TempSensor.S
+		ms.hkSetValue = thermometer.TempSensor.CurrentTemperature.SetValue
+	case drivers.MeasurementHumidity:
+		humidity := service.NewHumiditySensor()
+		hkService = humidity.S
+		ms.hkSetValue = humidity.CurrentRelativeHumidity.SetValue
+	case drivers.MeasurementIlluminance:
+		light := service.NewLightSensor()
+		hkService = light.S
+		ms.hkSetValue = light.CurrentAmbientLightLevel.SetValue
+	case drivers.MeasurementCo2:
+		co2 := service.NewCarbonDioxideSensor()
+		level := characteristic.NewCarbonDioxideLevel()
+		co2.AddC(level.C)
+		hkService = co2.S
+		ms.hkSetValue = func(value float64) {
+			level.SetValue(value)
+			detected := characteristic.CarbonDioxideDetectedCO2LevelsNormal
+			if value > ms.getAlarmLevel() {
+				detected = characteristic.CarbonDioxideDetectedCO2LevelsAbnormal
+			}
+			co2.CarbonDioxideDetected.SetValue(detected)
+		}
+	case drivers.MeasurementVoc:
+		airQuality := service.NewAirQualitySensor()
+		density := characteristic.NewVOCDensity()
+		density.SetMaxValue(100000)
+		airQuality.AddC(density.C)
+		hkService = airQuality.S
+		ms.hkSetValue = func(value float64) {
+			density.SetValue(value)
+			airQuality.AirQuality.SetValue(getVocAirQuality(value))
+		}
+	default:
+		return nil
+	}
+	if ms.hkA == nil {
+		ms.hkA = accessory.New(info, accessory.TypeSensor)
+		ms.hkA.AddS(hkService)
+	}
+	ms.hkStatusFault = characteristic.NewStatusFault()
+	ms.hkStatusFault.SetValue(characteristic.StatusFaultGeneralFault)
+	hkService.AddC(ms.hkStatusFault.C)
+
+	return nil
+}
+
+func (ms *MeasurementSensor) getAlarmLevel() float64 {
+	if ms.AlarmLevel > 0 {
+		return ms.AlarmLevel
+	}
+	return defaultCo2AlarmLevel
+}
+
+// getVocAirQuality returns HomeKit air quality of VOC density.
+func getVocAirQuality(density float64) int {
+	for ix, limit := range vocAirQuality {
+		if density <= limit {
+			return characteristic.AirQualityExcellent + ix
+		}
+	}
+	return characteristic.AirQualityPoor
+}
+
+func (ms *MeasurementSensor) Sync() error {
+	val, err := ms.GetValue()
+	if err != nil {
+		err = errors.Wrapf(err, "failed to sync %s %s sensor %s", ms.Name, ms.GetMeasurement(), ms.Id)
+	}
+
+	ms.updateHomekitFaultStatus(err)
+
+	if err == nil && ms.hkA != nil {
+		ms.hkSetValue(val)
+	}
+
+	return err
+}
+
+func (ms *MeasurementSensor) updateHomekitFaultStatus(err error) {
+	if ms.hkStatusFault == nil {
+		return
+	}
+
+	if err != nil {
+		ms.hkStatusFault.SetValue(characteristic.StatusFaultGeneralFault)
+	} else {
+		ms.hkStatusFault.SetValue(characteristic.StatusFaultNoFault)
+	}
+}
+
+func (ms *MeasurementSensor) GetHk() *accessory.A {
+	return ms.hkA
+}
+
+func (ms *MeasurementSensor) GetValue() (value float64, err error) {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+
+	if ms.lastSync.IsZero() {
+		err = errors.Errorf("cannot get sensor %s value, never synced", ms.Id)
+		return
+	}
+
+	if time.Since(ms.lastSync) > oldDataDuration {
+		err = errors.Errorf("cannot get value of sensor %s, data is too old (%v old)", ms.Id, time.Since(ms.lastSync))
+		return
+	}
+
+	value = ms.value
+	return
+}
+
+func (ms *MeasurementSensor) SetValue(val float64) error {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+
+	ms.value = val
+	ms.lastSync = time.Now()
+	return nil
+}
@@ -0,0 +1,94 @@
+package swkit
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/brutella/hap/characteristic"
+	"github.com/brutella/hap/service"
+)
+
+func hkCharacteristic(ts *MeasurementSensor, serviceType string, characteristicType string) interface{} {
+	for _, s := range ts.GetHk().Ss {
+		if s.Type != serviceType {
+			continue
+		}
+		for _, c := range s.Cs {
+			if c.Type == characteristicType {
+				return c.Val
+			}
+		}
+	}
+	return nil
+}
+
+func TestMeasurementSensors(t *testing.T) {
+	temperature := &MeasurementSensor{Id: "1:100", Name: "Office temperature", DriverName: "modbus"}
+	humidity := &MeasurementSensor{Id: "1:101", Name: "Office humidity", DriverName: "modbus", Measurement: "humidity"}
+	co2 := &MeasurementSensor{Id: "1:102", Name: "Office CO2", DriverName: "modbus", Measurement: "CO2", AlarmLevel: 1200}
+	voc := &MeasurementSensor{Id: "1:103", Name: "Office VOC", DriverName: "modbus", Measurement: "voc"}
+	pressure := &MeasurementSensor{Id: "1:104", Name: "Office pressure", DriverName: "modbus", Measurement: "pressure"}
+	sw := newMockKit(t, func(sw *SwKit) {
+		sw.TemperatureSensors = []*MeasurementSensor{temperature}
+		sw.Sensors = []*MeasurementSensor{humidity, co2, voc, pressure}
+	})
+	msd, _ := sw.GetMockSensorDriver("modbus")
+
+	// temperature sensor keeps unique id of TemperatureSensor
+	assertBools(t, temperature.identity() == accessoryIdentity{Kind: "TemperatureSensor", Name: "Office temperature", Serial: "temp_sensor:modbus:1:100"}, true)
+	assertBools(t, pressure.GetHk() == nil, true)
+	assertInts(t, len(sw.getHkThings()), 5)
+
+	for id, value := range map[string]float64{"1:100": 21.5, "1:101": 45, "1:102": 1300, "1:103": 700, "1:104": 1013} {
+		msd.SetValue(id, value)
+	}
+	for _, ts := range sw.getMeasurementSensors() {
+		err := ts.Sync()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	assertBools(t, hkCharacteristic(temperature, service.TypeTemperatureSensor, characteristic.TypeCurrentTemperature) == 21.5, true)
+	assertBools(t, hkCharacteristic(humidity, service.TypeHumiditySensor, characteristic.TypeCurrentRelativeHumidity) == 45.0, true)
+	assertBools(t, hkCharacteristic(co2, service.TypeCarbonDioxideSensor, characteristic.TypeCarbonDioxideLevel) == 1300.0, true)
+	assertBools(t, hkCharacteristic(co2, service.TypeCarbonDioxideSensor, characteristic.TypeCarbonDioxideDetected) == characteristic.CarbonDioxideDetectedCO2LevelsAbnormal, true)
+	assertBools(t, hkCharacteristic(voc, service.TypeAirQualitySensor, characteristic.TypeAirQuality) == characteristic.AirQualityFair, true)
+	assertBools(t, hkCharacteristic(voc, service.TypeAirQualitySensor, characteristic.TypeStatusFault) == characteristic.StatusFaultNoFault, true)
+
+	assertInts(t, getVocAirQuality(100), characteristic.AirQualityExcellent)
+	assertInts(t, getVocAirQuality(5000), characteristic.AirQualityPoor)
+}
+
+func TestThermostatHumidity(t *testing.T) {
+	thermostat := &Thermostat{Name: "Office", DriverName: "gpio", HeatPin: 5, SensorId: "1:100", HumiditySensorId: "1:101"}
+	sw := newMockKit(t, func(sw *SwKit) {
+		sw.Thermostats = []*Thermostat{thermostat}
+		sw.Sensors = []*MeasurementSensor{
+			{Id: "1:100", Name: "Office temperature", DriverName: "modbus"},
+			{Id: "1:101", Name: "Office humidity", DriverName: "modbus", Measurement: "humidity"},
+		}
+	})
+	msd, _ := sw.GetMockSensorDriver("modbus")
+
+	msd.SetValue("1:100", 21)
+	msd.SetValue("1:101", 55)
+	err := thermostat.Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertFloats(t, thermostat.hkHumidity.Value(), 55)
+
+	// humidity sensor can't be used as temperature
+	thermostat.SensorId = "1:101"
+	assertBools(t, sw.MatchSensors() != nil, true)
+	err = sw.Validate()
+	assertBools(t, err != nil && strings.Contains(err.Error(), `sensor with Id "1:101" measures humidity, not temperature`), true)
+}
+
+func TestValidateMeasurement(t *testing.T) {
+	sw := &SwKit{Sensors: []*MeasurementSensor{{Id: "1:100", Name: "Office radiation", DriverName: "modbus", Measurement: "radiation"}}}
+	sw.UseMockDrivers()
+	err := sw.Validate()
+	assertBools(t, err != nil && strings.Contains(err.Error(), "unknown measurement radiation"), true)
+}
@@ -2,8 +2,10 @@ package swkit
 
 import (
 	"fmt"
-	"hash/fnv"
+	"log"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/brutella/hap/accessory"
 	"github.com/brutella/hap/characteristic"
@@ -12,18 +14,50 @@ import (
 	"github.com/pkg/errors"
 )
 
+const defaultMotionOnTime = 2 * time.Minute
+const defaultMotionOverrideTime = 30 * time.Minute
+
+// MotionSensor reports motion for HoldTime after last detection (retriggered by every detection), so PIR pulses
+// do not flicker. Lights with the sensor input in ControlBy are switched on by motion and off OnTime after last
+// motion, only when value of LuxSensorId sensor is below LuxThreshold (if set). Lights switched on by hand are
+// not switched off, light switched off by hand is not switched on by motion for OverrideTime.
 type MotionSensor struct {
 	Name           string
+	Id             string
 	State          bool
 	DriverName     string
 	InPin          uint16
 	DisableHomekit bool
 
+	HoldTime     string // default no hold
+	OnTime       string // default "2m"
+	OverrideTime string // default "30m"
+	LuxSensorId  string
+	LuxThreshold float64
+
 	input       drivers.DigitalInput
 	driver      drivers.IoDriver
 	hkAccessory *accessory.A
 	hkService   *service.MotionSensor
 	hkFault     *characteristic.StatusFault
+
+	lock         sync.Mutex
+	holdTime     time.Duration
+	onTime       time.Duration
+	overrideTime time.Duration
+	lastMotion   time.Time
+	controlled   []*motionControlled
+	luxSensor    drivers.Sensor
+
+	uniqueId uint64
+}
+
+// motionControlled is device controlled by motion sensor.
+type motionControlled struct {
+	device          Controllable
+	switched        bool // switched on by motion
+	lastState       bool
+	suppressedUntil time.Time // switched off by hand, not switched on by motion until
 }
 
 func (ms *MotionSensor) GetDriverName() string {
@@ -31,9 +65,46 @@ func (ms *MotionSensor) GetDriverName() string {
 }
 
 func (ms *MotionSensor) GetUniqueId() uint64 {
-	hash := fnv.New64()
-	hash.Write([]byte("MotionSensor_" + ms.Name))
-	return hash.Sum64()
+	if ms.uniqueId != 0 {
+		return ms.uniqueId
+	}
+	return ms.identity().hash()
+}
+
+func (ms *MotionSensor) identity() accessoryIdentity {
+	return accessoryIdentity{Kind: "MotionSensor", Id: ms.Id, Name: ms.Name, Serial: ms.serialNumber()}
+}
+
+func (ms *MotionSensor) serialNumber() string {
+	return fmt.Sprintf("motion_sensor:%s:%02d", ms.DriverName, ms.InPin)
+}
+
+func (ms *MotionSensor) setUniqueId(id uint64) {
+	ms.uniqueId = id
+}
+
+// parseDurations parses HoldTime, OnTime and OverrideTime.
+func (ms *MotionSensor) parseDurations() (err error) {
+	durations := []struct {
+		field    string
+		value    string
+		duration *time.Duration
+		fallback time.Duration
+	}{
+		{"HoldTime", ms.HoldTime, &ms.holdTime, 0},
+		{"OnTime", ms.OnTime, &ms.onTime, defaultMotionOnTime},
+		{"OverrideTime", ms.OverrideTime, &ms.overrideTime, defaultMotionOverrideTime},
+	}
+	for _, duration := range durations {
+		*duration.duration = duration.fallback
+		if len(duration.value) > 0 {
+			*duration.duration, err = time.ParseDuration(duration.value)
+			if err != nil {
+				return errors.Wrapf(err, "failed to parse %s", duration.field)
+			}
+		}
+	}
+	return nil
 }
 
 func (ms *MotionSensor) Init(driver drivers.IoDriver) error {
@@ -45,7 +116,10 @@ func (ms *MotionSensor) Init(driver drivers.IoDriver) error {
 		return fmt.Errorf("Init failed, driver not ready")
 	}
 
-	var err error
+	err := ms.parseDurations()
+	if err != nil {
+		return errors.Wrap(err, "Init failed")
+	}
 
 	ms.driver = driver
 	ms.input, err = driver.GetInput(ms.InPin)
@@ -53,9 +127,10 @@ func (ms *MotionSensor) Init(driver drivers.IoDriver) error {
 		return errors.Wrap(err, "Init failed on getting input")
 	}
 
+	// unreadable sensor is reported as faulty (as by Sync), not failing whole swkit
 	initState, err := ms.input.GetState()
 	if err != nil {
-		return errors.Wrap(err, "Init failed, on reading state")
+		log.Printf("motion sensor %s | failed to read initial state: %v", ms.Name, err)
 	}
 
 	if ms.DisableHomekit {
@@ -64,17 +139,17 @@ func (ms *MotionSensor) Init(driver drivers.IoDriver) error {
 
 	info := accessory.Info{
 		Name:         ms.Name,
-		SerialNumber: fmt.Sprintf("motion_sensor:%s:%02d", ms.DriverName, ms.InPin),
+		SerialNumber: ms.serialNumber(),
 	}
 
 	ms.hkAccessory = accessory.New(info, accessory.TypeSensor)
 	ms.hkService = service.NewMotionSensor()
 	ms.hkFault = characteristic.NewStatusFault()
-	ms.hkFault.SetValue(characteristic.StatusFaultGeneralFault)
+	ms.updateHomekitFaultStatus(err)
 
 	ms.hkService.AddC(ms.hkFault.C)
 	ms.hkAccessory.AddS(ms.hkService.S)
-	ms.hkService.MotionDetected.SetValue(initState)
+	ms.hkService.MotionDetected.SetValue(initState && err == nil)
 
 	return nil
 }
@@ -91,16 +166,101 @@ func (ms *MotionSensor) updateHomekitFaultStatus(err error) {
 	}
 }
 
-func (ms *MotionSensor) Sync() (err error) {
-	ms.State, err = ms.input.GetState()
+func (ms *MotionSensor) Sync() error {
+	motion, err := ms.input.GetState()
 
 	ms.updateHomekitFaultStatus(err)
+	if err != nil {
+		return errors.Wrap(err, "Sync failed")
+	}
+	// lux sensor is read before taking lock, not holding it while waiting for sensor
+	dark := ms.isDark()
+
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+
+	now := time.Now()
+	if motion {
+		ms.lastMotion = now
+	}
+	ms.State = motion || (!ms.lastMotion.IsZero() && now.Sub(ms.lastMotion) < ms.holdTime)
 
-	if ms.hkService != nil {
+	if ms.hkService != nil && ms.hkService.MotionDetected.Value() != ms.State {
 		ms.hkService.MotionDetected.SetValue(ms.State)
 	}
 
-	return
+	ms.control(now, dark)
+
+	return nil
+}
+
+// control switches controlled devices, has to be called with lock held.
+func (ms *MotionSensor) control(now time.Time, dark bool) {
+	toSwitchOn := []*motionControlled{}
+	for _, controlled := range ms.controlled {
+		state := controlled.lastState
+		if stateful, ok := controlled.device.(accessoryState); ok {
+			current, err := stateful.GetState()
+			if err == nil {
+				state = current
+			}
+		}
+		if controlled.lastState && !state {
+			// switched off by hand
+			log.Printf("motion sensor %s | controlled device switched off, motion suppressed for %v", ms.Name, ms.overrideTime)
+			controlled.switched = false
+			controlled.suppressedUntil = now.Add(ms.overrideTime)
+		}
+		if !state && !controlled.switched && !now.Before(controlled.suppressedUntil) {
+			toSwitchOn = append(toSwitchOn, controlled)
+		}
+		controlled.lastState = state
+	}
+
+	if ms.State {
+		if len(toSwitchOn) == 0 || !dark {
+			return
+		}
+		for _, controlled := range toSwitchOn {
+			controlled.device.SetValue(true)
+			controlled.switched = true
+			controlled.lastState = true
+		}
+		return
+	}
+
+	if now.Sub(ms.lastMotion) < ms.onTime {
+		return
+	}
+	for _, controlled := range ms.controlled {
+		if controlled.switched {
+			controlled.device.SetValue(false)
+			controlled.switched = false
+			controlled.lastState = false
+		}
+	}
+}
+
+// isDark is true without lux sensor or threshold, when lux sensor value can't be read it is assumed dark.
+func (ms *MotionSensor) isDark() bool {
+	if ms.luxSensor == nil || ms.LuxThreshold <= 0 {
+		return true
+	}
+
+	lux, err := ms.luxSensor.GetValue()
+	if err != nil {
+		log.Printf("motion sensor %s | failed to read lux sensor, assuming dark: %v", ms.Name, err)
+		return true
+	}
+	return lux < ms.LuxThreshold
+}
+
+// addControlled adds device switched by motion.
+func (ms *MotionSensor) addControlled(device Controllable) {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+
+	ms.controlled = append(ms.controlled, &motionControlled{device: device})
 }
 
 func (ms *MotionSensor) GetHk() *accessory.A {
@@ -109,5 +269,13 @@ func (ms *MotionSensor) GetHk() *accessory.A {
 }
 
 func (ms *MotionSensor) GetValue() bool {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+
 	return ms.State
 }
+
+// GetState returns motion state, held for HoldTime.
+func (ms *MotionSensor) GetState() (bool, error) {
+	return ms.GetValue(), nil
+}
@@ -0,0 +1,154 @@
+package swkit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/brutella/hap/characteristic"
+	drivers "github.com/hubertat/swkit/drivers"
+)
+
+func motionTest(t *testing.T, sensor *MotionSensor) (*drivers.MockIoDriver, *drivers.MockSensorDriver) {
+	t.Helper()
+
+	sw := newMockKit(t, func(sw *SwKit) {
+		sw.MotionSensors = []*MotionSensor{sensor}
+		sw.Lights = []*Light{{Name: "Hall", DriverName: "gpio", OutPin: 1, ControlBy: []ControllingDevice{{Pin: 10}}}}
+		sw.Sensors = []*MeasurementSensor{{Id: "lux-01", Name: "Hall lux", DriverName: "modbus", Measurement: "illuminance"}}
+	})
+	md, _ := sw.GetMockIoDriver("gpio")
+	msd, _ := sw.GetMockSensorDriver("modbus")
+	return md, msd
+}
+
+func TestMotionSensorHoldTime(t *testing.T) {
+	sensor := &MotionSensor{Name: "Hall", DriverName: "gpio", InPin: 10, HoldTime: "80ms"}
+	md, _ := motionTest(t, sensor)
+
+	md.SetInput(10, true)
+	sensor.Sync()
+	md.SetInput(10, false)
+	sensor.Sync()
+	assertBools(t, sensor.GetValue(), true)
+	assertBools(t, sensor.hkService.MotionDetected.Value(), true)
+
+	// retriggered
+	time.Sleep(50 * time.Millisecond)
+	md.SetInput(10, true)
+	sensor.Sync()
+	md.SetInput(10, false)
+	time.Sleep(50 * time.Millisecond)
+	sensor.Sync()
+	assertBools(t, sensor.GetValue(), true)
+
+	time.Sleep(40 * time.Millisecond)
+	sensor.Sync()
+	assertBools(t, sensor.GetValue(), false)
+	assertBools(t, sensor.hkService.MotionDetected.Value(), false)
+}
+
+func TestMotionSensorLights(t *testing.T) {
+	sensor := &MotionSensor{Name: "Hall", DriverName: "gpio", InPin: 10, OnTime: "60ms", OverrideTime: "100ms"}
+	md, _ := motionTest(t, sensor)
+
+	md.SetInput(10, true)
+	sensor.Sync()
+	assertBools(t, outputState(md, 1), true)
+
+	// light stays on for OnTime after last motion
+	md.SetInput(10, false)
+	sensor.Sync()
+	time.Sleep(30 * time.Millisecond)
+	sensor.Sync()
+	assertBools(t, outputState(md, 1), true)
+	time.Sleep(40 * time.Millisecond)
+	sensor.Sync()
+	assertBools(t, outputState(md, 1), false)
+
+	// switched off by hand suppresses motion for OverrideTime
+	md.SetInput(10, true)
+	sensor.Sync()
+	assertBools(t, outputState(md, 1), true)
+	out, _ := md.GetOutput(1)
+	out.Set(false)
+	sensor.Sync()
+	assertBools(t, outputState(md, 1), false)
+	time.Sleep(110 * time.Millisecond)
+	sensor.Sync()
+	assertBools(t, outputState(md, 1), true)
+
+	// switched on by hand is not switched off by motion
+	md.SetInput(10, false)
+	out.Set(false)
+	sensor.Sync()
+	time.Sleep(110 * time.Millisecond)
+	out.Set(true)
+	md.SetInput(10, true)
+	sensor.Sync()
+	md.SetInput(10, false)
+	time.Sleep(70 * time.Millisecond)
+	sensor.Sync()
+	assertBools(t, outputState(md, 1), true)
+}
+
+func TestMotionSensorLux(t *testing.T) {
+	sensor := &MotionSensor{Name: "Hall", DriverName: "gpio", InPin: 10, LuxSensorId: "lux-01", LuxThreshold: 50}
+	md, msd := motionTest(t, sensor)
+
+	msd.SetValue("lux-01", 120)
+	md.SetInput(10, true)
+	sensor.Sync()
+	assertBools(t, outputState(md, 1), false)
+
+	msd.SetValue("lux-01", 20)
+	sensor.Sync()
+	assertBools(t, outputState(md, 1), true)
+}
+
+func TestMotionSensorOverridePerLight(t *testing.T) {
+	sensor := &MotionSensor{Name: "Hall", DriverName: "gpio", InPin: 10, OnTime: "10ms", OverrideTime: "1m"}
+	sw := newMockKit(t, func(sw *SwKit) {
+		sw.MotionSensors = []*MotionSensor{sensor}
+		sw.Lights = []*Light{
+			{Name: "Hall", DriverName: "gpio", OutPin: 1, ControlBy: []ControllingDevice{{Pin: 10}}},
+			{Name: "Stairs", DriverName: "gpio", OutPin: 2, ControlBy: []ControllingDevice{{Pin: 10}}},
+		}
+	})
+	md, _ := sw.GetMockIoDriver("gpio")
+
+	md.SetInput(10, true)
+	sensor.Sync()
+	assertBools(t, outputState(md, 1) && outputState(md, 2), true)
+
+	// only light switched off by hand is suppressed
+	out, _ := md.GetOutput(1)
+	out.Set(false)
+	md.SetInput(10, false)
+	sensor.Sync()
+	time.Sleep(20 * time.Millisecond)
+	sensor.Sync()
+	assertBools(t, outputState(md, 2), false)
+
+	md.SetInput(10, true)
+	sensor.Sync()
+	assertBools(t, outputState(md, 1), false)
+	assertBools(t, outputState(md, 2), true)
+}
+
+func TestMotionSensorInitFault(t *testing.T) {
+	md := &drivers.MockIoDriver{DriverName: "gpio"}
+	err := md.Setup(context.Background(), []uint16{10}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer md.Close()
+
+	sensor := &MotionSensor{Name: "Hall", DriverName: "gpio", InPin: 10}
+	err = sensor.Init(failingInputDriver{md})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertInts(t, sensor.hkFault.Value(), characteristic.StatusFaultGeneralFault)
+	assertBools(t, sensor.hkService.MotionDetected.Value(), false)
+}
@@ -0,0 +1,653 @@
+package swkit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/pkg/errors"
+
+	drivers "github.com/hubertat/swkit/drivers"
+)
+
+const defaultMqttBridgeBaseTopic = "swkit"
+const defaultMqttDiscoveryPrefix = "homeassistant"
+const defaultMqttBridgePublishInterval = time.Second
+const mqttBridgeConnectTimeout = 10 * time.Second
+
+const (
+	mqttPayloadOn      = "ON"
+	mqttPayloadOff     = "OFF"
+	mqttPayloadOnline  = "online"
+	mqttPayloadOffline = "offline"
+)
+
+var mqttEventTypes = map[drivers.PushEvent]string{
+	drivers.PushEventSinglePress: "single",
+	drivers.PushEventDoublePress: "double",
+	drivers.PushEventLongPress:   "long",
+}
+
+var mqttThermostatModes = []string{"off", "heat", "cool", "auto"}
+
+// mqttSensorClasses are Home Assistant device class and unit of measurements.
+var mqttSensorClasses = map[drivers.Measurement][2]string{
+	drivers.MeasurementTemperature: {"temperature", "°C"},
+	drivers.MeasurementHumidity:    {"humidity", "%"},
+	drivers.MeasurementPressure:    {"atmospheric_pressure", "hPa"},
+	drivers.MeasurementCo2:         {"carbon_dioxide", "ppm"},
+	drivers.MeasurementIlluminance: {"illuminance", "lx"},
+	drivers.MeasurementVoc:         {"volatile_organic_compounds", "µg/m³"},
+}
+
+// mqttEntity is a single swkit thing exported to mqtt. State is published to <base>/<component>/<id>/state,
+// commands are received on <base>/<component>/<id>/<command> topics (e.g. "set", "mode/set").
+type mqttEntity struct {
+	component string
+	uniqueId  uint64
+	name      string
+	discovery map[string]interface{}
+	state     func() (string, error)
+	commands  map[string]func(payload string)
+}
+
+func (me *mqttEntity) getObjectId() string {
+	return fmt.Sprintf("swkit_%016x", me.uniqueId)
+}
+
+// MqttBridge publishes state of all swkit things to mqtt broker, accepts commands on /set topics
+// and announces things with Home Assistant mqtt discovery. Object ids are based on GetUniqueId hashes.
+type MqttBridge struct {
+	Broker           string // e.g. "tcp://192.168.1.10:1883"
+	ClientId         string
+	Username         string
+	Password         string
+	BaseTopic        string // default "swkit"
+	DiscoveryPrefix  string // default "homeassistant"
+	DisableDiscovery bool
+	PublishInterval  string // default "1s"
+
+	deviceName string
+	client     mqtt.Client
+	entities   []*mqttEntity
+	published  map[string]string
+	lock       sync.Mutex
+}
+
+func (mb *MqttBridge) getBaseTopic() string {
+	if len(mb.BaseTopic) == 0 {
+		return defaultMqttBridgeBaseTopic
+	}
+	return strings.TrimSuffix(mb.BaseTopic, "/")
+}
+
+func (mb *MqttBridge) getDiscoveryPrefix() string {
+	if len(mb.DiscoveryPrefix) == 0 {
+		return defaultMqttDiscoveryPrefix
+	}
+	return strings.TrimSuffix(mb.DiscoveryPrefix, "/")
+}
+
+func (mb *MqttBridge) getAvailabilityTopic() string {
+	return mb.getBaseTopic() + "/status"
+}
+
+func (mb *MqttBridge) getTopic(entity *mqttEntity, suffix string) string {
+	return fmt.Sprintf("%s/%s/%s/%s", mb.getBaseTopic(), entity.component, entity.getObjectId(), suffix)
+}
+
+func mqttBoolPayload(state bool) string {
+	if state {
+		return mqttPayloadOn
+	}
+	return mqttPayloadOff
+}
+
+func mqttOutputState(output accessoryState) func() (string, error) {
+	return func() (string, error) {
+		state, err := output.GetState()
+		return mqttBoolPayload(state), err
+	}
+}
+
+func mqttInputState(input drivers.DigitalInput) func() (string, error) {
+	return func() (string, error) {
+		state, err := input.GetState()
+		return mqttBoolPayload(state), err
+	}
+}
+
+func mqttSetValueCommand(setValue func(bool)) map[string]func(string) {
+	return map[string]func(string){
+		"set": func(payload string) {
+			switch strings.ToUpper(strings.TrimSpace(payload)) {
+			case mqttPayloadOn:
+				setValue(true)
+			case mqttPayloadOff:
+				setValue(false)
+			default:
+				log.Printf("mqtt bridge | unexpected command payload (%s)", payload)
+			}
+		},
+	}
+}
+
+func (mb *MqttBridge) addEntity(entity *mqttEntity) {
+	mb.entities = append(mb.entities, entity)
+}
+
+func (mb *MqttBridge) getEntities() []*mqttEntity {
+	mb.lock.Lock()
+	defer mb.lock.Unlock()
+
+	return mb.entities
+}
+
+func (mb *MqttBridge) buildEntities(sw *SwKit) {
+	mb.lock.Lock()
+	defer mb.lock.Unlock()
+
+	mb.entities = []*mqttEntity{}
+
+	for _, li := range sw.Lights {
+		mb.addEntity(&mqttEntity{
+			component: "light",
+			uniqueId:  li.GetUniqueId(),
+			name:      li.Name,
+			state:     mqttOutputState(li.output),
+			commands:  mqttSetValueCommand(li.SetValue),
+		})
+	}
+	for _, cl := range sw.ColorLights {
+		mb.addEntity(&mqttEntity{
+			component: "light",
+			uniqueId:  cl.GetUniqueId(),
+			name:      cl.Name,
+			state:     mqttOutputState(cl),
+			commands:  mqttSetValueCommand(cl.SetValue),
+		})
+	}
+	for _, ou := range sw.Outlets {
+		mb.addEntity(&mqttEntity{
+			component: "switch",
+			uniqueId:  ou.GetUniqueId(),
+			name:      ou.Name,
+			discovery: map[string]interface{}{"device_class": "outlet"},
+			state:     mqttOutputState(ou.output),
+			commands:  mqttSetValueCommand(ou.SetValue),
+		})
+	}
+	for _, swb := range sw.Switches {
+		mb.addEntity(&mqttEntity{
+			component: "binary_sensor",
+			uniqueId:  swb.GetUniqueId(),
+			name:      swb.Name,
+			state:     mqttInputState(swb.input),
+		})
+	}
+	for _, ms := range sw.MotionSensors {
+		mb.addEntity(&mqttEntity{
+			component: "binary_sensor",
+			uniqueId:  ms.GetUniqueId(),
+			name:      ms.Name,
+			discovery: map[string]interface{}{"device_class": "motion"},
+			state:     mqttOutputState(ms),
+		})
+	}
+	for _, bs := range sw.BinarySensors {
+		mb.addEntity(&mqttEntity{
+			component: "binary_sensor",
+			uniqueId:  bs.GetUniqueId(),
+			name:      bs.Name,
+			discovery: map[string]interface{}{"device_class": binarySensorDeviceClasses[bs.getKind()]},
+			state:     mqttOutputState(bs),
+		})
+	}
+	for _, ts := range sw.getMeasurementSensors() {
+		ts := ts
+		class := mqttSensorClasses[ts.GetMeasurement()]
+		mb.addEntity(&mqttEntity{
+			component: "sensor",
+			uniqueId:  ts.GetUniqueId(),
+			name:      ts.Name,
+			discovery: map[string]interface{}{
+				"device_class":        class[0],
+				"state_class":         "measurement",
+				"unit_of_measurement": class[1],
+			},
+			state: func() (string, error) {
+				value, err := ts.GetValue()
+				return strconv.FormatFloat(value, 'f', 2, 64), err
+			},
+		})
+	}
+	for _, th := range sw.Thermostats {
+		mb.addEntity(mb.getThermostatEntity(th))
+	}
+	for _, shu := range sw.Shutters {
+		mb.addEntity(mb.getShutterEntity(shu))
+	}
+	for _, gd := range sw.GarageDoors {
+		gd := gd
+		mb.addEntity(&mqttEntity{
+			component: "cover",
+			uniqueId:  gd.GetUniqueId(),
+			name:      gd.Name,
+			discovery: map[string]interface{}{"device_class": "garage"},
+			state: func() (string, error) {
+				state, _ := gd.GetDoorState()
+				return garageDoorStates[state], nil
+			},
+			commands: map[string]func(string){
+				"set": func(payload string) {
+					switch strings.ToUpper(strings.TrimSpace(payload)) {
+					case "OPEN":
+						gd.SetValue(true)
+					case "CLOSE":
+						gd.SetValue(false)
+					case "STOP":
+						gd.Stop()
+					default:
+						log.Printf("mqtt bridge | unexpected cover command (%s)", payload)
+					}
+				},
+			},
+		})
+	}
+	for _, bu := range sw.Buttons {
+		entity := &mqttEntity{
+			component: "event",
+			uniqueId:  bu.GetUniqueId(),
+			name:      bu.Name,
+			discovery: map[string]interface{}{
+				"device_class": "button",
+				"event_types":  []string{"single", "double", "long"},
+			},
+		}
+		mb.addEntity(entity)
+		bu.SubscribeToPushEvent(&mqttEventPublisher{bridge: mb, entity: entity})
+	}
+}
+
+func (mb *MqttBridge) getThermostatEntity(th *Thermostat) *mqttEntity {
+	modes := mqttThermostatModes[:2]
+	if th.CoolingEnabled {
+		modes = mqttThermostatModes
+	}
+
+	entity := &mqttEntity{
+		component: "climate",
+		uniqueId:  th.GetUniqueId(),
+		name:      th.Name,
+		state: func() (string, error) {
+			th.lock.Lock()
+			defer th.lock.Unlock()
+
+			action := "idle"
+			switch {
+			case th.TargetState == 0:
+				action = "off"
+			case th.getCurrentHeatingCoolingState() == 1:
+				action = "heating"
+			case th.getCurrentHeatingCoolingState() == 2:
+				action = "cooling"
+			}
+			mode := "off"
+			if th.TargetState > 0 && th.TargetState < len(mqttThermostatModes) {
+				mode = mqttThermostatModes[th.TargetState]
+			}
+			payload, err := json.Marshal(map[string]interface{}{
+				"current_temperature": th.CurrentTemperature,
+				"target_temperature":  th.TargetTemperature,
+				"mode":                mode,
+				"action":              action,
+			})
+			return string(payload), err
+		},
+		commands: map[string]func(string){
+			"mode/set": func(payload string) {
+				for state, mode := range mqttThermostatModes {
+					if strings.EqualFold(mode, strings.TrimSpace(payload)) {
+						th.updateTargetState(state)
+						return
+					}
+				}
+				log.Printf("mqtt bridge | unknown thermostat mode (%s)", payload)
+			},
+			"temperature/set": func(payload string) {
+				target, err := strconv.ParseFloat(strings.TrimSpace(payload), 64)
+				if err != nil {
+					log.Printf("mqtt bridge | invalid target temperature (%s)", payload)
+					return
+				}
+				th.updateTargetTemperature(target)
+			},
+		},
+	}
+
+	stateTopic := mb.getTopic(entity, "state")
+	entity.discovery = map[string]interface{}{
+		"modes":                        modes,
+		"min_temp":                     th.MinimumTemperature,
+		"max_temp":                     th.MaximumTemperature,
+		"temp_step":                    th.StepTemperature,
+		"temperature_unit":             "C",
+		"current_temperature_topic":    stateTopic,
+		"current_temperature_template": "{{ value_json.current_temperature }}",
+		"temperature_state_topic":      stateTopic,
+		"temperature_state_template":   "{{ value_json.target_temperature }}",
+		"temperature_command_topic":    mb.getTopic(entity, "temperature/set"),
+		"mode_state_topic":             stateTopic,
+		"mode_state_template":          "{{ value_json.mode }}",
+		"mode_command_topic":           mb.getTopic(entity, "mode/set"),
+		"action_topic":                 stateTopic,
+		"action_template":              "{{ value_json.action }}",
+	}
+	return entity
+}
+
+func (mb *MqttBridge) getShutterEntity(shu *Shutter) *mqttEntity {
+	entity := &mqttEntity{
+		component: "cover",
+		uniqueId:  shu.GetUniqueId(),
+		name:      shu.Name,
+		state: func() (string, error) {
+			position, direction := shu.GetPosition()
+			state := "stopped"
+			switch {
+			case direction == shutterUp:
+				state = "opening"
+			case direction == shutterDown:
+				state = "closing"
+			case position == 0:
+				state = "closed"
+			case position == 100:
+				state = "open"
+			}
+			payload, err := json.Marshal(map[string]interface{}{"state": state, "position": position, "tilt": shu.GetTilt()})
+			return string(payload), err
+		},
+		commands: map[string]func(string){
+			"set": func(payload string) {
+				switch strings.ToUpper(strings.TrimSpace(payload)) {
+				case "OPEN":
+					shu.Travel(true)
+				case "CLOSE":
+					shu.Travel(false)
+				case "STOP":
+					shu.Stop()
+				default:
+					log.Printf("mqtt bridge | unexpected cover command (%s)", payload)
+				}
+			},
+			"position/set": func(payload string) {
+				position, err := strconv.Atoi(strings.TrimSpace(payload))
+				if err != nil {
+					log.Printf("mqtt bridge | invalid cover position (%s)", payload)
+					return
+				}
+				shu.MoveTo(position)
+			},
+			"tilt/set": func(payload string) {
+				angle, err := strconv.Atoi(strings.TrimSpace(payload))
+				if err != nil {
+					log.Printf("mqtt bridge | invalid cover tilt (%s)", payload)
+					return
+				}
+				shu.TiltTo(angle)
+			},
+		},
+	}
+
+	entity.discovery = map[string]interface{}{
+		"device_class":       "shutter",
+		"value_template":     "{{ value_json.state }}",
+		"position_topic":     mb.getTopic(entity, "state"),
+		"position_template":  "{{ value_json.position }}",
+		"set_position_topic": mb.getTopic(entity, "position/set"),
+	}
+	if len(shu.TiltDuration) > 0 {
+		entity.discovery["tilt_status_topic"] = mb.getTopic(entity, "state")
+		entity.discovery["tilt_status_template"] = "{{ value_json.tilt }}"
+		entity.discovery["tilt_command_topic"] = mb.getTopic(entity, "tilt/set")
+		entity.discovery["tilt_min"] = -90
+		entity.discovery["tilt_max"] = 90
+	}
+	return entity
+}
+
+type mqttEventPublisher struct {
+	bridge *MqttBridge
+	entity *mqttEntity
+}
+
+func (mep *mqttEventPublisher) FireEvent(event drivers.PushEvent) {
+	payload, _ := json.Marshal(map[string]string{"event_type": mqttEventTypes[event]})
+	mep.bridge.client.Publish(mep.bridge.getTopic(mep.entity, "state"), 0, false, payload)
+}
+
+func (mb *MqttBridge) getDiscoveryPayload(entity *mqttEntity) ([]byte, error) {
+	config := map[string]interface{}{
+		"name":                  entity.name,
+		"unique_id":             entity.getObjectId(),
+		"object_id":             entity.getObjectId(),
+		"availability_topic":    mb.getAvailabilityTopic(),
+		"payload_available":     mqttPayloadOnline,
+		"payload_not_available": mqttPayloadOffline,
+		"device": map[string]interface{}{
+			"identifiers":  []string{mb.getBaseTopic()},
+			"name":         mb.deviceName,
+			"manufacturer": homeKitBridgeAuthor,
+		},
+	}
+
+	if entity.state != nil || entity.component == "event" {
+		config["state_topic"] = mb.getTopic(entity, "state")
+	}
+	if _, ok := entity.commands["set"]; ok {
+		config["command_topic"] = mb.getTopic(entity, "set")
+	}
+	for key, value := range entity.discovery {
+		config[key] = value
+	}
+
+	return json.Marshal(config)
+}
+
+func (mb *MqttBridge) publishDiscovery() {
+	if mb.DisableDiscovery {
+		return
+	}
+
+	for _, entity := range mb.getEntities() {
+		payload, err := mb.getDiscoveryPayload(entity)
+		if err != nil {
+			log.Printf("mqtt bridge | failed to prepare discovery of %s: %v", entity.name, err)
+			continue
+		}
+		mb.client.Publish(mb.getDiscoveryTopic(entity), 0, true, payload)
+	}
+}
+
+func (mb *MqttBridge) getDiscoveryTopic(entity *mqttEntity) string {
+	return fmt.Sprintf("%s/%s/%s/config", mb.getDiscoveryPrefix(), entity.component, entity.getObjectId())
+}
+
+// publishStates publishes states which changed since last publish (all of them when force is set).
+func (mb *MqttBridge) publishStates(force bool) {
+	for _, entity := range mb.getEntities() {
+		mb.publishState(entity, force)
+	}
+}
+
+func (mb *MqttBridge) publishState(entity *mqttEntity, force bool) {
+	if entity.state == nil {
+		return
+	}
+	payload, err := entity.state()
+	if err != nil {
+		return
+	}
+
+	topic := mb.getTopic(entity, "state")
+
+	mb.lock.Lock()
+	changed := mb.published[topic] != payload
+	mb.published[topic] = payload
+	mb.lock.Unlock()
+
+	if changed || force {
+		mb.client.Publish(topic, 0, true, payload)
+	}
+}
+
+func (mb *MqttBridge) onConnect(client mqtt.Client) {
+	client.Publish(mb.getAvailabilityTopic(), 0, true, mqttPayloadOnline)
+
+	filters := map[string]byte{}
+	handlers := map[string]func(string){}
+	for _, entity := range mb.getEntities() {
+		entity := entity
+		for command, handler := range entity.commands {
+			handler := handler
+			topic := mb.getTopic(entity, command)
+			filters[topic] = 0
+			handlers[topic] = func(payload string) {
+				handler(payload)
+				mb.publishState(entity, false)
+			}
+		}
+	}
+
+	if !mb.DisableDiscovery {
+		// home assistant announces restart on its status topic, discovery has to be sent again
+		statusTopic := mb.getDiscoveryPrefix() + "/status"
+		filters[statusTopic] = 0
+		handlers[statusTopic] = func(payload string) {
+			if payload == mqttPayloadOnline {
+				mb.publishDiscovery()
+				mb.publishStates(true)
+			}
+		}
+	}
+
+	token := client.SubscribeMultiple(filters, func(client mqtt.Client, msg mqtt.Message) {
+		if msg.Retained() {
+			return
+		}
+		if handler, ok := handlers[msg.Topic()]; ok {
+			handler(string(msg.Payload()))
+		}
+	})
+	if token.WaitTimeout(mqttBridgeConnectTimeout) && token.Error() != nil {
+		log.Printf("mqtt bridge | subscribe failed: %v", token.Error())
+	}
+
+	mb.publishDiscovery()
+	mb.publishStates(true)
+}
+
+// rebuild creates entities again after config reload (see SwKit.Reload), discovery of removed entities is
+// cleared.
+func (mb *MqttBridge) rebuild(sw *SwKit) {
+	if mb.client == nil {
+		return
+	}
+
+	removed := map[string]bool{}
+	for _, entity := range mb.getEntities() {
+		removed[mb.getDiscoveryTopic(entity)] = true
+	}
+	mb.buildEntities(sw)
+	for _, entity := range mb.getEntities() {
+		delete(removed, mb.getDiscoveryTopic(entity))
+	}
+
+	if !mb.client.IsConnected() {
+		return
+	}
+	if !mb.DisableDiscovery {
+		for topic := range removed {
+			mb.client.Publish(topic, 0, true, "")
+		}
+	}
+	mb.onConnect(mb.client)
+}
+
+// Start connects to broker and publishes states until ctx is done, things have to be initialized before.
+func (mb *MqttBridge) Start(ctx context.Context, sw *SwKit) error {
+	if len(mb.Broker) == 0 {
+		return errors.New("mqtt bridge Broker address is empty")
+	}
+
+	interval := defaultMqttBridgePublishInterval
+	if len(mb.PublishInterval) > 0 {
+		var err error
+		interval, err = time.ParseDuration(mb.PublishInterval)
+		if err != nil {
+			return errors.Wrap(err, "parsing PublishInterval failed")
+		}
+	}
+
+	mb.deviceName = sw.Name
+	if len(mb.deviceName) == 0 {
+		mb.deviceName = homeKitBridgeName
+	}
+	mb.published = map[string]string{}
+
+	clientId := mb.ClientId
+	if len(clientId) == 0 {
+		clientId = mb.getBaseTopic() + "_bridge"
+	}
+	opts := mqtt.NewClientOptions().
+		AddBroker(mb.Broker).
+		SetClientID(clientId).
+		SetUsername(mb.Username).
+		SetPassword(mb.Password).
+		SetCleanSession(true).
+		SetAutoReconnect(true).
+		SetConnectTimeout(mqttBridgeConnectTimeout).
+		SetOrderMatters(false).
+		SetWill(mb.getAvailabilityTopic(), mqttPayloadOffline, 0, true).
+		SetOnConnectHandler(mb.onConnect).
+		SetConnectionLostHandler(func(client mqtt.Client, err error) {
+			log.Printf("mqtt bridge | connection lost: %v", err)
+		})
+
+	mb.client = mqtt.NewClient(opts)
+	mb.buildEntities(sw)
+
+	token := mb.client.Connect()
+	if !token.WaitTimeout(mqttBridgeConnectTimeout) {
+		mb.client.Disconnect(0)
+		return errors.Errorf("connecting to mqtt broker %s timed out", mb.Broker)
+	}
+	if token.Error() != nil {
+		return errors.Wrapf(token.Error(), "failed to connect to mqtt broker %s", mb.Broker)
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				token := mb.client.Publish(mb.getAvailabilityTopic(), 0, true, mqttPayloadOffline)
+				token.WaitTimeout(time.Second)
+				mb.client.Disconnect(250)
+				return
+			case <-ticker.C:
+				mb.publishStates(false)
+			}
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package swkit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+
+	drivers "github.com/hubertat/swkit/drivers"
+)
+
+type mqttTestBroker struct {
+	server   *mochi.Server
+	address  string
+	messages map[string]string
+	lock     sync.Mutex
+}
+
+func startMqttTestBroker(t testing.TB) *mqttTestBroker {
+	t.Helper()
+
+	broker := &mqttTestBroker{messages: map[string]string{}}
+	broker.server = mochi.New(&mochi.Options{
+		InlineClient: true,
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	broker.server.AddHook(new(auth.AllowHook), nil)
+
+	tcp := listeners.NewTCP(listeners.Config{ID: "test", Address: "127.0.0.1:0"})
+	err := broker.server.AddListener(tcp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go broker.server.Serve()
+	t.Cleanup(func() { broker.server.Close() })
+
+	broker.address = "tcp://" + tcp.Address()
+	broker.server.Subscribe("#", 1, func(cl *mochi.Client, sub packets.Subscription, pk packets.Packet) {
+		broker.lock.Lock()
+		defer broker.lock.Unlock()
+		broker.messages[pk.TopicName] = string(pk.Payload)
+	})
+	return broker
+}
+
+func (mtb *mqttTestBroker) last(topic string) string {
+	mtb.lock.Lock()
+	defer mtb.lock.Unlock()
+	return mtb.messages[topic]
+}
+
+func (mtb *mqttTestBroker) waitFor(t testing.TB, topic string, condition func(payload string) bool) string {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		payload := mtb.last(topic)
+		if condition(payload) {
+			return payload
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s (last payload: %s)", topic, payload)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestMqttBridge(t *testing.T) {
+	broker := startMqttTestBroker(t)
+
+	md := &drivers.MockIoDriver{}
+	md.Setup(context.Background(), []uint16{3}, []uint16{1, 2})
+
+	sw := &SwKit{
+		Name:               "home",
+		Lights:             []*Light{{Name: "Kitchen", DriverName: "mock_driver", OutPin: 1}},
+		Outlets:            []*Outlet{{Name: "Tv", DriverName: "mock_driver", OutPin: 2}},
+		MotionSensors:      []*MotionSensor{{Name: "Hall", DriverName: "mock_driver", InPin: 3}},
+		TemperatureSensors: []*MeasurementSensor{{Name: "Living room", Id: "t1"}},
+		Buttons:            []*Button{{Name: "Door", DisableHomekit: true}},
+		MqttBridge:         &MqttBridge{Broker: broker.address, PublishInterval: "20ms"},
+	}
+	for _, io := range []IO{sw.Lights[0], sw.Outlets[0], sw.MotionSensors[0]} {
+		err := io.Init(md)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	sw.TemperatureSensors[0].SetValue(21.5)
+
+	outletId := fmt.Sprintf("swkit_%016x", sw.Outlets[0].GetUniqueId())
+	broker.server.Publish("swkit/switch/"+outletId+"/set", []byte("ON"), true, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err := sw.MqttBridge.Start(ctx, sw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	broker.waitFor(t, "swkit/status", func(payload string) bool { return payload == "online" })
+
+	lightId := fmt.Sprintf("swkit_%016x", sw.Lights[0].GetUniqueId())
+	payload := broker.waitFor(t, "homeassistant/light/"+lightId+"/config", func(payload string) bool { return len(payload) > 0 })
+	discovery := map[string]interface{}{}
+	err = json.Unmarshal([]byte(payload), &discovery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertBools(t, discovery["unique_id"] == lightId, true)
+	assertBools(t, discovery["command_topic"] == "swkit/light/"+lightId+"/set", true)
+	assertBools(t, discovery["state_topic"] == "swkit/light/"+lightId+"/state", true)
+
+	broker.waitFor(t, "swkit/light/"+lightId+"/state", func(payload string) bool { return payload == "OFF" })
+	broker.server.Publish("swkit/light/"+lightId+"/set", []byte("ON"), false, 0)
+	broker.waitFor(t, "swkit/light/"+lightId+"/state", func(payload string) bool { return payload == "ON" })
+	state, _ := sw.Lights[0].output.GetState()
+	assertBools(t, state, true)
+
+	// retained command was sent before bridge started, it must not be replayed
+	broker.waitFor(t, "swkit/switch/"+outletId+"/state", func(payload string) bool { return payload == "OFF" })
+	state, _ = sw.Outlets[0].output.GetState()
+	assertBools(t, state, false)
+
+	tempId := fmt.Sprintf("swkit_%016x", sw.TemperatureSensors[0].GetUniqueId())
+	broker.waitFor(t, "swkit/sensor/"+tempId+"/state", func(payload string) bool { return payload == "21.50" })
+	sw.TemperatureSensors[0].SetValue(22)
+	broker.waitFor(t, "swkit/sensor/"+tempId+"/state", func(payload string) bool { return payload == "22.00" })
+
+	buttonId := fmt.Sprintf("swkit_%016x", sw.Buttons[0].GetUniqueId())
+	sw.Buttons[0].FireEvent(drivers.PushEventDoublePress)
+	broker.waitFor(t, "swkit/event/"+buttonId+"/state", func(payload string) bool { return payload == `{"event_type":"double"}` })
+
+	cancel()
+	broker.waitFor(t, "swkit/status", func(payload string) bool { return payload == "offline" })
+}
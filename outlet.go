@@ -2,7 +2,6 @@ package swkit
 
 import (
 	"fmt"
-	"hash/fnv"
 	"strings"
 	"sync"
 
@@ -14,6 +13,7 @@ import (
 
 type Outlet struct {
 	Name           string
+	Id             string
 	State          bool
 	DriverName     string
 	OutPin         uint16
@@ -29,6 +29,8 @@ type Outlet struct {
 	fault *characteristic.StatusFault
 
 	lock sync.Mutex
+
+	uniqueId uint64
 }
 
 func (ou *Outlet) GetDriverName() string {
@@ -36,9 +38,22 @@ func (ou *Outlet) GetDriverName() string {
 }
 
 func (ou *Outlet) GetUniqueId() uint64 {
-	hash := fnv.New64()
-	hash.Write([]byte("Outlet_" + ou.Name))
-	return hash.Sum64()
+	if ou.uniqueId != 0 {
+		return ou.uniqueId
+	}
+	return ou.identity().hash()
+}
+
+func (ou *Outlet) identity() accessoryIdentity {
+	return accessoryIdentity{Kind: "Outlet", Id: ou.Id, Name: ou.Name, Serial: ou.serialNumber()}
+}
+
+func (ou *Outlet) serialNumber() string {
+	return fmt.Sprintf("outlet:%s:%02d", ou.DriverName, ou.OutPin)
+}
+
+func (ou *Outlet) setUniqueId(id uint64) {
+	ou.uniqueId = id
 }
 
 func (ou *Outlet) Init(driver drivers.IoDriver) error {
@@ -63,7 +78,7 @@ func (ou *Outlet) Init(driver drivers.IoDriver) error {
 	}
 	info := accessory.Info{
 		Name:         ou.Name,
-		SerialNumber: fmt.Sprintf("outlet:%s:%02d", ou.DriverName, ou.OutPin),
+		SerialNumber: ou.serialNumber(),
 	}
 	ou.hk = accessory.NewOutlet(info)
 
@@ -120,6 +135,10 @@ func (ou *Outlet) SetValue(state bool) {
 	ou.output.Set(ou.State)
 }
 
+func (ou *Outlet) GetState() (bool, error) {
+	return ou.output.GetState()
+}
+
 func (ou *Outlet) Toggle() {
 	ou.SetValue(!ou.State)
 }
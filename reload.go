@@ -0,0 +1,622 @@
+package swkit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/brutella/hap/accessory"
+	"github.com/pkg/errors"
+
+	drivers "github.com/hubertat/swkit/drivers"
+)
+
+// runtimeFields are exported accessory fields holding state, not configuration.
+var runtimeFields = []string{"State", "IsFaulty", "CurrentTemperature", "TargetTemperature", "TargetState", "Position", "Tilt"}
+
+// ReloadSummary describes changes applied by Reload.
+type ReloadSummary struct {
+	Added     []string
+	Removed   []string
+	Modified  []string
+	Recreated []string
+	Drivers   []string
+	Notes     []string
+
+	HomeKitRestarted     bool
+	HomeKitRestartReason string
+}
+
+// Changed returns true when any accessory or driver was changed.
+func (rs *ReloadSummary) Changed() bool {
+	return len(rs.Added)+len(rs.Removed)+len(rs.Modified)+len(rs.Recreated)+len(rs.Drivers) > 0
+}
+
+func (rs *ReloadSummary) String() string {
+	if !rs.Changed() && len(rs.Notes) == 0 {
+		return "no changes"
+	}
+	lines := []string{}
+	for _, item := range rs.Added {
+		lines = append(lines, "added "+item)
+	}
+	for _, item := range rs.Removed {
+		lines = append(lines, "removed "+item)
+	}
+	for _, item := range rs.Modified {
+		lines = append(lines, "modified "+item)
+	}
+	for _, item := range rs.Recreated {
+		lines = append(lines, "recreated "+item+" (driver restarted)")
+	}
+	lines = append(lines, rs.Drivers...)
+	lines = append(lines, rs.Notes...)
+	if rs.HomeKitRestarted {
+		lines = append(lines, "HomeKit server restarted, "+rs.HomeKitRestartReason)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// configOf returns exported fields of v (config) as decoded json, without runtimeFields.
+func configOf(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err.Error()
+	}
+	var config interface{}
+	json.Unmarshal(data, &config)
+	if fields, isObject := config.(map[string]interface{}); isObject {
+		for _, field := range runtimeFields {
+			delete(fields, field)
+		}
+	}
+	return config
+}
+
+func sameConfig(a, b interface{}) bool {
+	return reflect.TypeOf(a) == reflect.TypeOf(b) && reflect.DeepEqual(configOf(a), configOf(b))
+}
+
+// mergeAccessories returns accessories of next config, unchanged ones (matched by name) are taken from current
+// when keep allows it (their drivers are kept running).
+func mergeAccessories[T comparable](kind string, current []T, next []T, name func(T) string, keep func(T) bool, kept map[interface{}]bool, summary *ReloadSummary) []T {
+	merged := []T{}
+	for _, item := range next {
+		found := false
+		for _, existing := range current {
+			if name(existing) != name(item) {
+				continue
+			}
+			found = true
+			switch {
+			case !sameConfig(existing, item):
+				summary.Modified = append(summary.Modified, fmt.Sprintf("%s %s", kind, name(item)))
+			case keep(existing):
+				item = existing
+				kept[item] = true
+			default:
+				summary.Recreated = append(summary.Recreated, fmt.Sprintf("%s %s", kind, name(item)))
+			}
+		}
+		if !found {
+			summary.Added = append(summary.Added, fmt.Sprintf("%s %s", kind, name(item)))
+		}
+		merged = append(merged, item)
+	}
+	for _, existing := range current {
+		found := false
+		for _, item := range next {
+			found = found || name(existing) == name(item)
+		}
+		if !found {
+			summary.Removed = append(summary.Removed, fmt.Sprintf("%s %s", kind, name(existing)))
+		}
+	}
+	return merged
+}
+
+func samePins(a []uint16, b []uint16) bool {
+	return len(a) == len(b) && len(unionPins(a, b)) == len(a)
+}
+
+// unionPins returns pins present in a or b.
+func unionPins(a []uint16, b []uint16) []uint16 {
+	pins := append([]uint16{}, a...)
+	for _, pin := range b {
+		found := false
+		for _, existing := range pins {
+			found = found || pin == existing
+		}
+		if !found {
+			pins = append(pins, pin)
+		}
+	}
+	return pins
+}
+
+// reconfiguredDriver is running driver which pins are changed by reload. Until reload is applied the driver has
+// pins of both configs, so running accessories are not affected. Previous is a copy of running driver config
+// (pin definitions) restored on rollback, next is driver of next config.
+type reconfiguredDriver struct {
+	driver                  drivers.ReconfigurableIoDriver
+	previous, next          drivers.IoDriver
+	inputs, outputs         []uint16
+	nextInputs, nextOutputs []uint16
+}
+
+// copyIoDriver returns not set up driver with config of driver.
+func copyIoDriver(driver drivers.IoDriver) (drivers.IoDriver, error) {
+	data, err := json.Marshal(driver)
+	if err != nil {
+		return nil, err
+	}
+	copied := reflect.New(reflect.TypeOf(driver).Elem()).Interface().(drivers.IoDriver)
+	err = json.Unmarshal(data, copied)
+	return copied, err
+}
+
+// reloadPlan is next config prepared off to the side of running SwKit, see Reload.
+type reloadPlan struct {
+	next         *SwKit
+	summary      *ReloadSummary
+	kept         map[interface{}]bool // running drivers and accessories used by next
+	started      []interface{ Close() error }
+	reconfigured []reconfiguredDriver
+}
+
+// prepareIoDrivers sets up io drivers for next config: drivers with unchanged config and pins are kept, running
+// drivers.ReconfigurableIoDriver are reconfigured with next config, others are set up again, next to running ones.
+func (sw *SwKit) prepareIoDrivers(ctx context.Context, plan *reloadPlan) error {
+	next := plan.next
+	next.ioDrivers = map[string]drivers.IoDriver{}
+	for _, name := range next.getIoDriverNames() {
+		fresh, err := next.getIoDriverByName(name)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get %s io driver by name", name)
+		}
+		inPins, outPins := next.getInPins(name), next.getOutPins(name)
+
+		running, isRunning := sw.ioDrivers[name]
+		currentIn, currentOut := unionPins(nil, sw.getInPins(name)), unionPins(nil, sw.getOutPins(name))
+		if isRunning && sameConfig(running, fresh) && samePins(currentIn, inPins) && samePins(currentOut, outPins) {
+			next.ioDrivers[name] = running
+			plan.kept[running] = true
+			continue
+		}
+		if reconfigurable, ok := running.(drivers.ReconfigurableIoDriver); ok && reflect.TypeOf(running) == reflect.TypeOf(fresh) {
+			// driver keeps running (its connection, lines or listener can't be opened twice)
+			previous, err := copyIoDriver(running)
+			if err != nil {
+				return errors.Wrapf(err, "failed to copy %s driver config", name)
+			}
+			err = reconfigurable.Reconfigure(ctx, fresh, unionPins(currentIn, inPins), unionPins(currentOut, outPins))
+			if err != nil {
+				return errors.Wrapf(err, "failed to reconfigure %s driver", name)
+			}
+			plan.reconfigured = append(plan.reconfigured, reconfiguredDriver{reconfigurable, previous, fresh, currentIn, currentOut, inPins, outPins})
+			next.ioDrivers[name] = running
+			plan.kept[running] = true
+			plan.summary.Drivers = append(plan.summary.Drivers, fmt.Sprintf("reconfigured %s driver", name))
+			continue
+		}
+
+		err = fresh.Setup(ctx, inPins, outPins)
+		if err != nil {
+			return errors.Wrapf(err, "got error with setup for %s driver", name)
+		}
+		plan.started = append(plan.started, fresh)
+		next.ioDrivers[name] = fresh
+		if isRunning {
+			plan.summary.Drivers = append(plan.summary.Drivers, fmt.Sprintf("restarted %s driver", name))
+		} else {
+			plan.summary.Drivers = append(plan.summary.Drivers, fmt.Sprintf("started %s driver", name))
+		}
+	}
+
+	for name := range sw.ioDrivers {
+		if _, used := next.ioDrivers[name]; !used {
+			plan.summary.Drivers = append(plan.summary.Drivers, fmt.Sprintf("stopped %s driver", name))
+		}
+	}
+	return nil
+}
+
+// prepareSensorDrivers sets up sensor drivers for next config, drivers with unchanged config and sensors are kept.
+func (sw *SwKit) prepareSensorDrivers(plan *reloadPlan) error {
+	next := plan.next
+	next.sensorDrivers = map[string]drivers.SensorDriver{}
+	for _, s := range next.getSensors() {
+		next.sensorDrivers[s.GetDriverName()] = nil
+	}
+
+	for name := range next.sensorDrivers {
+		fresh, err := next.getSensorDriverByName(name)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get %s sensor driver by name", name)
+		}
+
+		running, isRunning := sw.sensorDrivers[name]
+		unchanged := isRunning && sameConfig(running, fresh) && sameSensorConfigs(sw.getDriverSensors(name), next.getDriverSensors(name))
+		if name == "modbus" && !sw.isMockedIoDriver(name) {
+			// modbus sensor driver is a part of modbus io driver, it is kept only together with it (kept or reconfigured)
+			if modbus, used := next.ioDrivers[name]; used {
+				unchanged = unchanged && plan.kept[modbus]
+			} else {
+				unchanged = unchanged && sameConfig(sw.Modbus, next.Modbus)
+			}
+		}
+		if unchanged {
+			next.sensorDrivers[name] = running
+			plan.kept[running] = true
+			continue
+		}
+
+		err = fresh.Setup(next.getDriverSensors(name))
+		if err != nil {
+			return errors.Wrapf(err, "got error with setup %s sensor driver", name)
+		}
+		plan.started = append(plan.started, fresh)
+		next.sensorDrivers[name] = fresh
+		if isRunning {
+			plan.summary.Drivers = append(plan.summary.Drivers, fmt.Sprintf("restarted %s sensor driver", name))
+		} else {
+			plan.summary.Drivers = append(plan.summary.Drivers, fmt.Sprintf("started %s sensor driver", name))
+		}
+	}
+
+	for name := range sw.sensorDrivers {
+		if _, used := next.sensorDrivers[name]; !used {
+			plan.summary.Drivers = append(plan.summary.Drivers, fmt.Sprintf("stopped %s sensor driver", name))
+		}
+	}
+	return nil
+}
+
+func sameSensorConfigs(a []drivers.Sensor, b []drivers.Sensor) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for ix := range a {
+		if !sameConfig(a[ix], b[ix]) {
+			return false
+		}
+	}
+	return true
+}
+
+// prepareAccessories merges accessories of next with running ones and initializes new ones with next drivers.
+// Running accessories are kept only when their config and drivers are unchanged.
+func (sw *SwKit) prepareAccessories(plan *reloadPlan) error {
+	next, summary, kept := plan.next, plan.summary, plan.kept
+	keptDriver := func(name string) bool {
+		return plan.kept[next.ioDrivers[name]]
+	}
+	keepIo := func(io IO) bool {
+		return keptDriver(io.GetDriverName())
+	}
+
+	next.Lights = mergeAccessories("light", sw.Lights, next.Lights, func(li *Light) string { return li.Name }, func(li *Light) bool { return keepIo(li) }, kept, summary)
+	next.ColorLights = mergeAccessories("color light", sw.ColorLights, next.ColorLights, func(cl *ColorLight) string { return cl.Name }, func(cl *ColorLight) bool { return keepIo(cl) }, kept, summary)
+	next.Buttons = mergeAccessories("button", sw.Buttons, next.Buttons, func(bu *Button) string { return bu.Name }, func(bu *Button) bool { return keepIo(bu) }, kept, summary)
+	next.Switches = mergeAccessories("switch", sw.Switches, next.Switches, func(swb *Switch) string { return swb.Name }, func(swb *Switch) bool { return keepIo(swb) }, kept, summary)
+	next.Outlets = mergeAccessories("outlet", sw.Outlets, next.Outlets, func(ou *Outlet) string { return ou.Name }, func(ou *Outlet) bool { return keepIo(ou) }, kept, summary)
+	next.Thermostats = mergeAccessories("thermostat", sw.Thermostats, next.Thermostats, func(th *Thermostat) string { return th.Name }, func(th *Thermostat) bool { return keepIo(th) }, kept, summary)
+	next.MotionSensors = mergeAccessories("motion sensor", sw.MotionSensors, next.MotionSensors, func(ms *MotionSensor) string { return ms.Name }, func(ms *MotionSensor) bool { return keepIo(ms) }, kept, summary)
+	next.BinarySensors = mergeAccessories("binary sensor", sw.BinarySensors, next.BinarySensors, func(bs *BinarySensor) string { return bs.Name }, func(bs *BinarySensor) bool { return keepIo(bs) }, kept, summary)
+	next.GarageDoors = mergeAccessories("garage door", sw.GarageDoors, next.GarageDoors, func(gd *GarageDoor) string { return gd.Name }, func(gd *GarageDoor) bool {
+		return keepIo(gd) && (!gd.hasSensors() || keptDriver(gd.GetSensorDriverName()))
+	}, kept, summary)
+	next.Shutters = mergeAccessories("shutter", sw.Shutters, next.Shutters, func(shu *Shutter) string { return shu.Name }, func(shu *Shutter) bool { return keepIo(shu) }, kept, summary)
+
+	keepSensor := func(ts *MeasurementSensor) bool {
+		return plan.kept[next.sensorDrivers[ts.DriverName]]
+	}
+	next.TemperatureSensors = mergeAccessories("temperature sensor", sw.TemperatureSensors, next.TemperatureSensors, func(ts *MeasurementSensor) string { return ts.Name }, keepSensor, kept, summary)
+	next.Sensors = mergeAccessories("sensor", sw.Sensors, next.Sensors, func(ts *MeasurementSensor) string { return ts.Name }, keepSensor, kept, summary)
+
+	err := next.initIos(kept)
+	if err != nil {
+		return err
+	}
+	err = next.initSensors(kept)
+	if err != nil {
+		return err
+	}
+	err = next.matchControllers(false)
+	if err != nil {
+		return err
+	}
+	return next.matchSensors(false)
+}
+
+// rollback closes drivers started for next config and restores pins of reconfigured ones.
+func (sw *SwKit) rollback(ctx context.Context, plan *reloadPlan) {
+	for _, driver := range plan.started {
+		err := driver.Close()
+		if err != nil {
+			log.Printf("reload | closing driver started for new config failed: %v", err)
+		}
+	}
+	for _, reconfigured := range plan.reconfigured {
+		err := reconfigured.driver.Reconfigure(ctx, reconfigured.previous, reconfigured.inputs, reconfigured.outputs)
+		if err != nil {
+			log.Printf("reload | restoring pins of %s driver failed: %v", reconfigured.driver.NameId(), err)
+		}
+	}
+	// new buttons on kept drivers could take over push events of running ones
+	for _, bu := range sw.Buttons {
+		if bu.input != nil {
+			bu.input.SubscribeToPushEvent(bu)
+		}
+	}
+}
+
+// setDriverConfig sets config field of driver kind to running driver kept by reload.
+func (sw *SwKit) setDriverConfig(driver interface{}) {
+	switch d := driver.(type) {
+	case *drivers.McpIO:
+		sw.Mcp23017 = d
+	case *drivers.GpIO:
+		sw.Gpio = d
+	case *drivers.GpiodIO:
+		sw.Gpiod = d
+	case *drivers.GrentonIO:
+		sw.Grenton = d
+	case *drivers.MockIoDriver:
+		sw.FakeDriver = d
+	case *drivers.RemoteIoSlave:
+		sw.RemoteIoSlave = d
+	case *drivers.ShellyIO:
+		sw.Shelly = d
+	case *drivers.PcfIO:
+		for ix, pcf := range sw.PcfExpanders {
+			if pcf.NameId() == d.NameId() {
+				sw.PcfExpanders[ix] = d
+			}
+		}
+	case *drivers.MqttIO:
+		sw.Mqtt = d
+	case *drivers.ModbusIO:
+		sw.Modbus = d
+	case *drivers.EspRelayIO:
+		sw.EspRelays = d
+	case *drivers.WebhookIO:
+		sw.Webhook = d
+	case *drivers.VirtualIO:
+		sw.Virtual = d
+	case *drivers.InfluxSensors:
+		sw.InfluxSensors = d
+	case *drivers.Wire:
+		sw.WireSensors = d
+	}
+}
+
+// apply switches running SwKit to prepared next config, it can't fail (errors are logged).
+func (sw *SwKit) apply(ctx context.Context, plan *reloadPlan) {
+	next := plan.next
+	published := sw.getHkAccessories()
+
+	// kept drivers stay referenced by config fields (mocks replace config, see UseMockDrivers)
+	for name, driver := range next.ioDrivers {
+		if plan.kept[driver] && !sw.isMockedIoDriver(name) {
+			next.setDriverConfig(driver)
+		}
+	}
+	for name, driver := range next.sensorDrivers {
+		if plan.kept[driver] && sw.mockSensorDrivers == nil {
+			next.setDriverConfig(driver)
+			if name == "modbus" {
+				next.Modbus = sw.Modbus
+			}
+		}
+	}
+
+	current := reflect.ValueOf(sw).Elem()
+	target := reflect.ValueOf(next).Elem()
+	for i := 0; i < current.NumField(); i++ {
+		if current.Type().Field(i).IsExported() {
+			current.Field(i).Set(target.Field(i))
+		}
+	}
+
+	for _, reconfigured := range plan.reconfigured {
+		err := reconfigured.driver.Reconfigure(ctx, reconfigured.next, reconfigured.nextInputs, reconfigured.nextOutputs)
+		if err != nil {
+			log.Printf("reload | removing pins of %s driver failed: %v", reconfigured.driver.NameId(), err)
+		}
+	}
+	for name, running := range sw.ioDrivers {
+		if next.ioDrivers[name] == running {
+			continue
+		}
+		err := running.Close()
+		if err != nil {
+			log.Printf("reload | closing %s driver failed: %v", name, err)
+		}
+	}
+	for name, running := range sw.sensorDrivers {
+		if next.sensorDrivers[name] == running {
+			continue
+		}
+		err := running.Close()
+		if err != nil {
+			log.Printf("reload | closing %s sensor driver failed: %v", name, err)
+		}
+	}
+	sw.ioDrivers = next.ioDrivers
+	sw.sensorDrivers = next.sensorDrivers
+	if sw.mockIoDrivers != nil {
+		sw.mockIoDrivers = next.mockIoDrivers
+		sw.mockSensorDrivers = next.mockSensorDrivers
+		for name, driver := range sw.ioDrivers {
+			if md, isMock := driver.(*drivers.MockIoDriver); isMock {
+				sw.mockIoDrivers[name] = md
+			}
+		}
+		for name, driver := range sw.sensorDrivers {
+			if msd, isMock := driver.(*drivers.MockSensorDriver); isMock {
+				sw.mockSensorDrivers[name] = msd
+			}
+		}
+	}
+
+	// controllers are matched again, new accessories were reset by Init
+	for _, swb := range sw.Switches {
+		swb.switchSlice = nil
+	}
+	for _, bu := range sw.Buttons {
+		bu.toggleMap = make(map[drivers.PushEvent][]ClickableDevice)
+		bu.rampSlice = nil
+		bu.listeners = nil
+	}
+	for _, ms := range sw.MotionSensors {
+		ms.controlled = nil
+	}
+	if _, used := sw.ioDrivers["virtual"]; used {
+		// inputs resolved with next when prepared, states are read from sw from now on
+		err := sw.Virtual.ResolveInputs(sw.getAccessoryState)
+		if err != nil {
+			log.Printf("reload | %v", err)
+		}
+	}
+	err := sw.MatchControllers()
+	if err != nil {
+		log.Printf("reload | %v", err)
+	}
+	err = sw.MatchSensors()
+	if err != nil {
+		log.Printf("reload | %v", err)
+	}
+	if sw.uniqueIdsAssigned {
+		err = sw.AssignUniqueIds()
+		if err != nil {
+			log.Printf("reload | %v", err)
+		}
+	}
+
+	if sw.MqttBridge != nil {
+		sw.MqttBridge.rebuild(sw)
+	}
+
+	changed := changedHkAccessories(published, sw.getHkAccessories())
+	if len(changed) > 0 {
+		plan.summary.HomeKitRestarted = sw.restartHomeKit()
+		plan.summary.HomeKitRestartReason = "HomeKit accessories changed: " + strings.Join(changed, ", ")
+	}
+}
+
+// getHkAccessories returns HomeKit accessories of accessories (published by HomeKit server).
+func (sw *SwKit) getHkAccessories() (accessories []*accessory.A) {
+	for _, th := range sw.getHkThings() {
+		if hk := th.GetHk(); hk != nil {
+			accessories = append(accessories, hk)
+		}
+	}
+	return
+}
+
+// changedHkAccessories returns names of HomeKit accessories added or removed, recreated accessory is both.
+func changedHkAccessories(before []*accessory.A, after []*accessory.A) (names []string) {
+	for _, a := range before {
+		if !slices.Contains(after, a) {
+			names = append(names, a.Name())
+		}
+	}
+	for _, a := range after {
+		if !slices.Contains(before, a) && !slices.Contains(names, a.Name()) {
+			names = append(names, a.Name())
+		}
+	}
+	sort.Strings(names)
+	return
+}
+
+// Reload applies configuration next to running SwKit. Accessories are matched by kind and name, unchanged
+// ones are kept with their state and outputs, others are added, replaced or removed. Drivers with unchanged
+// config and pins are kept, drivers.ReconfigurableIoDriver are reconfigured with next config (reload fails
+// when a change needs restart), others are set up again. New drivers and accessories are set up next to running ones and applied only when all of them
+// succeeded, otherwise they are closed and running SwKit is left untouched. HomeKit server started by
+// StartHomeKit is created again with the same pairing only when HomeKit accessories changed. MqttBridge and
+// HomeKit settings changes need restart. Nothing is applied when next is not valid (see Validate).
+func (sw *SwKit) Reload(ctx context.Context, next *SwKit) (*ReloadSummary, error) {
+	if sw.mockIoDrivers != nil {
+		next.UseMockDrivers()
+	}
+	err := next.Validate()
+	if err != nil {
+		return nil, errors.Wrap(err, "reload aborted")
+	}
+
+	sw.reloadLock.Lock()
+	defer sw.reloadLock.Unlock()
+
+	summary := &ReloadSummary{}
+	if !sameConfig(sw.MqttBridge, next.MqttBridge) {
+		summary.Notes = append(summary.Notes, "mqtt bridge config changed, restart needed to apply it")
+	}
+	next.MqttBridge = sw.MqttBridge
+	if sw.Name != next.Name || sw.HkPin != next.HkPin || sw.HkDirectory != next.HkDirectory || sw.HkAddress != next.HkAddress {
+		summary.Notes = append(summary.Notes, "HomeKit settings changed, restart needed to apply them")
+	}
+	next.Name, next.HkPin, next.HkDirectory, next.HkAddress, next.HkDebug = sw.Name, sw.HkPin, sw.HkDirectory, sw.HkAddress, sw.HkDebug
+
+	plan := &reloadPlan{next: next, summary: summary, kept: map[interface{}]bool{}}
+	err = sw.prepareIoDrivers(ctx, plan)
+	if err == nil {
+		err = sw.prepareSensorDrivers(plan)
+	}
+	if err == nil {
+		err = sw.prepareAccessories(plan)
+	}
+	if err != nil {
+		sw.rollback(ctx, plan)
+		return nil, errors.Wrap(err, "reload failed, running config left unchanged")
+	}
+
+	if !summary.Changed() {
+		return summary, nil
+	}
+	sw.apply(ctx, plan)
+
+	return summary, nil
+}
+
+// ReloadConfig loads configuration file (see LoadConfig) and applies it with Reload.
+func (sw *SwKit) ReloadConfig(ctx context.Context, path string) (*ReloadSummary, error) {
+	next, err := LoadConfig(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reload aborted")
+	}
+	return sw.Reload(ctx, next)
+}
+
+// ReloadOnSignal reloads configuration file on every SIGHUP until ctx is done.
+func (sw *SwKit) ReloadOnSignal(ctx context.Context, path string) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			log.Printf("reloading config %s", path)
+			summary, err := sw.ReloadConfig(ctx, path)
+			if summary != nil {
+				log.Printf("reload | %s", strings.ReplaceAll(summary.String(), "\n", "\n reload | "))
+			}
+			if err != nil {
+				log.Printf("reload | %v", err)
+			}
+		}
+	}
+}
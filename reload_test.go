@@ -0,0 +1,281 @@
+package swkit
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	drivers "github.com/hubertat/swkit/drivers"
+)
+
+func reloadTestConfig() *SwKit {
+	return &SwKit{
+		Buttons: []*Button{{Name: "Hall button", DriverName: "mock_driver", InPin: 10}},
+		Lights: []*Light{
+			{Name: "Hall", DriverName: "mock_driver", OutPin: 1, ControlBy: []ControllingDevice{{Pin: 10}}},
+			{Name: "Kitchen", DriverName: "mock_driver", OutPin: 2},
+		},
+		FakeDriver: &drivers.MockIoDriver{},
+	}
+}
+
+func TestReload(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sw := reloadTestConfig()
+	err := sw.InitDrivers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sw.Close()
+	err = sw.InitIos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sw.MatchControllers()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md := sw.FakeDriver
+	hall := sw.Lights[0]
+	hall.SetValue(true)
+	sw.Lights[1].SetValue(true)
+	md.ClearWrites()
+
+	summary, err := sw.Reload(ctx, reloadTestConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertBools(t, summary.Changed(), false)
+
+	next := reloadTestConfig()
+	next.Lights = []*Light{
+		{Name: "Hall", DriverName: "mock_driver", OutPin: 1},
+		{Name: "Porch", DriverName: "mock_driver", OutPin: 3, ControlBy: []ControllingDevice{{Pin: 10}}},
+	}
+	summary, err = sw.Reload(ctx, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.String() != "added light Porch\nremoved light Kitchen\nmodified light Hall\nreconfigured mock_driver driver" {
+		t.Errorf("unexpected reload summary:\n%s", summary)
+	}
+
+	assertBools(t, sw.FakeDriver == md, true)
+	assertInts(t, len(sw.Lights), 2)
+	assertBools(t, sw.Lights[0] == hall, false)
+
+	// modified light keeps its output, removed output is switched off
+	state, _ := sw.Lights[0].output.GetState()
+	assertBools(t, state, true)
+	writes := md.Writes()
+	assertInts(t, len(writes), 1)
+	assertBools(t, writes[0].Pin == 2 && !writes[0].State, true)
+
+	md.Push(10, drivers.PushEventSinglePress)
+	writes = md.Writes()
+	assertInts(t, len(writes), 2)
+	assertBools(t, writes[1].Pin == 3 && writes[1].State, true)
+
+	invalid := reloadTestConfig()
+	invalid.Lights = append(invalid.Lights, &Light{Name: "Hall", DriverName: "mock_driver", OutPin: 4})
+	_, err = sw.Reload(ctx, invalid)
+	assertBools(t, err != nil && strings.Contains(err.Error(), "duplicate name"), true)
+	assertInts(t, len(sw.Lights), 2)
+}
+
+func TestReloadMockDrivers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sw := &SwKit{
+		Switches:           []*Switch{{Name: "Garage", DriverName: "gpio", InPin: 5}},
+		Outlets:            []*Outlet{{Name: "Pump", DriverName: "gpio", OutPin: 6, ControlBy: []ControllingDevice{{Pin: 5}}}},
+		Thermostats:        []*Thermostat{{Name: "Living room", DriverName: "gpio", HeatPin: 7, SensorId: "28-01"}},
+		TemperatureSensors: []*MeasurementSensor{{Id: "28-01", Name: "Living room", DriverName: "wire"}},
+	}
+	sw.UseMockDrivers()
+	err := sw.InitDrivers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sw.Close()
+	err = sw.InitIos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sw.InitSensors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sw.MatchControllers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sw.MatchSensors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	md, _ := sw.GetMockIoDriver("gpio")
+	msd, _ := sw.GetMockSensorDriver("wire")
+
+	next := &SwKit{
+		Switches:           []*Switch{{Name: "Garage", DriverName: "gpio", InPin: 5}},
+		Outlets:            []*Outlet{{Name: "Pump", DriverName: "gpio", OutPin: 6, ControlBy: []ControllingDevice{{Pin: 5}}}},
+		Thermostats:        []*Thermostat{{Name: "Living room", DriverName: "gpio", HeatPin: 7, SensorId: "28-02"}},
+		TemperatureSensors: []*MeasurementSensor{{Id: "28-02", Name: "Living room floor", DriverName: "wire"}},
+	}
+	summary, err := sw.Reload(ctx, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.String() != "added temperature sensor Living room floor\nremoved temperature sensor Living room\nmodified thermostat Living room\nrestarted wire sensor driver" {
+		t.Errorf("unexpected reload summary:\n%s", summary)
+	}
+
+	reloadedMd, _ := sw.GetMockIoDriver("gpio")
+	reloadedMsd, _ := sw.GetMockSensorDriver("wire")
+	assertBools(t, reloadedMd == md, true)
+	assertBools(t, reloadedMsd == msd, false)
+
+	reloadedMsd.SetValue("28-02", 22.5)
+	sw.syncSensorDriversAndSensors()
+	time.Sleep(10 * time.Millisecond)
+	value, err := sw.Thermostats[0].temperatureSensor.GetValue()
+	assertBools(t, err == nil && value == 22.5, true)
+
+	md.SetInput(5, true)
+	sw.Switches[0].Sync()
+	assertBools(t, sw.Outlets[0].State, true)
+}
+
+func TestReloadFailed(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sw := reloadTestConfig()
+	err := sw.InitDrivers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sw.Close()
+	err = sw.InitIos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sw.MatchControllers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	md := sw.FakeDriver
+	hall, kitchen := sw.Lights[0], sw.Lights[1]
+
+	// virtual driver setup fails after mock_driver was reconfigured with new pin
+	next := reloadTestConfig()
+	next.Lights = append(next.Lights, &Light{Name: "Porch", DriverName: "mock_driver", OutPin: 3})
+	next.Switches = []*Switch{{Name: "Guest mode", DriverName: "virtual", InPin: 1}}
+	next.Virtual = &drivers.VirtualIO{Inputs: []*drivers.VirtualInput{{Pin: 1, Expression: "Hall &&"}}}
+	_, err = sw.Reload(ctx, next)
+	assertBools(t, err != nil && strings.Contains(err.Error(), "running config left unchanged"), true)
+
+	assertBools(t, sw.FakeDriver == md, true)
+	assertBools(t, sw.Virtual == nil, true)
+	assertInts(t, len(sw.Lights), 2)
+	assertBools(t, sw.Lights[0] == hall && sw.Lights[1] == kitchen, true)
+	_, outputs := md.GetAllIo()
+	assertInts(t, len(outputs), 2)
+
+	md.ClearWrites()
+	md.Push(10, drivers.PushEventSinglePress)
+	writes := md.Writes()
+	assertInts(t, len(writes), 1)
+	assertBools(t, writes[0].Pin == 1 && writes[0].State, true)
+}
+
+func TestReloadWebhookDriver(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var requests []string
+	var lock sync.Mutex
+	device := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		requests = append(requests, r.URL.Path)
+	}))
+	defer device.Close()
+	received := func() string {
+		lock.Lock()
+		defer lock.Unlock()
+		return strings.Join(requests, " ")
+	}
+
+	// event listener can't be opened twice, the running driver is reconfigured
+	free, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listenAddr := free.Addr().String()
+	free.Close()
+	output := func(pin string) *drivers.WebhookOutput {
+		pinNo, _ := strconv.Atoi(pin)
+		return &drivers.WebhookOutput{Pin: uint16(pinNo), On: drivers.WebhookRequest{Url: device.URL + "/on/" + pin}, Off: drivers.WebhookRequest{Url: device.URL + "/off/" + pin}}
+	}
+	config := func() *SwKit {
+		return &SwKit{
+			Buttons: []*Button{{Name: "Hall button", DriverName: "webhook", InPin: 10}},
+			Lights:  []*Light{{Name: "Hall", DriverName: "webhook", OutPin: 1, ControlBy: []ControllingDevice{{Pin: 10}}}},
+			Webhook: &drivers.WebhookIO{ListenAddr: listenAddr, Token: "secret", Outputs: []*drivers.WebhookOutput{output("1")}, Inputs: []*drivers.WebhookInput{{Pin: 10}}},
+		}
+	}
+
+	sw := config()
+	err = sw.InitDrivers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sw.Close()
+	err = sw.InitIos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sw.MatchControllers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wio, hall := sw.Webhook, sw.Lights[0]
+	hall.SetValue(true)
+
+	next := config()
+	next.Lights = append(next.Lights, &Light{Name: "Porch", DriverName: "webhook", OutPin: 2})
+	next.Webhook.Outputs = append(next.Webhook.Outputs, output("2"))
+	summary, err := sw.Reload(ctx, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.String() != "added light Porch\nreconfigured webhook driver" {
+		t.Errorf("unexpected reload summary:\n%s", summary)
+	}
+	assertBools(t, sw.Webhook == wio && sw.Lights[0] == hall, true)
+	assertBools(t, received() == "/on/1", true)
+	state, err := hall.output.GetState()
+	assertBools(t, err == nil && state, true)
+
+	sw.Lights[1].SetValue(true)
+	resp, err := http.Get("http://" + listenAddr + "/input/10/event/single/token/secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if received() != "/on/1 /on/2 /off/1" {
+		t.Errorf("unexpected requests: %s", received())
+	}
+}
@@ -1,163 +1,763 @@
 package swkit
 
 import (
+	"encoding/json"
 	"fmt"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/brutella/hap/accessory"
-	"github.com/stianeikeland/go-rpio/v4"
+	"github.com/brutella/hap/characteristic"
+	drivers "github.com/hubertat/swkit/drivers"
+	"github.com/pkg/errors"
 )
 
+const shutterStep = 50 * time.Millisecond
+const defaultShutterTravelDuration = 30 * time.Second
+const defaultShutterDeadTime = 500 * time.Millisecond
+const defaultShutterOverrun = 2 * time.Second
+const defaultShutterStepSize = 10
+
+// shutterPositionsFile is kept in HomeKit directory, it holds last positions of shutters.
+const shutterPositionsFile = "shutter_positions.json"
+
+const (
+	shutterWiringUpDown      = "up_down"
+	shutterWiringOnDirection = "on_direction"
+)
+
+const (
+	shutterDown    = -1
+	shutterStopped = 0
+	shutterUp      = 1
+)
+
+// Shutter is time based window covering (roller shutter, blind) driven by two digital outputs: up and down
+// relays (Wiring "up_down", default) or motor on and direction relays (Wiring "on_direction", direction on
+// moves up). Position (0 closed - 100 open) is computed from UpDuration and DownDuration, direction changes
+// are separated by DeadTime and travel to end stop continues for Overrun, so position is recalibrated.
+// Buttons in ControlUpBy/ControlDownBy step (StepSize) or stop the shutter with short press and travel
+// fully with long press, switches travel fully when switched on and stop when switched off.
+// Venetian blind with TiltDuration turns its slats (tilt -90 - 90) before position starts changing, moving
+// up tilts slats towards 90, moving down towards -90. With RestoreTilt tilt is restored after position move.
 type Shutter struct {
-	Name             string
-	GpioOn           int
-	GpioDirection    int
-	GoUpByGpio       int
-	GoDownByGpio     int
-	MovementDuration string
-
-	InvertOn        bool
-	InvertDirection bool
-	State           int
-
-	hk                   *WindowShutter
-	isMoving             bool
-	isGoingUp            bool
-	movementStartedAt    time.Time
-	movementStartState   int
-	fullMovementDuration time.Duration
-	targetState          int
+	Name            string
+	Id              string
+	DriverName      string
+	Wiring          string // "up_down" (default) or "on_direction"
+	UpPin           uint16 // up_down wiring
+	DownPin         uint16
+	OnPin           uint16 // on_direction wiring
+	DirectionPin    uint16
+	InvertDirection bool // direction output on moves down
+	DisableHomekit  bool
+	IsFaulty        bool
+
+	Position         int    // 0 (closed) - 100 (open), initial position when no position was saved
+	MovementDuration string // full travel, default "30s"
+	UpDuration       string // full travel up, default MovementDuration
+	DownDuration     string // full travel down, default MovementDuration
+	DeadTime         string // pause before direction change, default "500ms"
+	Overrun          string // travel beyond end stop, default "2s"
+	StepSize         int    // percent moved by short press, default 10
+
+	Tilt         int    // -90 - 90, initial slats tilt
+	TiltDuration string // slats travel from -90 to 90, enables tilt
+	RestoreTilt  bool
+
+	ControlUpBy   []ControllingDevice
+	ControlDownBy []ControllingDevice
+
+	up        drivers.DigitalOutput // up or motor on output
+	down      drivers.DigitalOutput // down or direction output
+	driver    drivers.IoDriver
+	positions *shutterPositions
+
+	hk         *WindowShutter
+	fault      *characteristic.StatusFault
+	tilt       *characteristic.CurrentHorizontalTiltAngle
+	targetTilt *characteristic.TargetHorizontalTiltAngle
+	lock       sync.Mutex
+
+	upTime   time.Duration
+	downTime time.Duration
+	deadTime time.Duration
+	overrun  time.Duration
+	tiltTime time.Duration
+
+	position      float64 // exceeds 0-100 during overrun
+	target        int
+	angle         float64
+	targetAngle   int
+	goal          int  // direction to target
+	tilting       bool // goal is direction to target angle
+	moving        int  // direction of motor
+	lastDirection int
+	movedAt       time.Time
+	stoppedAt     time.Time
+	runId         int
+	running       bool
+
+	uniqueId uint64
 }
 
-func (shu *Shutter) GetHk() *accessory.A {
-	info := accessory.Info{
-		Name:         shu.Name,
-		SerialNumber: fmt.Sprintf("light:gpio:%02d/%02d", shu.GpioOn, shu.GpioDirection),
+func (shu *Shutter) GetDriverName() string {
+	return shu.DriverName
+}
+
+func (shu *Shutter) GetUniqueId() uint64 {
+	if shu.uniqueId != 0 {
+		return shu.uniqueId
 	}
-	shu.hk = NewWindowShutter(info)
+	return shu.identity().hash()
+}
 
-	shu.hk.WindowCovering.TargetPosition.OnValueRemoteUpdate(shu.StartMovement)
+func (shu *Shutter) identity() accessoryIdentity {
+	return accessoryIdentity{Kind: "Shutter", Id: shu.Id, Name: shu.Name, Serial: shu.serialNumber()}
+}
 
-	return shu.hk.A
+func (shu *Shutter) serialNumber() string {
+	pins := shu.getPins()
+	return fmt.Sprintf("shutter:%s:%02d/%02d", shu.DriverName, pins[0], pins[1])
 }
 
-func (shu *Shutter) GetState() int {
-	return shu.State
+func (shu *Shutter) setUniqueId(id uint64) {
+	shu.uniqueId = id
 }
 
-func (shu *Shutter) GetPositionState() int {
-	if !shu.isMoving {
-		return 2
+func (shu *Shutter) isOnDirection() bool {
+	return strings.EqualFold(shu.Wiring, shutterWiringOnDirection)
+}
+
+// getPins returns up and down pins, or on and direction pins.
+func (shu *Shutter) getPins() []uint16 {
+	if shu.isOnDirection() {
+		return []uint16{shu.OnPin, shu.DirectionPin}
 	}
-	if shu.isGoingUp {
-		return 1
+	return []uint16{shu.UpPin, shu.DownPin}
+}
+
+// getStateKey identifies shutter in positions file.
+func (shu *Shutter) getStateKey() string {
+	if len(shu.Id) > 0 {
+		return "#" + shu.Id
 	}
-	return 0
+	return shu.Name
 }
-func (shu *Shutter) GoUp() {
-	shu.StartMovement(100)
+
+func parseShutterDuration(value string, fallback time.Duration) (time.Duration, error) {
+	if len(value) == 0 {
+		return fallback, nil
+	}
+	return time.ParseDuration(value)
 }
 
-func (shu *Shutter) GoDown() {
-	shu.StartMovement(0)
+func (shu *Shutter) parseDurations() (err error) {
+	travel, err := parseShutterDuration(shu.MovementDuration, defaultShutterTravelDuration)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse MovementDuration")
+	}
+	shu.upTime, err = parseShutterDuration(shu.UpDuration, travel)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse UpDuration")
+	}
+	shu.downTime, err = parseShutterDuration(shu.DownDuration, travel)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse DownDuration")
+	}
+	if shu.upTime <= 0 || shu.downTime <= 0 {
+		return errors.New("travel duration has to be positive")
+	}
+	shu.deadTime, err = parseShutterDuration(shu.DeadTime, defaultShutterDeadTime)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse DeadTime")
+	}
+	shu.overrun, err = parseShutterDuration(shu.Overrun, defaultShutterOverrun)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse Overrun")
+	}
+	shu.tiltTime, err = parseShutterDuration(shu.TiltDuration, 0)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse TiltDuration")
+	}
+	return nil
 }
 
-func (shu *Shutter) StartMovement(target int) {
-	fmt.Printf("DEBUG== StartMovement target: %v\n", target)
-	shu.targetState = target
-	if shu.State == target {
-		shu.StopMovement()
+func (shu *Shutter) Init(driver drivers.IoDriver) error {
+	if !strings.EqualFold(driver.NameId(), shu.DriverName) {
+		return fmt.Errorf("Init failed, mismatched or incorrect driver")
+	}
+
+	if !driver.IsReady() {
+		return fmt.Errorf("Init failed, driver not ready")
+	}
+
+	err := shu.parseDurations()
+	if err != nil {
+		return errors.Wrap(err, "Init failed")
+	}
+	if len(shu.Wiring) > 0 && !shu.isOnDirection() && !strings.EqualFold(shu.Wiring, shutterWiringUpDown) {
+		return errors.Errorf("Init failed, unknown Wiring %s", shu.Wiring)
+	}
+
+	shu.lock.Lock()
+	defer shu.lock.Unlock()
+
+	// movement in progress (reload) is stopped
+	shu.runId++
+	shu.running = false
+	shu.goal = shutterStopped
+	shu.tilting = false
+	if shu.moving != shutterStopped {
+		shu.stop()
+	}
+
+	pins := shu.getPins()
+	shu.driver = driver
+	shu.up, err = driver.GetOutput(pins[0])
+	if err != nil {
+		return errors.Wrap(err, "Init failed")
+	}
+	shu.down, err = driver.GetOutput(pins[1])
+	if err != nil {
+		return errors.Wrap(err, "Init failed")
+	}
+
+	shu.Position = min(max(shu.Position, 0), 100)
+	shu.position = float64(shu.Position)
+	shu.target = shu.Position
+	shu.Tilt = min(max(shu.Tilt, -90), 90)
+	shu.angle = float64(shu.Tilt)
+	shu.targetAngle = shu.Tilt
+	if shu.StepSize <= 0 {
+		shu.StepSize = defaultShutterStepSize
+	}
+
+	if shu.DisableHomekit {
+		return nil
+	}
+
+	shu.hk = NewWindowShutter(accessory.Info{
+		Name:         shu.Name,
+		SerialNumber: shu.serialNumber(),
+	})
+
+	shu.fault = characteristic.NewStatusFault()
+	shu.fault.SetValue(characteristic.StatusFaultNoFault)
+	shu.hk.WindowCovering.AddC(shu.fault.C)
+
+	shu.hk.WindowCovering.TargetPosition.OnValueRemoteUpdate(shu.MoveTo)
+	shu.tilt = nil
+	shu.targetTilt = nil
+	if shu.tiltTime > 0 {
+		shu.tilt = characteristic.NewCurrentHorizontalTiltAngle()
+		shu.hk.WindowCovering.AddC(shu.tilt.C)
+		shu.targetTilt = characteristic.NewTargetHorizontalTiltAngle()
+		shu.hk.WindowCovering.AddC(shu.targetTilt.C)
+		shu.targetTilt.OnValueRemoteUpdate(shu.TiltTo)
+	}
+	shu.updateHk()
+
+	return nil
+}
+
+// restorePosition sets position saved in positions file, positions are updated when shutter stops.
+func (shu *Shutter) restorePosition(positions *shutterPositions) {
+	shu.lock.Lock()
+	defer shu.lock.Unlock()
+
+	shu.positions = positions
+	position, found := positions.get(shu.getStateKey())
+	if !found || shu.running {
 		return
 	}
+	shu.Position = min(max(position, 0), 100)
+	shu.position = float64(shu.Position)
+	shu.target = shu.Position
+	shu.updateHk()
+}
+
+func (shu *Shutter) Sync() error {
+	shu.lock.Lock()
+	defer shu.lock.Unlock()
 
-	shu.movementStartedAt = time.Now()
-	shu.movementStartState = shu.State
-	shu.isGoingUp = target > shu.State
-	fmt.Println("DEBUG ------> Movement!")
-	shu.isMoving = true
+	shu.updatePosition()
+
+	_, err := shu.up.GetState()
+	if err == nil {
+		_, err = shu.down.GetState()
+	}
+	if shu.hk != nil {
+		if err != nil {
+			shu.fault.SetValue(characteristic.StatusFaultGeneralFault)
+			shu.IsFaulty = true
+		} else {
+			shu.fault.SetValue(characteristic.StatusFaultNoFault)
+			shu.IsFaulty = false
+		}
+	}
+	shu.updateHk()
+
+	if err != nil {
+		return errors.Wrap(err, "Sync failed")
+	}
+	return nil
 }
 
-func (shu *Shutter) StopMovement() {
-	fmt.Println("DEBUG ++ StopMoevement")
-	shu.isMoving = false
-	shu.movementStartedAt = time.Time{}
-	shu.hk.WindowCovering.CurrentPosition.SetValue(shu.State)
+func (shu *Shutter) GetHk() *accessory.A {
+	if shu.hk == nil {
+		return nil
+	}
+	return shu.hk.A
 }
 
-func (shu *Shutter) updateCurrentState() {
-	if !shu.isMoving {
-		return
+// GetPosition returns current position (0-100) and direction of movement.
+func (shu *Shutter) GetPosition() (position int, direction int) {
+	shu.lock.Lock()
+	defer shu.lock.Unlock()
+
+	shu.updatePosition()
+	return shu.Position, shu.goal
+}
+
+// GetTilt returns current slats tilt (-90 - 90).
+func (shu *Shutter) GetTilt() int {
+	shu.lock.Lock()
+	defer shu.lock.Unlock()
+
+	shu.updatePosition()
+	return shu.Tilt
+}
+
+// MoveTo starts movement to target position (0-100), movement in progress is redirected.
+func (shu *Shutter) MoveTo(target int) {
+	shu.lock.Lock()
+	defer shu.lock.Unlock()
+
+	shu.moveTo(target)
+}
+
+// moveTo has to be called with lock held.
+func (shu *Shutter) moveTo(target int) {
+	shu.updatePosition()
+	shu.target = min(max(target, 0), 100)
+	shu.tilting = false
+	shu.goal = shu.getDirection()
+	if shu.goal != shutterStopped && (!shu.RestoreTilt || shu.target == 0 || shu.target == 100) {
+		shu.targetAngle = 90 * shu.goal
 	}
-	percentageMoved := float64(100*time.Since(shu.movementStartedAt)) / float64(shu.fullMovementDuration)
-	calculatedState := float64(shu.movementStartState)
-	if shu.isGoingUp {
-		calculatedState += percentageMoved
-	} else {
-		calculatedState -= percentageMoved
+	if shu.goal == shutterStopped {
+		shu.goal = shu.getTiltDirection()
+		shu.tilting = shu.goal != shutterStopped
 	}
-	fmt.Printf("t=DEBUG percentage: %f, calc: %f\n", percentageMoved, calculatedState)
-	if calculatedState >= 100 {
-		shu.State = 100
-		shu.StopMovement()
+	shu.updateHk()
+	shu.startRun()
+}
+
+// TiltTo turns slats to angle (-90 - 90) keeping position, during position move angle is set after the move.
+func (shu *Shutter) TiltTo(angle int) {
+	shu.lock.Lock()
+	defer shu.lock.Unlock()
+
+	if shu.tiltTime == 0 {
 		return
 	}
-	if calculatedState <= 0 {
-		shu.State = 0
-		shu.StopMovement()
-		return
+	shu.updatePosition()
+	shu.targetAngle = min(max(angle, -90), 90)
+	if shu.goal == shutterStopped || shu.tilting {
+		shu.target = shu.Position
+		shu.goal = shu.getTiltDirection()
+		shu.tilting = shu.goal != shutterStopped
 	}
+	shu.updateHk()
+	shu.startRun()
+}
 
-	shu.State = int(calculatedState)
-	if shu.isGoingUp && shu.State >= shu.targetState {
-		shu.StopMovement()
-		return
+// startRun starts movement goroutine when it is not running, has to be called with lock held.
+func (shu *Shutter) startRun() {
+	if !shu.running && shu.goal != shutterStopped {
+		shu.running = true
+		shu.runId++
+		go shu.run(shu.runId)
 	}
-	if !shu.isGoingUp && shu.State <= shu.targetState {
-		shu.StopMovement()
+}
+
+// Stop stops movement in progress.
+func (shu *Shutter) Stop() {
+	shu.lock.Lock()
+	defer shu.lock.Unlock()
+
+	shu.updatePosition()
+	shu.halt()
+}
+
+// halt sets targets to current position, has to be called with lock held.
+func (shu *Shutter) halt() {
+	shu.target = shu.Position
+	shu.targetAngle = shu.Tilt
+	shu.goal = shutterStopped
+	shu.tilting = false
+	shu.updateHk()
+}
+
+// Step moves shutter by StepSize up or down, moving shutter is stopped instead.
+func (shu *Shutter) Step(up bool) {
+	shu.lock.Lock()
+	defer shu.lock.Unlock()
+
+	shu.updatePosition()
+	if shu.goal != shutterStopped {
+		shu.halt()
 		return
 	}
-	shu.hk.WindowCovering.CurrentPosition.SetValue(shu.State)
+	if up {
+		shu.moveTo(shu.Position + shu.StepSize)
+	} else {
+		shu.moveTo(shu.Position - shu.StepSize)
+	}
 }
 
-func (shu *Shutter) SetupGpio() {
-	pinOn := rpio.Pin(shu.GpioOn)
-	pinDir := rpio.Pin(shu.GpioDirection)
-	pinOn.Output()
-	pinDir.Output()
+// Travel moves shutter fully up or down.
+func (shu *Shutter) Travel(up bool) {
+	if up {
+		shu.MoveTo(100)
+	} else {
+		shu.MoveTo(0)
+	}
+}
 
-	duration, err := time.ParseDuration(shu.MovementDuration)
-	if err != nil {
-		duration = 30 * time.Second
+// getTravelTarget returns target position, end stops are moved by overrun. Has to be called with lock held.
+func (shu *Shutter) getTravelTarget() float64 {
+	switch shu.target {
+	case 0:
+		return -100 * float64(shu.overrun) / float64(shu.downTime)
+	case 100:
+		return 100 + 100*float64(shu.overrun)/float64(shu.upTime)
+	}
+	return float64(shu.target)
+}
+
+// getDirection returns direction of movement to target, has to be called with lock held.
+func (shu *Shutter) getDirection() int {
+	target := shu.getTravelTarget()
+	atTarget := math.Abs(shu.position-float64(shu.target)) < 0.5
+	switch {
+	case atTarget && (shu.moving == shutterStopped || target == float64(shu.target)):
+		return shutterStopped
+	case shu.position < target:
+		return shutterUp
+	case shu.position > target:
+		return shutterDown
 	}
-	shu.fullMovementDuration = duration
+	return shutterStopped
 }
 
-func (shu *Shutter) Sync() {
-	shu.updateCurrentState()
-	fmt.Printf("DEBUG shutter move? %v position: %03d\n", shu.isMoving, shu.State)
-	pinOn := rpio.Pin(shu.GpioOn)
-	pinDir := rpio.Pin(shu.GpioDirection)
+// getTiltDirection returns direction of movement to target angle, has to be called with lock held.
+func (shu *Shutter) getTiltDirection() int {
+	switch {
+	case shu.tiltTime == 0 || math.Abs(float64(shu.targetAngle)-shu.angle) < 1:
+		return shutterStopped
+	case shu.angle < float64(shu.targetAngle):
+		return shutterUp
+	}
+	return shutterDown
+}
 
-	goingUp := shu.isGoingUp
-	if shu.InvertDirection {
-		goingUp = !goingUp
+// isReached is true when target position (target angle while tilting) is reached in goal direction, has to be
+// called with lock held.
+func (shu *Shutter) isReached() bool {
+	if shu.tilting {
+		return float64(shu.goal)*(float64(shu.targetAngle)-shu.angle) <= 0
 	}
-	if shu.isMoving {
-		if goingUp {
-			pinDir.High()
-		} else {
-			pinDir.Low()
+	return float64(shu.goal)*(shu.getTravelTarget()-shu.position) <= 0
+}
+
+// getTiltRoom returns time needed to turn slats fully in direction, has to be called with lock held.
+func (shu *Shutter) getTiltRoom(direction int) float64 {
+	return math.Max(90-float64(direction)*shu.angle, 0) / 180 * float64(shu.tiltTime)
+}
+
+// getRemainingTime returns travel time to target in current direction, has to be called with lock held.
+func (shu *Shutter) getRemainingTime() time.Duration {
+	if shu.tilting {
+		return time.Duration(math.Abs(float64(shu.targetAngle)-shu.angle) / 180 * float64(shu.tiltTime))
+	}
+	travel := shu.upTime
+	if shu.moving == shutterDown {
+		travel = shu.downTime
+	}
+	remaining := math.Abs(shu.getTravelTarget()-shu.position) / 100 * float64(travel)
+	return time.Duration(remaining + shu.getTiltRoom(shu.moving))
+}
+
+// updatePosition moves position by time elapsed since last update, slats are turned first. Has to be called
+// with lock held.
+func (shu *Shutter) updatePosition() {
+	now := time.Now()
+	elapsed := float64(now.Sub(shu.movedAt))
+	shu.movedAt = now
+
+	if shu.moving != shutterStopped && shu.tiltTime > 0 {
+		tiltElapsed := math.Min(elapsed, shu.getTiltRoom(shu.moving))
+		shu.angle += float64(shu.moving) * 180 * tiltElapsed / float64(shu.tiltTime)
+		shu.Tilt = min(max(int(math.Round(shu.angle)), -90), 90)
+		elapsed -= tiltElapsed
+	}
+
+	switch shu.moving {
+	case shutterUp:
+		shu.position += 100 * elapsed / float64(shu.upTime)
+	case shutterDown:
+		shu.position -= 100 * elapsed / float64(shu.downTime)
+	}
+	shu.Position = min(max(int(math.Round(shu.position)), 0), 100)
+}
+
+// run drives outputs until target is reached, target can be changed while running.
+func (shu *Shutter) run(id int) {
+	for {
+		shu.lock.Lock()
+		if shu.runId != id {
+			shu.lock.Unlock()
+			return
 		}
-		if shu.InvertOn {
-			pinOn.Low()
-		} else {
-			pinOn.High()
+
+		shu.updatePosition()
+		if shu.goal != shutterStopped && shu.isReached() {
+			shu.goal = shutterStopped
+			if !shu.tilting {
+				shu.goal = shu.getTiltDirection()
+			}
+			shu.tilting = shu.goal != shutterStopped
 		}
-	} else {
-		if shu.InvertOn {
-			pinOn.High()
-		} else {
-			pinOn.Low()
+
+		var err error
+		wait := shutterStep
+		if shu.moving != shutterStopped && shu.moving != shu.goal {
+			err = shu.stop()
+		}
+		if err == nil && shu.goal != shutterStopped && shu.moving == shutterStopped {
+			pause := shu.deadTime - time.Since(shu.stoppedAt)
+			if shu.goal != shu.lastDirection && pause > 0 {
+				wait = pause
+			} else {
+				err = shu.start(shu.goal)
+			}
+		}
+		if remaining := shu.getRemainingTime(); shu.moving != shutterStopped && remaining < wait {
+			wait = remaining
+		}
+
+		done := err != nil || (shu.goal == shutterStopped && shu.moving == shutterStopped)
+		if err != nil {
+			shu.stop()
+			shu.halt()
+		}
+		if done {
+			shu.running = false
+		}
+		shu.updateHk()
+		key, position := shu.getStateKey(), shu.Position
+		positions := shu.positions
+		shu.lock.Unlock()
+
+		if err != nil {
+			log.Printf("shutter %s | movement failed: %v", shu.Name, err)
+		}
+		if done {
+			if positions != nil {
+				positions.set(key, position)
+			}
+			return
+		}
+		time.Sleep(wait)
+	}
+}
+
+// start switches motor on, has to be called with lock held.
+func (shu *Shutter) start(direction int) error {
+	err := shu.drive(direction)
+	if err != nil {
+		return err
+	}
+	shu.moving = direction
+	shu.lastDirection = direction
+	shu.movedAt = time.Now()
+	return nil
+}
+
+// stop switches motor off, position beyond end stop is recalibrated. Has to be called with lock held.
+func (shu *Shutter) stop() error {
+	shu.updatePosition()
+	err := shu.drive(shutterStopped)
+	shu.moving = shutterStopped
+	shu.stoppedAt = time.Now()
+	shu.position = math.Min(math.Max(shu.position, 0), 100)
+	return err
+}
+
+// drive sets outputs for direction, output of active direction (or motor on output) is always switched off
+// first, so up and down are never on together.
+func (shu *Shutter) drive(direction int) error {
+	if shu.isOnDirection() {
+		err := shu.up.Set(false)
+		if err != nil || direction == shutterStopped {
+			if dirErr := shu.down.Set(false); err == nil {
+				err = dirErr
+			}
+			return err
+		}
+		err = shu.down.Set((direction == shutterUp) != shu.InvertDirection)
+		if err != nil {
+			return err
 		}
+		return shu.up.Set(true)
+	}
+
+	on, off := shu.up, shu.down
+	if direction == shutterDown {
+		on, off = shu.down, shu.up
+	}
+	err := off.Set(false)
+	if err != nil {
+		return err
+	}
+	return on.Set(direction != shutterStopped)
+}
+
+// updateHk has to be called with lock held.
+func (shu *Shutter) updateHk() {
+	if shu.hk == nil {
+		return
+	}
+
+	state := characteristic.PositionStateStopped
+	switch shu.goal {
+	case shutterUp:
+		state = characteristic.PositionStateIncreasing
+	case shutterDown:
+		state = characteristic.PositionStateDecreasing
+	}
+
+	wc := shu.hk.WindowCovering
+	if wc.CurrentPosition.Value() != shu.Position {
+		wc.CurrentPosition.SetValue(shu.Position)
+	}
+	if wc.TargetPosition.Value() != shu.target {
+		wc.TargetPosition.SetValue(shu.target)
+	}
+	if wc.PositionState.Value() != state {
+		wc.PositionState.SetValue(state)
+	}
+	if shu.tilt != nil && shu.tilt.Value() != shu.Tilt {
+		shu.tilt.SetValue(shu.Tilt)
+	}
+	if shu.targetTilt != nil && shu.targetTilt.Value() != shu.targetAngle {
+		shu.targetTilt.SetValue(shu.targetAngle)
+	}
+}
+
+// getControls returns Controllables of up and down buttons and switches.
+func (shu *Shutter) getControls() []Controllable {
+	return []Controllable{
+		&shutterControl{shu, true, drivers.PushEventSinglePress},
+		&shutterControl{shu, true, drivers.PushEventLongPress},
+		&shutterControl{shu, false, drivers.PushEventSinglePress},
+		&shutterControl{shu, false, drivers.PushEventLongPress},
+	}
+}
+
+// shutterControl moves shutter up or down: button single press steps or stops the shutter, long press
+// travels fully, switch travels fully when on and stops the shutter when off.
+type shutterControl struct {
+	shutter *Shutter
+	up      bool
+	event   drivers.PushEvent
+}
+
+func (sc *shutterControl) GetControllers() (controllers []ControllingDevice) {
+	configured := sc.shutter.ControlDownBy
+	if sc.up {
+		configured = sc.shutter.ControlUpBy
+	}
+	for _, controller := range configured {
+		controller.Event = int(sc.event)
+		controllers = append(controllers, controller)
+	}
+	return
+}
+
+func (sc *shutterControl) GetDriverName() string {
+	return sc.shutter.DriverName
+}
+
+func (sc *shutterControl) SetValue(state bool) {
+	if sc.event != drivers.PushEventSinglePress {
+		return
+	}
+	if state {
+		sc.shutter.Travel(sc.up)
+	} else {
+		sc.shutter.Stop()
+	}
+}
+
+func (sc *shutterControl) Toggle() {
+	if sc.event == drivers.PushEventLongPress {
+		sc.shutter.Travel(sc.up)
+	} else {
+		sc.shutter.Step(sc.up)
+	}
+}
+
+// shutterPositions keeps last positions of shutters in file.
+type shutterPositions struct {
+	path      string
+	positions map[string]int
+	lock      sync.Mutex
+}
+
+func loadShutterPositions(path string) (*shutterPositions, error) {
+	sp := &shutterPositions{path: path, positions: map[string]int{}}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return sp, nil
+	}
+	if err != nil {
+		return sp, errors.Wrap(err, "failed to read shutter positions")
+	}
+	err = json.Unmarshal(data, &sp.positions)
+	if err != nil {
+		return sp, errors.Wrapf(err, "failed to decode shutter positions (%s)", path)
+	}
+	return sp, nil
+}
+
+func (sp *shutterPositions) get(key string) (int, bool) {
+	sp.lock.Lock()
+	defer sp.lock.Unlock()
+
+	position, found := sp.positions[key]
+	return position, found
+}
+
+func (sp *shutterPositions) set(key string, position int) {
+	sp.lock.Lock()
+	defer sp.lock.Unlock()
+
+	if current, found := sp.positions[key]; found && current == position {
+		return
+	}
+	sp.positions[key] = position
+	data, err := json.MarshalIndent(sp.positions, "", "\t")
+	if err == nil {
+		err = writeFileAtomic(sp.path, data)
+	}
+	if err != nil {
+		log.Printf("failed to save shutter positions: %v", err)
 	}
 }
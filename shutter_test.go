@@ -0,0 +1,207 @@
+package swkit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/brutella/hap/characteristic"
+	drivers "github.com/hubertat/swkit/drivers"
+)
+
+func shutterTest(t *testing.T, directory string, shutter *Shutter) *drivers.MockIoDriver {
+	t.Helper()
+
+	sw := newMockKit(t, func(sw *SwKit) {
+		sw.HkDirectory = directory
+		sw.Buttons = []*Button{
+			{Name: "Up button", DriverName: "mock_driver", InPin: 10},
+			{Name: "Down button", DriverName: "mock_driver", InPin: 11},
+		}
+		sw.Shutters = []*Shutter{shutter}
+	})
+	md, _ := sw.GetMockIoDriver("mock_driver")
+	return md
+}
+
+func outputState(md *drivers.MockIoDriver, pin uint16) bool {
+	out, _ := md.GetOutput(pin)
+	state, _ := out.GetState()
+	return state
+}
+
+func isStopped(shutter *Shutter) func() bool {
+	return func() bool {
+		shutter.lock.Lock()
+		defer shutter.lock.Unlock()
+		return !shutter.running
+	}
+}
+
+func TestShutterMovement(t *testing.T) {
+	directory := t.TempDir()
+	shutter := &Shutter{Name: "Office", DriverName: "mock_driver", UpPin: 1, DownPin: 2,
+		UpDuration: "400ms", DownDuration: "200ms", DeadTime: "50ms", Overrun: "0s"}
+	md := shutterTest(t, directory, shutter)
+
+	shutter.MoveTo(50)
+	waitFor(t, func() bool { return outputState(md, 1) }, "moving up")
+	assertBools(t, outputState(md, 2), false)
+	assertInts(t, shutter.hk.WindowCovering.PositionState.Value(), characteristic.PositionStateIncreasing)
+	assertInts(t, shutter.hk.WindowCovering.TargetPosition.Value(), 50)
+
+	waitFor(t, isStopped(shutter), "stop")
+	assertBools(t, outputState(md, 1) || outputState(md, 2), false)
+	position, _ := shutter.GetPosition()
+	assertBools(t, position >= 48 && position <= 53, true)
+	assertInts(t, shutter.hk.WindowCovering.CurrentPosition.Value(), position)
+	assertInts(t, shutter.hk.WindowCovering.PositionState.Value(), characteristic.PositionStateStopped)
+
+	// down travel is faster
+	started := time.Now()
+	shutter.MoveTo(0)
+	waitFor(t, isStopped(shutter), "stop at 0")
+	assertBools(t, time.Since(started) < 180*time.Millisecond, true)
+	position, _ = shutter.GetPosition()
+	assertInts(t, position, 0)
+
+	positions, err := loadShutterPositions(filepath.Join(directory, shutterPositionsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved, found := positions.get("Office")
+	assertBools(t, found, true)
+	assertInts(t, saved, 0)
+}
+
+func TestShutterInterlock(t *testing.T) {
+	shutter := &Shutter{Name: "Office", DriverName: "mock_driver", UpPin: 1, DownPin: 2,
+		MovementDuration: "400ms", DeadTime: "100ms", Overrun: "0s", Position: 50}
+	md := shutterTest(t, t.TempDir(), shutter)
+
+	shutter.MoveTo(100)
+	waitFor(t, func() bool { return outputState(md, 1) }, "moving up")
+	time.Sleep(50 * time.Millisecond)
+	shutter.MoveTo(0)
+	waitFor(t, func() bool { return outputState(md, 2) }, "moving down")
+	shutter.Stop()
+	waitFor(t, isStopped(shutter), "stop")
+
+	states := map[uint16]bool{}
+	var upStopped time.Time
+	for _, write := range md.Writes() {
+		if write.Pin == 1 && !write.State && states[1] {
+			upStopped = write.At
+		}
+		if write.Pin == 2 && write.State && !states[2] {
+			assertBools(t, write.At.Sub(upStopped) >= 100*time.Millisecond, true)
+		}
+		states[write.Pin] = write.State
+		assertBools(t, states[1] && states[2], false)
+	}
+	assertBools(t, upStopped.IsZero(), false)
+}
+
+func TestShutterOverrun(t *testing.T) {
+	shutter := &Shutter{Name: "Office", DriverName: "mock_driver", Wiring: "on_direction", OnPin: 1, DirectionPin: 2,
+		MovementDuration: "200ms", DeadTime: "0s", Overrun: "150ms", Position: 80}
+	md := shutterTest(t, t.TempDir(), shutter)
+
+	started := time.Now()
+	shutter.Travel(true)
+	waitFor(t, func() bool { return outputState(md, 1) }, "moving")
+	assertBools(t, outputState(md, 2), true)
+	waitFor(t, isStopped(shutter), "stop")
+	assertBools(t, time.Since(started) >= 190*time.Millisecond, true)
+	position, _ := shutter.GetPosition()
+	assertInts(t, position, 100)
+
+	// shutter at end stop does not move again
+	md.ClearWrites()
+	shutter.Travel(true)
+	time.Sleep(20 * time.Millisecond)
+	assertInts(t, len(md.Writes()), 0)
+}
+
+func TestShutterButtons(t *testing.T) {
+	shutter := &Shutter{Name: "Office", DriverName: "mock_driver", UpPin: 1, DownPin: 2,
+		MovementDuration: "300ms", DeadTime: "0s", Overrun: "0s", StepSize: 20, Position: 50,
+		ControlUpBy: []ControllingDevice{{Pin: 10}}, ControlDownBy: []ControllingDevice{{Pin: 11}}}
+	md := shutterTest(t, t.TempDir(), shutter)
+
+	md.Push(10, drivers.PushEventSinglePress)
+	waitFor(t, func() bool { return outputState(md, 1) }, "moving up")
+	waitFor(t, isStopped(shutter), "step up")
+	position, _ := shutter.GetPosition()
+	assertBools(t, position >= 68 && position <= 73, true)
+
+	// short press stops moving shutter
+	md.Push(11, drivers.PushEventLongPress)
+	waitFor(t, func() bool { return outputState(md, 2) }, "travel down")
+	time.Sleep(60 * time.Millisecond)
+	md.Push(11, drivers.PushEventSinglePress)
+	waitFor(t, isStopped(shutter), "stop")
+	position, _ = shutter.GetPosition()
+	assertBools(t, position > 0 && position < 65, true)
+
+	md.Push(11, drivers.PushEventLongPress)
+	waitFor(t, func() bool { return outputState(md, 2) }, "moving down")
+	waitFor(t, isStopped(shutter), "travel down")
+	position, _ = shutter.GetPosition()
+	assertInts(t, position, 0)
+}
+
+func TestShutterPositionRestore(t *testing.T) {
+	directory := t.TempDir()
+	err := os.WriteFile(filepath.Join(directory, shutterPositionsFile), []byte(`{"Office": 35}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shutter := &Shutter{Name: "Office", DriverName: "mock_driver", UpPin: 1, DownPin: 2, Position: 80}
+	shutterTest(t, directory, shutter)
+	position, _ := shutter.GetPosition()
+	assertInts(t, position, 35)
+	assertInts(t, shutter.hk.WindowCovering.CurrentPosition.Value(), 35)
+	assertInts(t, shutter.hk.WindowCovering.TargetPosition.Value(), 35)
+}
+
+func TestShutterTilt(t *testing.T) {
+	shutter := &Shutter{Name: "Office", DriverName: "mock_driver", UpPin: 1, DownPin: 2,
+		MovementDuration: "400ms", TiltDuration: "100ms", DeadTime: "0s", Overrun: "0s", Position: 50, RestoreTilt: true}
+	md := shutterTest(t, t.TempDir(), shutter)
+	assertBools(t, shutter.tilt != nil, true)
+
+	// tilt only turns the slats
+	shutter.TiltTo(90)
+	waitFor(t, func() bool { return outputState(md, 1) }, "tilting up")
+	waitFor(t, isStopped(shutter), "tilt")
+	position, _ := shutter.GetPosition()
+	assertInts(t, position, 50)
+	tilt := shutter.GetTilt()
+	assertBools(t, tilt >= 85, true)
+	assertInts(t, shutter.tilt.Value(), tilt)
+
+	// down move turns slats to -90 first, restores them afterwards
+	started := time.Now()
+	shutter.MoveTo(25)
+	waitFor(t, func() bool { return outputState(md, 2) }, "moving down")
+	waitFor(t, func() bool { return outputState(md, 1) }, "restoring tilt")
+	assertBools(t, time.Since(started) >= 190*time.Millisecond, true)
+	waitFor(t, isStopped(shutter), "move")
+	position, _ = shutter.GetPosition()
+	assertBools(t, position >= 23 && position <= 27, true)
+	assertBools(t, shutter.GetTilt() >= 85, true)
+	assertInts(t, shutter.targetTilt.Value(), 90)
+
+	// without restore slats stay turned in direction of move
+	shutter.RestoreTilt = false
+	shutter.MoveTo(15)
+	waitFor(t, func() bool { return outputState(md, 2) }, "moving down")
+	waitFor(t, isStopped(shutter), "move")
+	assertBools(t, shutter.GetTilt() <= -85, true)
+	assertInts(t, shutter.targetTilt.Value(), -90)
+	position, _ = shutter.GetPosition()
+	assertBools(t, position >= 13 && position <= 17, true)
+}
@@ -2,7 +2,6 @@ package swkit
 
 import (
 	"fmt"
-	"hash/fnv"
 	"strings"
 
 	drivers "github.com/hubertat/swkit/drivers"
@@ -14,6 +13,7 @@ import (
 
 type Switch struct {
 	Name           string
+	Id             string
 	State          bool
 	DriverName     string
 	InPin          uint16
@@ -27,6 +27,8 @@ type Switch struct {
 
 	hk    *accessory.Switch
 	fault *characteristic.StatusFault
+
+	uniqueId uint64
 }
 
 type SwitchableDevice interface {
@@ -38,9 +40,22 @@ func (swb *Switch) GetDriverName() string {
 }
 
 func (swb *Switch) GetUniqueId() uint64 {
-	hash := fnv.New64()
-	hash.Write([]byte("Switch_" + swb.Name))
-	return hash.Sum64()
+	if swb.uniqueId != 0 {
+		return swb.uniqueId
+	}
+	return swb.identity().hash()
+}
+
+func (swb *Switch) identity() accessoryIdentity {
+	return accessoryIdentity{Kind: "Switch", Id: swb.Id, Name: swb.Name, Serial: swb.serialNumber()}
+}
+
+func (swb *Switch) serialNumber() string {
+	return fmt.Sprintf("switch:%s:%02d", swb.DriverName, swb.InPin)
+}
+
+func (swb *Switch) setUniqueId(id uint64) {
+	swb.uniqueId = id
 }
 
 func (swb *Switch) Init(driver drivers.IoDriver) error {
@@ -66,7 +81,7 @@ func (swb *Switch) Init(driver drivers.IoDriver) error {
 
 	info := accessory.Info{
 		Name:         swb.Name,
-		SerialNumber: fmt.Sprintf("switch:%s:%02d", swb.DriverName, swb.InPin),
+		SerialNumber: swb.serialNumber(),
 	}
 	swb.hk = accessory.NewSwitch(info)
 
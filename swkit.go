@@ -7,7 +7,9 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -28,13 +30,17 @@ type SwKit struct {
 	Name string
 
 	Lights             []*Light
+	ColorLights        []*ColorLight
 	Buttons            []*Button
 	Switches           []*Switch
 	Shutters           []*Shutter
+	GarageDoors        []*GarageDoor
 	Outlets            []*Outlet
 	Thermostats        []*Thermostat
 	MotionSensors      []*MotionSensor
-	TemperatureSensors []*TemperatureSensor
+	BinarySensors      []*BinarySensor
+	TemperatureSensors []*MeasurementSensor
+	Sensors            []*MeasurementSensor
 
 	HkPin       string
 	HkDirectory string
@@ -43,18 +49,37 @@ type SwKit struct {
 
 	Mcp23017      *drivers.McpIO
 	Gpio          *drivers.GpIO
+	Gpiod         *drivers.GpiodIO
 	Grenton       *drivers.GrentonIO
 	FakeDriver    *drivers.MockIoDriver
 	RemoteIoSlave *drivers.RemoteIoSlave
 	Shelly        *drivers.ShellyIO
+	PcfExpanders  []*drivers.PcfIO
+	Mqtt          *drivers.MqttIO
+	Modbus        *drivers.ModbusIO
+	EspRelays     *drivers.EspRelayIO
+	Webhook       *drivers.WebhookIO
+	Virtual       *drivers.VirtualIO
+
+	MqttBridge *MqttBridge
 
 	InfluxSensors *drivers.InfluxSensors
 	WireSensors   *drivers.Wire
 
 	ioDrivers     map[string]drivers.IoDriver
 	sensorDrivers map[string]drivers.SensorDriver
-	ticker        *time.Ticker
-	sensorsTicker *time.Ticker
+
+	mockIoDrivers     map[string]*drivers.MockIoDriver
+	mockSensorDrivers map[string]*drivers.MockSensorDriver
+	ticker            *time.Ticker
+	sensorsTicker     *time.Ticker
+
+	uniqueIdsAssigned bool
+
+	reloadLock sync.RWMutex
+	hkLock     sync.Mutex
+	hkStop     context.CancelFunc
+	hkRestart  bool
 }
 
 type IO interface {
@@ -104,6 +129,16 @@ func (sw *SwKit) getInPins(driverName string) (pins []uint16) {
 			pins = append(pins, io.InPin)
 		}
 	}
+	for _, io := range sw.BinarySensors {
+		if strings.EqualFold(io.DriverName, driverName) {
+			pins = append(pins, io.getInPins()...)
+		}
+	}
+	for _, io := range sw.GarageDoors {
+		if strings.EqualFold(io.GetSensorDriverName(), driverName) {
+			pins = append(pins, io.getSensorPins()...)
+		}
+	}
 
 	return
 }
@@ -114,11 +149,26 @@ func (sw *SwKit) getOutPins(driverName string) (pins []uint16) {
 			pins = append(pins, io.OutPin)
 		}
 	}
+	for _, io := range sw.ColorLights {
+		if strings.EqualFold(io.DriverName, driverName) {
+			pins = append(pins, io.getPins()...)
+		}
+	}
 	for _, io := range sw.Outlets {
 		if strings.EqualFold(io.DriverName, driverName) {
 			pins = append(pins, io.OutPin)
 		}
 	}
+	for _, io := range sw.Shutters {
+		if strings.EqualFold(io.DriverName, driverName) {
+			pins = append(pins, io.getPins()...)
+		}
+	}
+	for _, io := range sw.GarageDoors {
+		if strings.EqualFold(io.DriverName, driverName) {
+			pins = append(pins, io.OutPin)
+		}
+	}
 	for _, th := range sw.Thermostats {
 		if strings.EqualFold(th.DriverName, driverName) {
 			pins = append(pins, th.HeatPin)
@@ -131,8 +181,14 @@ func (sw *SwKit) getOutPins(driverName string) (pins []uint16) {
 	return
 }
 
-func (sw *SwKit) getTemperatureSensors(driverName string) (tss []drivers.TemperatureSensor) {
-	for _, ts := range sw.TemperatureSensors {
+// getMeasurementSensors returns TemperatureSensors and Sensors.
+func (sw *SwKit) getMeasurementSensors() (sensors []*MeasurementSensor) {
+	sensors = append(sensors, sw.TemperatureSensors...)
+	return append(sensors, sw.Sensors...)
+}
+
+func (sw *SwKit) getDriverSensors(driverName string) (tss []drivers.Sensor) {
+	for _, ts := range sw.getMeasurementSensors() {
 		if strings.EqualFold(driverName, ts.DriverName) {
 			tss = append(tss, ts)
 		}
@@ -141,7 +197,49 @@ func (sw *SwKit) getTemperatureSensors(driverName string) (tss []drivers.Tempera
 	return
 }
 
-func (sw *SwKit) getIoDriverByName(name string) (driver drivers.IoDriver, err error) {
+// UseMockDrivers replaces configured io and sensor drivers with in-memory mocks of the same names, so a real
+// configuration can be simulated without hardware (see cmd/mock). Virtual driver is software only and is kept.
+// Must be called before InitDrivers.
+func (sw *SwKit) UseMockDrivers() {
+	sw.mockIoDrivers = map[string]*drivers.MockIoDriver{}
+	sw.mockSensorDrivers = map[string]*drivers.MockSensorDriver{}
+}
+
+// GetMockIoDriver returns mock used in place of io driver name, see UseMockDrivers.
+func (sw *SwKit) GetMockIoDriver(name string) (*drivers.MockIoDriver, error) {
+	md, exist := sw.mockIoDrivers[name]
+	if !exist {
+		return nil, errors.Errorf("mock io driver (%s) not found", name)
+	}
+	return md, nil
+}
+
+// GetMockSensorDriver returns mock used in place of sensor driver name, see UseMockDrivers.
+func (sw *SwKit) GetMockSensorDriver(name string) (*drivers.MockSensorDriver, error) {
+	msd, exist := sw.mockSensorDrivers[name]
+	if !exist {
+		return nil, errors.Errorf("mock sensor driver (%s) not found", name)
+	}
+	return msd, nil
+}
+
+// isMockedIoDriver is true when io driver name is replaced by mock, see UseMockDrivers.
+func (sw *SwKit) isMockedIoDriver(name string) bool {
+	return sw.mockIoDrivers != nil && name != "virtual"
+}
+
+// getIoDriverByName returns configured io driver, or registers new mock in its place (see UseMockDrivers).
+func (sw *SwKit) getIoDriverByName(name string) (drivers.IoDriver, error) {
+	if sw.isMockedIoDriver(name) {
+		md := &drivers.MockIoDriver{DriverName: name}
+		sw.mockIoDrivers[name] = md
+		return md, nil
+	}
+
+	return sw.getConfiguredIoDriver(name)
+}
+
+func (sw *SwKit) getConfiguredIoDriver(name string) (driver drivers.IoDriver, err error) {
 	switch name {
 	case "gpio":
 		if sw.Gpio == nil {
@@ -149,6 +247,12 @@ func (sw *SwKit) getIoDriverByName(name string) (driver drivers.IoDriver, err er
 		} else {
 			driver = sw.Gpio
 		}
+	case "gpiod":
+		if sw.Gpiod == nil {
+			driver = &drivers.GpiodIO{}
+		} else {
+			driver = sw.Gpiod
+		}
 	case "mcpio":
 		if sw.Mcp23017 == nil {
 			err = errors.New("cannot initialize Mcp23017 driver, config not present")
@@ -179,7 +283,43 @@ func (sw *SwKit) getIoDriverByName(name string) (driver drivers.IoDriver, err er
 		} else {
 			driver = sw.Shelly
 		}
+	case "modbus":
+		if sw.Modbus == nil {
+			err = errors.New("cannot initialize Modbus driver, not configured")
+		} else {
+			driver = sw.Modbus
+		}
+	case "mqtt":
+		if sw.Mqtt == nil {
+			err = errors.New("cannot initialize Mqtt driver, not configured")
+		} else {
+			driver = sw.Mqtt
+		}
+	case "esp_relay":
+		if sw.EspRelays == nil {
+			err = errors.New("cannot initialize EspRelays driver, not configured")
+		} else {
+			driver = sw.EspRelays
+		}
+	case "webhook":
+		if sw.Webhook == nil {
+			err = errors.New("cannot initialize Webhook driver, not configured")
+		} else {
+			driver = sw.Webhook
+		}
+	case "virtual":
+		if sw.Virtual == nil {
+			err = errors.New("cannot initialize Virtual driver, not configured")
+		} else {
+			driver = sw.Virtual
+		}
 	default:
+		for _, pcf := range sw.PcfExpanders {
+			if pcf.NameId() == name {
+				driver = pcf
+				return
+			}
+		}
 		err = errors.Errorf("driver (%s) not found", name)
 	}
 
@@ -191,6 +331,9 @@ func (sw *SwKit) getIos() []IO {
 	for _, li := range sw.Lights {
 		ios = append(ios, li)
 	}
+	for _, li := range sw.ColorLights {
+		ios = append(ios, li)
+	}
 	for _, li := range sw.Buttons {
 		ios = append(ios, li)
 	}
@@ -200,18 +343,47 @@ func (sw *SwKit) getIos() []IO {
 	for _, li := range sw.Outlets {
 		ios = append(ios, li)
 	}
+	for _, shu := range sw.Shutters {
+		ios = append(ios, shu)
+	}
+	for _, gd := range sw.GarageDoors {
+		ios = append(ios, gd)
+	}
 	for _, thermo := range sw.Thermostats {
 		ios = append(ios, thermo)
 	}
 	for _, mosens := range sw.MotionSensors {
 		ios = append(ios, mosens)
 	}
+	for _, bs := range sw.BinarySensors {
+		ios = append(ios, bs)
+	}
 
 	return ios
 }
 
+// getIoDriverNames returns names of io drivers used by accessories, garage door sensor drivers included.
+func (sw *SwKit) getIoDriverNames() (names []string) {
+	used := map[string]bool{}
+	add := func(name string) {
+		if !used[name] {
+			used[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, io := range sw.getIos() {
+		add(io.GetDriverName())
+	}
+	for _, gd := range sw.GarageDoors {
+		if gd.hasSensors() {
+			add(gd.GetSensorDriverName())
+		}
+	}
+	return
+}
+
 func (sw *SwKit) getSensors() (sensors []Sensor) {
-	for _, s := range sw.TemperatureSensors {
+	for _, s := range sw.getMeasurementSensors() {
 		sensors = append(sensors, s)
 	}
 	return
@@ -221,6 +393,9 @@ func (sw *SwKit) getHkThings() (things []HkThing) {
 	for _, th := range sw.Lights {
 		things = append(things, th)
 	}
+	for _, th := range sw.ColorLights {
+		things = append(things, th)
+	}
 	for _, th := range sw.Buttons {
 		things = append(things, th)
 	}
@@ -230,23 +405,32 @@ func (sw *SwKit) getHkThings() (things []HkThing) {
 	for _, th := range sw.Outlets {
 		things = append(things, th)
 	}
+	for _, th := range sw.Shutters {
+		things = append(things, th)
+	}
+	for _, th := range sw.GarageDoors {
+		things = append(things, th)
+	}
 	for _, th := range sw.Thermostats {
 		things = append(things, th)
 	}
-	for _, th := range sw.TemperatureSensors {
+	for _, th := range sw.getMeasurementSensors() {
 		things = append(things, th)
 	}
 	for _, th := range sw.MotionSensors {
 		things = append(things, th)
 	}
+	for _, th := range sw.BinarySensors {
+		things = append(things, th)
+	}
 
 	return
 }
 
 func (sw *SwKit) InitDrivers(ctx context.Context) error {
 	sw.ioDrivers = make(map[string]drivers.IoDriver)
-	for _, io := range sw.getIos() {
-		sw.ioDrivers[io.GetDriverName()] = nil
+	for _, name := range sw.getIoDriverNames() {
+		sw.ioDrivers[name] = nil
 	}
 
 	sw.sensorDrivers = make(map[string]drivers.SensorDriver)
@@ -271,7 +455,7 @@ func (sw *SwKit) InitDrivers(ctx context.Context) error {
 		if err != nil {
 			return errors.Wrapf(err, "failed initializing drivers: failed to get %s sensor driver by name", sensorDriverName)
 		}
-		err = sensorDriver.Setup(sw.getTemperatureSensors(sensorDriverName))
+		err = sensorDriver.Setup(sw.getDriverSensors(sensorDriverName))
 		if err != nil {
 			return errors.Wrapf(err, "got error with setup %s sensor driver", sensorDriverName)
 		}
@@ -282,18 +466,67 @@ func (sw *SwKit) InitDrivers(ctx context.Context) error {
 }
 
 func (sw *SwKit) InitIos() error {
+	return sw.initIos(nil)
+}
+
+// initIos initializes accessories with their io drivers, skipping accessories and drivers kept running by Reload.
+func (sw *SwKit) initIos(kept map[interface{}]bool) error {
 	for _, io := range sw.getIos() {
+		if kept[io] {
+			continue
+		}
 		err := io.Init(sw.ioDrivers[io.GetDriverName()])
 		if err != nil {
 			return errors.Wrapf(err, "failed to init io")
 		}
 	}
 
+	shutters := []*Shutter{}
+	for _, shu := range sw.Shutters {
+		if !kept[shu] {
+			shutters = append(shutters, shu)
+		}
+	}
+	if len(shutters) > 0 {
+		positions, err := loadShutterPositions(filepath.Join(sw.getHkDirectory(), shutterPositionsFile))
+		if err != nil {
+			log.Printf("shutters | %v", err)
+		}
+		for _, shu := range shutters {
+			shu.restorePosition(positions)
+		}
+	}
+
+	for _, gd := range sw.GarageDoors {
+		if kept[gd] {
+			continue
+		}
+		err := gd.initSensors(sw.ioDrivers[gd.GetSensorDriverName()])
+		if err != nil {
+			return errors.Wrapf(err, "failed to init garage door %s", gd.Name)
+		}
+	}
+
+	if virtual, used := sw.ioDrivers["virtual"]; used && !kept[virtual] {
+		err := sw.Virtual.ResolveInputs(sw.getAccessoryState)
+		if err != nil {
+			return errors.Wrap(err, "failed to resolve virtual inputs")
+		}
+	}
+
 	return nil
 }
 
 func (sw *SwKit) InitSensors() error {
+	return sw.initSensors(nil)
+}
+
+// initSensors initializes sensors with their sensor drivers, skipping sensors kept running by Reload.
+func (sw *SwKit) initSensors(kept map[interface{}]bool) error {
 	for _, s := range sw.getSensors() {
+		if kept[s] {
+			continue
+		}
 		err := s.Init(sw.sensorDrivers[s.GetDriverName()])
 		if err != nil {
 			return errors.Wrap(err, "faied to init sensor")
@@ -313,6 +546,16 @@ func (sw *SwKit) findSwitch(pinNo uint16, driverName string) *Switch {
 	return nil
 }
 
+func (sw *SwKit) findMotionSensor(pinNo uint16, driverName string) *MotionSensor {
+	for _, ms := range sw.MotionSensors {
+		if ms.InPin == pinNo && ms.DriverName == driverName {
+			return ms
+		}
+	}
+
+	return nil
+}
+
 func (sw *SwKit) findButton(pinNo uint16, driverName string) *Button {
 	for _, but := range sw.Buttons {
 		if but.InPin == pinNo && but.DriverName == driverName {
@@ -323,17 +566,89 @@ func (sw *SwKit) findButton(pinNo uint16, driverName string) *Button {
 	return nil
 }
 
+type accessoryState interface {
+	GetState() (bool, error)
+}
+
+// getAccessoryState resolves accessory name used in virtual input expressions. Name can be prefixed with
+// kind (light:, outlet:, switch:, motion:, button:, sensor:, garage:) when it is ambiguous. Not initialized accessory is off.
+func (sw *SwKit) getAccessoryState(name string) (bool, error) {
+	kind, accName, found := strings.Cut(name, ":")
+	if !found {
+		kind, accName = "", name
+	}
+
+	matches := []accessoryState{}
+	match := func(accKind string, accessoryName string, io accessoryState) {
+		if (len(kind) == 0 || strings.EqualFold(kind, accKind)) && strings.EqualFold(accName, accessoryName) {
+			matches = append(matches, io)
+		}
+	}
+	for _, li := range sw.Lights {
+		match("light", li.Name, li.output)
+	}
+	for _, cl := range sw.ColorLights {
+		match("light", cl.Name, cl)
+	}
+	for _, ou := range sw.Outlets {
+		match("outlet", ou.Name, ou.output)
+	}
+	for _, swb := range sw.Switches {
+		match("switch", swb.Name, swb.input)
+	}
+	for _, ms := range sw.MotionSensors {
+		match("motion", ms.Name, ms)
+	}
+	for _, bs := range sw.BinarySensors {
+		match("sensor", bs.Name, bs)
+	}
+	for _, gd := range sw.GarageDoors {
+		match("garage", gd.Name, gd)
+	}
+	for _, bu := range sw.Buttons {
+		match("button", bu.Name, bu.input)
+	}
+
+	switch {
+	case len(matches) == 0:
+		return false, errors.Errorf("accessory %s not found", name)
+	case len(matches) > 1:
+		return false, errors.Errorf("accessory name %s is ambiguous, prefix it with kind", name)
+	case matches[0] == nil:
+		return false, nil
+	}
+	return matches[0].GetState()
+}
+
 func (sw *SwKit) MatchControllers() error {
+	return sw.matchControllers(true)
+}
+
+// matchControllers finds buttons, switches and motion sensors controlling devices, they are linked only when link
+// is set (otherwise matching is only checked, see Reload).
+func (sw *SwKit) matchControllers(link bool) error {
 	controllables := []Controllable{}
 
 	for _, li := range sw.Lights {
 		controllables = append(controllables, li)
 	}
 
+	for _, cl := range sw.ColorLights {
+		controllables = append(controllables, cl)
+	}
+
 	for _, ou := range sw.Outlets {
 		controllables = append(controllables, ou)
 	}
 
+	for _, shu := range sw.Shutters {
+		controllables = append(controllables, shu.getControls()...)
+	}
+
+	for _, gd := range sw.GarageDoors {
+		controllables = append(controllables, gd)
+	}
+
 	for _, controllable := range controllables {
 		driverName := controllable.GetDriverName()
 		for _, controller := range controllable.GetControllers() {
@@ -345,12 +660,22 @@ func (sw *SwKit) MatchControllers() error {
 				return errors.Errorf("matching controlled failed, driver (%s) not present or not ready", driverName)
 			}
 
-			log.Println("| match ctrl | got controller driver: ", controller.DriverName, " pin: ", controller.Pin, " event: ", controller.Event)
-
 			swb := sw.findSwitch(controller.Pin, driverName)
 			but := sw.findButton(controller.Pin, driverName)
-			if swb == nil && but == nil {
-				return errors.Errorf("matching controlled failed, no button or switch found with pin = %d and driver %s", controller.Pin, driverName)
+			ms := sw.findMotionSensor(controller.Pin, driverName)
+			if swb == nil && but == nil && ms == nil {
+				return errors.Errorf("matching controlled failed, no button, switch or motion sensor found with pin = %d and driver %s", controller.Pin, driverName)
+			}
+			if !link {
+				continue
+			}
+
+			log.Println("| match ctrl | got controller driver: ", controller.DriverName, " pin: ", controller.Pin, " event: ", controller.Event)
+
+			if ms != nil {
+				ms.addControlled(controllable)
+
+				log.Println("| match ctrl | matched to motion sensor (driver: ", ms.DriverName, " pin: ", ms.InPin, ")")
 			}
 
 			if swb != nil {
@@ -368,6 +693,12 @@ func (sw *SwKit) MatchControllers() error {
 				toggleMap = append(toggleMap, controllable)
 				but.toggleMap[event] = toggleMap
 
+				// dimmable device toggled by single press is ramped by long press of the same button
+				rampable, ok := controllable.(RampableDevice)
+				if ok && rampable.IsRampable() && event == drivers.PushEventSinglePress {
+					but.rampSlice = append(but.rampSlice, rampable)
+				}
+
 				log.Println("| match ctrl | matched to button (driver: ", but.DriverName, " pin: ", but.InPin, ")")
 			}
 		}
@@ -393,7 +724,18 @@ func (sw *SwKit) GetHkAccessories(firmwareVersion string) (acc []*accessory.A) {
 	return
 }
 
-func (sw *SwKit) getSensorDriverByName(name string) (driver drivers.SensorDriver, err error) {
+// getSensorDriverByName returns configured sensor driver, or registers new mock in its place (see UseMockDrivers).
+func (sw *SwKit) getSensorDriverByName(name string) (drivers.SensorDriver, error) {
+	if sw.mockSensorDrivers != nil {
+		msd := &drivers.MockSensorDriver{DriverName: name}
+		sw.mockSensorDrivers[name] = msd
+		return msd, nil
+	}
+
+	return sw.getConfiguredSensorDriver(name)
+}
+
+func (sw *SwKit) getConfiguredSensorDriver(name string) (driver drivers.SensorDriver, err error) {
 	switch name {
 	case "wire":
 		if sw.WireSensors == nil {
@@ -407,6 +749,12 @@ func (sw *SwKit) getSensorDriverByName(name string) (driver drivers.SensorDriver
 		} else {
 			driver = sw.InfluxSensors
 		}
+	case "modbus":
+		if sw.Modbus == nil {
+			err = errors.Errorf("cannot get modbus sensor driver, it is not configured")
+		} else {
+			driver = sw.Modbus.GetSensorDriver()
+		}
 	default:
 		err = errors.Errorf("sensor driver (%s) not found", name)
 	}
@@ -414,25 +762,57 @@ func (sw *SwKit) getSensorDriverByName(name string) (driver drivers.SensorDriver
 	return
 }
 
-func (sw *SwKit) findTemperatureSensor(id string) (temp drivers.TemperatureSensor, err error) {
+func (sw *SwKit) findSensor(id string, measurement drivers.Measurement) (sensor drivers.Sensor, err error) {
 
 	for _, driver := range sw.sensorDrivers {
-		temp, err = driver.FindTemperatureSensor(id)
+		sensor, err = driver.FindSensor(id)
 		if err == nil {
+			if sensor.GetMeasurement() != measurement {
+				err = errors.Errorf("sensor id = %s measures %s, not %s", id, sensor.GetMeasurement(), measurement)
+			}
 			return
 		}
 	}
-	err = errors.Wrapf(err, "temperature sensor id = %s not found", id)
+	err = errors.Wrapf(err, "%s sensor id = %s not found", measurement, id)
 	return
 }
 
 func (sw *SwKit) MatchSensors() error {
+	return sw.matchSensors(true)
+}
+
+// matchSensors finds sensors used by thermostats and motion sensors, they are linked only when link is set.
+func (sw *SwKit) matchSensors(link bool) error {
 	for _, thermo := range sw.Thermostats {
-		thermoFound, err := sw.findTemperatureSensor(thermo.SensorId)
+		thermoFound, err := sw.findSensor(thermo.SensorId, drivers.MeasurementTemperature)
 		if err != nil {
 			return errors.Wrap(err, "MatchSensors failed")
 		}
-		thermo.temperatureSensor = thermoFound
+
+		var humidityFound drivers.Sensor
+		if len(thermo.HumiditySensorId) > 0 {
+			humidityFound, err = sw.findSensor(thermo.HumiditySensorId, drivers.MeasurementHumidity)
+			if err != nil {
+				return errors.Wrap(err, "MatchSensors failed")
+			}
+		}
+		if link {
+			thermo.temperatureSensor = thermoFound
+			thermo.humiditySensor = humidityFound
+		}
+	}
+	for _, ms := range sw.MotionSensors {
+		var luxFound drivers.Sensor
+		if len(ms.LuxSensorId) > 0 {
+			var err error
+			luxFound, err = sw.findSensor(ms.LuxSensorId, drivers.MeasurementIlluminance)
+			if err != nil {
+				return errors.Wrap(err, "MatchSensors failed")
+			}
+		}
+		if link {
+			ms.luxSensor = luxFound
+		}
 	}
 	return nil
 }
@@ -445,18 +825,23 @@ func (sw *SwKit) StartTicker(interval time.Duration) {
 		select {
 		case <-sw.ticker.C:
 			{
+				sw.reloadLock.RLock()
 				for _, io := range sw.getIos() {
 					err := io.Sync()
 					if err != nil {
 						log.Printf("Received error(s) from syncing io:\n%v", err)
 					}
 				}
+				sw.reloadLock.RUnlock()
 			}
 		}
 	}
 }
 
 func (sw *SwKit) syncSensorDriversAndSensors() {
+	sw.reloadLock.RLock()
+	defer sw.reloadLock.RUnlock()
+
 	for sDName, sD := range sw.sensorDrivers {
 		err := sD.Sync()
 		if err != nil {
@@ -539,7 +924,12 @@ func (sw *SwKit) PrintIoStatus(writer io.Writer) {
 	fmt.Fprintln(writer)
 }
 
-func (sw *SwKit) StartHomeKit(ctx context.Context, firmwareVersion string) error {
+// newHomeKitServer creates HomeKit server with all accessories, store in HkDirectory keeps pairing
+// so server can be created again (see Reload).
+func (sw *SwKit) newHomeKitServer(firmwareVersion string) (*hap.Server, error) {
+	sw.reloadLock.RLock()
+	defer sw.reloadLock.RUnlock()
+
 	hkName := sw.Name
 	if len(hkName) < 1 {
 		hkName = homeKitBridgeName
@@ -550,27 +940,39 @@ func (sw *SwKit) StartHomeKit(ctx context.Context, firmwareVersion string) error
 		Firmware:     firmwareVersion,
 	})
 
-	var store hap.Store
-	if len(sw.HkDirectory) > 1 {
-		store = hap.NewFsStore(sw.HkDirectory)
-	} else {
-		store = hap.NewFsStore(defaultHomeKitDirectory)
-	}
+	store := hap.NewFsStore(sw.getHkDirectory())
 	hkServer, err := hap.NewServer(store, bridge.A, sw.GetHkAccessories(firmwareVersion)...)
 	if err != nil {
-		return errors.Wrap(err, "failed to create HomeKit server")
+		return nil, errors.Wrap(err, "failed to create HomeKit server")
 	}
 	hkServer.Pin = sw.HkPin
 	if len(sw.HkAddress) > 0 {
 		hkServer.Addr = sw.HkAddress
 	}
 
+	return hkServer, nil
+}
+
+// restartHomeKit stops running HomeKit server, StartHomeKit creates it again with current accessories.
+func (sw *SwKit) restartHomeKit() bool {
+	sw.hkLock.Lock()
+	defer sw.hkLock.Unlock()
+
+	if sw.hkStop == nil {
+		return false
+	}
+	sw.hkRestart = true
+	sw.hkStop()
+	return true
+}
+
+func (sw *SwKit) StartHomeKit(ctx context.Context, firmwareVersion string) error {
 	if sw.HkDebug {
 		hklog.Debug.Enable()
 		dnslog.Debug.Enable()
 	}
 
-	c := make(chan os.Signal)
+	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt)
 	signal.Notify(c, syscall.SIGTERM)
 
@@ -583,5 +985,28 @@ func (sw *SwKit) StartHomeKit(ctx context.Context, firmwareVersion string) error
 		cancel()
 	}()
 
-	return hkServer.ListenAndServe(ctx)
+	for {
+		hkServer, err := sw.newHomeKitServer(firmwareVersion)
+		if err != nil {
+			return err
+		}
+
+		serverCtx, stop := context.WithCancel(ctx)
+		sw.hkLock.Lock()
+		sw.hkStop = stop
+		sw.hkRestart = false
+		sw.hkLock.Unlock()
+
+		err = hkServer.ListenAndServe(serverCtx)
+		stop()
+
+		sw.hkLock.Lock()
+		restart := sw.hkRestart && ctx.Err() == nil
+		sw.hkStop = nil
+		sw.hkLock.Unlock()
+		if !restart {
+			return err
+		}
+		log.Println("restarting HomeKit server with reloaded accessories")
+	}
 }
@@ -0,0 +1,123 @@
+package swkit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	drivers "github.com/hubertat/swkit/drivers"
+)
+
+// newMockKit returns SwKit set by configure with all drivers (but virtual) mocked, initialized and matched.
+func newMockKit(t *testing.T, configure func(*SwKit)) *SwKit {
+	t.Helper()
+
+	sw := &SwKit{}
+	configure(sw)
+	sw.UseMockDrivers()
+	err := sw.InitDrivers(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sw.Close() })
+	for _, step := range []func() error{sw.InitIos, sw.InitSensors, sw.MatchControllers, sw.MatchSensors} {
+		err = step()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return sw
+}
+
+func TestVirtualDriverExpressions(t *testing.T) {
+	sw := &SwKit{
+		Switches: []*Switch{
+			{Name: "Alarm", DriverName: "virtual", InPin: 10},
+		},
+		Lights: []*Light{
+			{Name: "Away", DriverName: "mock_driver", OutPin: 2},
+		},
+		Outlets: []*Outlet{
+			{Name: "Away", DriverName: "virtual", OutPin: 1},
+			{Name: "Guest mode", DriverName: "virtual", OutPin: 2},
+		},
+		FakeDriver: &drivers.MockIoDriver{},
+		Virtual: &drivers.VirtualIO{
+			EvalInterval: "10ms",
+			Outputs:      []*drivers.VirtualOutput{{Pin: 1}, {Pin: 2}},
+			Inputs:       []*drivers.VirtualInput{{Pin: 10, Expression: `outlet:Away && !"Guest mode"`}},
+		},
+	}
+
+	_, err := sw.getAccessoryState("Away")
+	assertBools(t, err != nil, true)
+	_, err = sw.getAccessoryState("Door")
+	assertBools(t, err != nil, true)
+
+	err = sw.InitDrivers(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sw.Close()
+	err = sw.InitIos()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alarm := sw.Switches[0]
+	alarm.Sync()
+	assertBools(t, alarm.State, false)
+
+	sw.Outlets[0].SetValue(true)
+	time.Sleep(50 * time.Millisecond)
+	alarm.Sync()
+	assertBools(t, alarm.State, true)
+
+	sw.Outlets[1].SetValue(true)
+	time.Sleep(50 * time.Millisecond)
+	alarm.Sync()
+	assertBools(t, alarm.State, false)
+}
+
+func TestMatchControllersWithMockDriver(t *testing.T) {
+	md := &drivers.MockIoDriver{}
+	sw := &SwKit{
+		Buttons:  []*Button{{Name: "Hall button", DriverName: "mock_driver", InPin: 1}},
+		Switches: []*Switch{{Name: "Kitchen switch", DriverName: "mock_driver", InPin: 2}},
+		Lights: []*Light{
+			{Name: "Hall", DriverName: "mock_driver", OutPin: 10, ControlBy: []ControllingDevice{{Pin: 1, Event: int(drivers.PushEventDoublePress)}}},
+			{Name: "Kitchen", DriverName: "mock_driver", OutPin: 11, ControlBy: []ControllingDevice{{Pin: 2}}},
+		},
+		FakeDriver: md,
+	}
+
+	err := sw.InitDrivers(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sw.Close()
+	err = sw.InitIos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sw.MatchControllers()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md.Push(1, drivers.PushEventSinglePress)
+	assertInts(t, len(md.Writes()), 0)
+	md.Push(1, drivers.PushEventDoublePress)
+	md.SetInput(2, true)
+	sw.Switches[0].Sync()
+
+	writes := md.Writes()
+	assertInts(t, len(writes), 2)
+	assertBools(t, writes[0].Pin == 10 && writes[0].State, true)
+	assertBools(t, writes[1].Pin == 11 && writes[1].State, true)
+
+	md.FailOutput(11, errors.New("relay failure"))
+	err = sw.Lights[1].Sync()
+	assertBools(t, err != nil && sw.Lights[1].IsFaulty, true)
+}
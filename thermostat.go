@@ -2,7 +2,6 @@ package swkit
 
 import (
 	"fmt"
-	"hash/fnv"
 	"strings"
 	"sync"
 
@@ -20,6 +19,7 @@ const defaultThermostatThreshold = float64(0.4)
 
 type Thermostat struct {
 	Name               string
+	Id                 string
 	CurrentTemperature float64
 	TargetTemperature  float64
 	TargetState        int
@@ -30,6 +30,8 @@ type Thermostat struct {
 	CoolPin    uint16
 	SensorId   string
 
+	HumiditySensorId string // optional, current humidity shown in HomeKit
+
 	MinimumTemperature float64
 	MaximumTemperature float64
 	StepTemperature    float64
@@ -43,7 +45,11 @@ type Thermostat struct {
 	hk                *accessory.Thermostat
 	hkFaultStatus     *characteristic.StatusFault
 	lock              sync.Mutex
-	temperatureSensor drivers.TemperatureSensor
+	temperatureSensor drivers.Sensor
+	humiditySensor    drivers.Sensor
+	hkHumidity        *characteristic.CurrentRelativeHumidity
+
+	uniqueId uint64
 }
 
 func (th *Thermostat) GetDriverName() string {
@@ -51,9 +57,22 @@ func (th *Thermostat) GetDriverName() string {
 }
 
 func (th *Thermostat) GetUniqueId() uint64 {
-	hash := fnv.New64()
-	hash.Write([]byte("Thermostat_" + th.Name))
-	return hash.Sum64()
+	if th.uniqueId != 0 {
+		return th.uniqueId
+	}
+	return th.identity().hash()
+}
+
+func (th *Thermostat) identity() accessoryIdentity {
+	return accessoryIdentity{Kind: "Thermostat", Id: th.Id, Name: th.Name, Serial: th.serialNumber()}
+}
+
+func (th *Thermostat) serialNumber() string {
+	return fmt.Sprintf("thermostat:%s:%02d", th.DriverName, th.HeatPin)
+}
+
+func (th *Thermostat) setUniqueId(id uint64) {
+	th.uniqueId = id
 }
 
 func (th *Thermostat) Init(driver drivers.IoDriver) error {
@@ -99,12 +118,17 @@ func (th *Thermostat) Init(driver drivers.IoDriver) error {
 
 	info := accessory.Info{
 		Name:         th.Name,
-		SerialNumber: fmt.Sprintf("thermostat:%s:%02d", th.DriverName, th.HeatPin),
+		SerialNumber: th.serialNumber(),
 	}
 
 	th.hk = accessory.NewThermostat(info)
 	th.hkFaultStatus = characteristic.NewStatusFault()
 	th.hk.Thermostat.AddC(th.hkFaultStatus.C)
+	th.hkHumidity = nil
+	if len(th.HumiditySensorId) > 0 {
+		th.hkHumidity = characteristic.NewCurrentRelativeHumidity()
+		th.hk.Thermostat.AddC(th.hkHumidity.C)
+	}
 
 	th.hk.Thermostat.TargetHeatingCoolingState.OnValueRemoteUpdate(th.updateTargetState)
 	th.hk.Thermostat.TargetTemperature.OnValueRemoteUpdate(th.updateTargetTemperature)
@@ -184,6 +208,12 @@ func (th *Thermostat) Sync() (err error) {
 	th.hk.Thermostat.TargetTemperature.SetValue(th.TargetTemperature)
 	th.hk.Thermostat.CurrentHeatingCoolingState.SetValue(th.getCurrentHeatingCoolingState())
 	th.hk.Thermostat.TargetHeatingCoolingState.SetValue(th.TargetState)
+	if th.hkHumidity != nil && th.humiditySensor != nil {
+		humidity, humidityErr := th.humiditySensor.GetValue()
+		if humidityErr == nil {
+			th.hkHumidity.SetValue(humidity)
+		}
+	}
 
 	th.updateHomekitFaultStatus(err)
 	return
@@ -1,6 +1,7 @@
 package swkit
 
 import (
+	"context"
 	"testing"
 
 	drivers "github.com/hubertat/swkit/drivers"
@@ -43,7 +44,7 @@ func TestThremostatInit(t *testing.T) {
 		t.Error("got nil error when Init with not ready driver")
 	}
 
-	md.Setup([]uint16{}, []uint16{5})
+	md.Setup(context.Background(), []uint16{}, []uint16{5})
 
 	err = thermo.Init(&md)
 	if err != nil {
@@ -76,7 +77,7 @@ func TestCheckHeatingCondition(t *testing.T) {
 	thermo.HeatPin = uint16(3)
 
 	md := drivers.MockIoDriver{}
-	md.Setup([]uint16{}, []uint16{3})
+	md.Setup(context.Background(), []uint16{}, []uint16{3})
 	thermo.Init(&md)
 
 	heatOut, _ := md.GetOutput(3)
@@ -121,7 +122,7 @@ func TestGetCurrentHeatingCoolingState(t *testing.T) {
 	thermo.CoolingEnabled = true
 
 	md := drivers.MockIoDriver{}
-	md.Setup([]uint16{}, []uint16{3, 5})
+	md.Setup(context.Background(), []uint16{}, []uint16{3, 5})
 	thermo.Init(&md)
 
 	heatOut, _ := md.GetOutput(3)
@@ -160,7 +161,7 @@ func TestCalculateOutputs(t *testing.T) {
 	thermo.CoolingEnabled = true
 
 	md := drivers.MockIoDriver{}
-	md.Setup([]uint16{}, []uint16{3, 5})
+	md.Setup(context.Background(), []uint16{}, []uint16{3, 5})
 	thermo.Init(&md)
 
 	heatOut, _ := md.GetOutput(3)
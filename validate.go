@@ -0,0 +1,526 @@
+package swkit
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brutella/hap"
+	drivers "github.com/hubertat/swkit/drivers"
+	"github.com/pkg/errors"
+)
+
+// ConfigError lists all problems found by Validate.
+type ConfigError struct {
+	Problems []string
+}
+
+func (ce *ConfigError) Error() string {
+	return fmt.Sprintf("%d configuration problem(s):\n\t%s", len(ce.Problems), strings.Join(ce.Problems, "\n\t"))
+}
+
+func (ce *ConfigError) add(format string, args ...interface{}) {
+	ce.Problems = append(ce.Problems, fmt.Sprintf(format, args...))
+}
+
+// jsonPosition returns line and column of byte offset in data.
+func jsonPosition(data []byte, offset int64) (line int, column int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	column = int(offset) - bytes.LastIndexByte(before, '\n')
+	return
+}
+
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// jsonFields returns exported fields of struct type as decoded by encoding/json (promoted fields included).
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			for name, fieldType := range jsonFields(field.Type) {
+				fields[name] = fieldType
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		name := field.Name
+		tag, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if tag == "-" {
+			continue
+		}
+		if len(tag) > 0 {
+			name = tag
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+// findUnknownFields walks decoded json value along type t and reports keys which would be silently ignored.
+func findUnknownFields(value interface{}, t reflect.Type, path string, problems *ConfigError) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if reflect.PointerTo(t).Implements(jsonUnmarshalerType) || reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return
+	}
+
+	switch node := value.(type) {
+	case map[string]interface{}:
+		switch t.Kind() {
+		case reflect.Struct:
+			fields := jsonFields(t)
+			keys := make([]string, 0, len(node))
+			for key := range node {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				fieldType, found := fields[key]
+				if !found {
+					for name, ft := range fields {
+						if strings.EqualFold(name, key) {
+							fieldType, found = ft, true
+							break
+						}
+					}
+				}
+				if !found {
+					problems.add("%s: unknown field %q", strings.TrimPrefix(path+"."+key, "."), key)
+					continue
+				}
+				findUnknownFields(node[key], fieldType, path+"."+key, problems)
+			}
+		case reflect.Map:
+			for key, item := range node {
+				findUnknownFields(item, t.Elem(), fmt.Sprintf("%s[%q]", path, key), problems)
+			}
+		}
+	case []interface{}:
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			for ix, item := range node {
+				findUnknownFields(item, t.Elem(), fmt.Sprintf("%s[%d]", path, ix), problems)
+			}
+		}
+	}
+}
+
+// ValidateConfig decodes json configuration and checks it with Validate, unknown fields are reported too.
+// Syntax and type errors are reported with line and column.
+func ValidateConfig(data []byte) (*SwKit, error) {
+	return validateConfig(data, true)
+}
+
+// ValidateConfigFile reads configuration file (see ReadConfig) and checks it as ValidateConfig does.
+// The file is converted to json first (yaml, toml, includes), so type errors are reported by field path only,
+// syntax errors are reported by the parser of the file format.
+func ValidateConfigFile(path string) (*SwKit, error) {
+	data, err := ReadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return validateConfig(data, false)
+}
+
+// validateConfig checks json configuration, positions are reported only when data is the source file.
+func validateConfig(data []byte, positions bool) (*SwKit, error) {
+	sw := &SwKit{}
+	err := json.Unmarshal(data, sw)
+	if err != nil {
+		switch jsonErr := err.(type) {
+		case *json.SyntaxError:
+			if !positions {
+				return nil, errors.Wrap(err, "invalid json")
+			}
+			line, column := jsonPosition(data, jsonErr.Offset)
+			return nil, errors.Errorf("invalid json at line %d, column %d: %v", line, column, err)
+		case *json.UnmarshalTypeError:
+			if !positions {
+				return nil, errors.Errorf("invalid value of %s: expected %s, got %s", jsonErr.Field, jsonErr.Type, jsonErr.Value)
+			}
+			line, column := jsonPosition(data, jsonErr.Offset)
+			return nil, errors.Errorf("invalid value of %s at line %d, column %d: expected %s, got json %s", jsonErr.Field, line, column, jsonErr.Type, jsonErr.Value)
+		}
+		return nil, err
+	}
+
+	problems := &ConfigError{}
+	var raw interface{}
+	json.Unmarshal(data, &raw)
+	findUnknownFields(raw, reflect.TypeOf(sw), "", problems)
+
+	err = sw.Validate()
+	if configErr, ok := err.(*ConfigError); ok {
+		problems.Problems = append(problems.Problems, configErr.Problems...)
+	}
+	if len(problems.Problems) > 0 {
+		return sw, problems
+	}
+	return sw, nil
+}
+
+type pinUsage struct {
+	what   string
+	driver string
+	pin    uint16
+}
+
+// Validate checks configuration without touching hardware: drivers of all accessories are configured,
+// names are unique per kind (they are used for unique ids), pins are not used twice per driver, ControlBy
+// and thermostat SensorId references exist and HomeKit pin is valid. Returns *ConfigError.
+func (sw *SwKit) Validate() error {
+	problems := &ConfigError{}
+
+	sw.validateNames(problems)
+	sw.validateUniqueIds(problems)
+	sw.validateDrivers(problems)
+	sw.validatePins(problems)
+
+	for _, li := range sw.Lights {
+		sw.validateControllers(fmt.Sprintf("light %q", li.Name), li, problems)
+		if _, err := time.ParseDuration(li.FadeDuration); len(li.FadeDuration) > 0 && err != nil {
+			problems.add("light %q: invalid FadeDuration %q", li.Name, li.FadeDuration)
+		}
+		if _, err := time.ParseDuration(li.RampDuration); len(li.RampDuration) > 0 && err != nil {
+			problems.add("light %q: invalid RampDuration %q", li.Name, li.RampDuration)
+		}
+	}
+	for _, cl := range sw.ColorLights {
+		sw.validateControllers(fmt.Sprintf("color light %q", cl.Name), cl, problems)
+		if _, err := time.ParseDuration(cl.FadeDuration); len(cl.FadeDuration) > 0 && err != nil {
+			problems.add("color light %q: invalid FadeDuration %q", cl.Name, cl.FadeDuration)
+		}
+		if len(cl.ChannelPins) > 0 && (len(cl.ChannelPins) < 3 || len(cl.ChannelPins) > 4) {
+			problems.add("color light %q: ChannelPins needs 3 (rgb) or 4 (rgbw) pins, got %d", cl.Name, len(cl.ChannelPins))
+		}
+	}
+	for _, ou := range sw.Outlets {
+		sw.validateControllers(fmt.Sprintf("outlet %q", ou.Name), ou, problems)
+	}
+	for _, shu := range sw.Shutters {
+		controls := shu.getControls()
+		sw.validateControllers(fmt.Sprintf("shutter %q up", shu.Name), controls[0], problems)
+		sw.validateControllers(fmt.Sprintf("shutter %q down", shu.Name), controls[2], problems)
+		if len(shu.Wiring) > 0 && !strings.EqualFold(shu.Wiring, shutterWiringUpDown) && !shu.isOnDirection() {
+			problems.add("shutter %q: unknown Wiring %q", shu.Name, shu.Wiring)
+		}
+		durations := []struct{ field, value string }{
+			{"MovementDuration", shu.MovementDuration},
+			{"UpDuration", shu.UpDuration},
+			{"DownDuration", shu.DownDuration},
+			{"DeadTime", shu.DeadTime},
+			{"Overrun", shu.Overrun},
+			{"TiltDuration", shu.TiltDuration},
+		}
+		for _, duration := range durations {
+			if _, err := time.ParseDuration(duration.value); len(duration.value) > 0 && err != nil {
+				problems.add("shutter %q: invalid %s %q", shu.Name, duration.field, duration.value)
+			}
+		}
+	}
+	for _, bs := range sw.BinarySensors {
+		if _, known := binarySensorDeviceClasses[bs.getKind()]; !known {
+			problems.add("binary sensor %q: unknown Kind %q", bs.Name, bs.Kind)
+		}
+		if _, err := time.ParseDuration(bs.DebounceDuration); len(bs.DebounceDuration) > 0 && err != nil {
+			problems.add("binary sensor %q: invalid DebounceDuration %q", bs.Name, bs.DebounceDuration)
+		}
+	}
+	for _, gd := range sw.GarageDoors {
+		sw.validateControllers(fmt.Sprintf("garage door %q", gd.Name), gd, problems)
+		if _, err := time.ParseDuration(gd.PulseDuration); len(gd.PulseDuration) > 0 && err != nil {
+			problems.add("garage door %q: invalid PulseDuration %q", gd.Name, gd.PulseDuration)
+		}
+		if _, err := time.ParseDuration(gd.TravelDuration); len(gd.TravelDuration) > 0 && err != nil {
+			problems.add("garage door %q: invalid TravelDuration %q", gd.Name, gd.TravelDuration)
+		}
+	}
+
+	for _, th := range sw.Thermostats {
+		sw.validateSensorId(fmt.Sprintf("thermostat %q", th.Name), th.SensorId, drivers.MeasurementTemperature, problems)
+		if len(th.HumiditySensorId) > 0 {
+			sw.validateSensorId(fmt.Sprintf("thermostat %q", th.Name), th.HumiditySensorId, drivers.MeasurementHumidity, problems)
+		}
+	}
+	for _, ms := range sw.MotionSensors {
+		durations := []struct{ field, value string }{
+			{"HoldTime", ms.HoldTime},
+			{"OnTime", ms.OnTime},
+			{"OverrideTime", ms.OverrideTime},
+		}
+		for _, duration := range durations {
+			if _, err := time.ParseDuration(duration.value); len(duration.value) > 0 && err != nil {
+				problems.add("motion sensor %q: invalid %s %q", ms.Name, duration.field, duration.value)
+			}
+		}
+		if len(ms.LuxSensorId) > 0 {
+			sw.validateSensorId(fmt.Sprintf("motion sensor %q", ms.Name), ms.LuxSensorId, drivers.MeasurementIlluminance, problems)
+		}
+	}
+
+	if len(sw.HkPin) > 0 {
+		valid := len(sw.HkPin) == 8 && strings.Trim(sw.HkPin, "0123456789") == ""
+		if !valid {
+			problems.add("HkPin: must be 8 digits, HomeKit would be disabled")
+		} else if hap.InvalidPins[sw.HkPin] {
+			problems.add("HkPin: %s is too trivial, it is rejected by HomeKit", sw.HkPin)
+		}
+	}
+
+	if len(problems.Problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+func (sw *SwKit) validateNames(problems *ConfigError) {
+	check := func(kind string, names []string) {
+		seen := map[string]int{}
+		for ix, name := range names {
+			if len(name) == 0 {
+				problems.add("%s[%d]: missing Name", kind, ix)
+				continue
+			}
+			if first, duplicate := seen[name]; duplicate {
+				problems.add("%s[%d]: duplicate name %q (same as %s[%d]), unique ids would collide", kind, ix, name, kind, first)
+				continue
+			}
+			seen[name] = ix
+		}
+	}
+
+	names := []string{}
+	for _, li := range sw.Lights {
+		names = append(names, li.Name)
+	}
+	check("Lights", names)
+	names = []string{}
+	for _, cl := range sw.ColorLights {
+		names = append(names, cl.Name)
+	}
+	check("ColorLights", names)
+	names = []string{}
+	for _, bu := range sw.Buttons {
+		names = append(names, bu.Name)
+	}
+	check("Buttons", names)
+	names = []string{}
+	for _, swb := range sw.Switches {
+		names = append(names, swb.Name)
+	}
+	check("Switches", names)
+	names = []string{}
+	for _, ou := range sw.Outlets {
+		names = append(names, ou.Name)
+	}
+	check("Outlets", names)
+	names = []string{}
+	for _, th := range sw.Thermostats {
+		names = append(names, th.Name)
+	}
+	check("Thermostats", names)
+	names = []string{}
+	for _, ms := range sw.MotionSensors {
+		names = append(names, ms.Name)
+	}
+	check("MotionSensors", names)
+	names = []string{}
+	for _, bs := range sw.BinarySensors {
+		names = append(names, bs.Name)
+	}
+	check("BinarySensors", names)
+	names = []string{}
+	for ix, ts := range sw.TemperatureSensors {
+		names = append(names, ts.Name)
+		if len(ts.Name) == 1 {
+			problems.add("TemperatureSensors[%d]: name %q is too short", ix, ts.Name)
+		}
+	}
+	check("TemperatureSensors", names)
+	names = []string{}
+	for ix, ts := range sw.Sensors {
+		names = append(names, ts.Name)
+		if len(ts.Name) == 1 {
+			problems.add("Sensors[%d]: name %q is too short", ix, ts.Name)
+		}
+		if _, err := drivers.ParseMeasurement(ts.Measurement); err != nil {
+			problems.add("sensor %q: %v", ts.Name, err)
+		}
+	}
+	check("Sensors", names)
+	names = []string{}
+	for _, shu := range sw.Shutters {
+		names = append(names, shu.Name)
+	}
+	check("Shutters", names)
+	names = []string{}
+	for _, gd := range sw.GarageDoors {
+		names = append(names, gd.Name)
+	}
+	check("GarageDoors", names)
+}
+
+// validateUniqueIds checks explicit Ids are unique per kind and default unique ids (hashes) do not collide.
+func (sw *SwKit) validateUniqueIds(problems *ConfigError) {
+	ids := map[string]accessoryIdentity{}
+	hashes := map[uint64]accessoryIdentity{}
+	for _, thing := range sw.getIdentifiables() {
+		identity := thing.identity()
+		if len(identity.Id) > 0 {
+			key := identity.Kind + "#" + identity.Id
+			if other, duplicate := ids[key]; duplicate {
+				problems.add("%s: duplicate Id %q (same as %s)", identity, identity.Id, other)
+				continue
+			}
+			ids[key] = identity
+		}
+
+		hash := identity.hash()
+		other, collides := hashes[hash]
+		if collides && (other.Kind != identity.Kind || other.Name != identity.Name) {
+			problems.add("%s: unique id collides with %s, set different Id", identity, other)
+		}
+		hashes[hash] = identity
+	}
+}
+
+func (sw *SwKit) validateDrivers(problems *ConfigError) {
+	for _, name := range sw.getIoDriverNames() {
+		if len(name) == 0 {
+			problems.add("accessory without DriverName")
+			continue
+		}
+		if sw.isMockedIoDriver(name) {
+			continue
+		}
+		_, err := sw.getConfiguredIoDriver(name)
+		if err != nil {
+			problems.add("io driver %q: %v", name, err)
+		}
+	}
+
+	checked := map[string]bool{}
+	for _, s := range sw.getSensors() {
+		name := s.GetDriverName()
+		if checked[name] || sw.mockSensorDrivers != nil {
+			continue
+		}
+		checked[name] = true
+		_, err := sw.getConfiguredSensorDriver(name)
+		if err != nil {
+			problems.add("sensor driver %q: %v", name, err)
+		}
+	}
+}
+
+func (sw *SwKit) validatePins(problems *ConfigError) {
+	inputs := []pinUsage{}
+	for _, bu := range sw.Buttons {
+		inputs = append(inputs, pinUsage{fmt.Sprintf("button %q", bu.Name), bu.DriverName, bu.InPin})
+	}
+	for _, swb := range sw.Switches {
+		inputs = append(inputs, pinUsage{fmt.Sprintf("switch %q", swb.Name), swb.DriverName, swb.InPin})
+	}
+	for _, ms := range sw.MotionSensors {
+		inputs = append(inputs, pinUsage{fmt.Sprintf("motion sensor %q", ms.Name), ms.DriverName, ms.InPin})
+	}
+	for _, bs := range sw.BinarySensors {
+		for _, pin := range bs.getInPins() {
+			inputs = append(inputs, pinUsage{fmt.Sprintf("binary sensor %q", bs.Name), bs.DriverName, pin})
+		}
+	}
+	for _, gd := range sw.GarageDoors {
+		for _, pin := range gd.getSensorPins() {
+			inputs = append(inputs, pinUsage{fmt.Sprintf("garage door %q", gd.Name), gd.GetSensorDriverName(), pin})
+		}
+	}
+
+	outputs := []pinUsage{}
+	for _, li := range sw.Lights {
+		outputs = append(outputs, pinUsage{fmt.Sprintf("light %q", li.Name), li.DriverName, li.OutPin})
+	}
+	for _, cl := range sw.ColorLights {
+		for _, pin := range cl.getPins() {
+			outputs = append(outputs, pinUsage{fmt.Sprintf("color light %q", cl.Name), cl.DriverName, pin})
+		}
+	}
+	for _, ou := range sw.Outlets {
+		outputs = append(outputs, pinUsage{fmt.Sprintf("outlet %q", ou.Name), ou.DriverName, ou.OutPin})
+	}
+	for _, shu := range sw.Shutters {
+		for _, pin := range shu.getPins() {
+			outputs = append(outputs, pinUsage{fmt.Sprintf("shutter %q", shu.Name), shu.DriverName, pin})
+		}
+	}
+	for _, gd := range sw.GarageDoors {
+		outputs = append(outputs, pinUsage{fmt.Sprintf("garage door %q", gd.Name), gd.DriverName, gd.OutPin})
+	}
+	for _, th := range sw.Thermostats {
+		outputs = append(outputs, pinUsage{fmt.Sprintf("thermostat %q heating", th.Name), th.DriverName, th.HeatPin})
+		if th.CoolingEnabled {
+			outputs = append(outputs, pinUsage{fmt.Sprintf("thermostat %q cooling", th.Name), th.DriverName, th.CoolPin})
+		}
+	}
+
+	findDuplicates := func(usages []pinUsage, kind string) map[string]pinUsage {
+		used := map[string]pinUsage{}
+		for _, usage := range usages {
+			key := fmt.Sprintf("%s:%d", strings.ToLower(usage.driver), usage.pin)
+			if first, duplicate := used[key]; duplicate {
+				problems.add("%s: %s pin %d of driver %s is already used by %s", usage.what, kind, usage.pin, usage.driver, first.what)
+				continue
+			}
+			used[key] = usage
+		}
+		return used
+	}
+	usedInputs := findDuplicates(inputs, "input")
+	findDuplicates(outputs, "output")
+
+	reported := map[string]bool{}
+	for _, output := range outputs {
+		key := fmt.Sprintf("%s:%d", strings.ToLower(output.driver), output.pin)
+		if input, used := usedInputs[key]; used && !reported[key] {
+			reported[key] = true
+			problems.add("%s: pin %d of driver %s is used as output and as input by %s", output.what, output.pin, output.driver, input.what)
+		}
+	}
+}
+
+// validateSensorId checks sensor with id exists and measures measurement.
+func (sw *SwKit) validateSensorId(what string, id string, measurement drivers.Measurement, problems *ConfigError) {
+	for _, ts := range sw.getMeasurementSensors() {
+		if strings.EqualFold(ts.Id, id) {
+			if ts.GetMeasurement() != measurement {
+				problems.add("%s: sensor with Id %q measures %s, not %s", what, id, ts.GetMeasurement(), measurement)
+			}
+			return
+		}
+	}
+	problems.add("%s: %s sensor with Id %q not found", what, measurement, id)
+}
+
+func (sw *SwKit) validateControllers(what string, controllable Controllable, problems *ConfigError) {
+	for _, controller := range controllable.GetControllers() {
+		driverName := controllable.GetDriverName()
+		if len(controller.DriverName) > 0 {
+			driverName = controller.DriverName
+		}
+		if sw.findSwitch(controller.Pin, driverName) == nil && sw.findButton(controller.Pin, driverName) == nil && sw.findMotionSensor(controller.Pin, driverName) == nil {
+			problems.add("%s: ControlBy pin %d of driver %s, no button, switch or motion sensor found", what, controller.Pin, driverName)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package swkit
+
+import (
+	"strings"
+	"testing"
+)
+
+func hasProblem(problems []string, fragment string) bool {
+	for _, problem := range problems {
+		if strings.Contains(problem, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestValidateConfig(t *testing.T) {
+	config := `{
+	"HkPin": "12345678",
+	"FakeDriver": {},
+	"Lights": [
+		{"Name": "Hall", "DriverName": "mock_driver", "OutPin": 1, "ControlBy": [{"Pin": 10}]},
+		{"Name": "Hall", "DriverName": "mock_driver", "OutPin": 1},
+		{"Name": "Kitchen", "DriverName": "mock_driver", "OutPn": 3, "ControlBy": [{"Pin": 12}]}
+	],
+	"Buttons": [
+		{"Name": "Hall button", "DriverName": "mock_driver", "InPin": 10},
+		{"Name": "Porch button", "DriverName": "gpi0", "InPin": 11}
+	],
+	"Switches": [
+		{"Name": "Garage", "DriverName": "mock_driver", "InPin": 1}
+	],
+	"Thermostats": [
+		{"Name": "Living room", "DriverName": "mock_driver", "HeatPin": 5, "SensorId": "28-0001"}
+	]
+}`
+
+	_, err := ValidateConfig([]byte(config))
+	configErr, ok := err.(*ConfigError)
+	if !ok {
+		t.Fatalf("expected *ConfigError, got %v", err)
+	}
+
+	for _, expected := range []string{
+		`Lights[2].OutPn: unknown field`,
+		`Lights[1]: duplicate name "Hall"`,
+		`light "Hall": output pin 1 of driver mock_driver is already used by light "Hall"`,
+		`light "Hall": pin 1 of driver mock_driver is used as output and as input by switch "Garage"`,
+		`light "Kitchen": ControlBy pin 12`,
+		`io driver "gpi0"`,
+		`thermostat "Living room": temperature sensor with Id "28-0001" not found`,
+		`HkPin: 12345678 is too trivial`,
+	} {
+		if !hasProblem(configErr.Problems, expected) {
+			t.Errorf("expected problem %q in:\n%v", expected, configErr)
+		}
+	}
+	assertBools(t, hasProblem(configErr.Problems, `light "Hall": ControlBy`), false)
+	assertInts(t, len(configErr.Problems), 8)
+}
+
+func TestValidateConfigValid(t *testing.T) {
+	config := `{
+	"hkpin": "10293847",
+	"FakeDriver": {"DriverName": "mock_driver"},
+	"Lights": [{"Name": "Hall", "DriverName": "mock_driver", "OutPin": 1, "ControlBy": [{"Pin": 10}]}],
+	"Buttons": [{"Name": "Hall button", "DriverName": "mock_driver", "InPin": 10}]
+}`
+	_, err := ValidateConfig([]byte(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateConfigSyntaxError(t *testing.T) {
+	_, err := ValidateConfig([]byte("{\n\t\"Lights\": [\n\t\t{\"Name\": \"Hall\",}\n\t]\n}"))
+	assertBools(t, err != nil && strings.Contains(err.Error(), "line 3"), true)
+
+	_, err = ValidateConfig([]byte("{\n\t\"HkPin\": 1234\n}"))
+	assertBools(t, err != nil && strings.Contains(err.Error(), "HkPin") && strings.Contains(err.Error(), "line 2"), true)
+}
+
+func TestValidateConfigFile(t *testing.T) {
+	dir := t.TempDir()
+
+	// positions of converted yaml would point to generated json, field path is reported
+	config := writeConfigFile(t, dir, "config.yaml", "Lights:\n  - Name: Hall\n    DriverName: mock_driver\n    OutPin: one\n")
+	_, err := ValidateConfigFile(config)
+	assertBools(t, err != nil && strings.Contains(err.Error(), "Lights.0.OutPin") && !strings.Contains(err.Error(), "line"), true)
+
+	config = writeConfigFile(t, dir, "broken.yaml", "Lights:\n  - Name: Hall\n   OutPin: 1\n")
+	_, err = ValidateConfigFile(config)
+	assertBools(t, err != nil && strings.Contains(err.Error(), "yaml: line"), true)
+}
+
+func TestValidateMockDrivers(t *testing.T) {
+	sw := &SwKit{
+		HkPin:              "10293847",
+		Lights:             []*Light{{Name: "Hall", DriverName: "gpio", OutPin: 1}},
+		TemperatureSensors: []*MeasurementSensor{{Id: "28-0001", Name: "Hall temperature", DriverName: "wire"}},
+	}
+	sw.UseMockDrivers()
+	err := sw.Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// validation doesn't register mocks
+	_, err = sw.GetMockIoDriver("gpio")
+	assertBools(t, err != nil, true)
+	_, err = sw.GetMockSensorDriver("wire")
+	assertBools(t, err != nil, true)
+}